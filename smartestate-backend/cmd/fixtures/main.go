@@ -0,0 +1,149 @@
+// cmd/fixtures/main.go - офлайн проверка извлечения объявлений на сохраненных страницах
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tebeka/selenium"
+
+	"smartestate/internal/config"
+	"smartestate/internal/services"
+)
+
+const defaultFixturesDir = "testdata/parser"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  fixtures verify [-dir DIR] [-selectors FILE]  сверить разбор снапшотов с эталонами
+  fixtures update [-dir DIR] [-selectors FILE]  перезаписать эталоны текущим разбором
+  fixtures capture -source SRC -name NAME -url URL [-dir DIR] [-selenium URL]
+                                                сохранить новую страницу и эталон для нее
+
+Снапшоты лежат в DIR/<source>/<name>.html, эталоны в DIR/<source>/<name>.golden.json.
+Имена с префиксом detail_ разбираются как страница объявления.
+Без -selectors используются встроенные профили селекторов.
+`)
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	dir := flags.String("dir", defaultFixturesDir, "каталог снапшотов")
+	source := flags.String("source", "", "источник (krisha, olx)")
+	name := flags.String("name", "", "имя снапшота, detail_* для страницы объявления")
+	pageURL := flags.String("url", "", "URL страницы для сохранения")
+	timeout := flags.Duration("timeout", time.Minute, "таймаут загрузки страницы")
+	seleniumURL := flags.String("selenium", "", "адрес Selenium Grid, если olx.kz не отдает объявления по HTTP")
+	selectorsPath := flags.String("selectors", "", "YAML или JSON файл профилей селекторов")
+	flags.Usage = usage
+	_ = flags.Parse(args)
+
+	// Страницы загружаются по HTTP, WebDriver подключается только по флагу -selenium
+	var newDriver func() (selenium.WebDriver, error)
+	if *seleniumURL != "" {
+		newDriver = func() (selenium.WebDriver, error) {
+			return services.NewRemoteWebDriver(*seleniumURL, false)
+		}
+	}
+	profiles := services.DefaultSelectorProfiles()
+	if *selectorsPath != "" {
+		data, err := os.ReadFile(*selectorsPath)
+		if err != nil {
+			log.Fatalf("failed to read selector profiles: %v", err)
+		}
+		if profiles, err = services.ParseSelectorProfiles(data); err != nil {
+			log.Fatal(err)
+		}
+	}
+	registry := services.NewDefaultSourceRegistry(services.NewPoliteFetcher(config.New().Parser),
+		services.NewStaticSelectorStore(profiles), newDriver)
+
+	switch command {
+	case "verify":
+		os.Exit(verify(registry, *dir))
+	case "update":
+		os.Exit(update(registry, *dir))
+	case "capture":
+		if *source == "" || *name == "" || *pageURL == "" {
+			usage()
+			os.Exit(2)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+
+		fixture := services.NewParserFixture(*dir, *source, *name)
+		if err := services.CaptureParserFixture(ctx, registry, fixture, *pageURL); err != nil {
+			log.Fatalf("capture failed: %v", err)
+		}
+		log.Printf("saved %s and %s", fixture.HTMLPath, fixture.GoldenPath)
+		log.Printf("review the golden file before committing it")
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// verify сверяет все снапшоты и возвращает код выхода
+func verify(registry *services.SourceRegistry, dir string) int {
+	fixtures, err := services.LoadParserFixtures(dir)
+	if err != nil {
+		log.Printf("failed to load fixtures: %v", err)
+		return 1
+	}
+	if len(fixtures) == 0 {
+		log.Printf("no fixtures found in %s", dir)
+		return 1
+	}
+
+	failed := 0
+	for _, fixture := range fixtures {
+		result := services.VerifyParserFixture(registry, fixture)
+		switch {
+		case result.Err != nil:
+			failed++
+			log.Printf("FAIL %s/%s: %v", fixture.Source, fixture.Name, result.Err)
+		case result.Diff != "":
+			failed++
+			log.Printf("FAIL %s/%s: output differs from %s\n%s", fixture.Source, fixture.Name, fixture.GoldenPath, result.Diff)
+		default:
+			log.Printf("ok   %s/%s", fixture.Source, fixture.Name)
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("%d of %d fixtures failed (run 'make fixtures-update' if the change is intended)", failed, len(fixtures))
+		return 1
+	}
+	log.Printf("all %d fixtures passed", len(fixtures))
+	return 0
+}
+
+// update перезаписывает эталоны всех снапшотов и возвращает код выхода
+func update(registry *services.SourceRegistry, dir string) int {
+	fixtures, err := services.LoadParserFixtures(dir)
+	if err != nil {
+		log.Printf("failed to load fixtures: %v", err)
+		return 1
+	}
+
+	for _, fixture := range fixtures {
+		if err := services.UpdateParserFixture(registry, fixture); err != nil {
+			log.Printf("FAIL %s/%s: %v", fixture.Source, fixture.Name, err)
+			return 1
+		}
+		log.Printf("updated %s", fixture.GoldenPath)
+	}
+	return 0
+}
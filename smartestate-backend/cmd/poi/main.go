@@ -0,0 +1,127 @@
+// cmd/poi/main.go - импорт точек интереса и расчет окружения объявлений
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	"smartestate/internal/config"
+	"smartestate/internal/database"
+	"smartestate/internal/services"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  poi import [-replace] [-score N] FILE  импортировать точки интереса из выгрузки OpenStreetMap
+  poi score [-limit N]                    посчитать окружение объявлений каталога
+  poi stats                               количество точек по категориям
+
+FILE - XML OpenStreetMap: .osm, .osm.bz2 или .osm.gz, например извлечение Geofabrik
+или ответ Overpass с "out center". PBF нужно сначала сконвертировать:
+  osmium cat kazakhstan-latest.osm.pbf -o kazakhstan.osm.bz2
+С -replace удаляются точки, которых нет в выгрузке.
+`)
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	replace := flags.Bool("replace", false, "удалить точки, которых нет в выгрузке")
+	limit := flags.Int("limit", 5000, "сколько объявлений обработать за один проход")
+	score := flags.Int("score", 0, "после импорта посчитать окружение N объявлений")
+	flags.Usage = usage
+	_ = flags.Parse(args)
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	cfg := config.New()
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+	poi := services.NewPOIService(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "import":
+		if flags.NArg() != 1 {
+			usage()
+			os.Exit(2)
+		}
+		result, err := poi.ImportOSMFile(ctx, flags.Arg(0), services.POIImportOptions{Replace: *replace})
+		if err != nil {
+			log.Fatalf("import failed: %v", err)
+		}
+		printCategories(result.Categories)
+		fmt.Printf("imported %d, ways without nodes %d, removed %d in %s\n",
+			result.Imported, result.Skipped, result.Removed, result.Duration.Round(1e6))
+		if *score > 0 {
+			scoreListings(poi, *score)
+		}
+	case "score":
+		scoreListings(poi, *limit)
+	case "stats":
+		stats, err := poi.Stats()
+		if err != nil {
+			log.Fatal(err)
+		}
+		categories := make(map[string]int, len(stats.Categories))
+		for category, count := range stats.Categories {
+			categories[category] = int(count)
+		}
+		printCategories(categories)
+		fmt.Printf("total %d\n", stats.Total)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// scoreListings обрабатывает объявления проходами, пока не кончатся или не наберется limit
+func scoreListings(poi *services.POIService, limit int) {
+	total := 0
+	for total < limit {
+		batch := limit - total
+		if batch > 500 {
+			batch = 500
+		}
+		processed, err := poi.ScorePending(batch)
+		if err != nil {
+			log.Fatalf("scoring failed: %v", err)
+		}
+		total += processed
+		if processed < batch {
+			break
+		}
+	}
+	fmt.Printf("scored %d listings\n", total)
+}
+
+func printCategories(categories map[string]int) {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-14s %d\n", name, categories[name])
+	}
+}
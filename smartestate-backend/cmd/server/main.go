@@ -64,11 +64,24 @@ func main() {
 	// Initialize services
 	serviceContainer := services.NewContainer(db, redisClient, cfg)
 
+	// Start parse queue workers
+	serviceContainer.ParseQueue.Start(context.Background())
+
+	// Start listing catalogue janitor
+	serviceContainer.Listing.Start(context.Background())
+
+	// Watch selector profiles file for changes
+	serviceContainer.Selectors.Start(context.Background())
+
+	// Start scheduled crawls
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	serviceContainer.Scheduler.Start(schedulerCtx)
+
 	// Initialize handlers
 	handlerContainer := handlers.NewContainer(serviceContainer)
 
 	// Setup Gin router with auth service
-	router := setupRouter(cfg, handlerContainer, serviceContainer.Auth)
+	router := setupRouter(cfg, handlerContainer, serviceContainer.Auth, serviceContainer.User)
 
 	// Start server
 	srv := &http.Server{
@@ -101,10 +114,16 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	stopScheduler()
+
+	if err := serviceContainer.ParseQueue.Stop(ctx); err != nil {
+		log.Printf("Parse queue forced to stop: %v", err)
+	}
+
 	log.Println("Server exited")
 }
 
-func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, authService *services.AuthService) *gin.Engine {
+func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, authService *services.AuthService, userService *services.UserService) *gin.Engine {
 	router := gin.New()
 
 	// Middleware
@@ -124,6 +143,8 @@ func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, auth
 
 	// Create auth middleware instance
 	authMiddleware := middleware.AuthMiddleware(authService)
+	adminMiddleware := middleware.RequireRole(userService, "admin")
+	optionalAuthMiddleware := middleware.OptionalAuthMiddleware(authService)
 
 	// API routes
 	api := router.Group("/api")
@@ -145,6 +166,7 @@ func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, auth
 		properties := api.Group("/properties")
 		{
 			properties.GET("", handlersContainer.Property.List)
+			properties.GET("/markers", handlersContainer.Property.Markers)
 			properties.GET("/:id", handlersContainer.Property.Get)
 			properties.GET("/search", handlersContainer.Property.Search)
 			properties.GET("/recommendations", authMiddleware, handlersContainer.Property.GetRecommendations)
@@ -168,6 +190,7 @@ func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, auth
 			chat.POST("/sessions", handlersContainer.Chat.CreateSession)
 			chat.GET("/sessions/:id", handlersContainer.Chat.GetSession)
 			chat.POST("/messages", handlersContainer.Chat.SendMessage)
+			chat.POST("/messages/stream", handlersContainer.Chat.StreamMessage)
 			chat.GET("/sessions/:id/messages", handlersContainer.Chat.GetMessages)
 		}
 
@@ -194,10 +217,73 @@ func setupRouter(cfg *config.Config, handlersContainer *handlers.Container, auth
 
 		// Parser routes
 		parser := api.Group("/parser")
+		parser.Use(optionalAuthMiddleware)
 		{
 			parser.POST("/properties", handlersContainer.Parser.ParseProperties)
 			parser.GET("/requests/:id", handlersContainer.Parser.GetParseRequest)
+			parser.POST("/requests/:id/cancel", authMiddleware, handlersContainer.Parser.CancelParseRequest)
 			parser.GET("/test", handlersContainer.Parser.TestParse)
+			parser.GET("/sources", handlersContainer.Parser.GetSources)
+			parser.POST("/import-url", handlersContainer.Searches.ImportSearchURL)
+		}
+
+		// Listings catalogue routes
+		listings := api.Group("/listings")
+		{
+			listings.GET("", handlersContainer.Listing.List)
+			listings.GET("/clusters", handlersContainer.Listing.ListClusters)
+			listings.GET("/markers", handlersContainer.Listing.Markers)
+			listings.GET("/:id", handlersContainer.Listing.Get)
+			listings.GET("/:id/price-history", handlersContainer.Listing.GetPriceHistory)
+			listings.GET("/:id/duplicates", handlersContainer.Listing.GetDuplicates)
+			listings.GET("/:id/nearby", handlersContainer.POI.GetListingNearby)
+		}
+
+		// Locations dictionary routes
+		locations := api.Group("/locations")
+		{
+			locations.GET("", handlersContainer.Locations.List)
+			locations.GET("/autocomplete", handlersContainer.Locations.Autocomplete)
+		}
+
+		// Saved searches routes
+		savedSearches := api.Group("/saved-searches")
+		savedSearches.Use(authMiddleware)
+		{
+			savedSearches.GET("", handlersContainer.Searches.List)
+			savedSearches.POST("", handlersContainer.Searches.Create)
+			savedSearches.DELETE("/:id", handlersContainer.Searches.Delete)
+		}
+
+		// Viewings routes
+		viewings := api.Group("/viewings")
+		viewings.Use(authMiddleware)
+		{
+			viewings.GET("", handlersContainer.Viewings.List)
+			viewings.POST("", handlersContainer.Viewings.Create)
+			viewings.DELETE("/:id", handlersContainer.Viewings.Cancel)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		admin.Use(authMiddleware, adminMiddleware)
+		{
+			admin.GET("/crawl-schedules", handlersContainer.Schedule.List)
+			admin.POST("/crawl-schedules", handlersContainer.Schedule.Create)
+			admin.GET("/crawl-schedules/:id", handlersContainer.Schedule.Get)
+			admin.DELETE("/crawl-schedules/:id", handlersContainer.Schedule.Delete)
+			admin.POST("/crawl-schedules/:id/pause", handlersContainer.Schedule.Pause)
+			admin.POST("/crawl-schedules/:id/resume", handlersContainer.Schedule.Resume)
+			admin.POST("/crawl-schedules/:id/trigger", handlersContainer.Schedule.Trigger)
+			admin.GET("/parser/fetcher", handlersContainer.Parser.GetFetcherStats)
+			admin.GET("/parser/selectors", handlersContainer.Parser.GetSelectorStatus)
+			admin.POST("/parser/selectors/reload", handlersContainer.Parser.ReloadSelectors)
+			admin.GET("/parser/health", handlersContainer.Parser.GetSourceHealth)
+			admin.GET("/parser/health/:source", handlersContainer.Parser.GetSourceHealthHistory)
+			admin.GET("/geocode", handlersContainer.Geocode.Geocode)
+			admin.POST("/listings/geocode", handlersContainer.Geocode.BackfillListings)
+			admin.GET("/poi", handlersContainer.POI.GetStats)
+			admin.POST("/listings/poi", handlersContainer.POI.ScoreListings)
 		}
 
 		// WebSocket for real-time chat
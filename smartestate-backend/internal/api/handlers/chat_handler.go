@@ -3,9 +3,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,7 +21,7 @@ import (
 type ChatHandler struct {
 	chatService *services.ChatService
 	aiService   *services.AIService
-	connections map[string]*websocket.Conn // sessionID -> connection
+	connections map[string]*wsConn // sessionID -> connection
 	connMutex   sync.RWMutex
 }
 
@@ -27,7 +29,7 @@ func NewChatHandler(chatService *services.ChatService, aiService *services.AISer
 	return &ChatHandler{
 		chatService: chatService,
 		aiService:   aiService,
-		connections: make(map[string]*websocket.Conn),
+		connections: make(map[string]*wsConn),
 	}
 }
 
@@ -101,6 +103,8 @@ func (h *ChatHandler) GetSession(c *gin.Context) {
 type MessageRequest struct {
 	SessionID string `json:"session_id" binding:"required" example:"550e8400-e29b-41d4-a716-446655440000"`
 	Content   string `json:"content" binding:"required" example:"Найди мне квартиру в Алматы"`
+	// Provider провайдер LLM для этого сообщения: openai, gemini или anthropic. Пусто - по настройкам сервера.
+	Provider  string `json:"provider,omitempty" example:"anthropic"`
 }
 
 // SendMessage godoc
@@ -112,29 +116,18 @@ type MessageRequest struct {
 // @Security BearerAuth
 // @Param request body MessageRequest true "Данные сообщения"
 // @Success 200 {object} models.ChatMessage "Ответ AI ассистента"
-// @Failure 400 {object} map[string]string "Некорректный запрос"
+// @Failure 400 {object} map[string]string "Некорректный запрос или провайдер AI не настроен"
 // @Failure 403 {object} map[string]string "Доступ запрещен"
 // @Failure 404 {object} map[string]string "Сессия не найдена"
 // @Failure 500 {object} map[string]string "Ошибка обработки сообщения"
 // @Router /chat/messages [post]
 func (h *ChatHandler) SendMessage(c *gin.Context) {
-	userID := c.GetString("user_id")
-
 	var req MessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Check session ownership
-	session, err := h.chatService.GetSession(req.SessionID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
-		return
-	}
-
-	if session.UserID.String() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	if _, ok := h.checkMessageRequest(c, req); !ok {
 		return
 	}
 
@@ -151,7 +144,7 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	}
 
 	// Get AI response
-	aiResponse, err := h.aiService.ProcessChatMessage(req.SessionID, req.Content)
+	aiResponse, err := h.aiService.ProcessChatMessage(c.Request.Context(), req.SessionID, req.Content, services.ChatOptions{Provider: req.Provider})
 	if err != nil {
 		log.Printf("❌ Chat Handler: AI service error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI response"})
@@ -174,6 +167,104 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, aiMessage)
 }
 
+// StreamMessage godoc
+// @Summary Отправить сообщение с ответом в потоке (SSE)
+// @Description Альтернатива WebSocket для клиентов за прокси, которые не пропускают WebSocket.
+// @Description Ответ - text/event-stream с теми же событиями, что и в /ws/chat: processing, progress,
+// @Description delta (очередной кусок текста ответа), итоговый response с metadata или error.
+// @Description Данные события - JSON ChatEvent. Если клиент закрыл соединение, запрос к провайдеру AI
+// @Description прерывается, а уже полученный текст сохраняется в сессии.
+// @Tags Chat
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param request body MessageRequest true "Данные сообщения"
+// @Success 200 {object} ChatEvent "Поток событий"
+// @Failure 400 {object} map[string]string "Некорректный запрос или провайдер AI не настроен"
+// @Failure 403 {object} map[string]string "Доступ запрещен"
+// @Failure 404 {object} map[string]string "Сессия не найдена"
+// @Router /chat/messages/stream [post]
+func (h *ChatHandler) StreamMessage(c *gin.Context) {
+	var req MessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	session, ok := h.checkMessageRequest(c, req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), chatReplyTimeout)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx не должен буферизовать поток
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	var writeMu sync.Mutex
+	emit := func(event ChatEvent) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		c.SSEvent(event.Type, event)
+		c.Writer.Flush()
+	}
+
+	// Комментарий раз в 15 секунд, чтобы прокси не закрыл соединение, пока идет парсинг.
+	// c.Writer принадлежит пулу gin, поэтому до выхода из обработчика ждем горутину.
+	stopHeartbeat := make(chan struct{})
+	var heartbeat sync.WaitGroup
+	defer heartbeat.Wait()
+	defer close(stopHeartbeat)
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				writeMu.Lock()
+				fmt.Fprint(c.Writer, ": ping\n\n")
+				c.Writer.Flush()
+				writeMu.Unlock()
+			case <-stopHeartbeat:
+				return
+			}
+		}
+	}()
+
+	h.streamReply(ctx, session, req.Content, req.Provider, emit)
+}
+
+// checkMessageRequest проверяет доступ к сессии и провайдера из запроса,
+// при ошибке отвечает клиенту сам
+func (h *ChatHandler) checkMessageRequest(c *gin.Context, req MessageRequest) (*models.ChatSession, bool) {
+	// Check session ownership
+	session, err := h.chatService.GetSession(req.SessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, false
+	}
+
+	if session.UserID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	// Провайдер из запроса должен быть настроен на сервере
+	if req.Provider != "" {
+		if _, err := h.aiService.LLM().Provider(services.LLMFeatureChat, req.Provider); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown AI provider %q, available: %s", req.Provider, strings.Join(h.aiService.LLM().Providers(), ", "))})
+			return nil, false
+		}
+	}
+	return session, true
+}
+
 // GetMessages godoc
 // @Summary Получить сообщения
 // @Description Получить все сообщения из чат-сессии
@@ -212,11 +303,14 @@ func (h *ChatHandler) GetMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
-// WebSocket message types
-type WSMessage struct {
+// ChatEvent кадр чата, общий для WebSocket и SSE: клиент отправляет по
+// WebSocket register, message, stop и typing, сервер отвечает processing,
+// progress, delta, response и error
+type ChatEvent struct {
 	Type      string                 `json:"type"`
 	SessionID string                 `json:"session_id,omitempty"`
 	Content   string                 `json:"content,omitempty"`
+	Provider  string                 `json:"provider,omitempty"`
 	Progress  *ProgressInfo          `json:"progress,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 }
@@ -224,9 +318,85 @@ type WSMessage struct {
 // Use ProgressInfo from services package
 type ProgressInfo = services.ProgressInfo
 
+// chatReplyTimeout сколько ответ может генерироваться вместе с парсингом
+const chatReplyTimeout = 5 * time.Minute
+
+// wsReadLimit наибольший кадр от клиента: в сообщение вставляют ссылки поиска
+// с площадок, они бывают длиной в несколько килобайт
+const wsReadLimit = 8 << 10
+
+// wsConn соединение WebSocket. gorilla/websocket допускает только одного писателя,
+// а кадры отправляют пинг, ответы на сообщения и прогресс парсинга.
+type wsConn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+}
+
+// send пишет кадр. Ошибка означает, что соединение закрыто.
+func (c *wsConn) send(msg ChatEvent) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.WriteJSON(msg)
+}
+
+func (c *wsConn) ping() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.WriteMessage(websocket.PingMessage, nil)
+}
+
+// wsReplies ответы, которые генерируются для соединения, по ID сессии
+type wsReplies struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// start начинает ответ в сессии. Пока предыдущий ответ в той же сессии не
+// закончен, новый не начинается. done нужно вызвать по окончании ответа.
+func (r *wsReplies) start(sessionID string) (ctx context.Context, done func(), ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, busy := r.cancels[sessionID]; busy {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), chatReplyTimeout)
+	r.cancels[sessionID] = cancel
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.cancels, sessionID)
+		r.mu.Unlock()
+		cancel()
+	}, true
+}
+
+// stop отменяет ответ в сессии, если он генерируется
+func (r *wsReplies) stop(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.cancels[sessionID]; ok {
+		cancel()
+	}
+}
+
+// stopAll отменяет все ответы соединения
+func (r *wsReplies) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+}
+
 // HandleWebSocket godoc
 // @Summary WebSocket для чата с real-time обновлениями
-// @Description Установить WebSocket соединение для real-time общения с поддержкой асинхронного парсинга
+// @Description Установить WebSocket соединение для real-time общения с поддержкой асинхронного парсинга.
+// @Description Клиент отправляет кадры register, message (session_id, content, provider), stop (session_id) и typing.
+// @Description На message сервер отвечает кадрами processing, progress, delta (очередной кусок текста ответа) и итоговым response
+// @Description с полным текстом и metadata; при ошибке - error. stop прерывает запрос к провайдеру AI,
+// @Description уже полученный текст сохраняется в сессии и приходит в response с действием cancelled.
 // @Tags Chat
 // @Accept json
 // @Produce json
@@ -239,16 +409,18 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 	if token != "" {
 		c.Request.Header.Set("Authorization", "Bearer "+token)
 	}
+	userID := c.GetString("user_id")
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	upgraded, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
+	conn := &wsConn{Conn: upgraded}
 	defer conn.Close()
 
 	// Set connection settings
-	conn.SetReadLimit(512)
+	conn.SetReadLimit(wsReadLimit)
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -259,20 +431,20 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 	go func() {
 		ticker := time.NewTicker(54 * time.Second)
 		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					return
-				}
+		for range ticker.C {
+			if err := conn.ping(); err != nil {
+				return
 			}
 		}
 	}()
 
+	replies := &wsReplies{cancels: make(map[string]context.CancelFunc)}
+	// Закрытое соединение прерывает ответы, полученный текст сохраняется
+	defer replies.stopAll()
+
 	// Handle WebSocket messages
 	for {
-		var msg WSMessage
+		var msg ChatEvent
 		if err := conn.ReadJSON(&msg); err != nil {
 			log.Printf("WebSocket read error: %v", err)
 			break
@@ -285,8 +457,8 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 				h.connMutex.Lock()
 				h.connections[msg.SessionID] = conn
 				h.connMutex.Unlock()
-				
-				conn.WriteJSON(WSMessage{
+
+				conn.send(ChatEvent{
 					Type: "registered",
 					Data: map[string]interface{}{"session_id": msg.SessionID},
 				})
@@ -294,11 +466,26 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 
 		case "message":
 			// Process message asynchronously
-			go h.processMessageAsync(conn, msg.SessionID, msg.Content)
+			ctx, done, ok := replies.start(msg.SessionID)
+			if !ok {
+				conn.send(ChatEvent{
+					Type:      "error",
+					SessionID: msg.SessionID,
+					Content:   "Дождитесь ответа на предыдущее сообщение или остановите его",
+				})
+				continue
+			}
+			go func() {
+				defer done()
+				h.processMessageAsync(ctx, conn, userID, msg)
+			}()
+
+		case "stop":
+			replies.stop(msg.SessionID)
 
 		case "typing":
 			// Echo typing indicator
-			conn.WriteJSON(WSMessage{
+			conn.send(ChatEvent{
 				Type: "typing",
 			})
 		}
@@ -315,70 +502,107 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 	h.connMutex.Unlock()
 }
 
-// processMessageAsync handles message processing with real-time updates
-func (h *ChatHandler) processMessageAsync(conn *websocket.Conn, sessionID, content string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// processMessageAsync отвечает на сообщение из WebSocket
+func (h *ChatHandler) processMessageAsync(ctx context.Context, conn *wsConn, userID string, msg ChatEvent) {
+	session, err := h.chatService.GetSession(msg.SessionID)
+	if err != nil || session.UserID.String() != userID {
+		conn.send(ChatEvent{Type: "error", SessionID: msg.SessionID, Content: "Сессия не найдена"})
+		return
+	}
 
-	// Send immediate acknowledgment
-	conn.WriteJSON(WSMessage{
-		Type:    "processing",
-		Content: "🤖 Обрабатываю ваш запрос...",
+	h.streamReply(ctx, session, msg.Content, msg.Provider, func(event ChatEvent) {
+		conn.send(event)
 	})
+}
 
-	// Create progress channel for parsing updates
-	progressChan := make(chan ProgressInfo, 10)
+// streamReply отвечает на сообщение событиями для WebSocket и SSE: прогресс,
+// текст ответа по мере генерации и итоговый ответ. Сообщение пользователя и
+// ответ сохраняются в сессии; если ctx отменен, сохраняется уже полученный текст.
+func (h *ChatHandler) streamReply(ctx context.Context, session *models.ChatSession, content, provider string, emit func(ChatEvent)) {
+	sessionID := session.ID.String()
+	userMessage := &models.ChatMessage{
+		SessionID: session.ID,
+		Role:      "user",
+		Content:   content,
+	}
+	if err := h.chatService.SaveMessage(userMessage); err != nil {
+		log.Printf("❌ Chat Handler: failed to save message: %v", err)
+		emit(ChatEvent{Type: "error", SessionID: sessionID, Content: "Не удалось сохранить сообщение"})
+		return
+	}
 
-	// Start processing with progress updates
-	go func() {
-		defer close(progressChan)
-		
-		// Send processing progress
-		progressChan <- ProgressInfo{
-			Step:        "ai_processing",
-			Current:     1,
-			Total:       3,
-			Percentage:  33,
-			Description: "Анализирую запрос с помощью AI...",
-		}
+	// Send immediate acknowledgment
+	emit(ChatEvent{
+		Type:      "processing",
+		SessionID: sessionID,
+		Content:   "🤖 Обрабатываю ваш запрос...",
+	})
 
-		// Get AI response with custom progress callback
-		response, err := h.aiService.ProcessChatMessageWithProgress(sessionID, content, progressChan)
-		if err != nil {
-			conn.WriteJSON(WSMessage{
-				Type:    "error",
-				Content: fmt.Sprintf("Ошибка: %v", err),
-			})
-			return
+	// partial - текст текущего шага модели: текст шага, после которого
+	// вызывались инструменты, окончательным ответом не был
+	var partial strings.Builder
+	response, err := h.aiService.ProcessChatMessageStream(ctx, sessionID, content, services.ChatOptions{Provider: provider},
+		func(progress ProgressInfo) {
+			if progress.Step == "tool_call" {
+				partial.Reset()
+			}
+			emit(ChatEvent{Type: "progress", SessionID: sessionID, Progress: &progress})
+		},
+		func(delta string) {
+			partial.WriteString(delta)
+			emit(ChatEvent{Type: "delta", SessionID: sessionID, Content: delta})
+		})
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.Canceled):
+		// Остановлен клиентом: ответ - то, что модель успела написать
+		response = &services.AIResponse{
+			Content: partial.String(),
+			Metadata: models.MessageMetadata{
+				Actions: []string{"cancelled"},
+				Extra:   map[string]interface{}{"cancelled": true},
+			},
 		}
-
-		// Send final response
-		conn.WriteJSON(WSMessage{
-			Type:    "response",
-			Content: response.Content,
-			Data:    map[string]interface{}{"metadata": response.Metadata},
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		emit(ChatEvent{
+			Type:      "error",
+			SessionID: sessionID,
+			Content:   "⏰ Время обработки истекло. Попробуйте еще раз.",
 		})
-	}()
+		return
+	default:
+		log.Printf("❌ Chat Handler: AI service error: %v", err)
+		emit(ChatEvent{
+			Type:      "error",
+			SessionID: sessionID,
+			Content:   fmt.Sprintf("Ошибка: %v", err),
+		})
+		return
+	}
 
-	// Forward progress updates to WebSocket
-	for {
-		select {
-		case progress, ok := <-progressChan:
-			if !ok {
-				return // Channel closed
-			}
-			conn.WriteJSON(WSMessage{
-				Type:     "progress",
-				Progress: &progress,
-			})
-		case <-ctx.Done():
-			conn.WriteJSON(WSMessage{
-				Type:    "error",
-				Content: "⏰ Время обработки истекло. Попробуйте еще раз.",
-			})
-			return
+	data := map[string]interface{}{"metadata": response.Metadata}
+	// Пустой остановленный ответ не сохраняем
+	if response.Content != "" {
+		aiMessage := &models.ChatMessage{
+			SessionID: session.ID,
+			Role:      "assistant",
+			Content:   response.Content,
+			Metadata:  response.Metadata,
+		}
+		if err := h.chatService.SaveMessage(aiMessage); err != nil {
+			log.Printf("❌ Chat Handler: failed to save AI response: %v", err)
+		} else {
+			data["message_id"] = aiMessage.ID
 		}
 	}
+
+	// Send final response
+	emit(ChatEvent{
+		Type:      "response",
+		SessionID: sessionID,
+		Content:   response.Content,
+		Data:      data,
+	})
 }
 
 // SendProgressToSession sends progress update to a specific session
@@ -388,7 +612,7 @@ func (h *ChatHandler) SendProgressToSession(sessionID string, progress ProgressI
 	h.connMutex.RUnlock()
 
 	if exists {
-		conn.WriteJSON(WSMessage{
+		conn.send(ChatEvent{
 			Type:     "progress",
 			Progress: &progress,
 		})
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"smartestate/internal/services"
+)
+
+// dryRunDB база, которая строит запросы, но не выполняет их: любая запись
+// не находится
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWebSocketAcceptsPastedSearchURL(t *testing.T) {
+	// Сессия не найдется, и ответ об ошибке означает, что кадр с сообщением
+	// прочитан целиком
+	handler := NewChatHandler(services.NewChatService(dryRunDB(t), nil), nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws/chat", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/chat", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Ссылка поиска krisha.kz со всеми фильтрами и метками рекламной кампании
+	pasted := "Посмотри вот этот поиск https://krisha.kz/prodazha/kvartiry/almaty-bostandykskij/?das[_sys.hasphoto]=1" +
+		"&das[flat.building]=2&das[flat.floor][from]=2&das[flat.floor][to]=9&das[floor_not_first]=1&das[floor_not_last]=1" +
+		"&das[house.year][from]=2010&das[live.rooms][]=2&das[live.rooms][]=3&das[live.square][from]=55&das[live.square][to]=90" +
+		"&das[price][from]=30000000&das[price][to]=65000000&das[who]=1&das[map.complex]=1234" +
+		"&utm_source=telegram&utm_medium=share&utm_campaign=" + strings.Repeat("spring_sale_2026_", 40)
+	if len(pasted) <= 512 {
+		t.Fatalf("test URL is only %d bytes", len(pasted))
+	}
+
+	if err := conn.WriteJSON(ChatEvent{Type: "message", SessionID: "missing", Content: pasted}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply ChatEvent
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("read: %v (connection closed on a %d byte frame?)", err, len(pasted))
+	}
+	if reply.Type != "error" || reply.SessionID != "missing" {
+		t.Errorf("reply = %+v, want session error", reply)
+	}
+}
@@ -12,6 +12,13 @@ type Container struct {
 	Targeting *TargetingHandler
 	Analytics *AnalyticsHandler
 	Parser    *ParserHandler
+	Listing   *ListingHandler
+	Schedule  *CrawlScheduleHandler
+	Geocode   *GeocodeHandler
+	POI       *POIHandler
+	Searches  *SavedSearchHandler
+	Locations *LocationHandler
+	Viewings  *ViewingHandler
 }
 
 func NewContainer(services *services.Container) *Container {
@@ -21,6 +28,13 @@ func NewContainer(services *services.Container) *Container {
 		Chat:      NewChatHandler(services.Chat, services.AI),
 		Targeting: NewTargetingHandler(services.Targeting, services.AI),
 		Analytics: NewAnalyticsHandler(services.Analytics),
-		Parser:    NewParserHandler(services.Parser),
+		Parser:    NewParserHandler(services.Parser, services.ParseQueue, services.Selectors, services.Health, services.User),
+		Listing:   NewListingHandler(services.Listing, services.Duplicate),
+		Schedule:  NewCrawlScheduleHandler(services.Scheduler),
+		Geocode:   NewGeocodeHandler(services.Geocoder),
+		POI:       NewPOIHandler(services.POI),
+		Searches:  NewSavedSearchHandler(services.Searches),
+		Locations: NewLocationHandler(services.Locations),
+		Viewings:  NewViewingHandler(services.Viewings),
 	}
 }
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"smartestate/internal/services"
+)
+
+type CrawlScheduleHandler struct {
+	scheduler *services.CrawlScheduler
+}
+
+func NewCrawlScheduleHandler(scheduler *services.CrawlScheduler) *CrawlScheduleHandler {
+	return &CrawlScheduleHandler{
+		scheduler: scheduler,
+	}
+}
+
+// List godoc
+// @Summary Расписания парсинга
+// @Description Возвращает все расписания регулярного парсинга с результатом последнего запуска
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Список расписаний"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /admin/crawl-schedules [get]
+func (h *CrawlScheduleHandler) List(c *gin.Context) {
+	schedules, err := h.scheduler.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "schedules_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules": schedules,
+		"total":     len(schedules),
+	})
+}
+
+// Create godoc
+// @Summary Создать расписание парсинга
+// @Description Создает расписание регулярного парсинга: источники, фильтры, cron выражение ("0 */6 * * *", "@daily", "CRON_TZ=Asia/Almaty 0 9 * * *") и лимит страниц. Вместо фильтров можно передать ссылку на поиск krisha.kz или olx.kz в search_url
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CrawlScheduleInput true "Параметры расписания"
+// @Success 201 {object} models.CrawlSchedule "Созданное расписание"
+// @Failure 400 {object} ErrorResponse "Некорректные параметры"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Router /admin/crawl-schedules [post]
+func (h *CrawlScheduleHandler) Create(c *gin.Context) {
+	var req services.CrawlScheduleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var createdBy *uuid.UUID
+	if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		createdBy = &id
+	}
+
+	schedule, err := h.scheduler.Create(req, createdBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_schedule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// Get godoc
+// @Summary Расписание парсинга
+// @Description Возвращает расписание и последние запуски
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "UUID расписания" Format(uuid)
+// @Param limit query int false "Количество запусков" default(20)
+// @Success 200 {object} map[string]interface{} "Расписание и запуски"
+// @Failure 404 {object} ErrorResponse "Расписание не найдено"
+// @Router /admin/crawl-schedules/{id} [get]
+func (h *CrawlScheduleHandler) Get(c *gin.Context) {
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduler.Get(scheduleID)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	runs, err := h.scheduler.ListRuns(scheduleID, limit)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule": schedule,
+		"runs":     runs,
+	})
+}
+
+// Pause godoc
+// @Summary Приостановить расписание
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "UUID расписания" Format(uuid)
+// @Success 200 {object} models.CrawlSchedule "Расписание"
+// @Failure 404 {object} ErrorResponse "Расписание не найдено"
+// @Router /admin/crawl-schedules/{id}/pause [post]
+func (h *CrawlScheduleHandler) Pause(c *gin.Context) {
+	h.setPaused(c, true)
+}
+
+// Resume godoc
+// @Summary Возобновить расписание
+// @Description Возобновляет расписание. Следующий запуск считается от текущего момента, пропущенные запуски не выполняются.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "UUID расписания" Format(uuid)
+// @Success 200 {object} models.CrawlSchedule "Расписание"
+// @Failure 404 {object} ErrorResponse "Расписание не найдено"
+// @Router /admin/crawl-schedules/{id}/resume [post]
+func (h *CrawlScheduleHandler) Resume(c *gin.Context) {
+	h.setPaused(c, false)
+}
+
+func (h *CrawlScheduleHandler) setPaused(c *gin.Context, paused bool) {
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduler.SetPaused(scheduleID, paused)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Trigger godoc
+// @Summary Запустить расписание сейчас
+// @Description Ставит парсинг по расписанию в очередь вне плана, в том числе для расписания на паузе. Время следующего планового запуска не меняется.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "UUID расписания" Format(uuid)
+// @Success 202 {object} ParseResponseSwagger "Запрос парсинга поставлен в очередь"
+// @Failure 404 {object} ErrorResponse "Расписание не найдено"
+// @Failure 500 {object} ErrorResponse "Ошибка постановки в очередь"
+// @Router /admin/crawl-schedules/{id}/trigger [post]
+func (h *CrawlScheduleHandler) Trigger(c *gin.Context) {
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	parseRequest, err := h.scheduler.Trigger(c.Request.Context(), scheduleID)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, services.NewParseResponse(parseRequest))
+}
+
+// Delete godoc
+// @Summary Удалить расписание
+// @Tags Admin
+// @Security BearerAuth
+// @Param id path string true "UUID расписания" Format(uuid)
+// @Success 204 "Расписание удалено"
+// @Failure 404 {object} ErrorResponse "Расписание не найдено"
+// @Router /admin/crawl-schedules/{id} [delete]
+func (h *CrawlScheduleHandler) Delete(c *gin.Context) {
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scheduler.Delete(scheduleID); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseScheduleID разбирает ID расписания из пути и отвечает 400 при ошибке
+func parseScheduleID(c *gin.Context) (uuid.UUID, bool) {
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_schedule_id",
+			Message: "Invalid schedule ID format",
+		})
+		return uuid.Nil, false
+	}
+	return scheduleID, true
+}
+
+// respondScheduleError отвечает 404 для ненайденного расписания и 500 для остальных ошибок
+func respondScheduleError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrCrawlScheduleNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "schedule_not_found",
+			Message: "Crawl schedule not found",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "schedule_operation_failed",
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"smartestate/internal/services"
+)
+
+// parseGeoFilter разбирает параметры поиска по карте:
+//   - lat, lon и radius в метрах - круг вокруг точки, без radius - только сортировка по расстоянию;
+//   - bbox=west,south,east,north - видимая область карты;
+//   - polygon=lat,lon;lat,lon;... - нарисованная область.
+//
+// Возвращает nil, если параметров нет. При ошибке отвечает 400.
+func parseGeoFilter(c *gin.Context) (*services.GeoFilter, bool) {
+	filter, err := geoFilterFromQuery(c)
+	if err == nil && filter != nil {
+		err = filter.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_geo_filter",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	return filter, true
+}
+
+func geoFilterFromQuery(c *gin.Context) (*services.GeoFilter, error) {
+	filter := &services.GeoFilter{}
+	empty := true
+
+	lat, lon := c.Query("lat"), c.Query("lon")
+	if lat != "" || lon != "" {
+		values, err := parseFloats([]string{lat, lon}, 2)
+		if err != nil {
+			return nil, fmt.Errorf("lat and lon: %w", err)
+		}
+		filter.Center = &services.GeoPoint{Latitude: values[0], Longitude: values[1]}
+		empty = false
+	}
+
+	if radius := c.Query("radius"); radius != "" {
+		value, err := strconv.ParseFloat(radius, 64)
+		if err != nil {
+			return nil, fmt.Errorf("radius must be a number of meters")
+		}
+		filter.RadiusM = value
+		empty = false
+	}
+
+	if bbox := c.Query("bbox"); bbox != "" {
+		values, err := parseFloats(strings.Split(bbox, ","), 4)
+		if err != nil {
+			return nil, fmt.Errorf("bbox must be west,south,east,north: %w", err)
+		}
+		filter.Bounds = &services.GeoBounds{West: values[0], South: values[1], East: values[2], North: values[3]}
+		empty = false
+	}
+
+	if polygon := c.Query("polygon"); polygon != "" {
+		for _, point := range strings.Split(polygon, ";") {
+			values, err := parseFloats(strings.Split(point, ","), 2)
+			if err != nil {
+				return nil, fmt.Errorf("polygon must be lat,lon;lat,lon;...: %w", err)
+			}
+			filter.Polygon = append(filter.Polygon, services.GeoPoint{Latitude: values[0], Longitude: values[1]})
+		}
+		empty = false
+	}
+
+	if empty {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// parseMarkersQuery разбирает область и масштаб для маркеров карты.
+// Без области карта загрузила бы все объекты, поэтому она обязательна.
+func parseMarkersQuery(c *gin.Context) (*services.GeoFilter, int, bool) {
+	geo, ok := parseGeoFilter(c)
+	if !ok {
+		return nil, 0, false
+	}
+	if geo == nil || !geo.HasArea() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_geo_filter",
+			Message: "bbox, polygon or lat, lon and radius is required",
+		})
+		return nil, 0, false
+	}
+
+	zoom, err := strconv.Atoi(c.DefaultQuery("zoom", "12"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_geo_filter",
+			Message: "zoom must be an integer",
+		})
+		return nil, 0, false
+	}
+	return geo, zoom, true
+}
+
+func parseFloats(parts []string, count int) ([]float64, error) {
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d numbers, got %d", count, len(parts))
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
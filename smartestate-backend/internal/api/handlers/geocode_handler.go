@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"smartestate/internal/services"
+)
+
+type GeocodeHandler struct {
+	geocoder *services.GeocodeService
+}
+
+func NewGeocodeHandler(geocoder *services.GeocodeService) *GeocodeHandler {
+	return &GeocodeHandler{geocoder: geocoder}
+}
+
+// GeocodeBackfillResponse итог геокодирования объявлений каталога
+type GeocodeBackfillResponse struct {
+	Processed int `json:"processed" example:"500"`
+	Found     int `json:"found" example:"472"`
+}
+
+// Geocode godoc
+// @Summary Геокодировать адрес
+// @Description Возвращает координаты, район и микрорайон адреса так же, как они определяются для объявлений: через провайдеров GEOCODER_PROVIDERS и кэш. Точность показывает, до чего найден адрес: exact, street, microdistrict, district, city
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param address query string true "Адрес" example(Бостандыкский р-н, мкр Орбита-1)
+// @Param city query string false "Город, если его нет в адресе" example(Алматы)
+// @Success 200 {object} services.GeocodeResult "Найденный адрес"
+// @Failure 400 {object} ErrorResponse "Адрес не указан"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Адрес не найден"
+// @Failure 502 {object} ErrorResponse "Ошибка провайдера геокодирования"
+// @Router /admin/geocode [get]
+func (h *GeocodeHandler) Geocode(c *gin.Context) {
+	address := c.Query("address")
+	if address == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "address is required",
+		})
+		return
+	}
+
+	result, err := h.geocoder.Geocode(c.Request.Context(), services.GeocodeQuery{
+		Address: address,
+		City:    c.Query("city"),
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrAddressNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "address_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "geocoder_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// BackfillListings godoc
+// @Summary Геокодировать каталог
+// @Description Определяет координаты, район и микрорайон объявлений каталога, которые еще не геокодировались, например сохраненных до подключения геокодера или не успевших до таймаута парсинга
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Сколько объявлений обработать" default(500)
+// @Success 200 {object} GeocodeBackfillResponse "Итог геокодирования"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Ошибка сохранения"
+// @Router /admin/listings/geocode [post]
+func (h *GeocodeHandler) BackfillListings(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+
+	processed, found, err := h.geocoder.BackfillListings(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "geocode_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GeocodeBackfillResponse{
+		Processed: processed,
+		Found:     found,
+	})
+}
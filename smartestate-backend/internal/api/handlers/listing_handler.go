@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/services"
+)
+
+type ListingHandler struct {
+	listingService   *services.ListingService
+	duplicateService *services.DuplicateService
+}
+
+func NewListingHandler(listingService *services.ListingService, duplicateService *services.DuplicateService) *ListingHandler {
+	return &ListingHandler{
+		listingService:   listingService,
+		duplicateService: duplicateService,
+	}
+}
+
+// List godoc
+// @Summary Каталог объявлений
+// @Description Возвращает объявления, собранные парсером с krisha.kz и olx.kz. Каждое объявление хранится один раз и обновляется при повторном появлении в выдаче.
+// @Tags Listings
+// @Produce json
+// @Param source query string false "Источник" Enums(krisha, olx)
+// @Param city query string false "Город"
+// @Param deal_type query string false "Тип сделки" Enums(sale, rent_long, rent_daily)
+// @Param property_type query string false "Категория" Enums(apartment, house, land, commercial)
+// @Param district query string false "Район города" example(Бостандыкский)
+// @Param rooms query int false "Количество комнат"
+// @Param price_min query int false "Минимальная цена"
+// @Param price_max query int false "Максимальная цена"
+// @Param active query bool false "Только активные объявления" default(true)
+// @Param price_dropped query bool false "Только объявления со снижением цены"
+// @Param near query string false "Рядом с точками интереса: категория:метры через запятую. Категории: school, kindergarten, metro, bus_stop, park, mall, supermarket, hospital, pharmacy" example(school:500,metro:1000)
+// @Param min_walk_score query int false "Минимальный индекс пешей доступности, 0-100"
+// @Param lat query number false "Широта точки поиска; вместе с lon сортирует выдачу по расстоянию" example(43.2220)
+// @Param lon query number false "Долгота точки поиска" example(76.8512)
+// @Param radius query number false "Радиус вокруг точки, м" example(1500)
+// @Param bbox query string false "Видимая область карты: west,south,east,north" example(76.85,43.20,76.95,43.26)
+// @Param polygon query string false "Нарисованная область: lat,lon;lat,lon;..." example(43.24,76.90;43.25,76.95;43.22,76.94)
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Объявлений на странице" default(20)
+// @Success 200 {object} map[string]interface{} "Список объявлений с пагинацией"
+// @Failure 400 {object} ErrorResponse "Некорректные параметры карты или окружения"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /listings [get]
+func (h *ListingHandler) List(c *gin.Context) {
+	filters, ok := listingFiltersFromQuery(c)
+	if !ok {
+		return
+	}
+
+	geo, ok := parseGeoFilter(c)
+	if !ok {
+		return
+	}
+	filters.Geo = geo
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	listings, total, err := h.listingService.List(filters, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "listings_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"listings": listings,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// Markers godoc
+// @Summary Маркеры объявлений на карте
+// @Description Возвращает объявления каталога в области карты для отображения маркерами. Близкие объявления объединяются в кластеры по масштабу zoom: у кластера count больше 1, координаты - среднее, указан диапазон цен. У одиночного маркера есть id объявления. Принимает те же фильтры, что и каталог.
+// @Tags Listings
+// @Produce json
+// @Param bbox query string false "Видимая область карты: west,south,east,north" example(76.85,43.20,76.95,43.26)
+// @Param polygon query string false "Нарисованная область: lat,lon;lat,lon;..."
+// @Param lat query number false "Широта центра круга"
+// @Param lon query number false "Долгота центра круга"
+// @Param radius query number false "Радиус круга, м"
+// @Param zoom query int false "Масштаб карты, 1-20" default(12)
+// @Param source query string false "Источник" Enums(krisha, olx)
+// @Param city query string false "Город"
+// @Param deal_type query string false "Тип сделки" Enums(sale, rent_long, rent_daily)
+// @Param property_type query string false "Категория" Enums(apartment, house, land, commercial)
+// @Param rooms query int false "Количество комнат"
+// @Param price_min query int false "Минимальная цена"
+// @Param price_max query int false "Максимальная цена"
+// @Param near query string false "Рядом с точками интереса: категория:метры через запятую" example(school:500)
+// @Param min_walk_score query int false "Минимальный индекс пешей доступности, 0-100"
+// @Success 200 {object} map[string]interface{} "Маркеры и кластеры"
+// @Failure 400 {object} ErrorResponse "Не указана область или некорректные параметры карты"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /listings/markers [get]
+func (h *ListingHandler) Markers(c *gin.Context) {
+	geo, zoom, ok := parseMarkersQuery(c)
+	if !ok {
+		return
+	}
+
+	filters, ok := listingFiltersFromQuery(c)
+	if !ok {
+		return
+	}
+	filters.Geo = geo
+
+	markers, err := h.listingService.Markers(filters, zoom)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "markers_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"markers": markers,
+		"zoom":    zoom,
+	})
+}
+
+// Get godoc
+// @Summary Объявление каталога
+// @Description Возвращает объявление каталога по ID, включая даты первого и последнего появления в выдаче
+// @Tags Listings
+// @Produce json
+// @Param id path string true "UUID объявления" Format(uuid)
+// @Success 200 {object} models.Listing "Объявление"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Router /listings/{id} [get]
+func (h *ListingHandler) Get(c *gin.Context) {
+	listingID, ok := parseListingID(c)
+	if !ok {
+		return
+	}
+
+	listing, err := h.listingService.GetByID(listingID)
+	if err != nil {
+		respondListingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// GetPriceHistory godoc
+// @Summary История цены объявления
+// @Description Возвращает все наблюдения цены объявления и производные показатели: начальная и текущая цена, изменение в процентах, дни с последнего изменения
+// @Tags Listings
+// @Produce json
+// @Param id path string true "UUID объявления" Format(uuid)
+// @Success 200 {object} services.ListingPriceHistoryResult "История цены"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Router /listings/{id}/price-history [get]
+func (h *ListingHandler) GetPriceHistory(c *gin.Context) {
+	listingID, ok := parseListingID(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.listingService.GetPriceHistory(listingID)
+	if err != nil {
+		respondListingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// ListClusters godoc
+// @Summary Группы дубликатов
+// @Description Возвращает группы объявлений, которые опубликованы на нескольких площадках (krisha.kz и olx.kz) и относятся к одному объекту. Для каждой группы указано каноническое объявление.
+// @Tags Listings
+// @Produce json
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Групп на странице" default(20)
+// @Success 200 {object} map[string]interface{} "Список групп с пагинацией"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /listings/clusters [get]
+func (h *ListingHandler) ListClusters(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	clusters, total, err := h.duplicateService.ListClusters(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "clusters_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clusters": clusters,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// GetDuplicates godoc
+// @Summary Дубликаты объявления
+// @Description Возвращает группу дубликатов, в которую входит объявление. Если дубликаты не найдены, cluster равен null.
+// @Tags Listings
+// @Produce json
+// @Param id path string true "UUID объявления" Format(uuid)
+// @Success 200 {object} map[string]interface{} "Группа дубликатов"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Router /listings/{id}/duplicates [get]
+func (h *ListingHandler) GetDuplicates(c *gin.Context) {
+	listingID, ok := parseListingID(c)
+	if !ok {
+		return
+	}
+
+	cluster, err := h.duplicateService.GetListingCluster(listingID)
+	if err != nil {
+		respondListingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"listing_id": listingID,
+		"cluster":    cluster,
+	})
+}
+
+// listingFiltersFromQuery разбирает фильтры каталога из параметров запроса,
+// кроме карты. При ошибке в фильтрах окружения отвечает 400.
+func listingFiltersFromQuery(c *gin.Context) (services.ListingFilters, bool) {
+	filters := services.ListingFilters{
+		Source:       c.Query("source"),
+		City:         c.Query("city"),
+		DealType:     c.Query("deal_type"),
+		PropertyType: c.Query("property_type"),
+		District:     c.Query("district"),
+		ActiveOnly:   c.DefaultQuery("active", "true") != "false",
+		PriceDropped: c.Query("price_dropped") == "true",
+	}
+
+	if rooms, err := strconv.Atoi(c.Query("rooms")); err == nil {
+		filters.Rooms = &rooms
+	}
+	if price, err := strconv.ParseInt(c.Query("price_min"), 10, 64); err == nil {
+		filters.PriceMin = &price
+	}
+	if price, err := strconv.ParseInt(c.Query("price_max"), 10, 64); err == nil {
+		filters.PriceMax = &price
+	}
+
+	near, minWalkScore, ok := parsePOIFilters(c)
+	if !ok {
+		return filters, false
+	}
+	filters.NearPOI = near
+	filters.MinWalkScore = minWalkScore
+	return filters, true
+}
+
+// parseListingID разбирает ID объявления из пути и отвечает 400 при ошибке
+func parseListingID(c *gin.Context) (uuid.UUID, bool) {
+	listingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_listing_id",
+			Message: "Invalid listing ID format",
+		})
+		return uuid.Nil, false
+	}
+	return listingID, true
+}
+
+// respondListingError отвечает 404 для ненайденного объявления и 500 для остальных ошибок
+func respondListingError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "listing_not_found",
+			Message: "Listing not found",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "listing_fetch_failed",
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"smartestate/internal/services"
+)
+
+type LocationHandler struct {
+	locations *services.LocationDictionary
+}
+
+func NewLocationHandler(locations *services.LocationDictionary) *LocationHandler {
+	return &LocationHandler{locations: locations}
+}
+
+// List godoc
+// @Summary Справочник мест
+// @Description Возвращает города с районами и жилыми комплексами: коды для фильтров district и residential_complex, названия на русском, казахском и латиницей, идентификаторы krisha.kz и olx.kz
+// @Tags Locations
+// @Produce json
+// @Success 200 {object} services.LocationDictionary "Справочник мест"
+// @Router /locations [get]
+func (h *LocationHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, h.locations)
+}
+
+// Autocomplete godoc
+// @Summary Автодополнение мест
+// @Description Подсказывает города, районы и жилые комплексы по началу названия на русском, казахском или латиницей. Код района и название ЖК из подсказки передаются в фильтры поиска
+// @Tags Locations
+// @Produce json
+// @Param q query string false "Начало названия" example(бост)
+// @Param city query string false "Город для районов и ЖК" example(Алматы)
+// @Param type query string false "Типы мест через запятую: city, district, complex" example(district,complex)
+// @Param limit query int false "Количество подсказок, до 50" default(10)
+// @Success 200 {array} services.LocationSuggestion "Подсказки"
+// @Failure 400 {object} ErrorResponse "Неизвестный город или тип места"
+// @Router /locations/autocomplete [get]
+func (h *LocationHandler) Autocomplete(c *gin.Context) {
+	city := c.Query("city")
+	if city != "" && h.locations.City(city) == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unknown_city",
+			Message: "unknown city " + strconv.Quote(city),
+		})
+		return
+	}
+
+	var types []string
+	if value := c.Query("type"); value != "" {
+		for _, locationType := range strings.Split(value, ",") {
+			locationType = strings.TrimSpace(locationType)
+			switch locationType {
+			case services.LocationTypeCity, services.LocationTypeDistrict, services.LocationTypeComplex:
+				types = append(types, locationType)
+			default:
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error:   "invalid_request",
+					Message: "type must be city, district or complex",
+				})
+				return
+			}
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	c.JSON(http.StatusOK, h.locations.Autocomplete(c.Query("q"), city, types, limit))
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -13,24 +14,32 @@ import (
 
 type ParserHandler struct {
 	parserService *services.ParserService
+	parseQueue    *services.ParseQueue
+	selectors     *services.SelectorStore
+	health        *services.SourceHealthService
+	userService   *services.UserService
 }
 
-func NewParserHandler(parserService *services.ParserService) *ParserHandler {
+func NewParserHandler(parserService *services.ParserService, parseQueue *services.ParseQueue, selectors *services.SelectorStore, health *services.SourceHealthService, userService *services.UserService) *ParserHandler {
 	return &ParserHandler{
 		parserService: parserService,
+		parseQueue:    parseQueue,
+		selectors:     selectors,
+		health:        health,
+		userService:   userService,
 	}
 }
 
 // ParseProperties godoc
 // @Summary Парсинг недвижимости по фильтрам
-// @Description Парсит недвижимость с сайта krisha.kz используя Selenium по заданным фильтрам. Поддерживает все основные фильтры для поиска квартир, домов и коммерческой недвижимости.
+// @Description Ставит в очередь парсинг недвижимости с выбранных источников (krisha, olx) по заданным фильтрам. Если источники не указаны, опрашиваются все зарегистрированные. Ответ возвращается сразу со статусом pending, прогресс и результаты доступны через GET /parser/requests/{id}.
 // @Tags Parser
 // @Accept json
 // @Produce json
 // @Param request body ParsePropertiesRequest true "Параметры парсинга с фильтрами и количеством страниц"
-// @Success 200 {object} ParseResponseSwagger "Успешный парсинг"
+// @Success 202 {object} ParseResponseSwagger "Запрос поставлен в очередь"
 // @Failure 400 {object} ErrorResponse "Некорректные параметры запроса"
-// @Failure 500 {object} ErrorResponse "Ошибка сервера или парсинга"
+// @Failure 500 {object} ErrorResponse "Ошибка постановки в очередь"
 // @Router /parser/properties [post]
 func (h *ParserHandler) ParseProperties(c *gin.Context) {
 	var req ParsePropertiesRequest
@@ -50,25 +59,61 @@ func (h *ParserHandler) ParseProperties(c *gin.Context) {
 		req.MaxPages = 10 // Ограничиваем максимальное количество страниц
 	}
 
+	// Проверяем выбранные источники
+	if _, err := h.parserService.Sources().Resolve(req.Sources); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_sources",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if _, ok := models.NormalizeDealType(req.Filters.DealType); !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_deal_type",
+			Message: "deal_type must be one of: sale, rent_long, rent_daily",
+		})
+		return
+	}
+	if _, ok := models.NormalizePropertyType(req.Filters.PropertyType); !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_property_type",
+			Message: "property_type must be one of: apartment, house, land, commercial",
+		})
+		return
+	}
+	if _, ok := models.NormalizeCommercialType(req.Filters.CommercialType); !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_commercial_type",
+			Message: "commercial_type must be one of: office, retail, warehouse",
+		})
+		return
+	}
+	if err := services.ValidateSearchFilters(req.Filters); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_filters",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	// Получаем ID пользователя из контекста (если авторизован)
 	var userID *uuid.UUID
-	if userIDValue, exists := c.Get("user_id"); exists {
-		if id, ok := userIDValue.(uuid.UUID); ok {
-			userID = &id
-		}
+	if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		userID = &id
 	}
 
-	// Запускаем парсинг
-	response, err := h.parserService.ParseProperties(req.Filters, req.MaxPages, userID)
+	// Ставим парсинг в очередь
+	parseRequest, err := h.parseQueue.Enqueue(c.Request.Context(), req.Filters, req.Sources, req.MaxPages, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "parsing_failed",
+			Error:   "enqueue_failed",
 			Message: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusAccepted, services.NewParseResponse(parseRequest))
 }
 
 // GetParseRequest godoc
@@ -104,6 +149,82 @@ func (h *ParserHandler) GetParseRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, request)
 }
 
+// CancelParseRequest godoc
+// @Summary Отменить запрос парсинга
+// @Description Отменяет запрос парсинга в статусе pending или processing. Уже найденные объявления сохраняются в результатах запроса.
+// @Description Отменить запрос может пользователь, который его создал, или администратор; запросы без пользователя - только администратор.
+// @Tags Parser
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "UUID идентификатор запроса парсинга" Format(uuid)
+// @Success 200 {object} models.ParseRequest "Запрос отменен"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 401 {object} map[string]string "Не авторизован"
+// @Failure 403 {object} ErrorResponse "Запрос создан другим пользователем"
+// @Failure 404 {object} ErrorResponse "Запрос парсинга не найден"
+// @Failure 409 {object} ErrorResponse "Запрос уже завершен"
+// @Router /parser/requests/{id}/cancel [post]
+func (h *ParserHandler) CancelParseRequest(c *gin.Context) {
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request_id",
+			Message: "Invalid request ID format",
+		})
+		return
+	}
+
+	existing, err := h.parserService.GetParseRequest(requestID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "request_not_found",
+			Message: "Parse request not found",
+		})
+		return
+	}
+	if !h.canCancel(c.GetString("user_id"), existing) {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: "You don't have permission to cancel this parse request",
+		})
+		return
+	}
+
+	request, err := h.parseQueue.Cancel(c.Request.Context(), requestID)
+	switch {
+	case errors.Is(err, services.ErrParseRequestNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "request_not_found",
+			Message: "Parse request not found",
+		})
+		return
+	case errors.Is(err, services.ErrParseRequestFinished):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "request_finished",
+			Message: "Parse request is already " + request.Status,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "cancel_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// canCancel разрешает отмену автору запроса и администратору. Роль берется
+// из базы, как в middleware.RequireRole.
+func (h *ParserHandler) canCancel(userID string, request *models.ParseRequest) bool {
+	if request.UserID != nil && request.UserID.String() == userID {
+		return true
+	}
+	user, err := h.userService.GetByID(userID)
+	return err == nil && user.Role == "admin"
+}
+
 // TestParse godoc
 // @Summary Тестовый парсинг недвижимости
 // @Description Выполняет быстрый тестовый парсинг с базовыми фильтрами для демонстрации работы системы. Парсит только одну страницу результатов.
@@ -144,14 +265,12 @@ func (h *ParserHandler) TestParse(c *gin.Context) {
 
 	// Получаем ID пользователя из контекста (если авторизован)
 	var userID *uuid.UUID
-	if userIDValue, exists := c.Get("user_id"); exists {
-		if id, ok := userIDValue.(uuid.UUID); ok {
-			userID = &id
-		}
+	if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		userID = &id
 	}
 
 	// Запускаем парсинг одной страницы
-	response, err := h.parserService.ParseProperties(filters, 1, userID)
+	response, err := h.parserService.ParseProperties(filters, nil, 1, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "test_parsing_failed",
@@ -163,21 +282,158 @@ func (h *ParserHandler) TestParse(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetSources godoc
+// @Summary Список источников объявлений
+// @Description Возвращает имена зарегистрированных источников, которые можно передать в поле sources запроса парсинга
+// @Tags Parser
+// @Produce json
+// @Success 200 {object} SourcesResponse "Список источников"
+// @Router /parser/sources [get]
+func (h *ParserHandler) GetSources(c *gin.Context) {
+	c.JSON(http.StatusOK, SourcesResponse{
+		Sources: h.parserService.Sources().Names(),
+	})
+}
+
+// GetFetcherStats godoc
+// @Summary Метрики загрузки страниц площадок
+// @Description Возвращает настройки вежливой загрузки и счетчики по каждому хосту: запросы, повторы, ответы 429 и 5xx, блокировки robots.txt, состояние отключения площадки и время ожидания лимита
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.FetcherStats "Метрики загрузчика"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Router /admin/parser/fetcher [get]
+func (h *ParserHandler) GetFetcherStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.parserService.FetcherStats())
+}
+
+// GetSelectorStatus godoc
+// @Summary Профили селекторов площадок
+// @Description Возвращает версию действующих профилей CSS селекторов, откуда они загружены и последнюю ошибку загрузки
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.SelectorStatus "Состояние профилей"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Router /admin/parser/selectors [get]
+func (h *ParserHandler) GetSelectorStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.selectors.Status())
+}
+
+// ReloadSelectors godoc
+// @Summary Перечитать профили селекторов
+// @Description Перечитывает файл SELECTOR_PROFILES_PATH без ожидания проверки изменений. Новая версия применяется, только если все снапшоты разбираются ею как в эталонах
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.SelectorStatus "Профили загружены"
+// @Failure 400 {object} ErrorResponse "Файл профилей не задан"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 422 {object} ErrorResponse "Профили не прошли проверку, действуют прежние"
+// @Router /admin/parser/selectors/reload [post]
+func (h *ParserHandler) ReloadSelectors(c *gin.Context) {
+	if err := h.selectors.Reload(); err != nil {
+		if errors.Is(err, services.ErrSelectorProfilesNotConfigured) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "selector_profiles_not_configured",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "invalid_selector_profiles",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.selectors.Status())
+}
+
+// GetSourceHealth godoc
+// @Summary Здоровье площадок
+// @Description Возвращает пороги и состояние каждой площадки: последний запуск, нарушенные пороги и средние за последние запуски карточки на странице, доля ошибок и заполненность цены, площади, комнат и фото. Площадка degraded, если последний запуск нарушил хотя бы один порог
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.SourceHealthReport "Состояние площадок"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Ошибка получения метрик"
+// @Router /admin/parser/health [get]
+func (h *ParserHandler) GetSourceHealth(c *gin.Context) {
+	report, err := h.health.Report(h.parserService.Sources().Names())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "health_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetSourceHealthHistory godoc
+// @Summary История здоровья площадки
+// @Description Возвращает метрики последних запусков площадки, новые первыми
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param source path string true "Источник" example(krisha)
+// @Param limit query int false "Количество запусков" default(20)
+// @Success 200 {array} models.SourceHealthRun "Запуски"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Источник не найден"
+// @Failure 500 {object} ErrorResponse "Ошибка получения метрик"
+// @Router /admin/parser/health/{source} [get]
+func (h *ParserHandler) GetSourceHealthHistory(c *gin.Context) {
+	source, ok := h.parserService.Sources().Get(c.Param("source"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "source_not_found",
+			Message: "unknown source " + c.Param("source"),
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	runs, err := h.health.History(source.Name(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "health_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
 // ParsePropertiesRequest структура запроса для парсинга
 type ParsePropertiesRequest struct {
 	Filters  models.PropertyFilters `json:"filters" binding:"required" example:"{\"city\":\"Алматы\",\"rooms\":2,\"price_max\":50000000}"`
+	Sources  []string               `json:"sources" example:"krisha,olx"`
 	MaxPages int                    `json:"max_pages" binding:"min=1,max=10" example:"2"`
 }
 
+// SourcesResponse список доступных источников объявлений
+type SourcesResponse struct {
+	Sources []string `json:"sources" example:"krisha,olx"`
+}
+
 // PropertyFiltersSwagger для Swagger документации
 type PropertyFiltersSwagger struct {
-	PropertyType      string `json:"property_type" example:"apartment"`
+	PropertyType      string `json:"property_type" example:"apartment" enums:"apartment,house,land,commercial"`
+	CommercialType    string `json:"commercial_type" example:"office" enums:"office,retail,warehouse"`
 	City              string `json:"city" example:"Алматы"`
 	Rooms             *int   `json:"rooms" example:"2"`
 	PriceMin          *int64 `json:"price_min" example:"10000000"`
 	PriceMax          *int64 `json:"price_max" example:"50000000"`
 	TotalAreaFrom     *int   `json:"total_area_from" example:"50"`
 	TotalAreaTo       *int   `json:"total_area_to" example:"120"`
+	LandAreaFrom      *int   `json:"land_area_from" example:"6"`
+	LandAreaTo        *int   `json:"land_area_to" example:"15"`
 	FloorFrom         *int   `json:"floor_from" example:"2"`
 	FloorTo           *int   `json:"floor_to" example:"15"`
 	TotalFloorsFrom   *int   `json:"total_floors_from" example:"5"`
@@ -195,6 +451,7 @@ type PropertyFiltersSwagger struct {
 // ParsedPropertySwagger для Swagger документации  
 type ParsedPropertySwagger struct {
 	ID                 string   `json:"id" example:"krisha_12345"`
+	Source             string   `json:"source" example:"krisha"`
 	Title              string   `json:"title" example:"Продается 2-комнатная квартира"`
 	Price              int64    `json:"price" example:"25000000"`
 	Currency           string   `json:"currency" example:"₸"`
@@ -217,12 +474,13 @@ type ParsedPropertySwagger struct {
 
 // ParseResponseSwagger для Swagger документации
 type ParseResponseSwagger struct {
-	Success    bool                    `json:"success" example:"true"`
-	RequestID  string                  `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Properties []ParsedPropertySwagger `json:"properties"`
-	Count      int                     `json:"count" example:"15"`
-	Status     string                  `json:"status" example:"completed"`
-	Error      string                  `json:"error,omitempty" example:""`
-	Cached     bool                    `json:"cached" example:"false"`
-	ParserType string                  `json:"parser_type" example:"selenium"`
-}
\ No newline at end of file
+	Success    bool                     `json:"success" example:"true"`
+	RequestID  string                   `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Properties []ParsedPropertySwagger  `json:"properties"`
+	Count      int                      `json:"count" example:"15"`
+	Status     string                   `json:"status" example:"pending"`
+	Error      string                   `json:"error,omitempty" example:""`
+	Cached     bool                     `json:"cached" example:"false"`
+	ParserType string                   `json:"parser_type" example:"krisha,olx"`
+	Clusters   []models.PropertyCluster `json:"clusters,omitempty"`
+}
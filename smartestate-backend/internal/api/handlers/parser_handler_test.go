@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"smartestate/internal/models"
+	"smartestate/internal/services"
+)
+
+func TestCanCancelParseRequest(t *testing.T) {
+	handler := &ParserHandler{userService: services.NewUserService(dryRunDB(t))}
+	owner := uuid.New()
+
+	if !handler.canCancel(owner.String(), &models.ParseRequest{UserID: &owner}) {
+		t.Errorf("owner cannot cancel own request")
+	}
+	if handler.canCancel(uuid.NewString(), &models.ParseRequest{UserID: &owner}) {
+		t.Errorf("other user can cancel the request")
+	}
+	if handler.canCancel(owner.String(), &models.ParseRequest{}) {
+		t.Errorf("user can cancel an anonymous request")
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"smartestate/internal/models"
+	"smartestate/internal/services"
+)
+
+type POIHandler struct {
+	poi *services.POIService
+}
+
+func NewPOIHandler(poi *services.POIService) *POIHandler {
+	return &POIHandler{poi: poi}
+}
+
+// POIScoreResponse итог расчета окружения объявлений каталога
+type POIScoreResponse struct {
+	Processed int `json:"processed" example:"500"`
+}
+
+// GetListingNearby godoc
+// @Summary Окружение объявления
+// @Description Возвращает ближайшие к объявлению школу, детский сад, метро, остановку, парк, ТРЦ, супермаркет, больницу и аптеку в радиусе 2 км и индекс пешей доступности 0-100. Для объявлений с координатами точнее микрорайона окружение не считается.
+// @Tags Listings
+// @Produce json
+// @Param id path string true "UUID объявления" Format(uuid)
+// @Success 200 {object} services.ListingNearbyResult "Окружение объявления"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Router /listings/{id}/nearby [get]
+func (h *POIHandler) GetListingNearby(c *gin.Context) {
+	listingID, ok := parseListingID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.poi.ListingNearby(listingID)
+	if err != nil {
+		respondListingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetStats godoc
+// @Summary Точки интереса
+// @Description Возвращает количество импортированных точек интереса по категориям. Точки импортируются из выгрузки OpenStreetMap командой poi import.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.POIStats "Количество точек по категориям"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /admin/poi [get]
+func (h *POIHandler) GetStats(c *gin.Context) {
+	stats, err := h.poi.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "poi_stats_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// ScoreListings godoc
+// @Summary Рассчитать окружение каталога
+// @Description Считает ближайшие точки интереса и индекс пешей доступности для объявлений каталога, у которых окружение еще не считалось или координаты изменились после расчета
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Сколько объявлений обработать" default(500)
+// @Success 200 {object} POIScoreResponse "Итог расчета"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Ошибка сохранения"
+// @Router /admin/listings/poi [post]
+func (h *POIHandler) ScoreListings(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+
+	processed, err := h.poi.ScorePending(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "poi_score_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, POIScoreResponse{Processed: processed})
+}
+
+// parsePOIFilters разбирает фильтры окружения: near=school:500,metro:1000 и
+// min_walk_score. При ошибке отвечает 400.
+func parsePOIFilters(c *gin.Context) ([]models.POIDistanceFilter, *int, bool) {
+	filters, minWalkScore, err := poiFiltersFromQuery(c)
+	if err == nil {
+		err = services.ValidatePOIFilters(filters, minWalkScore)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_poi_filter",
+			Message: err.Error(),
+		})
+		return nil, nil, false
+	}
+	return filters, minWalkScore, true
+}
+
+func poiFiltersFromQuery(c *gin.Context) ([]models.POIDistanceFilter, *int, error) {
+	var filters []models.POIDistanceFilter
+	if near := c.Query("near"); near != "" {
+		for _, part := range strings.Split(near, ",") {
+			category, distance, ok := strings.Cut(strings.TrimSpace(part), ":")
+			if !ok {
+				return nil, nil, fmt.Errorf("near must be category:meters, got %q", part)
+			}
+			within, err := strconv.Atoi(distance)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid distance %q", distance)
+			}
+			filters = append(filters, models.POIDistanceFilter{Category: category, WithinM: within})
+		}
+	}
+
+	var minWalkScore *int
+	if value := c.Query("min_walk_score"); value != "" {
+		score, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("min_walk_score must be an integer")
+		}
+		minWalkScore = &score
+	}
+	return filters, minWalkScore, nil
+}
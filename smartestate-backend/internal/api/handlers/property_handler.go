@@ -35,11 +35,86 @@ func NewPropertyHandler(ps *services.PropertyService, as *services.AIService, ss
 // @Param min_price query number false "Minimum price"
 // @Param max_price query number false "Maximum price"
 // @Param rooms query integer false "Number of rooms"
+// @Param lat query number false "Search point latitude; with lon sorts results by distance"
+// @Param lon query number false "Search point longitude"
+// @Param radius query number false "Radius around the point, meters"
+// @Param bbox query string false "Map viewport: west,south,east,north"
+// @Param polygon query string false "Drawn area: lat,lon;lat,lon;..."
 // @Param page query integer false "Page number" default(1)
 // @Param limit query integer false "Items per page" default(20)
 // @Success 200 {object} map[string]interface{} "Properties list with pagination"
+// @Failure 400 {object} ErrorResponse "Invalid map parameters"
 // @Router /properties [get]
 func (h *PropertyHandler) List(c *gin.Context) {
+	filters, ok := propertyFiltersFromQuery(c)
+	if !ok {
+		return
+	}
+
+	// Pagination
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	properties, total, err := h.propertyService.List(filters, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"properties": properties,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// Markers godoc
+// @Summary Property map markers
+// @Description Get properties inside a map area as markers. Nearby properties are grouped into clusters depending on zoom: a cluster has count above 1, averaged coordinates and a price range, a single marker has the property id
+// @Tags Properties
+// @Produce json
+// @Param bbox query string false "Map viewport: west,south,east,north"
+// @Param polygon query string false "Drawn area: lat,lon;lat,lon;..."
+// @Param lat query number false "Circle center latitude"
+// @Param lon query number false "Circle center longitude"
+// @Param radius query number false "Circle radius, meters"
+// @Param zoom query integer false "Map zoom, 1-20" default(12)
+// @Param city query string false "City filter"
+// @Param property_type query string false "Property type filter"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param rooms query integer false "Number of rooms"
+// @Success 200 {object} map[string]interface{} "Markers and clusters"
+// @Failure 400 {object} ErrorResponse "Missing area or invalid map parameters"
+// @Router /properties/markers [get]
+func (h *PropertyHandler) Markers(c *gin.Context) {
+	geo, zoom, ok := parseMarkersQuery(c)
+	if !ok {
+		return
+	}
+
+	filters, ok := propertyFiltersFromQuery(c)
+	if !ok {
+		return
+	}
+	filters["geo"] = geo
+
+	markers, err := h.propertyService.Markers(filters, zoom)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch markers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"markers": markers,
+		"zoom":    zoom,
+	})
+}
+
+// propertyFiltersFromQuery разбирает фильтры списка объектов. При ошибке в
+// параметрах карты отвечает 400.
+func propertyFiltersFromQuery(c *gin.Context) (map[string]interface{}, bool) {
 	filters := make(map[string]interface{})
 
 	// Parse query parameters
@@ -65,22 +140,15 @@ func (h *PropertyHandler) List(c *gin.Context) {
 		}
 	}
 
-	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-
-	properties, total, err := h.propertyService.List(filters, page, limit)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
-		return
+	geo, ok := parseGeoFilter(c)
+	if !ok {
+		return nil, false
+	}
+	if geo != nil {
+		filters["geo"] = geo
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"properties": properties,
-		"total":      total,
-		"page":       page,
-		"limit":      limit,
-	})
+	return filters, true
 }
 
 // Get godoc
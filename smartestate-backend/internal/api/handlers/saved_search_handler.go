@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"smartestate/internal/models"
+	"smartestate/internal/services"
+)
+
+type SavedSearchHandler struct {
+	searches *services.SavedSearchService
+}
+
+func NewSavedSearchHandler(searches *services.SavedSearchService) *SavedSearchHandler {
+	return &SavedSearchHandler{searches: searches}
+}
+
+// ImportSearchURLRequest ссылка на поиск krisha.kz или olx.kz
+type ImportSearchURLRequest struct {
+	URL string `json:"url" binding:"required" example:"https://krisha.kz/prodazha/kvartiry/almaty/?das[live.rooms][]=2&das[price][to]=40000000"`
+}
+
+// SavedSearchResponse сохраненный поиск и параметры ссылки, которые не удалось перенести
+type SavedSearchResponse struct {
+	Search      *models.SavedSearch `json:"search"`
+	Unsupported []string            `json:"unsupported,omitempty"`
+}
+
+// ImportSearchURL godoc
+// @Summary Фильтры из ссылки на поиск
+// @Description Разбирает ссылку на поиск krisha.kz или olx.kz с параметрами das[...] или search[...] в фильтры парсинга. Фильтры можно передать в запрос парсинга, расписание или сохраненный поиск. Параметры, которых нет в фильтрах, перечисляются в unsupported.
+// @Tags Parser
+// @Accept json
+// @Produce json
+// @Param request body ImportSearchURLRequest true "Ссылка на поиск"
+// @Success 200 {object} services.SearchURLImport "Фильтры поиска"
+// @Failure 400 {object} ErrorResponse "Ссылка не является поиском krisha.kz или olx.kz"
+// @Router /parser/import-url [post]
+func (h *SavedSearchHandler) ImportSearchURL(c *gin.Context) {
+	var req ImportSearchURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	imported, err := services.ParseSearchURL(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unsupported_search_url",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, imported)
+}
+
+// Create godoc
+// @Summary Сохранить поиск
+// @Description Сохраняет поиск пользователя по фильтрам или по ссылке на поиск krisha.kz или olx.kz (search_url). Если задана ссылка, фильтры и источник берутся из нее.
+// @Tags Saved Searches
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.SavedSearchInput true "Параметры поиска"
+// @Success 201 {object} SavedSearchResponse "Сохраненный поиск"
+// @Failure 400 {object} ErrorResponse "Некорректные фильтры или ссылка"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Router /saved-searches [post]
+func (h *SavedSearchHandler) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req services.SavedSearchInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.SearchURL == "" && req.Filters.City == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "search_url or filters.city is required",
+		})
+		return
+	}
+
+	search, unsupported, err := h.searches.Create(userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_saved_search",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SavedSearchResponse{Search: search, Unsupported: unsupported})
+}
+
+// List godoc
+// @Summary Сохраненные поиски
+// @Description Возвращает сохраненные поиски пользователя
+// @Tags Saved Searches
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Список поисков"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /saved-searches [get]
+func (h *SavedSearchHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	searches, err := h.searches.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "saved_searches_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"searches": searches,
+		"total":    len(searches),
+	})
+}
+
+// Delete godoc
+// @Summary Удалить сохраненный поиск
+// @Tags Saved Searches
+// @Security BearerAuth
+// @Param id path string true "UUID поиска" Format(uuid)
+// @Success 204 "Поиск удален"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Поиск не найден"
+// @Router /saved-searches/{id} [delete]
+func (h *SavedSearchHandler) Delete(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_saved_search_id",
+			Message: "Invalid saved search ID format",
+		})
+		return
+	}
+
+	if err := h.searches.Delete(userID, id); err != nil {
+		if errors.Is(err, services.ErrSavedSearchNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "saved_search_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "saved_search_delete_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// currentUserID возвращает ID пользователя из токена. При ошибке отвечает 401.
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: "User ID not found in token",
+		})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"smartestate/internal/services"
+)
+
+type ViewingHandler struct {
+	viewings *services.ViewingService
+}
+
+func NewViewingHandler(viewings *services.ViewingService) *ViewingHandler {
+	return &ViewingHandler{viewings: viewings}
+}
+
+// Create godoc
+// @Summary Записаться на просмотр
+// @Description Записывает пользователя на просмотр объявления из каталога (listing_id) или по ссылке на объявление (listing_url). Время просмотра - в ближайшие 90 дней.
+// @Tags Viewings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.ViewingInput true "Параметры просмотра"
+// @Success 201 {object} models.Viewing "Запись на просмотр"
+// @Failure 400 {object} ErrorResponse "Некорректные параметры"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Router /viewings [post]
+func (h *ViewingHandler) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ViewingInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	viewing, err := h.viewings.Schedule(userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_viewing",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, viewing)
+}
+
+// List godoc
+// @Summary Предстоящие просмотры
+// @Description Возвращает предстоящие просмотры пользователя, ближайшие первыми
+// @Tags Viewings
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Список просмотров"
+// @Failure 401 {object} ErrorResponse "Не авторизован"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /viewings [get]
+func (h *ViewingHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	viewings, err := h.viewings.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "viewings_fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"viewings": viewings,
+		"total":    len(viewings),
+	})
+}
+
+// Cancel godoc
+// @Summary Отменить просмотр
+// @Tags Viewings
+// @Security BearerAuth
+// @Param id path string true "UUID просмотра" Format(uuid)
+// @Success 204 "Просмотр отменен"
+// @Failure 400 {object} ErrorResponse "Некорректный формат ID"
+// @Failure 404 {object} ErrorResponse "Просмотр не найден"
+// @Router /viewings/{id} [delete]
+func (h *ViewingHandler) Cancel(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_viewing_id",
+			Message: "Invalid viewing ID format",
+		})
+		return
+	}
+
+	if err := h.viewings.Cancel(userID, id); err != nil {
+		if errors.Is(err, services.ErrViewingNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "viewing_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "viewing_cancel_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -13,6 +13,8 @@ type Config struct {
 	JWT      JWTConfig
 	AI       AIConfig
 	Storage  StorageConfig
+	Parser   ParserConfig
+	Geocoder GeocoderConfig
 }
 
 type ServerConfig struct {
@@ -38,11 +40,84 @@ type JWTConfig struct {
 	RefreshTokenExpiry string
 }
 
+// AIConfig настройки LLM провайдеров
 type AIConfig struct {
-	Provider        string
+	Provider        string // провайдер по умолчанию: openai, gemini, anthropic, fake
 	OpenAIKey      string
 	GeminiKey      string
 	AnthropicKey   string
+
+	OpenAIModel    string
+	GeminiModel    string
+	AnthropicModel string
+
+	// FeatureProviders провайдеры отдельных функций через запятую:
+	// chat=anthropic,description=openai. Остальные функции используют Provider.
+	FeatureProviders string
+	MaxTokens        int // ограничение длины ответа, Anthropic требует его в каждом запросе
+	// ChatHistoryTokens сколько токенов контекста чата передавать модели: системный
+	// промпт с кратким содержанием и предпочтениями и последние сообщения как есть.
+	// Более старые сообщения сворачиваются в краткое содержание.
+	ChatHistoryTokens int
+}
+
+type ParserConfig struct {
+	Workers     int // количество воркеров очереди парсинга
+	MaxAttempts int // сколько раз перезапускать задачу после падения сервера
+
+	ListingStaleHours int // через сколько часов без появления в выдаче объявление считается снятым
+
+	SchedulerEnabled  bool // запускать планировщик регулярного парсинга в этом процессе
+	SchedulerInterval int  // как часто проверять расписания, секунды
+
+	// Загрузка страниц новых объявлений после поиска
+	DetailEnabled     bool // включить этап обогащения
+	DetailConcurrency int  // сколько страниц объявлений загружать одновременно
+	DetailMaxAttempts int  // попыток загрузки одной страницы, если загрузчик сам не повторял запрос
+	DetailRetryDelay  int  // пауза перед повтором, миллисекунды; растет с каждой попыткой
+	DetailMaxPerRun   int  // сколько новых объявлений обогащать за один запуск
+
+	// Selenium нужен только как запасной способ загрузки страниц olx.kz
+	OlxSeleniumFallback bool   // рендерить страницу в браузере, если по HTTP в ней нет объявлений
+	SeleniumURL         string // адрес Selenium Grid
+
+	// Вежливая загрузка страниц площадок, лимиты действуют на каждый хост
+	FetchUserAgent        string // User-Agent запросов, первый продукт - имя робота для robots.txt; по умолчанию SmartEstateBot/1.0
+	FetchRatePerMinute    int    // запросов в минуту к одному хосту
+	FetchBurst            int    // сколько запросов можно отправить подряд без паузы
+	FetchMaxAttempts      int    // попыток при 429, ошибках сервера и сети
+	FetchBackoffBase      int    // первая пауза перед повтором, миллисекунды; удваивается
+	FetchBackoffMax       int    // максимальная пауза перед повтором, миллисекунды
+	FetchBreakerThreshold int    // после скольких ошибок подряд площадка отключается
+	FetchBreakerCooldown  int    // на сколько отключается площадка, секунды
+	FetchRespectRobots    bool   // проверять robots.txt
+
+	// Профили CSS селекторов площадок, по умолчанию встроенные в сервер
+	SelectorProfilesPath   string // YAML или JSON файл профилей, перечитывается при изменении
+	SelectorFixturesDir    string // снапшоты, по которым проверяется новая версия профилей
+	SelectorReloadInterval int    // как часто проверять изменение файла, секунды
+
+	// Пороги здоровья площадки, доли в процентах. Нарушение любого порога
+	// помечает площадку как сломанную и отправляет оповещение
+	HealthMinItemsPerPage int    // минимум карточек на странице поиска
+	HealthMinFillPrice    int    // минимум карточек с ценой
+	HealthMinFillArea     int    // минимум карточек с площадью
+	HealthMinFillRooms    int    // минимум квартир и домов с количеством комнат
+	HealthMinFillImages   int    // минимум карточек с фото
+	HealthMaxErrorRate    int    // максимум страниц с ошибкой загрузки
+	HealthMaxFillDrop     int    // на сколько пунктов заполненность может упасть относительно средней
+	HealthMinSample       int    // с какого числа карточек проверяется заполненность
+	HealthBaselineRuns    int    // по скольким прошлым запускам считается средняя
+	HealthAlertWebhookURL string // куда отправлять оповещения, пусто - только в лог
+}
+
+// GeocoderConfig настройки геокодирования адресов объявлений
+type GeocoderConfig struct {
+	Providers     string // порядок опроса через запятую: gazetteer, nominatim
+	NominatimURL  string // адрес сервера Nominatim
+	UserAgent     string // Nominatim требует User-Agent с контактами приложения
+	MinIntervalMs int    // пауза между запросами к внешнему геокодеру, миллисекунды
+	CacheTTLHours int    // сколько хранить найденный адрес в кэше, часы
 }
 
 type StorageConfig struct {
@@ -78,6 +153,14 @@ func New() *Config {
 			OpenAIKey:    getEnv("OPENAI_API_KEY", ""),
 			GeminiKey:    getEnv("GEMINI_API_KEY", ""),
 			AnthropicKey: getEnv("ANTHROPIC_API_KEY", ""),
+
+			OpenAIModel:    getEnv("OPENAI_MODEL", "gpt-4"),
+			GeminiModel:    getEnv("GEMINI_MODEL", "gemini-1.5-flash-latest"),
+			AnthropicModel: getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest"),
+
+			FeatureProviders:  getEnv("AI_FEATURE_PROVIDERS", ""),
+			MaxTokens:         getEnvAsInt("AI_MAX_TOKENS", 2048),
+			ChatHistoryTokens: getEnvAsInt("AI_CHAT_HISTORY_TOKENS", 4000),
 		},
 		Storage: StorageConfig{
 			S3Bucket:  getEnv("S3_BUCKET", ""),
@@ -85,6 +168,56 @@ func New() *Config {
 			AWSKey:    getEnv("AWS_ACCESS_KEY", ""),
 			AWSSecret: getEnv("AWS_SECRET_KEY", ""),
 		},
+		Parser: ParserConfig{
+			Workers:     getEnvAsInt("PARSER_WORKERS", 2),
+			MaxAttempts: getEnvAsInt("PARSER_MAX_ATTEMPTS", 3),
+
+			ListingStaleHours: getEnvAsInt("LISTING_STALE_HOURS", 72),
+
+			SchedulerEnabled:  getEnv("CRAWL_SCHEDULER_ENABLED", "true") != "false",
+			SchedulerInterval: getEnvAsInt("CRAWL_SCHEDULER_INTERVAL", 30),
+
+			DetailEnabled:     getEnv("DETAIL_ENRICH_ENABLED", "true") != "false",
+			DetailConcurrency: getEnvAsInt("DETAIL_ENRICH_CONCURRENCY", 3),
+			DetailMaxAttempts: getEnvAsInt("DETAIL_ENRICH_MAX_ATTEMPTS", 3),
+			DetailRetryDelay:  getEnvAsInt("DETAIL_ENRICH_RETRY_DELAY_MS", 1000),
+			DetailMaxPerRun:   getEnvAsInt("DETAIL_ENRICH_MAX_PER_RUN", 50),
+
+			OlxSeleniumFallback: getEnv("OLX_SELENIUM_FALLBACK", "false") == "true",
+			SeleniumURL:         getEnv("SELENIUM_URL", "http://localhost:4444/wd/hub"),
+
+			FetchUserAgent:        getEnv("FETCH_USER_AGENT", ""),
+			FetchRatePerMinute:    getEnvAsInt("FETCH_RATE_PER_MINUTE", 30),
+			FetchBurst:            getEnvAsInt("FETCH_BURST", 3),
+			FetchMaxAttempts:      getEnvAsInt("FETCH_MAX_ATTEMPTS", 4),
+			FetchBackoffBase:      getEnvAsInt("FETCH_BACKOFF_BASE_MS", 1000),
+			FetchBackoffMax:       getEnvAsInt("FETCH_BACKOFF_MAX_MS", 30000),
+			FetchBreakerThreshold: getEnvAsInt("FETCH_BREAKER_THRESHOLD", 5),
+			FetchBreakerCooldown:  getEnvAsInt("FETCH_BREAKER_COOLDOWN_SEC", 60),
+			FetchRespectRobots:    getEnv("FETCH_RESPECT_ROBOTS", "true") != "false",
+
+			SelectorProfilesPath:   getEnv("SELECTOR_PROFILES_PATH", ""),
+			SelectorFixturesDir:    getEnv("SELECTOR_FIXTURES_DIR", "testdata/parser"),
+			SelectorReloadInterval: getEnvAsInt("SELECTOR_RELOAD_INTERVAL", 30),
+
+			HealthMinItemsPerPage: getEnvAsInt("HEALTH_MIN_ITEMS_PER_PAGE", 1),
+			HealthMinFillPrice:    getEnvAsInt("HEALTH_MIN_FILL_PRICE", 80),
+			HealthMinFillArea:     getEnvAsInt("HEALTH_MIN_FILL_AREA", 60),
+			HealthMinFillRooms:    getEnvAsInt("HEALTH_MIN_FILL_ROOMS", 60),
+			HealthMinFillImages:   getEnvAsInt("HEALTH_MIN_FILL_IMAGES", 50),
+			HealthMaxErrorRate:    getEnvAsInt("HEALTH_MAX_ERROR_RATE", 50),
+			HealthMaxFillDrop:     getEnvAsInt("HEALTH_MAX_FILL_DROP", 30),
+			HealthMinSample:       getEnvAsInt("HEALTH_MIN_SAMPLE", 5),
+			HealthBaselineRuns:    getEnvAsInt("HEALTH_BASELINE_RUNS", 10),
+			HealthAlertWebhookURL: getEnv("HEALTH_ALERT_WEBHOOK_URL", ""),
+		},
+		Geocoder: GeocoderConfig{
+			Providers:     getEnv("GEOCODER_PROVIDERS", "gazetteer"),
+			NominatimURL:  getEnv("GEOCODER_NOMINATIM_URL", "https://nominatim.openstreetmap.org"),
+			UserAgent:     getEnv("GEOCODER_USER_AGENT", "SmartEstate/1.0"),
+			MinIntervalMs: getEnvAsInt("GEOCODER_MIN_INTERVAL_MS", 1000),
+			CacheTTLHours: getEnvAsInt("GEOCODER_CACHE_TTL_HOURS", 720),
+		},
 	}
 }
 
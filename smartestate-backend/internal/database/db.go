@@ -184,6 +184,16 @@ func runMigrations(db *gorm.DB) error {
 		&models.Campaign{},
 		&models.ParseRequest{},
 		&models.PriorityProperty{},
+		&models.Listing{},
+		&models.ListingPriceHistory{},
+		&models.ListingCluster{},
+		&models.CrawlSchedule{},
+		&models.SourceHealthRun{},
+		&models.GeocodeCacheEntry{},
+		&models.POI{},
+		&models.ListingPOIDistance{},
+		&models.SavedSearch{},
+		&models.Viewing{},
 	}
 
 	for _, model := range models {
@@ -251,6 +261,8 @@ func createOptimizedIndexes(db *gorm.DB) error {
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_properties_user_created ON properties (user_id, created_at DESC) WHERE deleted_at IS NULL",
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_properties_price ON properties (price) WHERE deleted_at IS NULL",
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_properties_city_price ON properties (city, price) WHERE deleted_at IS NULL",
+		// Поиск по карте: координаты объектов хранятся в jsonb
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_properties_lat_lon ON properties (((coordinates->>'latitude')::float8), ((coordinates->>'longitude')::float8))",
 		
 		// Частичные индексы для активных данных
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_property_views_property_recent ON property_views (property_id, created_at DESC) WHERE created_at > NOW() - INTERVAL '30 days'",
@@ -18,16 +18,8 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		var token string
-
-		// Проверяем формат - с Bearer или без
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			// Стандартный формат: "Bearer token"
-			token = strings.TrimPrefix(authHeader, "Bearer ")
-		} else if !strings.Contains(authHeader, " ") {
-			// Swagger отправляет просто токен без Bearer
-			token = authHeader
-		} else {
+		token, ok := headerToken(authHeader)
+		if !ok {
 			// Неправильный формат
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
@@ -49,3 +41,49 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthMiddleware как AuthMiddleware, но пропускает запрос и без
+// токена: маршрут открыт всем, а пользователь, если он вошел, запоминается
+// в контексте. Неверный токен считается отсутствующим.
+func OptionalAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := headerToken(c.GetHeader("Authorization")); ok && token != "" {
+			if claims, err := authService.ValidateAccessToken(token); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("user_role", claims.Role)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// headerToken извлекает токен из заголовка Authorization
+func headerToken(authHeader string) (string, bool) {
+	// Проверяем формат - с Bearer или без
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		// Стандартный формат: "Bearer token"
+		return strings.TrimPrefix(authHeader, "Bearer "), true
+	}
+	if !strings.Contains(authHeader, " ") {
+		// Swagger отправляет просто токен без Bearer
+		return authHeader, true
+	}
+	return "", false
+}
+
+// RequireRole пропускает только пользователей с указанной ролью. Роль берется
+// из базы, а не из токена, чтобы выдача и отзыв прав действовали сразу.
+// Должен стоять после AuthMiddleware.
+func RequireRole(userService *services.UserService, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := userService.GetByID(c.GetString("user_id"))
+		if err != nil || user.Role != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
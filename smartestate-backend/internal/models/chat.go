@@ -29,9 +29,13 @@ type ChatMessage struct {
 }
 
 type ChatContext struct {
-	PropertyPreferences map[string]interface{} `json:"property_preferences"`
+	PropertyPreferences map[string]interface{} `json:"property_preferences"` // параметры последнего поиска, всегда передаются модели
 	SearchHistory       []string               `json:"search_history"`
 	LastIntent          string                 `json:"last_intent"`
+	// Summary краткое содержание старых сообщений, которые больше не передаются модели как есть
+	Summary string `json:"summary,omitempty"`
+	// SummarizedUntil время последнего сообщения, вошедшего в Summary
+	SummarizedUntil *time.Time `json:"summarized_until,omitempty"`
 }
 
 type MessageMetadata struct {
@@ -39,6 +43,17 @@ type MessageMetadata struct {
 	Actions     []string               `json:"actions,omitempty"`
 	Confidence  float64                `json:"confidence,omitempty"`
 	Extra       map[string]interface{} `json:"extra,omitempty"`
+	ToolCalls   []ToolCallRecord       `json:"tool_calls,omitempty"` // инструменты, вызванные при подготовке ответа
+}
+
+// ToolCallRecord вызов инструмента ассистентом и его результат
+type ToolCallRecord struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Step      int             `json:"step"` // номер запроса к модели, в ответ на который сделан вызов
 }
 
 func (c ChatContext) Value() (driver.Value, error) {
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CrawlSchedule регулярный запуск парсинга по расписанию, например
+// "квартиры в Алматы с krisha каждые 6 часов". Каждый запуск создает
+// обычный ParseRequest с ScheduleID этого расписания.
+type CrawlSchedule struct {
+	ID       uuid.UUID       `gorm:"type:uuid;primary_key" json:"id"`
+	Name     string          `gorm:"not null" json:"name"`
+	Sources  StringSlice     `gorm:"type:jsonb" json:"sources"` // пусто - все источники
+	Filters  PropertyFilters `gorm:"type:jsonb" json:"filters"`
+	Cron     string          `gorm:"not null" json:"cron"` // "0 */6 * * *", "@daily", "CRON_TZ=Asia/Almaty 0 9 * * *"
+	MaxPages int             `json:"max_pages"`
+	IsPaused bool            `gorm:"default:false;index" json:"is_paused"`
+
+	NextRunAt *time.Time `gorm:"index" json:"next_run_at"` // nil, пока расписание на паузе
+
+	// Результат последнего запуска
+	LastRunAt          *time.Time `json:"last_run_at"`
+	LastStatus         string     `json:"last_status"` // queued, skipped, а после завершения - статус ParseRequest
+	LastError          string     `json:"last_error"`
+	LastCount          int        `json:"last_count"`
+	LastParseRequestID *uuid.UUID `gorm:"type:uuid" json:"last_parse_request_id"`
+
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Статусы запуска расписания помимо статусов ParseRequest
+const (
+	CrawlRunStatusQueued  = "queued"
+	CrawlRunStatusSkipped = "skipped" // предыдущий запуск еще не завершился
+)
+
+func (s *CrawlSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+)
+
+// Типы продавца
+const (
+	SellerTypeOwner     = "owner"     // собственник
+	SellerTypeAgent     = "agent"     // агентство или риелтор
+	SellerTypeDeveloper = "developer" // застройщик
+)
+
+// NormalizeSellerType приводит тип продавца к одной из констант SellerType*.
+// Пустое значение допустимо и означает любого продавца.
+func NormalizeSellerType(sellerType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(sellerType)) {
+	case "":
+		return "", true
+	case SellerTypeOwner, "private":
+		return SellerTypeOwner, true
+	case SellerTypeAgent, "agency", "realtor":
+		return SellerTypeAgent, true
+	case SellerTypeDeveloper:
+		return SellerTypeDeveloper, true
+	}
+	return "", false
+}
+
+// Материал стен дома
+const (
+	HouseTypeBrick    = "brick"    // кирпичный
+	HouseTypePanel    = "panel"    // панельный
+	HouseTypeMonolith = "monolith" // монолитный
+	HouseTypeOther    = "other"    // иной
+)
+
+// NormalizeHouseType приводит материал стен к одной из констант HouseType*.
+// Пустое значение допустимо и означает любой дом.
+func NormalizeHouseType(houseType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(houseType)) {
+	case "":
+		return "", true
+	case HouseTypeBrick:
+		return HouseTypeBrick, true
+	case HouseTypePanel:
+		return HouseTypePanel, true
+	case HouseTypeMonolith:
+		return HouseTypeMonolith, true
+	case HouseTypeOther:
+		return HouseTypeOther, true
+	}
+	return "", false
+}
+
+// UnsupportedFilters фильтры, которые источник не смог учесть: имя источника
+// и поля PropertyFilters по JSON, например {"olx": ["floor_from"]}
+type UnsupportedFilters map[string][]string
+
+func (u UnsupportedFilters) Value() (driver.Value, error) {
+	return json.Marshal(u)
+}
+
+func (u *UnsupportedFilters) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, u)
+}
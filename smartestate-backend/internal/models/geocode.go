@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Точность координат, от точной к грубой
+const (
+	GeoPrecisionExact         = "exact"         // дом или координаты с площадки
+	GeoPrecisionStreet        = "street"        // середина улицы
+	GeoPrecisionMicrodistrict = "microdistrict" // центр микрорайона
+	GeoPrecisionDistrict      = "district"      // центр района
+	GeoPrecisionCity          = "city"          // центр города
+)
+
+// GeoPrecisionRank возвращает ранг точности: чем точнее, тем больше. Для пустой - 0.
+func GeoPrecisionRank(precision string) int {
+	switch precision {
+	case GeoPrecisionExact:
+		return 5
+	case GeoPrecisionStreet:
+		return 4
+	case GeoPrecisionMicrodistrict:
+		return 3
+	case GeoPrecisionDistrict:
+		return 2
+	case GeoPrecisionCity:
+		return 1
+	}
+	return 0
+}
+
+// GeocodeCacheEntry сохраненный ответ геокодера. Ключ - нормализованные город и
+// адрес, поэтому одинаковые адреса из разных объявлений геокодируются один раз.
+// Found=false запоминает, что адрес не найден, чтобы не спрашивать провайдеров снова.
+type GeocodeCacheEntry struct {
+	Key           string    `gorm:"primaryKey;size:255" json:"key"`
+	Found         bool      `json:"found"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	City          string    `json:"city"`
+	District      string    `json:"district"`
+	Microdistrict string    `json:"microdistrict"`
+	Street        string    `json:"street"`
+	Precision     string    `json:"precision"`
+	Provider      string    `json:"provider"`
+	ExpiresAt     time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (GeocodeCacheEntry) TableName() string {
+	return "geocode_cache"
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Listing объявление из внешнего источника (krisha.kz, olx.kz).
+// Одно объявление хранится одной записью, ключ - пара (source, external_id).
+type Listing struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key" json:"id"`
+	Source             string      `gorm:"not null;uniqueIndex:idx_listings_source_external" json:"source"`
+	ExternalID         string      `gorm:"not null;uniqueIndex:idx_listings_source_external" json:"external_id"`
+	Title              string      `json:"title"`
+	Description        string      `json:"description"`
+	Price              int64       `gorm:"index" json:"price"`
+	Currency           string      `json:"currency"`
+	Address            string      `json:"address"`
+	City               string      `gorm:"index" json:"city"`
+	Rooms              *int        `json:"rooms"`
+	Area               *float64    `json:"area"`
+	Floor              *int        `json:"floor"`
+	TotalFloors        *int        `json:"total_floors"`
+	BuildYear          *int        `json:"build_year"`
+	KitchenArea        *float64    `json:"kitchen_area"`
+	Images             StringSlice `gorm:"type:jsonb" json:"images"`
+	URL                string      `json:"url"`
+	Phone              string      `json:"phone"`
+	IsNewBuilding      bool        `json:"is_new_building"`
+	BuildingType       string      `json:"building_type"`
+	SellerType         string      `json:"seller_type"`
+	ResidentialComplex string      `json:"residential_complex"`
+
+	// Аренда: Price указана за PricePeriod
+	DealType          string `gorm:"default:'sale';index" json:"deal_type"`
+	PricePeriod       string `json:"price_period"`
+	Deposit           *int64 `json:"deposit"`
+	UtilitiesIncluded *bool  `json:"utilities_included"`
+	PetsAllowed       *bool  `json:"pets_allowed"`
+
+	// Категория и ее атрибуты: для домов и участков площадь участка в сотках,
+	// для коммерческой недвижимости вид помещения
+	PropertyType   string   `gorm:"default:'apartment';index" json:"property_type"`
+	LandArea       *float64 `json:"land_area"`
+	LandPurpose    string   `json:"land_purpose"`
+	CommercialType string   `gorm:"index" json:"commercial_type"`
+
+	// Со страницы объявления. DetailFetchedAt пусто, пока страница не загружена.
+	CeilingHeight   *float64   `json:"ceiling_height"`
+	BathroomType    string     `json:"bathroom_type"`
+	Condition       string     `json:"condition"`
+	Parking         string     `json:"parking"`
+	SellerName      string     `json:"seller_name"`
+	PublishedAt     *time.Time `json:"published_at"`
+	Views           *int       `json:"views"`
+	DetailFetchedAt *time.Time `gorm:"index" json:"detail_fetched_at"`
+
+	// Местоположение. GeocodedAt пусто, пока адрес не обрабатывался геокодером
+	Latitude      *float64   `gorm:"index:idx_listings_lat_lon" json:"latitude"`
+	Longitude     *float64   `gorm:"index:idx_listings_lat_lon" json:"longitude"`
+	District      string     `gorm:"index" json:"district"`
+	Microdistrict string     `json:"microdistrict"`
+	GeoPrecision  string     `json:"geo_precision"` // exact, street, microdistrict, district, city
+	GeocodedAt    *time.Time `json:"geocoded_at"`
+
+	// Окружение: индекс пешей доступности 0-100 по ближайшим точкам интереса,
+	// расстояния до них - в listing_poi_distances. POIScoredAt пусто, пока не считалось
+	WalkScore   *int       `gorm:"index" json:"walk_score"`
+	POIScoredAt *time.Time `json:"poi_scored_at"`
+
+	// Distance расстояние до точки поиска по карте в метрах, только при поиске с центром
+	Distance *float64 `gorm:"->;-:migration" json:"distance,omitempty"`
+
+	// История цены: InitialPrice - цена при первом появлении, Price - текущая
+	InitialPrice   int64      `json:"initial_price"`
+	PriceChangedAt *time.Time `json:"price_changed_at"`
+
+	// Вычисляемые поля, заполняются после загрузки из базы
+	PriceChangePercent   float64 `gorm:"-" json:"price_change_percent"`
+	DaysSincePriceChange int     `gorm:"-" json:"days_since_price_change"`
+
+	FirstSeenAt        time.Time  `gorm:"not null" json:"first_seen_at"`
+	LastSeenAt         time.Time  `gorm:"not null;index" json:"last_seen_at"`
+	IsActive           bool       `gorm:"default:true;index" json:"is_active"` // false, если объявление пропало из выдачи
+	LastParseRequestID *uuid.UUID `gorm:"type:uuid" json:"last_parse_request_id"`
+
+	// Группа дубликатов на других площадках
+	ClusterID *uuid.UUID `gorm:"type:uuid;index" json:"cluster_id"`
+	ImageHash string     `json:"-"` // перцептивные хэши первых фото через запятую
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (l *Listing) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// AfterFind заполняет вычисляемые поля истории цены
+func (l *Listing) AfterFind(tx *gorm.DB) error {
+	l.PriceChangePercent = PriceChangePercent(l.InitialPrice, l.Price)
+	l.DaysSincePriceChange = l.daysSincePriceChange(time.Now())
+	return nil
+}
+
+// daysSincePriceChange считает дни с последнего изменения цены,
+// а если цена не менялась - с первого появления объявления
+func (l *Listing) daysSincePriceChange(now time.Time) int {
+	since := l.FirstSeenAt
+	if l.PriceChangedAt != nil {
+		since = *l.PriceChangedAt
+	}
+	if since.IsZero() {
+		return 0
+	}
+	return int(now.Sub(since).Hours() / 24)
+}
+
+// PriceChangePercent возвращает изменение цены в процентах, округленное до десятых
+func PriceChangePercent(from, to int64) float64 {
+	if from <= 0 {
+		return 0
+	}
+	return math.Round(float64(to-from)/float64(from)*1000) / 10
+}
+
+// ListingPriceHistory запись об изменении цены объявления
+type ListingPriceHistory struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	ListingID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_listing_price_history_listing_observed" json:"listing_id"`
+	Price          int64      `json:"price"`
+	PreviousPrice  *int64     `json:"previous_price"` // nil для первой записи
+	Currency       string     `json:"currency"`
+	ObservedAt     time.Time  `gorm:"not null;index:idx_listing_price_history_listing_observed" json:"observed_at"`
+	ParseRequestID *uuid.UUID `gorm:"type:uuid" json:"parse_request_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (ListingPriceHistory) TableName() string {
+	return "listing_price_history"
+}
+
+func (h *ListingPriceHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
+
+// NewListingFromParsed создает объявление каталога из результата парсинга
+func NewListingFromParsed(p ParsedProperty, city string, seenAt time.Time) Listing {
+	dealType := p.DealType
+	if dealType == "" {
+		dealType = DealTypeSale
+	}
+	propertyType := p.PropertyType
+	if propertyType == "" {
+		propertyType = PropertyTypeApartment
+	}
+
+	listing := Listing{
+		Source:             p.Source,
+		ExternalID:         p.ID,
+		Title:              p.Title,
+		Description:        p.Description,
+		Price:              p.Price,
+		InitialPrice:       p.Price,
+		Currency:           p.Currency,
+		Address:            p.Address,
+		City:               city,
+		Rooms:              p.Rooms,
+		Area:               p.Area,
+		Floor:              p.Floor,
+		TotalFloors:        p.TotalFloors,
+		BuildYear:          p.BuildYear,
+		KitchenArea:        p.KitchenArea,
+		Images:             StringSlice(p.Images),
+		URL:                p.URL,
+		Phone:              p.Phone,
+		IsNewBuilding:      p.IsNewBuilding,
+		BuildingType:       p.BuildingType,
+		SellerType:         p.SellerType,
+		ResidentialComplex: p.ResidentialComplex,
+		DealType:           dealType,
+		PricePeriod:        p.PricePeriod,
+		Deposit:            p.Deposit,
+		UtilitiesIncluded:  p.UtilitiesIncluded,
+		PetsAllowed:        p.PetsAllowed,
+		PropertyType:       propertyType,
+		LandArea:           p.LandArea,
+		LandPurpose:        p.LandPurpose,
+		CommercialType:     p.CommercialType,
+		CeilingHeight:      p.CeilingHeight,
+		BathroomType:       p.BathroomType,
+		Condition:          p.Condition,
+		Parking:            p.Parking,
+		SellerName:         p.SellerName,
+		PublishedAt:        p.PublishedAt,
+		Views:              p.Views,
+		DetailFetchedAt:    p.DetailFetchedAt,
+		Latitude:           p.Latitude,
+		Longitude:          p.Longitude,
+		District:           p.District,
+		Microdistrict:      p.Microdistrict,
+		GeoPrecision:       p.GeoPrecision,
+		FirstSeenAt:        seenAt,
+		LastSeenAt:         seenAt,
+		IsActive:           true,
+	}
+	if p.GeoPrecision != "" {
+		listing.GeocodedAt = &seenAt
+	}
+	return listing
+}
+
+// ToParseProperty конвертирует Listing в ParsedProperty для совместимости
+func (l *Listing) ToParseProperty() ParsedProperty {
+	return ParsedProperty{
+		ID:                 l.ExternalID,
+		Source:             l.Source,
+		Title:              l.Title,
+		Price:              l.Price,
+		Currency:           l.Currency,
+		Address:            l.Address,
+		Rooms:              l.Rooms,
+		Area:               l.Area,
+		Floor:              l.Floor,
+		TotalFloors:        l.TotalFloors,
+		BuildYear:          l.BuildYear,
+		Images:             []string(l.Images),
+		Description:        l.Description,
+		URL:                l.URL,
+		Phone:              l.Phone,
+		IsNewBuilding:      l.IsNewBuilding,
+		BuildingType:       l.BuildingType,
+		SellerType:         l.SellerType,
+		KitchenArea:        l.KitchenArea,
+		ResidentialComplex: l.ResidentialComplex,
+		DealType:           l.DealType,
+		PricePeriod:        l.PricePeriod,
+		Deposit:            l.Deposit,
+		UtilitiesIncluded:  l.UtilitiesIncluded,
+		PetsAllowed:        l.PetsAllowed,
+		PropertyType:       l.PropertyType,
+		LandArea:           l.LandArea,
+		LandPurpose:        l.LandPurpose,
+		CommercialType:     l.CommercialType,
+		CeilingHeight:      l.CeilingHeight,
+		BathroomType:       l.BathroomType,
+		Condition:          l.Condition,
+		Parking:            l.Parking,
+		SellerName:         l.SellerName,
+		PublishedAt:        l.PublishedAt,
+		Views:              l.Views,
+		DetailFetchedAt:    l.DetailFetchedAt,
+		Latitude:           l.Latitude,
+		Longitude:          l.Longitude,
+		District:           l.District,
+		Microdistrict:      l.Microdistrict,
+		GeoPrecision:       l.GeoPrecision,
+	}
+}
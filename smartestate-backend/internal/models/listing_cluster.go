@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListingCluster группа объявлений разных источников об одном и том же объекте
+type ListingCluster struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	CanonicalListingID uuid.UUID `gorm:"type:uuid;not null" json:"canonical_listing_id"` // наиболее полное объявление группы
+	Size               int       `json:"size"`
+	Score              float64   `json:"score"` // минимальная оценка сходства среди связанных пар
+	Listings           []Listing `gorm:"foreignKey:ClusterID" json:"listings,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func (c *ListingCluster) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// PropertyCluster группа дубликатов среди результатов одного парсинга.
+// Идентификаторы соответствуют ParsedProperty.ID.
+type PropertyCluster struct {
+	CanonicalID string   `json:"canonical_id"`
+	MemberIDs   []string `json:"member_ids"` // включая CanonicalID
+	Sources     []string `json:"sources"`
+	Score       float64  `json:"score"`
+}
+
+// JSONB support для []PropertyCluster
+type PropertyClusterSlice []PropertyCluster
+
+func (p PropertyClusterSlice) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *PropertyClusterSlice) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
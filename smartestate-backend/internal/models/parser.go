@@ -5,18 +5,26 @@ import (
 	"encoding/json"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
 // PropertyFilters структура для фильтров поиска недвижимости
 type PropertyFilters struct {
-	PropertyType      string  `json:"property_type"`      // apartment, house, commercial
-	City              string  `json:"city"`               // Алматы, Астана, и т.д.
+	PropertyType      string  `json:"property_type"`      // apartment, house, land, commercial; пусто - apartment
+	CommercialType    string  `json:"commercial_type"`    // office, retail, warehouse; только для commercial
+	DealType          string  `json:"deal_type"`          // sale, rent_long, rent_daily; пусто - sale
+	City              string  `json:"city"`               // Алматы, Астана, Шымкент; см. Cities
+	District          string  `json:"district,omitempty"` // код района из справочника мест: bostandyk
 	Rooms             *int    `json:"rooms"`              // количество комнат
 	PriceMin          *int64  `json:"price_min"`          // минимальная цена
 	PriceMax          *int64  `json:"price_max"`          // максимальная цена
 	TotalAreaFrom     *int    `json:"total_area_from"`    // минимальная площадь
 	TotalAreaTo       *int    `json:"total_area_to"`      // максимальная площадь
+	KitchenAreaFrom   *int    `json:"kitchen_area_from,omitempty"` // минимальная площадь кухни
+	KitchenAreaTo     *int    `json:"kitchen_area_to,omitempty"`   // максимальная площадь кухни
+	LandAreaFrom      *int    `json:"land_area_from"`     // минимальная площадь участка, сотки
+	LandAreaTo        *int    `json:"land_area_to"`       // максимальная площадь участка, сотки
 	FloorFrom         *int    `json:"floor_from"`         // минимальный этаж
 	FloorTo           *int    `json:"floor_to"`           // максимальный этаж
 	TotalFloorsFrom   *int    `json:"total_floors_from"`  // минимальная этажность дома
@@ -26,14 +34,109 @@ type PropertyFilters struct {
 	HasPhotos         bool    `json:"has_photos"`         // только с фото
 	IsNewBuilding     bool    `json:"is_new_building"`    // новостройка
 	SellerType        string  `json:"seller_type"`        // owner, agent, developer
+	HouseType         string  `json:"house_type,omitempty"` // brick, panel, monolith, other
 	NotFirstFloor     bool    `json:"not_first_floor"`    // не первый этаж
 	NotLastFloor      bool    `json:"not_last_floor"`     // не последний этаж
-	ResidentialComplex string `json:"residential_complex"` // жилой комплекс
+	ResidentialComplex string `json:"residential_complex"` // жилой комплекс, название из справочника мест
+	PetsAllowed       bool    `json:"pets_allowed"`       // аренда: можно с животными
+	NearPOI           []POIDistanceFilter `json:"near_poi,omitempty"` // рядом со школой, метро и т.д.
+	MinWalkScore      *int    `json:"min_walk_score,omitempty"` // минимальный индекс пешей доступности
+}
+
+// Типы сделки
+const (
+	DealTypeSale      = "sale"       // продажа
+	DealTypeRentLong  = "rent_long"  // долгосрочная аренда, цена за месяц
+	DealTypeRentDaily = "rent_daily" // посуточная аренда, цена за сутки
+)
+
+// Категории недвижимости
+const (
+	PropertyTypeApartment  = "apartment"  // квартиры
+	PropertyTypeHouse      = "house"      // дома и дачи
+	PropertyTypeLand       = "land"       // участки, площадь в сотках
+	PropertyTypeCommercial = "commercial" // коммерческая недвижимость
+)
+
+// Виды коммерческой недвижимости
+const (
+	CommercialTypeOffice    = "office"    // офисы
+	CommercialTypeRetail    = "retail"    // магазины и торговые помещения
+	CommercialTypeWarehouse = "warehouse" // склады и производственные базы
+)
+
+// Период, за который указана цена аренды
+const (
+	PricePeriodMonth = "month"
+	PricePeriodDay   = "day"
+)
+
+// NormalizeDealType приводит тип сделки к одной из констант DealType*.
+// Пустое значение означает продажу, для неизвестного возвращается false.
+func NormalizeDealType(dealType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(dealType)) {
+	case "", DealTypeSale:
+		return DealTypeSale, true
+	case DealTypeRentLong, "rent":
+		return DealTypeRentLong, true
+	case DealTypeRentDaily:
+		return DealTypeRentDaily, true
+	}
+	return "", false
+}
+
+// NormalizePropertyType приводит категорию к одной из констант PropertyType*.
+// Пустое значение означает квартиры, для неизвестного возвращается false.
+func NormalizePropertyType(propertyType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(propertyType)) {
+	case "", PropertyTypeApartment, "flat":
+		return PropertyTypeApartment, true
+	case PropertyTypeHouse, "dacha", "cottage":
+		return PropertyTypeHouse, true
+	case PropertyTypeLand, "plot":
+		return PropertyTypeLand, true
+	case PropertyTypeCommercial:
+		return PropertyTypeCommercial, true
+	}
+	return "", false
+}
+
+// NormalizeCommercialType приводит вид коммерческой недвижимости к одной из
+// констант CommercialType*. Пустое значение допустимо и означает любой вид.
+func NormalizeCommercialType(commercialType string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(commercialType)) {
+	case "":
+		return "", true
+	case CommercialTypeOffice:
+		return CommercialTypeOffice, true
+	case CommercialTypeRetail, "shop":
+		return CommercialTypeRetail, true
+	case CommercialTypeWarehouse, "industrial":
+		return CommercialTypeWarehouse, true
+	}
+	return "", false
+}
+
+// IsRent сообщает, что тип сделки - аренда
+func IsRent(dealType string) bool {
+	return dealType == DealTypeRentLong || dealType == DealTypeRentDaily
+}
+
+// DefaultPricePeriod возвращает период цены для типа сделки, для продажи пусто
+func DefaultPricePeriod(dealType string) string {
+	switch dealType {
+	case DealTypeRentLong:
+		return PricePeriodMonth
+	case DealTypeRentDaily:
+		return PricePeriodDay
+	}
+	return ""
 }
 
 // ParsedProperty структура для спарсенной недвижимости
 type ParsedProperty struct {
 	ID                 string  `json:"id"`
+	Source             string  `json:"source"` // krisha, olx
 	Title              string  `json:"title"`
 	Price              int64   `json:"price"`
 	Currency           string  `json:"currency"`
@@ -52,32 +155,101 @@ type ParsedProperty struct {
 	SellerType         string  `json:"seller_type"`
 	KitchenArea        *float64 `json:"kitchen_area"`
 	ResidentialComplex string  `json:"residential_complex"`
+
+	// Аренда
+	DealType          string  `json:"deal_type,omitempty"`          // sale, rent_long, rent_daily
+	PricePeriod       string  `json:"price_period,omitempty"`       // month, day; пусто для продажи
+	Deposit           *int64  `json:"deposit,omitempty"`            // залог
+	UtilitiesIncluded *bool   `json:"utilities_included,omitempty"` // коммунальные услуги включены в цену
+	PetsAllowed       *bool   `json:"pets_allowed,omitempty"`       // можно с животными
+
+	// Дома, участки и коммерческая недвижимость
+	PropertyType   string   `json:"property_type,omitempty"`   // apartment, house, land, commercial
+	LandArea       *float64 `json:"land_area,omitempty"`       // площадь участка, сотки
+	LandPurpose    string   `json:"land_purpose,omitempty"`    // назначение участка: ИЖС, ЛПХ, под коммерцию
+	CommercialType string   `json:"commercial_type,omitempty"` // office, retail, warehouse
+
+	// Со страницы объявления, заполняются на этапе обогащения
+	CeilingHeight   *float64   `json:"ceiling_height,omitempty"`    // высота потолков, м
+	BathroomType    string     `json:"bathroom_type,omitempty"`     // санузел: раздельный, совмещенный
+	Condition       string     `json:"condition,omitempty"`         // состояние: евроремонт, черновая отделка
+	Parking         string     `json:"parking,omitempty"`           // паркинг, гараж, рядом охраняемая стоянка
+	SellerName      string     `json:"seller_name,omitempty"`       // имя продавца или название агентства
+	PublishedAt     *time.Time `json:"published_at,omitempty"`      // дата публикации на площадке
+	Views           *int       `json:"views,omitempty"`             // счетчик просмотров на площадке
+	DetailFetchedAt *time.Time `json:"detail_fetched_at,omitempty"` // когда загружена страница объявления
+
+	// Местоположение: координаты с площадки или найденные геокодером по адресу
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	District      string   `json:"district,omitempty"`      // район города
+	Microdistrict string   `json:"microdistrict,omitempty"` // микрорайон
+	GeoPrecision  string   `json:"geo_precision,omitempty"` // exact, street, microdistrict, district, city
+
+	// Окружение: ближайшие школы, метро, парки и индекс пешей доступности 0-100
+	Nearby    []NearbyPOI `json:"nearby,omitempty"`
+	WalkScore *int        `json:"walk_score,omitempty"`
 }
 
 // ParseRequest структура для запроса парсинга
 type ParseRequest struct {
-	ID       uuid.UUID           `gorm:"type:uuid;primary_key" json:"id"`
-	UserID   *uuid.UUID          `gorm:"type:uuid" json:"user_id"`
-	Filters  PropertyFilters     `gorm:"type:jsonb" json:"filters"`
-	MaxPages int                 `json:"max_pages"`
-	Status   string              `gorm:"default:'pending'" json:"status"` // pending, processing, completed, failed
-	Results  ParsedPropertySlice `gorm:"type:jsonb" json:"results"`
-	Count    int                 `json:"count"`
-	Error    string              `json:"error"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
+	ID         uuid.UUID            `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     *uuid.UUID           `gorm:"type:uuid" json:"user_id"`
+	ScheduleID *uuid.UUID           `gorm:"type:uuid;index" json:"schedule_id"` // запуск по расписанию CrawlSchedule
+	Filters    PropertyFilters      `gorm:"type:jsonb" json:"filters"`
+	Sources    StringSlice          `gorm:"type:jsonb" json:"sources"`
+	MaxPages   int                  `json:"max_pages"`
+	Status     string               `gorm:"default:'pending';index" json:"status"` // pending, processing, completed, failed, cancelled
+	Results    ParsedPropertySlice  `gorm:"type:jsonb" json:"results"`
+	Clusters   PropertyClusterSlice `gorm:"type:jsonb" json:"clusters"` // группы дубликатов между источниками
+	Count      int                  `json:"count"`
+	Error      string               `json:"error"`
+
+	// Фильтры, которые источники не смогли учесть
+	UnsupportedFilters UnsupportedFilters `gorm:"type:jsonb" json:"unsupported_filters,omitempty"`
+
+	// Прогресс выполнения
+	PagesTotal  int        `json:"pages_total"`
+	PagesDone   int        `json:"pages_done"`
+	Attempts    int        `json:"attempts"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	HeartbeatAt *time.Time `json:"heartbeat_at"` // обновляется воркером, по нему находим зависшие задачи
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Статусы запроса парсинга
+const (
+	ParseStatusPending    = "pending"
+	ParseStatusProcessing = "processing"
+	ParseStatusCompleted  = "completed"
+	ParseStatusFailed     = "failed"
+	ParseStatusCancelled  = "cancelled"
+)
+
+// IsFinished сообщает, что запрос парсинга завершен и больше не изменится
+func (r *ParseRequest) IsFinished() bool {
+	switch r.Status {
+	case ParseStatusCompleted, ParseStatusFailed, ParseStatusCancelled:
+		return true
+	}
+	return false
 }
 
 // ParseResponse структура для ответа на запрос парсинга
 type ParseResponse struct {
-	Success    bool             `json:"success"`
-	RequestID  uuid.UUID        `json:"request_id"`
-	Properties []ParsedProperty `json:"properties"`
-	Count      int              `json:"count"`
-	Status     string           `json:"status"`
-	Error      string           `json:"error,omitempty"`
-	Cached     bool             `json:"cached"`
-	ParserType string           `json:"parser_type"` // selenium, http
+	Success    bool                 `json:"success"`
+	RequestID  uuid.UUID            `json:"request_id"`
+	Properties []ParsedProperty     `json:"properties"`
+	Count      int                  `json:"count"`
+	Status     string               `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	Cached     bool                 `json:"cached"`
+	ParserType string               `json:"parser_type"`        // имена источников через запятую: krisha,olx
+	Clusters   PropertyClusterSlice `json:"clusters,omitempty"` // группы дубликатов между источниками
+	UnsupportedFilters UnsupportedFilters `json:"unsupported_filters,omitempty"` // фильтры, которые источники не учли
 }
 
 func (r *ParseRequest) BeforeCreate(db *gorm.DB) error {
@@ -111,4 +283,21 @@ func (p *ParsedPropertySlice) Scan(value interface{}) error {
 		return nil
 	}
 	return json.Unmarshal(bytes, p)
-}
\ No newline at end of file
+}
+// JSONB support для []string
+type StringSlice []string
+
+func (s StringSlice) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *StringSlice) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
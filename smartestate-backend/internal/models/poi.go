@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Категории точек интереса
+const (
+	POICategorySchool       = "school"       // школы
+	POICategoryKindergarten = "kindergarten" // детские сады
+	POICategoryMetro        = "metro"        // станции метро
+	POICategoryBusStop      = "bus_stop"     // остановки общественного транспорта
+	POICategoryPark         = "park"         // парки и скверы
+	POICategoryMall         = "mall"         // торговые центры
+	POICategorySupermarket  = "supermarket"  // супермаркеты
+	POICategoryHospital     = "hospital"     // больницы и поликлиники
+	POICategoryPharmacy     = "pharmacy"     // аптеки
+)
+
+// POICategories все категории точек интереса в порядке показа
+var POICategories = []string{
+	POICategorySchool, POICategoryKindergarten, POICategoryMetro, POICategoryBusStop,
+	POICategoryPark, POICategoryMall, POICategorySupermarket, POICategoryHospital, POICategoryPharmacy,
+}
+
+// IsPOICategory проверяет, что категория точки интереса известна
+func IsPOICategory(category string) bool {
+	for _, known := range POICategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
+
+// POI точка интереса из выгрузки OpenStreetMap. ID - тип и номер объекта OSM,
+// например node/123 или way/456, поэтому повторный импорт обновляет записи.
+type POI struct {
+	ID         string    `gorm:"primaryKey;size:32" json:"id"`
+	Category   string    `gorm:"size:32;index" json:"category"`
+	Name       string    `json:"name"`
+	Latitude   float64   `gorm:"index:idx_pois_lat_lon" json:"latitude"`
+	Longitude  float64   `gorm:"index:idx_pois_lat_lon" json:"longitude"`
+	ImportedAt time.Time `gorm:"index" json:"imported_at"`
+}
+
+func (POI) TableName() string {
+	return "pois"
+}
+
+// NearbyPOI ближайшая к объекту точка интереса своей категории
+type NearbyPOI struct {
+	Category  string `json:"category"`
+	Name      string `json:"name,omitempty"`
+	DistanceM int    `json:"distance_m"`
+}
+
+// ListingPOIDistance расстояние от объявления каталога до ближайшей точки
+// интереса категории. Хранится отдельной строкой на категорию, чтобы фильтр
+// "не дальше 500 м от школы" работал по индексу.
+type ListingPOIDistance struct {
+	ListingID uuid.UUID `gorm:"type:uuid;primaryKey" json:"listing_id"`
+	Category  string    `gorm:"primaryKey;size:32;index:idx_listing_poi_category_distance,priority:1" json:"category"`
+	DistanceM int       `gorm:"index:idx_listing_poi_category_distance,priority:2" json:"distance_m"`
+	POIID     string    `gorm:"size:32" json:"poi_id"`
+	POIName   string    `json:"poi_name"`
+}
+
+// POIDistanceFilter условие поиска: не дальше WithinM метров от точки категории Category
+type POIDistanceFilter struct {
+	Category string `json:"category"`
+	WithinM  int    `json:"within_m"`
+}
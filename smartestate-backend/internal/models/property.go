@@ -29,6 +29,9 @@ type Property struct {
 	Views        []PropertyView `gorm:"foreignKey:PropertyID" json:"views,omitempty"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
+
+	// Distance расстояние до точки поиска по карте в метрах, только при поиске с центром
+	Distance *float64 `gorm:"->;-:migration" json:"distance,omitempty"`
 }
 
 type Address struct {
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SourceHealthRun метрики одного прохода парсера по площадке. По ним видно,
+// что площадка сменила верстку: карточек на странице стало меньше или в них
+// перестали находиться цена, площадь, комнаты, фото.
+type SourceHealthRun struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Source         string     `gorm:"size:50;not null;index:idx_source_health_source_created" json:"source"`
+	ParseRequestID *uuid.UUID `gorm:"type:uuid;index" json:"parse_request_id"`
+
+	// Страницы поиска
+	Pages        int     `json:"pages"`          // загруженных страниц
+	PagesFailed  int     `json:"pages_failed"`   // страниц, которые не удалось загрузить
+	Items        int     `json:"items"`          // карточек на загруженных страницах до фильтрации
+	ItemsPerPage float64 `json:"items_per_page"` // в среднем карточек на странице
+	ErrorRate    float64 `json:"error_rate"`     // доля страниц с ошибкой, 0..1
+
+	// Доля карточек с заполненным полем, 0..1. Nil, если в выдаче не было
+	// объявлений, для которых поле имеет смысл (комнаты у участков)
+	FillPrice  *float64 `json:"fill_price"`
+	FillArea   *float64 `json:"fill_area"`
+	FillRooms  *float64 `json:"fill_rooms"`
+	FillImages *float64 `json:"fill_images"`
+
+	Degraded bool        `gorm:"index" json:"degraded"`
+	Reasons  StringSlice `gorm:"type:jsonb" json:"reasons"` // какие пороги нарушены
+
+	CreatedAt time.Time `gorm:"index:idx_source_health_source_created" json:"created_at"`
+}
+
+func (r *SourceHealthRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
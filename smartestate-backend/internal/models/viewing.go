@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Статусы записи на просмотр
+const (
+	ViewingStatusScheduled = "scheduled"
+	ViewingStatusCancelled = "cancelled"
+)
+
+// Viewing запись пользователя на просмотр объекта. Объект - объявление
+// каталога (ListingID) или ссылка на объявление, если его нет в каталоге.
+type Viewing struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ListingID   *uuid.UUID `gorm:"type:uuid;index" json:"listing_id,omitempty"`
+	ListingURL  string     `json:"listing_url,omitempty"`
+	Address     string     `json:"address,omitempty"`
+	ScheduledAt time.Time  `gorm:"not null;index" json:"scheduled_at"`
+	Phone       string     `json:"phone,omitempty"` // телефон для связи с пользователем
+	Note        string     `json:"note,omitempty"`
+	Status      string     `gorm:"default:'scheduled'" json:"status"` // scheduled, cancelled
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (v *Viewing) BeforeCreate(tx *gorm.DB) error {
+	v.ID = uuid.New()
+	return nil
+}
@@ -2,21 +2,20 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"github.com/sashabaranov/go-openai"
 	"smartestate/internal/config"
 	"smartestate/internal/models"
 	"strings"
+	"time"
 )
 
 type AIService struct {
-	client             *openai.Client
+	llm                *LLMRegistry
+	tools              *ChatToolRegistry
 	config             *config.Config
 	parserService      *ParserService
 	chatService        *ChatService
@@ -24,24 +23,23 @@ type AIService struct {
 }
 
 func NewAIService(cfg *config.Config) *AIService {
-	client := openai.NewClient(cfg.AI.OpenAIKey)
-	return &AIService{
-		client: client,
+	s := &AIService{
+		llm:    NewLLMRegistry(cfg.AI),
+		tools:  NewChatToolRegistry(),
 		config: cfg,
 	}
+	s.tools.Register(s.chatTools()...)
+	return s
 }
 
-func (s *AIService) isAPIKeyConfigured() bool {
-	switch s.config.AI.Provider {
-	case "openai":
-		return s.config.AI.OpenAIKey != ""
-	case "gemini":
-		return s.config.AI.GeminiKey != ""
-	case "claude":
-		return s.config.AI.AnthropicKey != ""
-	default:
-		return false
-	}
+// LLM возвращает провайдеров LLM, например чтобы подключить FakeLLM в тестах
+func (s *AIService) LLM() *LLMRegistry {
+	return s.llm
+}
+
+// Tools возвращает инструменты ассистента, сервисы добавляют в него свои
+func (s *AIService) Tools() *ChatToolRegistry {
+	return s.tools
 }
 
 // SetParserService устанавливает парсер сервис для AI
@@ -64,167 +62,8 @@ type AIResponse struct {
 	Metadata models.MessageMetadata `json:"metadata"`
 }
 
-// Gemini API structures
-type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
-}
-
-type GeminiContent struct {
-	Parts []GeminiPart `json:"parts"`
-}
-
-type GeminiPart struct {
-	Text string `json:"text"`
-}
-
-type GeminiResponse struct {
-	Candidates []GeminiCandidate `json:"candidates"`
-}
-
-type GeminiCandidate struct {
-	Content GeminiContent `json:"content"`
-}
-
-func (s *AIService) callGeminiAPI(prompt string) (string, error) {
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash-latest:generateContent?key=%s", s.config.AI.GeminiKey)
-	
-	reqData := GeminiRequest{
-		Contents: []GeminiContent{
-			{
-				Parts: []GeminiPart{
-					{Text: prompt},
-				},
-			},
-		},
-	}
-	
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return "", err
-	}
-	
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Gemini API error: %s", string(body))
-	}
-	
-	var geminiResp GeminiResponse
-	if err := json.Unmarshal(body, &geminiResp); err != nil {
-		return "", err
-	}
-	
-	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
-	}
-	
-	return "", fmt.Errorf("no response from Gemini")
-}
-
-func (s *AIService) processWithGemini(sessionID, content, systemPrompt string) (*AIResponse, error) {
-	// Проверяем, является ли это подтверждением для парсинга
-	confirmationWords := []string{"да", "согласен", "подтверждаю", "запускай", "давай", "окей", "ок", "старт"}
-	userContentLower := strings.ToLower(content)
-	
-	hasConfirmation := false
-	for _, word := range confirmationWords {
-		if strings.Contains(userContentLower, word) {
-			hasConfirmation = true
-			break
-		}
-	}
-	
-	if hasConfirmation {
-		// Пользователь дал подтверждение - пытаемся извлечь параметры из истории сообщений
-		if s.chatService != nil {
-			session, err := s.chatService.GetSession(sessionID)
-			if err == nil && session != nil && len(session.Messages) > 0 {
-				// Ищем последние параметры поиска в истории
-				for i := len(session.Messages) - 1; i >= 0; i-- {
-					msg := session.Messages[i]
-					if msg.Role == "user" {
-						filters := s.extractSearchParams(msg.Content)
-						if filters.City != "" {
-							// Нашли параметры поиска - вызываем парсинг
-							return s.handleParsePropertiesWithParams(sessionID, filters, content)
-						}
-					}
-				}
-			}
-		}
-	}
-	
-	// Обычный чат с Gemini
-	fullPrompt := systemPrompt + "\n\nПользователь: " + content
-	aiResponse, err := s.callGeminiAPI(fullPrompt)
-	if err != nil {
-		return nil, err
-	}
-	
-	metadata := s.extractMetadata(content, aiResponse)
-	return &AIResponse{
-		Content:  aiResponse,
-		Metadata: metadata,
-	}, nil
-}
-
-func (s *AIService) extractSearchParams(content string) models.PropertyFilters {
-	var filters models.PropertyFilters
-	contentLower := strings.ToLower(content)
-	
-	// Извлекаем город
-	cities := []string{"алматы", "нур-султан", "астана", "шымкент"}
-	for _, city := range cities {
-		if strings.Contains(contentLower, city) {
-			if city == "нур-султан" || city == "астана" {
-				filters.City = "Нур-Sultan"
-			} else if city == "алматы" {
-				filters.City = "Алматы"
-			} else if city == "шымкент" {
-				filters.City = "Шымкент"
-			}
-			break
-		}
-	}
-	
-	// Извлекаем количество комнат
-	if strings.Contains(contentLower, "1-комн") || strings.Contains(contentLower, "1 комн") || strings.Contains(contentLower, "однокомн") {
-		rooms := 1
-		filters.Rooms = &rooms
-	} else if strings.Contains(contentLower, "2-комн") || strings.Contains(contentLower, "2 комн") || strings.Contains(contentLower, "двухкомн") {
-		rooms := 2
-		filters.Rooms = &rooms
-	} else if strings.Contains(contentLower, "3-комн") || strings.Contains(contentLower, "3 комн") || strings.Contains(contentLower, "трехкомн") {
-		rooms := 3
-		filters.Rooms = &rooms
-	}
-	
-	// Извлекаем бюджет
-	if strings.Contains(contentLower, "40 млн") || strings.Contains(contentLower, "40млн") {
-		price := int64(40000000)
-		filters.PriceMax = &price
-	} else if strings.Contains(contentLower, "30 млн") || strings.Contains(contentLower, "30млн") {
-		price := int64(30000000)
-		filters.PriceMax = &price
-	} else if strings.Contains(contentLower, "50 млн") || strings.Contains(contentLower, "50млн") {
-		price := int64(50000000)
-		filters.PriceMax = &price
-	}
-	
-	filters.PropertyType = "apartment"
-	return filters
-}
-
-func (s *AIService) handleParsePropertiesWithParams(sessionID string, filters models.PropertyFilters, userContent string) (*AIResponse, error) {
+// searchWithKrishaFilters ищет на krisha.kz по готовым фильтрам и форматирует ответ
+func (s *AIService) searchWithKrishaFilters(filters models.PropertyFilters, krishaFilters KrishaFilters) (*AIResponse, error) {
 	if s.krishaFilterService == nil {
 		return &AIResponse{
 			Content: "Извините, сервис парсинга временно недоступен. Попробуйте позже.",
@@ -236,9 +75,6 @@ func (s *AIService) handleParsePropertiesWithParams(sessionID string, filters mo
 		}, nil
 	}
 
-	// Convert to KrishaFilters format
-	krishaFilters := s.convertToKrishaFilters(filters)
-	
 	// Call KrishaFilterService
 	krishaResult, err := s.krishaFilterService.ParseWithFilters(krishaFilters)
 	if err != nil {
@@ -252,6 +88,13 @@ func (s *AIService) handleParsePropertiesWithParams(sessionID string, filters mo
 		}, nil
 	}
 
+	// Окружение считаем сами: krisha.kz не ищет "рядом со школой"
+	if s.parserService != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		krishaResult.Properties = s.parserService.ApplyProximity(ctx, filters.City, krishaResult.Properties, filters)
+		cancel()
+	}
+
 	// Format response based on parsing results
 	if len(krishaResult.Properties) == 0 {
 		content := "К сожалению, по вашим критериям ничего не найдено. Попробуйте расширить параметры поиска или изменить город."
@@ -285,24 +128,24 @@ func (s *AIService) handleParsePropertiesWithParams(sessionID string, filters mo
 				"filters_used": krishaFilters,
 				"total_found":  len(krishaResult.Properties),
 				"properties":   krishaResult.Properties,
+				"unsupported_filters": krishaResult.Unsupported,
 			},
 		},
 	}, nil
 }
 
-func (s *AIService) ProcessChatMessage(sessionID, content string) (*AIResponse, error) {
-	// Проверяем наличие API ключа
-	if !s.isAPIKeyConfigured() {
-		return &AIResponse{
-			Content: fmt.Sprintf("❌ AI API ключ для провайдера '%s' не настроен. Пожалуйста, добавьте соответствующий ключ в файл .env", s.config.AI.Provider),
-			Metadata: models.MessageMetadata{
-				Actions:    []string{"configuration_error"},
-				Confidence: 1.0,
-				Extra:      map[string]interface{}{"error": "ai_api_key_missing", "provider": s.config.AI.Provider},
-			},
-		}, nil
-	}
-	systemPrompt := `You are SmartEstate AI assistant, helping users find and manage real estate in Kazakhstan. 
+// chatMaxToolSteps сколько раз подряд модель может вызывать инструменты до
+// ответа пользователю. Защищает от зацикливания модели на инструментах.
+const chatMaxToolSteps = 5
+
+// ChatOptions параметры обработки сообщения чата
+type ChatOptions struct {
+	// Provider провайдер LLM для этого сообщения. Пусто - по AI_FEATURE_PROVIDERS и AI_PROVIDER.
+	Provider string
+}
+
+// chatSystemPrompt системный промпт ассистента в чате
+const chatSystemPrompt = `You are SmartEstate AI assistant, helping users find and manage real estate in Kazakhstan. 
 
 	CRITICAL RULE - NEVER call parse_properties function without EXPLICIT final confirmation!
 
@@ -316,10 +159,14 @@ func (s *AIService) ProcessChatMessage(sessionID, content string) (*AIResponse,
 
 	You can help with:
 	- Finding properties (only after confirmation)
-	- Calculating mortgage payments
-	- Property valuation
-	- Scheduling viewings
-	- Market analysis
+	- Finding rentals: long-term with monthly price (deal_type rent_long) or daily (deal_type rent_daily). For rentals price_min/price_max are per month or per day
+	- Finding houses and dachas (property_type house), land plots with area in sotkas (property_type land) and commercial premises: offices, retail, warehouses (property_type commercial with commercial_type)
+	- Calculating mortgage payments (calculate_mortgage)
+	- Property valuation by comparable listings (estimate_price)
+	- Scheduling viewings (schedule_viewing, list_viewings): ask for the listing and the exact date and time first
+	- Market analysis: prices, price per m² and its change, supply (market_analysis)
+
+	Use the tools for any numbers instead of estimating them yourself. Only parse_properties needs the final confirmation.
 	
 	Always respond in Russian or Kazakh based on user's language.
 	
@@ -337,146 +184,203 @@ func (s *AIService) ProcessChatMessage(sessionID, content string) (*AIResponse,
 	
 	NEVER call parse_properties without final user confirmation!`
 
-	// Определяем доступные функции
-	functions := []openai.FunctionDefinition{
-		{
-			Name:        "parse_properties",
-			Description: "Парсит недвижимость с сайта krisha.kz по заданным фильтрам. КРИТИЧЕСКИ ВАЖНО: Используй эту функцию ТОЛЬКО после ЯВНОГО подтверждения пользователем типа 'да, ищи', 'согласен', 'подтверждаю'. НИКОГДА не вызывай без финального подтверждения!",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"city": map[string]interface{}{
-						"type":        "string",
-						"description": "Город поиска (Алматы, Нур-Султан, Шымкент)",
-						"enum":        []string{"Алматы", "Нур-Sultan", "Шымкент"},
-					},
-					"property_type": map[string]interface{}{
-						"type":        "string", 
-						"description": "Тип недвижимости",
-						"enum":        []string{"apartment", "house", "commercial"},
-					},
-					"rooms": map[string]interface{}{
-						"type":        "integer",
-						"description": "Количество комнат",
-						"minimum":     1,
-						"maximum":     10,
-					},
-					"price_min": map[string]interface{}{
-						"type":        "integer",
-						"description": "Минимальная цена в тенге",
-					},
-					"price_max": map[string]interface{}{
-						"type":        "integer", 
-						"description": "Максимальная цена в тенге",
-					},
-					"total_area_from": map[string]interface{}{
-						"type":        "integer",
-						"description": "Минимальная площадь в м²",
-					},
-					"total_area_to": map[string]interface{}{
-						"type":        "integer",
-						"description": "Максимальная площадь в м²",
-					},
-					"has_photos": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Только с фотографиями",
-					},
-					"is_new_building": map[string]interface{}{
-						"type":        "boolean", 
-						"description": "Только новостройки",
-					},
-					"seller_type": map[string]interface{}{
-						"type":        "string",
-						"description": "Тип продавца",
-						"enum":        []string{"owner", "agent", "developer"},
-					},
-				},
-				"required": []string{"city"},
-			},
-		},
+func (s *AIService) ProcessChatMessage(ctx context.Context, sessionID, content string, options ChatOptions) (*AIResponse, error) {
+	return s.processChatMessage(ctx, sessionID, content, options, func(ProgressInfo) {}, nil)
+}
+
+// ProcessChatMessageStream отвечает на сообщение и передает текст ответа в onDelta
+// по мере генерации. Если модель вызывала инструменты, в onDelta попадает и текст
+// промежуточных шагов, поэтому окончательный текст - Content результата. Каждый
+// вызов инструмента предваряется этапом progress tool_call.
+// При отмене ctx возвращает ошибку ctx, запрос к провайдеру прерывается.
+func (s *AIService) ProcessChatMessageStream(ctx context.Context, sessionID, content string, options ChatOptions, progress func(ProgressInfo), onDelta func(string)) (*AIResponse, error) {
+	return s.processChatMessage(ctx, sessionID, content, options, progress, onDelta)
+}
+
+// processChatMessage отвечает на сообщение: ссылку на поиск обрабатывает сама,
+// остальное передает модели с инструментами. progress получает этапы обработки,
+// onDelta, если задан, - текст ответа модели по мере генерации.
+func (s *AIService) processChatMessage(ctx context.Context, sessionID, content string, options ChatOptions, progress func(ProgressInfo), onDelta func(string)) (*AIResponse, error) {
+	// По ссылке на поиск krisha.kz или olx.kz ищем сразу, без AI
+	if imported := detectSearchURL(content); imported != nil {
+		progress(ProgressInfo{
+			Step:        "parsing_start",
+			Current:     3,
+			Total:       4,
+			Percentage:  75,
+			Description: "🔗 Ищу по вашей ссылке...",
+		})
+		return s.handleImportedSearch(imported)
 	}
 
-	// Получаем историю сообщений для контекста
-	var messages []openai.ChatCompletionMessage
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: systemPrompt,
+	progress(ProgressInfo{
+		Step:        "ai_analysis",
+		Current:     1,
+		Total:       4,
+		Percentage:  25,
+		Description: "🤖 Анализирую ваш запрос...",
 	})
 
-	// Добавляем историю сообщений если ChatService доступен
+	provider, err := s.llm.Provider(LLMFeatureChat, options.Provider)
+	if err != nil {
+		name := s.llm.ProviderName(LLMFeatureChat, options.Provider)
+		return &AIResponse{
+			Content: fmt.Sprintf("❌ AI API ключ для провайдера '%s' не настроен. Пожалуйста, добавьте соответствующий ключ в файл .env", name),
+			Metadata: models.MessageMetadata{
+				Actions:    []string{"configuration_error"},
+				Confidence: 1.0,
+				Extra:      map[string]interface{}{"error": "ai_api_key_missing", "provider": name},
+			},
+		}, nil
+	}
+
+	var session *models.ChatSession
+	env := ChatToolEnv{SessionID: sessionID, UserMessage: content, Progress: progress}
 	if s.chatService != nil {
-		session, err := s.chatService.GetSession(sessionID)
-		if err == nil && session != nil {
-			// Добавляем все сообщения из истории (кроме последнего - его добавим отдельно)
-			for _, msg := range session.Messages {
-				var role string
-				switch msg.Role {
-				case "user":
-					role = openai.ChatMessageRoleUser
-				case "assistant":
-					role = openai.ChatMessageRoleAssistant
-				default:
-					continue
-				}
-				messages = append(messages, openai.ChatCompletionMessage{
-					Role:    role,
-					Content: msg.Content,
-				})
-			}
+		if found, err := s.chatService.GetSession(sessionID); err == nil {
+			session = found
+			env.UserID = found.UserID
 		}
 	}
 
-	// Добавляем текущее сообщение пользователя
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: content,
-	})
+	conversation := s.buildChatContext(ctx, session, content)
+	request := LLMRequest{
+		System:   conversation.System + "\n\nCurrent date and time: " + time.Now().Format(time.RFC3339),
+		Messages: conversation.Messages,
+		Tools:    s.tools.Tools(),
+	}
 
-	// Выбираем провайдера AI
-	var aiResponse string
-	var err error
-	
-	switch s.config.AI.Provider {
-	case "gemini":
-		// Для Gemini используем специальную логику с парсингом намерений
-		return s.processWithGemini(sessionID, content, systemPrompt)
-	case "openai":
-	default:
-		// OpenAI как fallback
-		resp, openaiErr := s.client.CreateChatCompletion(
-			context.Background(),
-			openai.ChatCompletionRequest{
-				Model:        openai.GPT4,
-				Messages:     messages,
-				Functions:    functions,
-				FunctionCall: "auto",
-			},
-		)
-		
-		if openaiErr != nil {
-			err = openaiErr
+	var usage LLMUsage
+	var records []models.ToolCallRecord
+	var actions []string
+	var toolResponse *AIResponse
+	var llmResponse *LLMResponse
+	for step := 1; toolResponse == nil; step++ {
+		if step > chatMaxToolSteps {
+			// Вызывать инструменты больше нельзя: модель отвечает тем, что уже узнала
+			request.NoToolCalls = true
+		}
+		if onDelta != nil {
+			llmResponse, err = provider.Stream(ctx, request, onDelta)
 		} else {
-			message := resp.Choices[0].Message
-			
-			// Проверяем, вызывает ли ИИ функцию
-			if message.FunctionCall != nil {
-				return s.handleFunctionCall(sessionID, message, content)
+			llmResponse, err = provider.Complete(ctx, request)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// Провайдеры по-разному оборачивают отмену, вызывающему нужна ошибка ctx
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		usage.Add(llmResponse.Usage)
+		if len(llmResponse.ToolCalls) == 0 || request.NoToolCalls {
+			break
+		}
+
+		request.Messages = append(request.Messages, LLMMessage{Role: LLMRoleAssistant, Content: llmResponse.Content, ToolCalls: llmResponse.ToolCalls})
+		for _, call := range llmResponse.ToolCalls {
+			progress(ProgressInfo{
+				Step:        "tool_call",
+				Current:     2,
+				Total:       4,
+				Percentage:  50,
+				Description: "🛠 " + call.Name + "...",
+			})
+			result, record := s.executeChatTool(ctx, env, call, step)
+			records = append(records, record)
+			if result != nil {
+				actions = append(actions, result.Actions...)
+				if result.Response != nil {
+					toolResponse = result.Response
+				}
 			}
-			
-			aiResponse = message.Content
+			request.Messages = append(request.Messages, LLMMessage{Role: LLMRoleTool, Content: toolResultContent(record), ToolCallID: call.ID, ToolName: call.Name})
 		}
 	}
-	
+	llmResponse.Usage = usage
+	s.rememberSearchPreferences(session, records)
+
+	response := toolResponse
+	if response == nil {
+		progress(ProgressInfo{
+			Step:        "completed",
+			Current:     4,
+			Total:       4,
+			Percentage:  100,
+			Description: "✅ Готово!",
+		})
+		answer := llmResponse.Content
+		if strings.TrimSpace(answer) == "" {
+			answer = "Не удалось подготовить ответ. Попробуйте переформулировать вопрос."
+		}
+		response = &AIResponse{
+			Content:  answer,
+			Metadata: s.extractMetadata(content, answer),
+		}
+	}
+	response.Metadata.Actions = append(response.Metadata.Actions, actions...)
+	response.Metadata.ToolCalls = records
+
+	setLLMMetadata(&response.Metadata, llmResponse)
+	return response, nil
+}
+
+// executeChatTool выполняет вызов инструмента и записывает его для метаданных
+// ответа. Ошибка инструмента не прерывает ответ: ее получает модель.
+func (s *AIService) executeChatTool(ctx context.Context, env ChatToolEnv, call LLMToolCall, step int) (*ChatToolResult, models.ToolCallRecord) {
+	record := models.ToolCallRecord{
+		ID:        call.ID,
+		Name:      call.Name,
+		Arguments: rawJSON(jsonObjectOrEmpty(call.Arguments)),
+		Step:      step,
+	}
+
+	result, err := s.tools.Execute(ctx, env, call)
 	if err != nil {
-		return nil, err
+		log.Printf("⚠️ AI Service: инструмент %s: %v", call.Name, err)
+		record.Error = err.Error()
+		if errors.Is(err, ErrChatToolNotConfirmed) {
+			return &ChatToolResult{Actions: []string{"waiting_confirmation"}}, record
+		}
+		return nil, record
 	}
 
-	metadata := s.extractMetadata(content, aiResponse)
+	if data, err := json.Marshal(result.Data); err == nil {
+		record.Result = data
+	}
+	return result, record
+}
 
-	return &AIResponse{
-		Content:  aiResponse,
-		Metadata: metadata,
-	}, nil
+// toolResultContent результат вызова для модели: данные или ошибка
+func toolResultContent(record models.ToolCallRecord) string {
+	if record.Error != "" {
+		content, _ := json.Marshal(map[string]string{"error": record.Error})
+		return string(content)
+	}
+	if len(record.Result) == 0 {
+		return "{}"
+	}
+	return string(record.Result)
+}
+
+// rawJSON возвращает JSON как есть или, если модель прислала невалидный JSON,
+// строкой, чтобы метаданные сообщения можно было сохранить
+func rawJSON(value string) json.RawMessage {
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	quoted, _ := json.Marshal(value)
+	return quoted
+}
+
+// setLLMMetadata сохраняет в метаданных ответа провайдера, модель и расход токенов
+func setLLMMetadata(metadata *models.MessageMetadata, llmResponse *LLMResponse) {
+	if metadata.Extra == nil {
+		metadata.Extra = make(map[string]interface{})
+	}
+	metadata.Extra["provider"] = llmResponse.Provider
+	metadata.Extra["model"] = llmResponse.Model
+	metadata.Extra["usage"] = llmResponse.Usage
 }
 
 func (s *AIService) GenerateAdCreatives(propertyID string, platforms []string) ([]models.Creative, error) {
@@ -504,24 +408,19 @@ func (s *AIService) GeneratePropertyDescription(property *models.Property) (stri
 		property.Address.Street,
 	)
 
-	resp, err := s.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-		},
-	)
+	provider, err := s.llm.Provider(LLMFeatureDescription, "")
+	if err != nil {
+		return "", err
+	}
 
+	resp, err := provider.Complete(context.Background(), LLMRequest{
+		Messages: []LLMMessage{{Role: LLMRoleUser, Content: prompt}},
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return resp.Content, nil
 }
 
 func (s *AIService) extractMetadata(userContent, aiResponse string) models.MessageMetadata {
@@ -545,57 +444,8 @@ func (s *AIService) extractMetadata(userContent, aiResponse string) models.Messa
 	return metadata
 }
 
-func (s *AIService) handleFunctionCall(sessionID string, message openai.ChatCompletionMessage, userContent string) (*AIResponse, error) {
-	if message.FunctionCall == nil {
-		return nil, fmt.Errorf("no function call found")
-	}
-
-	switch message.FunctionCall.Name {
-	case "parse_properties":
-		return s.handleParsePropertiesCall(sessionID, message.FunctionCall.Arguments, userContent)
-	default:
-		return nil, fmt.Errorf("unknown function: %s", message.FunctionCall.Name)
-	}
-}
-
-func (s *AIService) handleParsePropertiesCall(sessionID, arguments, userContent string) (*AIResponse, error) {
-	// КРИТИЧЕСКАЯ ПРОВЕРКА: Блокируем вызов парсера без подтверждения
-	// Только ЯВНЫЕ слова согласия, БЕЗ слов из поисковых запросов типа "найди", "ищи", "поиск"
-	confirmationWords := []string{"да", "согласен", "подтверждаю", "запускай", "давай", "окей", "ок", "старт"}
-	userContentLower := strings.ToLower(userContent)
-	
-	hasConfirmation := false
-	foundWord := ""
-	for _, word := range confirmationWords {
-		if strings.Contains(userContentLower, word) {
-			hasConfirmation = true
-			foundWord = word
-			break
-		}
-	}
-	
-	// Debug логирование
-	fmt.Printf("[AI SERVICE] Проверка подтверждения для текста: '%s'\n", userContent)
-	fmt.Printf("[AI SERVICE] Найдено слово подтверждения: '%s', hasConfirmation: %v\n", foundWord, hasConfirmation)
-	
-	if !hasConfirmation {
-		return &AIResponse{
-			Content: `Я понял ваши требования к поиску, но для запуска парсинга нужно ваше подтверждение.
-
-Параметры поиска готовы. Подтвердите запуск поиска, написав:
-- "Да, ищи"  
-- "Согласен"
-- "Запускай поиск"
-
-Вы готовы начать поиск недвижимости?`,
-			Metadata: models.MessageMetadata{
-				Actions:    []string{"waiting_confirmation"},
-				Confidence: 1.0,
-				Extra:      map[string]interface{}{"requires_confirmation": true},
-			},
-		}, nil
-	}
-
+// handleParsePropertiesCall ищет объявления по фильтрам модели и форматирует ответ
+func (s *AIService) handleParsePropertiesCall(filters models.PropertyFilters, progress func(ProgressInfo)) (*AIResponse, error) {
 	if s.parserService == nil {
 		return &AIResponse{
 			Content: "Извините, сервис парсинга временно недоступен. Попробуйте позже.",
@@ -607,21 +457,16 @@ func (s *AIService) handleParsePropertiesCall(sessionID, arguments, userContent
 		}, nil
 	}
 
-	// Parse function arguments
-	var filters models.PropertyFilters
-	if err := json.Unmarshal([]byte(arguments), &filters); err != nil {
-		return &AIResponse{
-			Content: "Не удалось обработать параметры поиска. Попробуйте переформулировать запрос.",
-			Metadata: models.MessageMetadata{
-				Actions:    []string{"parse_error"},
-				Confidence: 0.8,
-				Extra:      map[string]interface{}{"error": err.Error()},
-			},
-		}, nil
-	}
+	progress(ProgressInfo{
+		Step:        "parsing_start",
+		Current:     3,
+		Total:       4,
+		Percentage:  75,
+		Description: "🏠 Ищу подходящие квартиры...",
+	})
 
-	// Call parser service
-	parseResponse, err := s.parserService.ParseProperties(filters, 1, nil) // максимум 1 страница для быстроты
+	// Call parser service (this takes the most time)
+	parseResponse, err := s.parserService.ParseProperties(filters, nil, 1, nil) // максимум 1 страница для быстроты
 	if err != nil {
 		return &AIResponse{
 			Content: fmt.Sprintf("Не удалось выполнить поиск недвижимости: %v. Попробуйте изменить параметры поиска.", err),
@@ -633,6 +478,14 @@ func (s *AIService) handleParsePropertiesCall(sessionID, arguments, userContent
 		}, nil
 	}
 
+	progress(ProgressInfo{
+		Step:        "formatting_results",
+		Current:     4,
+		Total:       4,
+		Percentage:  100,
+		Description: "📝 Обрабатываю результаты...",
+	})
+
 	// Format response based on parsing results
 	if len(parseResponse.Properties) == 0 {
 		content := "К сожалению, по вашим критериям ничего не найдено. Попробуйте расширить параметры поиска или изменить город."
@@ -651,7 +504,7 @@ func (s *AIService) handleParsePropertiesCall(sessionID, arguments, userContent
 	}
 
 	// Create response with found properties
-	content := s.formatPropertiesResponse(parseResponse.Properties, filters)
+	content := s.formatPropertiesResponse(parseResponse.Properties, parseResponse.Clusters, filters)
 	
 	return &AIResponse{
 		Content: content,
@@ -664,19 +517,29 @@ func (s *AIService) handleParsePropertiesCall(sessionID, arguments, userContent
 				"filters_used":     filters,
 				"total_found":      len(parseResponse.Properties),
 				"properties":       parseResponse.Properties,
+				"clusters":         parseResponse.Clusters,
 			},
 		},
 	}, nil
 }
 
-func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty, filters models.PropertyFilters) string {
+func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty, clusters []models.PropertyCluster, filters models.PropertyFilters) string {
 	if len(properties) == 0 {
 		return "Недвижимость не найдена."
 	}
 
+	// Одно объявление с нескольких площадок показываем один раз
+	properties, duplicates := collapseDuplicates(properties, clusters)
+
 	var response strings.Builder
 	response.WriteString(fmt.Sprintf("🏠 Найдено %d объектов недвижимости", len(properties)))
 	
+	switch filters.DealType {
+	case models.DealTypeRentLong:
+		response.WriteString(" в долгосрочную аренду")
+	case models.DealTypeRentDaily:
+		response.WriteString(" посуточно")
+	}
 	if filters.City != "" {
 		response.WriteString(fmt.Sprintf(" в городе %s", filters.City))
 	}
@@ -694,7 +557,11 @@ func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty,
 		response.WriteString(fmt.Sprintf("🏢 **%s**\n", property.Title))
 		
 		if property.Price > 0 {
-			response.WriteString(fmt.Sprintf("💰 Цена: %s ₸\n", formatPrice(property.Price)))
+			response.WriteString(fmt.Sprintf("💰 Цена: %s ₸%s\n", formatPrice(property.Price), pricePeriodSuffix(property.PricePeriod)))
+		}
+
+		if details := rentalDetails(property); details != "" {
+			response.WriteString(fmt.Sprintf("🔑 Условия: %s\n", details))
 		}
 		
 		if property.Rooms != nil && *property.Rooms > 0 {
@@ -704,10 +571,18 @@ func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty,
 		if property.Area != nil && *property.Area > 0 {
 			response.WriteString(fmt.Sprintf("📐 Площадь: %.1f м²\n", *property.Area))
 		}
+
+		if details := categoryDetails(property); details != "" {
+			response.WriteString(fmt.Sprintf("🏡 %s\n", details))
+		}
 		
 		if property.Address != "" {
 			response.WriteString(fmt.Sprintf("📍 Адрес: %s\n", property.Address))
 		}
+
+		if nearby := nearbySummary(property); nearby != "" {
+			response.WriteString(fmt.Sprintf("🏫 Рядом: %s\n", nearby))
+		}
 		
 		// Добавляем изображения
 		if len(property.Images) > 0 {
@@ -733,6 +608,14 @@ func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty,
 			}
 			response.WriteString(fmt.Sprintf("🔗 [Подробнее](%s)\n", fullURL))
 		}
+
+		if others := duplicates[property.ID]; len(others) > 0 {
+			links := make([]string, 0, len(others))
+			for _, other := range others {
+				links = append(links, fmt.Sprintf("[%s, %s ₸](%s)", sourceDisplayName(other.Source), formatPrice(other.Price), other.URL))
+			}
+			response.WriteString(fmt.Sprintf("🔁 Также размещено: %s\n", strings.Join(links, ", ")))
+		}
 		
 		response.WriteString("\n---\n\n")
 	}
@@ -741,6 +624,47 @@ func (s *AIService) formatPropertiesResponse(properties []models.ParsedProperty,
 	return response.String()
 }
 
+// collapseDuplicates оставляет из каждой группы дубликатов каноническое объявление.
+// Остальные объявления группы возвращаются по ID канонического.
+func collapseDuplicates(properties []models.ParsedProperty, clusters []models.PropertyCluster) ([]models.ParsedProperty, map[string][]models.ParsedProperty) {
+	duplicates := make(map[string][]models.ParsedProperty)
+	if len(clusters) == 0 {
+		return properties, duplicates
+	}
+
+	canonicalOf := make(map[string]string)
+	for _, cluster := range clusters {
+		for _, id := range cluster.MemberIDs {
+			if id != cluster.CanonicalID {
+				canonicalOf[id] = cluster.CanonicalID
+			}
+		}
+	}
+
+	unique := make([]models.ParsedProperty, 0, len(properties))
+	for _, property := range properties {
+		if canonicalID, ok := canonicalOf[property.ID]; ok {
+			duplicates[canonicalID] = append(duplicates[canonicalID], property)
+			continue
+		}
+		unique = append(unique, property)
+	}
+
+	return unique, duplicates
+}
+
+// sourceDisplayName название площадки для показа пользователю
+func sourceDisplayName(source string) string {
+	switch source {
+	case "krisha":
+		return "Krisha.kz"
+	case "olx":
+		return "OLX.kz"
+	default:
+		return source
+	}
+}
+
 func formatPrice(price int64) string {
 	if price >= 1000000 {
 		millions := float64(price) / 1000000
@@ -759,6 +683,64 @@ func formatPrice(price int64) string {
 	return fmt.Sprintf("%d", price)
 }
 
+// pricePeriodSuffix подпись периода к цене аренды
+func pricePeriodSuffix(period string) string {
+	switch period {
+	case models.PricePeriodMonth:
+		return " / мес."
+	case models.PricePeriodDay:
+		return " / сутки"
+	}
+	return ""
+}
+
+// rentalDetails строка с условиями аренды: залог, коммунальные услуги, животные
+func rentalDetails(property models.ParsedProperty) string {
+	var details []string
+	if property.Deposit != nil {
+		if *property.Deposit > 0 {
+			details = append(details, fmt.Sprintf("залог %s ₸", formatPrice(*property.Deposit)))
+		} else {
+			details = append(details, "без залога")
+		}
+	}
+	if property.UtilitiesIncluded != nil {
+		if *property.UtilitiesIncluded {
+			details = append(details, "коммунальные включены")
+		} else {
+			details = append(details, "коммунальные отдельно")
+		}
+	}
+	if property.PetsAllowed != nil {
+		if *property.PetsAllowed {
+			details = append(details, "можно с животными")
+		} else {
+			details = append(details, "без животных")
+		}
+	}
+	return strings.Join(details, ", ")
+}
+
+// categoryDetails строка с атрибутами дома, участка или коммерческой недвижимости
+func categoryDetails(property models.ParsedProperty) string {
+	var details []string
+	switch property.CommercialType {
+	case models.CommercialTypeOffice:
+		details = append(details, "офис")
+	case models.CommercialTypeRetail:
+		details = append(details, "торговое помещение")
+	case models.CommercialTypeWarehouse:
+		details = append(details, "склад / производство")
+	}
+	if property.LandArea != nil && *property.LandArea > 0 {
+		details = append(details, fmt.Sprintf("участок %.1f сот.", *property.LandArea))
+	}
+	if property.LandPurpose != "" {
+		details = append(details, property.LandPurpose)
+	}
+	return strings.Join(details, ", ")
+}
+
 func extractPropertyIDs(properties []models.ParsedProperty) []string {
 	ids := make([]string, 0, len(properties))
 	for _, property := range properties {
@@ -788,132 +770,36 @@ type ProgressInfo struct {
 	Description string `json:"description"`
 }
 
-// ProcessChatMessageWithProgress processes chat message with real-time progress updates
-func (s *AIService) ProcessChatMessageWithProgress(sessionID, content string, progressChan chan<- ProgressInfo) (*AIResponse, error) {
-	// Send initial progress
-	progressChan <- ProgressInfo{
-		Step:        "ai_analysis",
-		Current:     1,
-		Total:       4,
-		Percentage:  25,
-		Description: "🤖 Анализирую ваш запрос...",
-	}
-
-	// Check API key first
-	if !s.isAPIKeyConfigured() {
-		return &AIResponse{
-			Content: fmt.Sprintf("❌ AI API ключ для провайдера '%s' не настроен. Пожалуйста, добавьте соответствующий ключ в файл .env", s.config.AI.Provider),
-			Metadata: models.MessageMetadata{
-				Actions:    []string{"configuration_error"},
-				Confidence: 1.0,
-				Extra:      map[string]interface{}{"error": "ai_api_key_missing", "provider": s.config.AI.Provider},
-			},
-		}, nil
-	}
-
-	// Check if this is a confirmation for parsing
-	confirmationWords := []string{"да", "согласен", "подтверждаю", "запускай", "давай", "окей", "ок", "старт"}
-	userContentLower := strings.ToLower(content)
-	
-	hasConfirmation := false
-	for _, word := range confirmationWords {
-		if strings.Contains(userContentLower, word) {
-			hasConfirmation = true
-			break
-		}
-	}
-
-	if hasConfirmation {
-		progressChan <- ProgressInfo{
-			Step:        "params_extraction",
-			Current:     2,
-			Total:       4,
-			Percentage:  50,
-			Description: "🔍 Извлекаю параметры поиска...",
-		}
-
-		// Extract parameters from chat history
-		if s.chatService != nil {
-			session, err := s.chatService.GetSession(sessionID)
-			if err == nil && session != nil && len(session.Messages) > 0 {
-				// Search for parameters in history
-				for i := len(session.Messages) - 1; i >= 0; i-- {
-					msg := session.Messages[i]
-					if msg.Role == "user" {
-						filters := s.extractSearchParams(msg.Content)
-						if filters.City != "" {
-							// Found parameters - start parsing with progress
-							return s.handleParsePropertiesWithProgress(sessionID, filters, content, progressChan)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Regular Gemini chat
-	progressChan <- ProgressInfo{
-		Step:        "ai_response",
-		Current:     3,
-		Total:       4,
-		Percentage:  75,
-		Description: "💬 Генерирую ответ...",
+// handleImportedSearch ищет по ссылке на поиск krisha.kz или olx.kz из сообщения.
+// Подтверждение не спрашиваем: ссылка сама задает все параметры.
+func (s *AIService) handleImportedSearch(imported *SearchURLImport) (*AIResponse, error) {
+	var response *AIResponse
+	var err error
+	if imported.Source == "krisha" {
+		response, err = s.searchWithKrishaFilters(imported.Filters, s.convertToKrishaFilters(imported.Filters))
+	} else {
+		response, err = s.searchWithSources(imported.Filters, []string{imported.Source})
 	}
-
-	systemPrompt := `You are SmartEstate AI assistant, helping users find and manage real estate in Kazakhstan. 
-	CRITICAL RULE - NEVER call parse_properties function without EXPLICIT final confirmation!
-	SMART CONVERSATION FLOW:
-	1. When user provides search request with COMPLETE information (city, rooms, budget), IMMEDIATELY summarize and ask for confirmation
-	2. When user provides INCOMPLETE information, ask only for missing critical details
-	3. ALWAYS summarize parameters and ask FINAL CONFIRMATION: "Подтверждаете поиск?" 
-	4. ONLY use parse_properties function when user explicitly confirms with "да", "согласен", "подтверждаю", "давай", "окей" etc.
-	CRITICAL: If user provides city + rooms + budget in first message - DON'T ask additional questions, go straight to confirmation!
-	You can help with:
-	- Finding properties (only after confirmation)
-	- Calculating mortgage payments
-	- Property valuation
-	- Scheduling viewings
-	- Market analysis
-	
-	Always respond in Russian or Kazakh based on user's language.
-	
-	Example conversation flow:
-	User: "Найди 2-комн в Алматы до 40 млн"
-	AI: "Понял ваши требования:
-	✅ 2-комнатная квартира
-	✅ Город: Алматы  
-	✅ Бюджет: до 40 млн тенге
-	
-	Подтверждаете поиск? Напишите 'Да' и я начну парсинг."
-	
-	User: "Да"
-	AI: (NOW calls parse_properties function)
-	
-	NEVER call parse_properties without final user confirmation!`
-
-	fullPrompt := systemPrompt + "\n\nПользователь: " + content
-	aiResponse, err := s.callGeminiAPI(fullPrompt)
 	if err != nil {
 		return nil, err
 	}
 
-	progressChan <- ProgressInfo{
-		Step:        "completed",
-		Current:     4,
-		Total:       4,
-		Percentage:  100,
-		Description: "✅ Готово!",
+	note := fmt.Sprintf("🔗 Ищу по вашей ссылке на %s.kz", imported.Source)
+	if len(imported.Unsupported) > 0 {
+		note += "\n⚠️ Не учтены параметры: " + strings.Join(imported.Unsupported, ", ")
 	}
-
-	metadata := s.extractMetadata(content, aiResponse)
-	return &AIResponse{
-		Content:  aiResponse,
-		Metadata: metadata,
-	}, nil
+	response.Content = note + "\n\n" + response.Content
+	response.Metadata.Actions = append(response.Metadata.Actions, "search_url_imported")
+	if response.Metadata.Extra == nil {
+		response.Metadata.Extra = make(map[string]interface{})
+	}
+	response.Metadata.Extra["search_url"] = imported.URL
+	response.Metadata.Extra["unsupported_url_params"] = imported.Unsupported
+	return response, nil
 }
 
-// handleParsePropertiesWithProgress handles property parsing with progress updates
-func (s *AIService) handleParsePropertiesWithProgress(sessionID string, filters models.PropertyFilters, userContent string, progressChan chan<- ProgressInfo) (*AIResponse, error) {
+// searchWithSources ищет через ParserService на заданных площадках и форматирует ответ
+func (s *AIService) searchWithSources(filters models.PropertyFilters, sources []string) (*AIResponse, error) {
 	if s.parserService == nil {
 		return &AIResponse{
 			Content: "Извините, сервис парсинга временно недоступен. Попробуйте позже.",
@@ -925,16 +811,7 @@ func (s *AIService) handleParsePropertiesWithProgress(sessionID string, filters
 		}, nil
 	}
 
-	progressChan <- ProgressInfo{
-		Step:        "parsing_start",
-		Current:     3,
-		Total:       4,
-		Percentage:  75,
-		Description: "🏠 Ищу подходящие квартиры...",
-	}
-
-	// Call parser service (this takes the most time)
-	parseResponse, err := s.parserService.ParseProperties(filters, 1, nil) // максимум 1 страница для быстроты
+	parseResponse, err := s.parserService.ParseProperties(filters, sources, 1, nil)
 	if err != nil {
 		return &AIResponse{
 			Content: fmt.Sprintf("Не удалось выполнить поиск недвижимости: %v. Попробуйте изменить параметры поиска.", err),
@@ -946,19 +823,9 @@ func (s *AIService) handleParsePropertiesWithProgress(sessionID string, filters
 		}, nil
 	}
 
-	progressChan <- ProgressInfo{
-		Step:        "formatting_results",
-		Current:     4,
-		Total:       4,
-		Percentage:  100,
-		Description: "📝 Обрабатываю результаты...",
-	}
-
-	// Format response based on parsing results
 	if len(parseResponse.Properties) == 0 {
-		content := "К сожалению, по вашим критериям ничего не найдено. Попробуйте расширить параметры поиска или изменить город."
 		return &AIResponse{
-			Content: content,
+			Content: "К сожалению, по вашим критериям ничего не найдено. Попробуйте расширить параметры поиска или изменить город.",
 			Metadata: models.MessageMetadata{
 				Actions:    []string{"search_no_results"},
 				Confidence: 0.9,
@@ -971,70 +838,32 @@ func (s *AIService) handleParsePropertiesWithProgress(sessionID string, filters
 		}, nil
 	}
 
-	// Create response with found properties
-	content := s.formatPropertiesResponse(parseResponse.Properties, filters)
-	
 	return &AIResponse{
-		Content: content,
+		Content: s.formatPropertiesResponse(parseResponse.Properties, parseResponse.Clusters, filters),
 		Metadata: models.MessageMetadata{
-			Actions: []string{"search_completed", "properties_found"},
+			Actions:     []string{"search_completed", "properties_found"},
 			PropertyIDs: extractPropertyIDs(parseResponse.Properties),
-			Confidence: 0.95,
+			Confidence:  0.95,
 			Extra: map[string]interface{}{
 				"parse_request_id": parseResponse.RequestID,
-				"filters_used":     filters,
-				"total_found":      len(parseResponse.Properties),
-				"properties":       parseResponse.Properties,
+				"filters_used":        filters,
+				"total_found":         len(parseResponse.Properties),
+				"properties":          parseResponse.Properties,
+				"clusters":            parseResponse.Clusters,
+				"unsupported_filters": parseResponse.UnsupportedFilters,
 			},
 		},
 	}, nil
 }
 
-// convertToKrishaFilters converts PropertyFilters to enhanced KrishaFilters format
+// convertToKrishaFilters дополняет фильтры поиска параметрами обхода страниц krisha.kz
 func (s *AIService) convertToKrishaFilters(filters models.PropertyFilters) KrishaFilters {
-	krishaFilters := KrishaFilters{
+	return KrishaFilters{
+		PropertyFilters: filters,
 		CollectAllPages: true, // Включаем сбор всех страниц по умолчанию
-		MaxResults:     200,  // Максимум 200 результатов как в проекте krisha
-		Page:           1,
-		HasPhoto:       false, // Убираем фильтр по фото - может ограничивать результаты
+		MaxResults:      200,  // Максимум 200 результатов как в проекте krisha
+		Page:            1,
 	}
-
-	// Convert city
-	if filters.City != "" {
-		switch strings.ToLower(filters.City) {
-		case "алматы", "almaty":
-			krishaFilters.City = "almaty"
-		case "нур-султан", "астана", "nur-sultan", "astana":
-			krishaFilters.City = "nur-sultan"
-		case "шымкент", "shymkent":
-			krishaFilters.City = "shymkent"
-		default:
-			krishaFilters.City = strings.ToLower(filters.City)
-		}
-	}
-
-	// Convert rooms
-	if filters.Rooms != nil {
-		krishaFilters.Rooms = fmt.Sprintf("%d", *filters.Rooms)
-	}
-
-	// Convert price range
-	if filters.PriceMin != nil {
-		krishaFilters.PriceFrom = fmt.Sprintf("%d", *filters.PriceMin)
-	}
-	if filters.PriceMax != nil {
-		krishaFilters.PriceTo = fmt.Sprintf("%d", *filters.PriceMax)
-	}
-
-	// Convert area range (используем TotalAreaFrom/TotalAreaTo)
-	if filters.TotalAreaFrom != nil {
-		krishaFilters.AreaFrom = fmt.Sprintf("%d", *filters.TotalAreaFrom)
-	}
-	if filters.TotalAreaTo != nil {
-		krishaFilters.AreaTo = fmt.Sprintf("%d", *filters.TotalAreaTo)
-	}
-
-	return krishaFilters
 }
 
 // formatKrishaPropertiesResponse formats Krisha properties into chat response with enhanced display
@@ -1047,19 +876,10 @@ func (s *AIService) formatKrishaPropertiesResponse(properties []models.ParsedPro
 	response.WriteString(fmt.Sprintf("🏠 **Найдено %d объектов недвижимости**", len(properties)))
 
 	if filters.City != "" {
-		cityName := filters.City
-		switch filters.City {
-		case "almaty":
-			cityName = "Алматы"
-		case "nur-sultan":
-			cityName = "Нур-Султан"
-		case "shymkent":
-			cityName = "Шымкент"
-		}
-		response.WriteString(fmt.Sprintf(" в городе **%s**", cityName))
+		response.WriteString(fmt.Sprintf(" в городе **%s**", filters.City))
 	}
-	if filters.Rooms != "" {
-		response.WriteString(fmt.Sprintf(", **%s-комнатные**", filters.Rooms))
+	if filters.Rooms != nil {
+		response.WriteString(fmt.Sprintf(", **%d-комнатные**", *filters.Rooms))
 	}
 	response.WriteString(":\n\n")
 
@@ -1073,7 +893,11 @@ func (s *AIService) formatKrishaPropertiesResponse(properties []models.ParsedPro
 		response.WriteString(fmt.Sprintf("**%d. %s**\n", i+1, property.Title))
 
 		if property.Price > 0 {
-			response.WriteString(fmt.Sprintf("💰 Цена: %s ₸\n", formatPrice(property.Price)))
+			response.WriteString(fmt.Sprintf("💰 Цена: %s ₸%s\n", formatPrice(property.Price), pricePeriodSuffix(property.PricePeriod)))
+		}
+
+		if details := rentalDetails(property); details != "" {
+			response.WriteString(fmt.Sprintf("🔑 Условия: %s\n", details))
 		}
 
 		if property.Rooms != nil && *property.Rooms > 0 {
@@ -1084,10 +908,18 @@ func (s *AIService) formatKrishaPropertiesResponse(properties []models.ParsedPro
 			response.WriteString(fmt.Sprintf("📐 Площадь: %.1f м²\n", *property.Area))
 		}
 
+		if details := categoryDetails(property); details != "" {
+			response.WriteString(fmt.Sprintf("🏡 %s\n", details))
+		}
+
 		if property.Address != "" {
 			response.WriteString(fmt.Sprintf("📍 Адрес: %s\n", property.Address))
 		}
 
+		if nearby := nearbySummary(property); nearby != "" {
+			response.WriteString(fmt.Sprintf("🏫 Рядом: %s\n", nearby))
+		}
+
 		// Показываем все доступные фотографии
 		if len(property.Images) > 0 {
 			response.WriteString("📸 Фото:\n")
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"smartestate/internal/config"
+)
+
+// newFakeAIService ассистент на сценарии FakeLLM с одним инструментом lookup,
+// который возвращает переданный город
+func newFakeAIService(script ...LLMResponse) (*AIService, *FakeLLM, *int) {
+	service := NewAIService(&config.Config{AI: config.AIConfig{Provider: "fake"}})
+	fake := NewFakeLLM(script...)
+	service.LLM().Register(fake)
+
+	calls := 0
+	type lookupArgs struct {
+		City string `json:"city"`
+	}
+	service.Tools().Register(NewChatTool("lookup", "test lookup", ChatToolPublic,
+		func(ctx context.Context, env ChatToolEnv, args lookupArgs) (*ChatToolResult, error) {
+			calls++
+			return &ChatToolResult{Data: map[string]string{"city": args.City}, Actions: []string{"lookup"}}, nil
+		}))
+	return service, fake, &calls
+}
+
+func lookupCall(id string) LLMResponse {
+	return LLMResponse{ToolCalls: []LLMToolCall{{ID: id, Name: "lookup", Arguments: `{"city":"Алматы"}`}}}
+}
+
+func TestProcessChatMessageToolLoop(t *testing.T) {
+	service, fake, calls := newFakeAIService(
+		lookupCall("call_1"),
+		LLMResponse{Content: "В Алматы есть варианты"},
+	)
+
+	var deltas string
+	response, err := service.ProcessChatMessageStream(context.Background(), "no-session", "что в Алматы?", ChatOptions{},
+		func(ProgressInfo) {}, func(delta string) { deltas += delta })
+	if err != nil {
+		t.Fatalf("ProcessChatMessageStream: %v", err)
+	}
+
+	if response.Content != "В Алматы есть варианты" || deltas != response.Content {
+		t.Errorf("content = %q, deltas = %q", response.Content, deltas)
+	}
+	if *calls != 1 {
+		t.Errorf("tool calls = %d, want 1", *calls)
+	}
+
+	records := response.Metadata.ToolCalls
+	if len(records) != 1 || records[0].Name != "lookup" || records[0].Step != 1 || records[0].Error != "" {
+		t.Fatalf("tool call records = %+v", records)
+	}
+	var result map[string]string
+	if err := json.Unmarshal(records[0].Result, &result); err != nil || result["city"] != "Алматы" {
+		t.Errorf("recorded result = %s", records[0].Result)
+	}
+	if !containsString(response.Metadata.Actions, "lookup") {
+		t.Errorf("actions = %v, want tool action", response.Metadata.Actions)
+	}
+
+	// Второй запрос продолжает разговор вызовом и его результатом
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+	messages := requests[1].Messages
+	if len(messages) < 3 {
+		t.Fatalf("second request messages = %+v", messages)
+	}
+	assistant, tool := messages[len(messages)-2], messages[len(messages)-1]
+	if assistant.Role != LLMRoleAssistant || len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].ID != "call_1" {
+		t.Errorf("assistant message = %+v", assistant)
+	}
+	if tool.Role != LLMRoleTool || tool.ToolCallID != "call_1" || tool.Content != `{"city":"Алматы"}` {
+		t.Errorf("tool message = %+v", tool)
+	}
+	if usage, ok := response.Metadata.Extra["usage"].(LLMUsage); !ok || usage.TotalTokens == 0 {
+		t.Errorf("usage = %v, want usage of both steps", response.Metadata.Extra["usage"])
+	}
+}
+
+func TestProcessChatMessageUnknownTool(t *testing.T) {
+	service, fake, _ := newFakeAIService(
+		LLMResponse{ToolCalls: []LLMToolCall{{ID: "call_1", Name: "missing"}}},
+		LLMResponse{Content: "Не получилось"},
+	)
+
+	response, err := service.ProcessChatMessage(context.Background(), "no-session", "привет", ChatOptions{})
+	if err != nil {
+		t.Fatalf("ProcessChatMessage: %v", err)
+	}
+	if response.Content != "Не получилось" {
+		t.Errorf("content = %q", response.Content)
+	}
+
+	// Ошибка инструмента не прерывает ответ, а передается модели
+	messages := fake.Requests()[1].Messages
+	if tool := messages[len(messages)-1]; tool.Role != LLMRoleTool || tool.Content == "{}" {
+		t.Errorf("tool message = %+v, want error for the model", tool)
+	}
+	if records := response.Metadata.ToolCalls; len(records) != 1 || records[0].Error == "" {
+		t.Errorf("tool call records = %+v, want recorded error", records)
+	}
+}
+
+func TestProcessChatMessageToolStepLimit(t *testing.T) {
+	var script []LLMResponse
+	for i := 0; i <= chatMaxToolSteps; i++ {
+		script = append(script, lookupCall("call"))
+	}
+	service, fake, calls := newFakeAIService(script...)
+
+	response, err := service.ProcessChatMessage(context.Background(), "no-session", "ищи", ChatOptions{})
+	if err != nil {
+		t.Fatalf("ProcessChatMessage: %v", err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != chatMaxToolSteps+1 || *calls != chatMaxToolSteps {
+		t.Fatalf("requests = %d, tool calls = %d, want %d and %d", len(requests), *calls, chatMaxToolSteps+1, chatMaxToolSteps)
+	}
+	// После лимита инструменты остаются в запросе, но вызывать их нельзя
+	for i, request := range requests {
+		last := i == len(requests)-1
+		if request.NoToolCalls != last || len(request.Tools) == 0 {
+			t.Errorf("request %d: NoToolCalls = %v, tools = %d", i+1, request.NoToolCalls, len(request.Tools))
+		}
+	}
+	if response.Content == "" || len(response.Metadata.ToolCalls) != chatMaxToolSteps {
+		t.Errorf("content = %q, tool call records = %d", response.Content, len(response.Metadata.ToolCalls))
+	}
+}
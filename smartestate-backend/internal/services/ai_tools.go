@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+
+	"smartestate/internal/models"
+)
+
+// propertySearchArgs аргументы parse_properties: те фильтры поиска, которые
+// модель умеет заполнять из разговора
+type propertySearchArgs struct {
+	City               string        `json:"city" binding:"required" enums:"Алматы,Астана,Шымкент" description:"Город поиска (Алматы, Астана, Шымкент)"`
+	District           string        `json:"district" description:"Район города, например Бостандыкский или Есиль. Название на русском, казахском или латиницей"`
+	ResidentialComplex string        `json:"residential_complex" description:"Жилой комплекс (ЖК), например Хайвил"`
+	PropertyType       string        `json:"property_type" enums:"apartment,house,land,commercial" description:"Тип недвижимости: apartment - квартира, house - дом или дача, land - участок, commercial - коммерческая недвижимость"`
+	CommercialType     string        `json:"commercial_type" enums:"office,retail,warehouse" description:"Вид коммерческой недвижимости, только для property_type commercial: office - офис, retail - магазин или торговое помещение, warehouse - склад или производство"`
+	LandAreaFrom       *int          `json:"land_area_from" description:"Минимальная площадь участка в сотках, для домов и участков"`
+	LandAreaTo         *int          `json:"land_area_to" description:"Максимальная площадь участка в сотках, для домов и участков"`
+	DealType           string        `json:"deal_type" enums:"sale,rent_long,rent_daily" description:"Тип сделки: sale - покупка, rent_long - долгосрочная аренда (цена за месяц), rent_daily - посуточная аренда (цена за сутки). По умолчанию sale"`
+	Rooms              *int          `json:"rooms" minimum:"1" maximum:"10" description:"Количество комнат"`
+	PriceMin           *int64        `json:"price_min" description:"Минимальная цена в тенге"`
+	PriceMax           *int64        `json:"price_max" description:"Максимальная цена в тенге"`
+	TotalAreaFrom      *int          `json:"total_area_from" description:"Минимальная площадь в м²"`
+	TotalAreaTo        *int          `json:"total_area_to" description:"Максимальная площадь в м²"`
+	HasPhotos          bool          `json:"has_photos" description:"Только с фотографиями"`
+	IsNewBuilding      bool          `json:"is_new_building" description:"Только новостройки"`
+	SellerType         string        `json:"seller_type" enums:"owner,agent,developer" description:"Тип продавца"`
+	HouseType          string        `json:"house_type" enums:"brick,panel,monolith,other" description:"Материал стен: brick - кирпичный, panel - панельный, monolith - монолитный, other - иной"`
+	KitchenAreaFrom    *int          `json:"kitchen_area_from" description:"Минимальная площадь кухни в м²"`
+	TotalFloorsFrom    *int          `json:"total_floors_from" description:"Минимальная этажность дома"`
+	TotalFloorsTo      *int          `json:"total_floors_to" description:"Максимальная этажность дома"`
+	PetsAllowed        bool          `json:"pets_allowed" description:"Только аренда, где можно с животными"`
+	NearPOI            []nearPOIArgs `json:"near_poi" description:"Что должно быть рядом: например школа не дальше 500 м. Используй, когда пользователь просит рядом школу, метро, парк и т.д."`
+	MinWalkScore       *int          `json:"min_walk_score" minimum:"0" maximum:"100" description:"Минимальный индекс пешей доступности 0-100: сколько магазинов, школ, остановок и т.д. в шаговой доступности"`
+}
+
+type nearPOIArgs struct {
+	Category string `json:"category" binding:"required" enums:"school,kindergarten,metro,bus_stop,park,mall,supermarket,hospital,pharmacy"`
+	WithinM  int    `json:"within_m" binding:"required" minimum:"100" maximum:"2000" description:"Максимальное расстояние в метрах, до 2000"`
+}
+
+// filters переводит аргументы в фильтры поиска
+func (a propertySearchArgs) filters() models.PropertyFilters {
+	filters := models.PropertyFilters{
+		City:               a.City,
+		District:           a.District,
+		ResidentialComplex: a.ResidentialComplex,
+		PropertyType:       a.PropertyType,
+		CommercialType:     a.CommercialType,
+		LandAreaFrom:       a.LandAreaFrom,
+		LandAreaTo:         a.LandAreaTo,
+		DealType:           a.DealType,
+		Rooms:              a.Rooms,
+		PriceMin:           a.PriceMin,
+		PriceMax:           a.PriceMax,
+		TotalAreaFrom:      a.TotalAreaFrom,
+		TotalAreaTo:        a.TotalAreaTo,
+		HasPhotos:          a.HasPhotos,
+		IsNewBuilding:      a.IsNewBuilding,
+		SellerType:         a.SellerType,
+		HouseType:          a.HouseType,
+		KitchenAreaFrom:    a.KitchenAreaFrom,
+		TotalFloorsFrom:    a.TotalFloorsFrom,
+		TotalFloorsTo:      a.TotalFloorsTo,
+		PetsAllowed:        a.PetsAllowed,
+		MinWalkScore:       a.MinWalkScore,
+	}
+	for _, near := range a.NearPOI {
+		filters.NearPOI = append(filters.NearPOI, models.POIDistanceFilter{Category: near.Category, WithinM: near.WithinM})
+	}
+	return filters
+}
+
+// chatTools собственные инструменты ассистента: поиск и расчет ипотеки
+func (s *AIService) chatTools() []ChatTool {
+	return []ChatTool{
+		NewChatTool("parse_properties",
+			"Парсит недвижимость с сайта krisha.kz по заданным фильтрам. КРИТИЧЕСКИ ВАЖНО: Используй эту функцию ТОЛЬКО после ЯВНОГО подтверждения пользователем типа 'да, ищи', 'согласен', 'подтверждаю'. НИКОГДА не вызывай без финального подтверждения!",
+			ChatToolConfirm,
+			func(ctx context.Context, env ChatToolEnv, args propertySearchArgs) (*ChatToolResult, error) {
+				response, err := s.handleParsePropertiesCall(args.filters(), env.Progress)
+				if err != nil {
+					return nil, err
+				}
+				return &ChatToolResult{
+					Data: map[string]interface{}{
+						"actions":     response.Metadata.Actions,
+						"total_found": response.Metadata.Extra["total_found"],
+					},
+					Response: response,
+				}, nil
+			}),
+		mortgageChatTool(),
+	}
+}
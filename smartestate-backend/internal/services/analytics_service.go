@@ -2,10 +2,17 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+
+	"smartestate/internal/models"
 )
 
 type AnalyticsService struct {
@@ -96,3 +103,245 @@ func (s *AnalyticsService) GetMarketTrends(city, propertyType string) (*MarketTr
 
 	return trends, nil
 }
+
+// ValuationInput объект для оценки по похожим объявлениям
+type ValuationInput struct {
+	City         string  `json:"city" binding:"required" enums:"Алматы,Астана,Шымкент" description:"Город"`
+	District     string  `json:"district" description:"Район города, например Бостандыкский или Есиль"`
+	PropertyType string  `json:"property_type" enums:"apartment,house,land,commercial" description:"Тип недвижимости, по умолчанию apartment"`
+	DealType     string  `json:"deal_type" enums:"sale,rent_long,rent_daily" description:"sale - цена продажи, rent_long - аренда в месяц, rent_daily - аренда в сутки. По умолчанию sale"`
+	Rooms        *int    `json:"rooms" minimum:"1" maximum:"10" description:"Количество комнат"`
+	Area         float64 `json:"area" binding:"required" minimum:"1" description:"Площадь в м², для участков - площадь в сотках"`
+}
+
+// Valuation оценка по медиане цены квадратного метра похожих активных объявлений.
+// Диапазон - от нижнего до верхнего квартиля.
+type Valuation struct {
+	EstimatedPrice int64  `json:"estimated_price"`
+	PriceLow       int64  `json:"price_low"`
+	PriceHigh      int64  `json:"price_high"`
+	PricePerUnit   int64  `json:"price_per_unit"` // за м², для участков - за сотку
+	Comparables    int64  `json:"comparables"`
+	DealType       string `json:"deal_type"`
+}
+
+// MarketAnalysisInput срез рынка для анализа
+type MarketAnalysisInput struct {
+	City         string `json:"city" binding:"required" enums:"Алматы,Астана,Шымкент" description:"Город"`
+	District     string `json:"district" description:"Район города"`
+	PropertyType string `json:"property_type" enums:"apartment,house,land,commercial" description:"Тип недвижимости, по умолчанию apartment"`
+	DealType     string `json:"deal_type" enums:"sale,rent_long,rent_daily" description:"Тип сделки, по умолчанию sale"`
+	Rooms        *int   `json:"rooms" minimum:"1" maximum:"10" description:"Количество комнат"`
+}
+
+// MarketAnalysis состояние рынка по активным объявлениям каталога
+type MarketAnalysis struct {
+	ActiveListings int64 `json:"active_listings"`
+	MedianPrice    int64 `json:"median_price"`
+	AveragePrice   int64 `json:"average_price"`
+	PricePerUnit   int64 `json:"median_price_per_unit"` // за м², для участков - за сотку
+	// Медиана цены за м² у объявлений, появившихся за последние 30 дней и за 30 дней до них
+	PricePerUnitLast30Days int64              `json:"median_price_per_unit_last_30_days"`
+	PricePerUnitPrev30Days int64              `json:"median_price_per_unit_prev_30_days"`
+	PricePerUnitChange     float64            `json:"price_per_unit_change_percent"`
+	NewListings30Days      int64              `json:"new_listings_30_days"`
+	PriceDrops30Days       int64              `json:"price_drops_30_days"`
+	ByRooms                []MarketRoomsStats `json:"by_rooms,omitempty"`
+}
+
+// MarketRoomsStats объявления с одним количеством комнат
+type MarketRoomsStats struct {
+	Rooms       int   `json:"rooms"`
+	Count       int64 `json:"count"`
+	MedianPrice int64 `json:"median_price"`
+}
+
+// marketStats медианы и квартили цены за единицу площади
+type marketStats struct {
+	Count  int64
+	Low    float64
+	Median float64
+	High   float64
+}
+
+// EstimatePrice оценивает объект по похожим активным объявлениям
+func (s *AnalyticsService) EstimatePrice(input ValuationInput) (*Valuation, error) {
+	if input.Area <= 0 {
+		return nil, errors.New("area must be positive")
+	}
+	query, area, err := s.marketQuery(input.City, input.District, input.PropertyType, input.DealType, input.Rooms)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats marketStats
+	err = query.Where(area + " > 0").
+		Select("COUNT(*) AS count, " +
+			"COALESCE(percentile_cont(0.25) WITHIN GROUP (ORDER BY price / " + area + "), 0) AS low, " +
+			"COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY price / " + area + "), 0) AS median, " +
+			"COALESCE(percentile_cont(0.75) WITHIN GROUP (ORDER BY price / " + area + "), 0) AS high").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	if stats.Count == 0 {
+		return nil, errors.New("no comparable listings, try without district or rooms")
+	}
+
+	dealType, _ := models.NormalizeDealType(input.DealType)
+	return &Valuation{
+		EstimatedPrice: int64(math.Round(stats.Median * input.Area)),
+		PriceLow:       int64(math.Round(stats.Low * input.Area)),
+		PriceHigh:      int64(math.Round(stats.High * input.Area)),
+		PricePerUnit:   int64(math.Round(stats.Median)),
+		Comparables:    stats.Count,
+		DealType:       dealType,
+	}, nil
+}
+
+// MarketAnalysis считает цены, динамику и предложение по активным объявлениям
+func (s *AnalyticsService) MarketAnalysis(input MarketAnalysisInput) (*MarketAnalysis, error) {
+	query, area, err := s.marketQuery(input.City, input.District, input.PropertyType, input.DealType, input.Rooms)
+	if err != nil {
+		return nil, err
+	}
+
+	var totals struct {
+		Count   int64
+		Median  float64
+		Average float64
+	}
+	err = query.Session(&gorm.Session{}).
+		Select("COUNT(*) AS count, COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY price), 0) AS median, COALESCE(AVG(price), 0) AS average").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &MarketAnalysis{
+		ActiveListings: totals.Count,
+		MedianPrice:    int64(math.Round(totals.Median)),
+		AveragePrice:   int64(math.Round(totals.Average)),
+	}
+	if totals.Count == 0 {
+		return analysis, nil
+	}
+
+	now := time.Now()
+	monthAgo, twoMonthsAgo := now.AddDate(0, 0, -30), now.AddDate(0, 0, -60)
+	medianPerUnit := func(extra *gorm.DB) (float64, error) {
+		var result struct {
+			Median *float64 // NULL, если объявлений нет
+		}
+		err := extra.Where(area + " > 0").
+			Select("percentile_cont(0.5) WITHIN GROUP (ORDER BY price / " + area + ") AS median").
+			Scan(&result).Error
+		if err != nil || result.Median == nil {
+			return 0, err
+		}
+		return *result.Median, nil
+	}
+
+	all, err := medianPerUnit(query.Session(&gorm.Session{}))
+	if err != nil {
+		return nil, err
+	}
+	last, err := medianPerUnit(query.Session(&gorm.Session{}).Where("first_seen_at >= ?", monthAgo))
+	if err != nil {
+		return nil, err
+	}
+	prev, err := medianPerUnit(query.Session(&gorm.Session{}).Where("first_seen_at >= ? AND first_seen_at < ?", twoMonthsAgo, monthAgo))
+	if err != nil {
+		return nil, err
+	}
+	analysis.PricePerUnit = int64(math.Round(all))
+	analysis.PricePerUnitLast30Days = int64(math.Round(last))
+	analysis.PricePerUnitPrev30Days = int64(math.Round(prev))
+	if last > 0 && prev > 0 {
+		analysis.PricePerUnitChange = models.PriceChangePercent(int64(prev), int64(last))
+	}
+
+	if err := query.Session(&gorm.Session{}).Where("first_seen_at >= ?", monthAgo).Count(&analysis.NewListings30Days).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).
+		Where("price_changed_at >= ? AND initial_price > 0 AND price < initial_price", monthAgo).
+		Count(&analysis.PriceDrops30Days).Error; err != nil {
+		return nil, err
+	}
+
+	if input.Rooms == nil {
+		err := query.Session(&gorm.Session{}).Where("rooms IS NOT NULL").
+			Select("rooms, COUNT(*) AS count, ROUND(percentile_cont(0.5) WITHIN GROUP (ORDER BY price))::bigint AS median_price").
+			Group("rooms").Order("rooms").
+			Scan(&analysis.ByRooms).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return analysis, nil
+}
+
+// marketQuery активные объявления в тенге по городу, району, типу и сделке.
+// Возвращает и колонку площади: для участков цена считается за сотку.
+func (s *AnalyticsService) marketQuery(cityName, districtName, propertyType, dealType string, rooms *int) (*gorm.DB, string, error) {
+	city := DefaultLocations().City(cityName)
+	if city == nil {
+		return nil, "", fmt.Errorf("unknown city %q", cityName)
+	}
+	normalizedType, ok := models.NormalizePropertyType(propertyType)
+	if !ok {
+		return nil, "", fmt.Errorf("unknown property type %q", propertyType)
+	}
+	normalizedDeal, ok := models.NormalizeDealType(dealType)
+	if !ok {
+		return nil, "", fmt.Errorf("unknown deal type %q", dealType)
+	}
+
+	query := s.db.Model(&models.Listing{}).
+		Where("is_active = ? AND price > 0 AND (currency = 'KZT' OR currency = '')", true).
+		Where("city = ? AND property_type = ? AND deal_type = ?", city.Name, normalizedType, normalizedDeal)
+	if districtName != "" {
+		district := city.District(districtName)
+		if district == nil {
+			return nil, "", fmt.Errorf("unknown district %q in %s", districtName, city.Name)
+		}
+		// Геокодер пишет район как в справочнике или с уточнением: "Бостандыкский район"
+		query = query.Where("LOWER(district) LIKE ?", strings.ToLower(district.Name)+"%")
+	}
+	if rooms != nil {
+		query = query.Where("rooms = ?", *rooms)
+	}
+
+	area := "area"
+	if normalizedType == models.PropertyTypeLand {
+		area = "land_area"
+	}
+	return query, area, nil
+}
+
+// ChatTools инструменты оценки и анализа рынка для ассистента
+func (s *AnalyticsService) ChatTools() []ChatTool {
+	return []ChatTool{
+		NewChatTool("estimate_price",
+			"Оценивает рыночную стоимость или ставку аренды объекта по похожим объявлениям каталога. Используй, когда пользователь спрашивает, сколько стоит его квартира или адекватна ли цена.",
+			ChatToolPublic,
+			func(ctx context.Context, env ChatToolEnv, input ValuationInput) (*ChatToolResult, error) {
+				valuation, err := s.EstimatePrice(input)
+				if err != nil {
+					return nil, err
+				}
+				return &ChatToolResult{Data: valuation, Actions: []string{"property_valuation"}}, nil
+			}),
+		NewChatTool("market_analysis",
+			"Анализ рынка по городу, району и количеству комнат: медианные цены, цена м² и ее изменение за месяц, новые объявления и снижения цен.",
+			ChatToolPublic,
+			func(ctx context.Context, env ChatToolEnv, input MarketAnalysisInput) (*ChatToolResult, error) {
+				analysis, err := s.MarketAnalysis(input)
+				if err != nil {
+					return nil, err
+				}
+				return &ChatToolResult{Data: analysis, Actions: []string{"market_analysis"}}, nil
+			}),
+	}
+}
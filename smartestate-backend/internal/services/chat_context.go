@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"smartestate/internal/models"
+)
+
+// chatMinRecentMessages сколько последних сообщений передается модели как есть
+// при любом бюджете: без них модель не поймет, на что отвечает пользователь
+const chatMinRecentMessages = 2
+
+// chatSummaryMessageRunes длина одного сообщения в запросе на краткое содержание.
+// Ответы с подборками объявлений длинные, для пересказа хватает начала.
+const chatSummaryMessageRunes = 1500
+
+// chatSummaryPrompt системный промпт для краткого содержания диалога
+const chatSummaryPrompt = `You maintain a running summary of a conversation between a user and a real estate assistant in Kazakhstan.
+Update the previous summary with the new messages. Keep facts that matter for the next turns: what the user is looking for
+(city, district, rooms, budget, deal type), decisions, listings and numbers the assistant provided, open questions.
+Drop greetings and repetitions. Write in the language of the conversation, at most 200 words. Reply with the summary only.`
+
+// chatContext что передается модели из истории сессии
+type chatContext struct {
+	System   string
+	Messages []LLMMessage
+}
+
+// estimateTokens оценивает число токенов без токенизатора провайдера: латиница
+// и цифры - около 4 символов на токен, кириллица и казахский - около 2.5.
+// Оценки хватает для бюджета: точный расход приходит в LLMUsage.
+func estimateTokens(text string) int {
+	ascii := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	other := utf8.RuneCountInString(text) - ascii
+	return (ascii+3)/4 + (other*2+4)/5
+}
+
+// messageTokens оценка сообщения вместе со служебными токенами роли
+func messageTokens(content string) int {
+	return estimateTokens(content) + 4
+}
+
+// buildChatContext собирает системный промпт и историю для модели в пределах
+// AI_CHAT_HISTORY_TOKENS: в бюджет входят системный промпт с предпочтениями
+// пользователя и кратким содержанием, остальное занимают последние сообщения.
+// Когда история не помещается, старые сообщения сворачиваются в краткое
+// содержание, которое хранится в контексте сессии, с запасом в полбюджета,
+// чтобы не пересказывать на каждом шаге. История всегда начинается с сообщения
+// пользователя: Anthropic и Gemini не принимают диалог, начатый ответом модели.
+func (s *AIService) buildChatContext(ctx context.Context, session *models.ChatSession, content string) chatContext {
+	var history []models.ChatMessage
+	if session != nil {
+		for _, msg := range session.Messages {
+			if msg.Role != LLMRoleUser && msg.Role != LLMRoleAssistant {
+				continue
+			}
+			if until := session.Context.SummarizedUntil; until != nil && !msg.CreatedAt.After(*until) {
+				continue
+			}
+			history = append(history, msg)
+		}
+	}
+
+	system := chatSystem(session)
+	if budget := s.config.AI.ChatHistoryTokens; budget > 0 {
+		available := budget - estimateTokens(system)
+		if len(recentMessages(history, available)) < len(history) {
+			recent := fromUserTurn(recentMessages(history, available/2))
+			older := history[:len(history)-len(recent)]
+			summary, err := s.summarizeChat(ctx, session.Context.Summary, older)
+			if err != nil {
+				// Без нового пересказа оставляем столько старых сообщений, сколько помещается
+				log.Printf("⚠️ AI Service: краткое содержание сессии %s: %v", session.ID, err)
+				recent = recentMessages(history, available)
+			} else {
+				session.Context.Summary = summary
+				until := older[len(older)-1].CreatedAt
+				session.Context.SummarizedUntil = &until
+				s.saveChatContext(session)
+				system = chatSystem(session)
+			}
+			history = recent
+		}
+	}
+	history = fromUserTurn(history)
+
+	var messages []LLMMessage
+	for _, msg := range history {
+		messages = append(messages, LLMMessage{Role: msg.Role, Content: msg.Content})
+	}
+	// Обработчик сохраняет сообщение пользователя до вызова AI, поэтому текущее
+	// добавляется, только если его нет в истории
+	if last := len(messages) - 1; last < 0 || messages[last].Role != LLMRoleUser || messages[last].Content != content {
+		messages = append(messages, LLMMessage{Role: LLMRoleUser, Content: content})
+	}
+	return chatContext{System: system, Messages: messages}
+}
+
+// chatSystem системный промпт с предпочтениями пользователя и кратким
+// содержанием ранних сообщений сессии
+func chatSystem(session *models.ChatSession) string {
+	var system strings.Builder
+	system.WriteString(chatSystemPrompt)
+	if session != nil {
+		if preferences := formatPreferences(session.Context.PropertyPreferences); preferences != "" {
+			system.WriteString("\n\nUser preferences from the last confirmed search (use them unless the user changes them):\n")
+			system.WriteString(preferences)
+		}
+		if session.Context.Summary != "" {
+			system.WriteString("\n\nSummary of the earlier conversation:\n")
+			system.WriteString(session.Context.Summary)
+		}
+	}
+	return system.String()
+}
+
+// recentMessages последние сообщения, которые помещаются в budget токенов,
+// но не меньше chatMinRecentMessages
+func recentMessages(history []models.ChatMessage, budget int) []models.ChatMessage {
+	tokens := 0
+	start := len(history)
+	for start > 0 {
+		next := tokens + messageTokens(history[start-1].Content)
+		if next > budget && len(history)-start >= chatMinRecentMessages {
+			break
+		}
+		tokens = next
+		start--
+	}
+	return history[start:]
+}
+
+// fromUserTurn отбрасывает ответы модели в начале истории, чтобы она
+// начиналась с сообщения пользователя
+func fromUserTurn(history []models.ChatMessage) []models.ChatMessage {
+	for i, msg := range history {
+		if msg.Role == LLMRoleUser {
+			return history[i:]
+		}
+	}
+	return nil
+}
+
+// summarizeChat дополняет краткое содержание диалога новыми сообщениями
+func (s *AIService) summarizeChat(ctx context.Context, summary string, messages []models.ChatMessage) (string, error) {
+	provider, err := s.llm.Provider(LLMFeatureSummary, "")
+	if err != nil {
+		return "", err
+	}
+
+	var transcript strings.Builder
+	if summary != "" {
+		transcript.WriteString("Previous summary:\n" + summary + "\n\n")
+	}
+	transcript.WriteString("New messages:\n")
+	for _, msg := range messages {
+		text := msg.Content
+		if utf8.RuneCountInString(text) > chatSummaryMessageRunes {
+			text = string([]rune(text)[:chatSummaryMessageRunes]) + "…"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", msg.Role, text)
+	}
+
+	temperature := float32(0.2)
+	response, err := provider.Complete(ctx, LLMRequest{
+		System:      chatSummaryPrompt,
+		Messages:    []LLMMessage{{Role: LLMRoleUser, Content: transcript.String()}},
+		MaxTokens:   512,
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	result := strings.TrimSpace(response.Content)
+	if result == "" {
+		return "", fmt.Errorf("%s returned an empty summary", provider.Name())
+	}
+	return result, nil
+}
+
+// rememberSearchPreferences запоминает параметры успешного поиска как
+// предпочтения пользователя. Новый поиск заменяет прежние параметры целиком:
+// если пользователь убрал ограничение, оно не должно остаться в промпте.
+func (s *AIService) rememberSearchPreferences(session *models.ChatSession, records []models.ToolCallRecord) {
+	if session == nil {
+		return
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Name != "parse_properties" || record.Error != "" {
+			continue
+		}
+		var arguments map[string]interface{}
+		if err := json.Unmarshal(record.Arguments, &arguments); err != nil {
+			return
+		}
+		preferences := make(map[string]interface{})
+		for key, value := range arguments {
+			if !isEmptyPreference(value) {
+				preferences[key] = value
+			}
+		}
+		session.Context.PropertyPreferences = preferences
+		s.saveChatContext(session)
+		return
+	}
+}
+
+func isEmptyPreference(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// formatPreferences предпочтения строками "ключ: значение" в постоянном порядке
+func formatPreferences(preferences map[string]interface{}) string {
+	keys := make([]string, 0, len(preferences))
+	for key, value := range preferences {
+		if !isEmptyPreference(value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, key := range keys {
+		value, ok := preferences[key].(string)
+		if !ok {
+			encoded, _ := json.Marshal(preferences[key])
+			value = string(encoded)
+		}
+		lines = append(lines, "- "+key+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// saveChatContext сохраняет контекст сессии, ошибка только логируется: ответ
+// пользователю важнее, в следующий раз контекст соберется заново
+func (s *AIService) saveChatContext(session *models.ChatSession) {
+	if s.chatService == nil {
+		return
+	}
+	if err := s.chatService.UpdateContext(session); err != nil {
+		log.Printf("⚠️ AI Service: не удалось сохранить контекст сессии %s: %v", session.ID, err)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+// longSession сессия из пар вопрос-ответ, которая не помещается в бюджет
+func longSession(turns int) *models.ChatSession {
+	session := &models.ChatSession{ID: uuid.New()}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < turns; i++ {
+		for j, role := range []string{LLMRoleUser, LLMRoleAssistant} {
+			session.Messages = append(session.Messages, models.ChatMessage{
+				Role:      role,
+				Content:   strings.Repeat(role+" message ", 40),
+				CreatedAt: start.Add(time.Duration(2*i+j) * time.Minute),
+			})
+		}
+	}
+	last := models.ChatMessage{Role: LLMRoleUser, Content: "текущий вопрос", CreatedAt: start.Add(time.Hour)}
+	session.Messages = append(session.Messages, last)
+	return session
+}
+
+func newChatContextService(budget int, provider LLMProvider) *AIService {
+	service := NewAIService(&config.Config{AI: config.AIConfig{Provider: "fake", ChatHistoryTokens: budget}})
+	service.LLM().Register(provider)
+	return service
+}
+
+func TestBuildChatContextSummarizes(t *testing.T) {
+	fake := NewFakeLLM(LLMResponse{Content: "Пользователь ищет квартиру"})
+	service := newChatContextService(estimateTokens(chatSystemPrompt)+600, fake)
+	session := longSession(10)
+
+	context := service.buildChatContext(context.Background(), session, "текущий вопрос")
+
+	if len(fake.Requests()) != 1 || session.Context.Summary != "Пользователь ищет квартиру" || session.Context.SummarizedUntil == nil {
+		t.Fatalf("summary = %q, until = %v, requests = %d", session.Context.Summary, session.Context.SummarizedUntil, len(fake.Requests()))
+	}
+	if !strings.Contains(context.System, "Пользователь ищет квартиру") {
+		t.Errorf("system prompt has no summary")
+	}
+	if len(context.Messages) == 0 || context.Messages[0].Role != LLMRoleUser {
+		t.Fatalf("messages = %+v, want history starting with a user turn", context.Messages)
+	}
+	if last := context.Messages[len(context.Messages)-1]; last.Content != "текущий вопрос" {
+		t.Errorf("last message = %q", last.Content)
+	}
+	if len(context.Messages) >= len(session.Messages) {
+		t.Errorf("messages = %d, want older turns folded into the summary", len(context.Messages))
+	}
+}
+
+func TestBuildChatContextKeepsHistoryWhenSummaryFails(t *testing.T) {
+	// Пустой ответ - ошибка краткого содержания
+	fake := NewFakeLLM(LLMResponse{Content: " "})
+	budget := estimateTokens(chatSystemPrompt) + 1000
+	service := newChatContextService(budget, fake)
+	session := longSession(10)
+
+	context := service.buildChatContext(context.Background(), session, "текущий вопрос")
+
+	if session.Context.Summary != "" || session.Context.SummarizedUntil != nil {
+		t.Errorf("summary = %q, want none after a failed summary", session.Context.Summary)
+	}
+	if len(context.Messages) == 0 || context.Messages[0].Role != LLMRoleUser {
+		t.Fatalf("messages = %+v, want history starting with a user turn", context.Messages)
+	}
+
+	// Сообщений больше, чем при пересказе в полбюджета, но в пределах бюджета
+	tokens := estimateTokens(context.System)
+	for _, message := range context.Messages {
+		tokens += messageTokens(message.Content)
+	}
+	if tokens > budget {
+		t.Errorf("context = %d tokens, budget %d", tokens, budget)
+	}
+	if half := fromUserTurn(recentMessages(session.Messages, 500)); len(context.Messages) <= len(half) {
+		t.Errorf("messages = %d, want older turns kept within the budget", len(context.Messages))
+	}
+}
+
+func TestFromUserTurn(t *testing.T) {
+	history := []models.ChatMessage{
+		{Role: LLMRoleAssistant, Content: "a1"},
+		{Role: LLMRoleAssistant, Content: "a2"},
+		{Role: LLMRoleUser, Content: "u1"},
+		{Role: LLMRoleAssistant, Content: "a3"},
+	}
+	if got := fromUserTurn(history); len(got) != 2 || got[0].Content != "u1" {
+		t.Errorf("fromUserTurn = %+v", got)
+	}
+	if got := fromUserTurn(history[:2]); got != nil {
+		t.Errorf("fromUserTurn without user turns = %+v, want nil", got)
+	}
+}
@@ -22,10 +22,17 @@ func (s *ChatService) CreateSession(session *models.ChatSession) error {
 
 func (s *ChatService) GetSession(id string) (*models.ChatSession, error) {
 	var session models.ChatSession
-	err := s.db.Preload("Messages").Where("id = ?", id).First(&session).Error
+	err := s.db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", id).First(&session).Error
 	return &session, err
 }
 
+// UpdateContext сохраняет контекст сессии: предпочтения и краткое содержание диалога
+func (s *ChatService) UpdateContext(session *models.ChatSession) error {
+	return s.db.Model(&models.ChatSession{}).Where("id = ?", session.ID).Update("context", session.Context).Error
+}
+
 func (s *ChatService) SaveMessage(message *models.ChatMessage) error {
 	return s.db.Create(message).Error
 }
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Права инструментов чата: что нужно, чтобы модель могла вызвать инструмент
+const (
+	ChatToolPublic  = "public"  // расчеты и чтение данных, без ограничений
+	ChatToolConfirm = "confirm" // долгое действие, только после явного подтверждения пользователя
+	ChatToolUser    = "user"    // действие от имени пользователя сессии
+)
+
+var (
+	// ErrChatToolNotFound модель вызвала незарегистрированный инструмент
+	ErrChatToolNotFound = errors.New("unknown tool")
+	// ErrChatToolNotConfirmed в последнем сообщении пользователь не подтвердил действие.
+	// Текст ошибки получает модель, поэтому он говорит, что делать дальше.
+	ErrChatToolNotConfirmed = errors.New("the user has not confirmed this action yet: summarize the parameters and ask for explicit confirmation")
+	// ErrChatToolNoUser у сессии нет пользователя, от имени которого выполнить действие
+	ErrChatToolNoUser = errors.New("this action requires a signed in user")
+)
+
+// ChatToolEnv кто и откуда вызывает инструмент
+type ChatToolEnv struct {
+	SessionID   string
+	UserID      uuid.UUID // uuid.Nil, если сессия не найдена
+	UserMessage string    // последнее сообщение пользователя, по нему проверяется подтверждение
+	Progress    func(ProgressInfo)
+}
+
+// ChatToolResult результат инструмента
+type ChatToolResult struct {
+	Data    interface{} // передается модели как JSON
+	Actions []string    // добавляются в Actions метаданных ответа
+	// Response готовый ответ пользователю, например найденные объявления.
+	// Модель после него больше не вызывается.
+	Response *AIResponse
+}
+
+// ChatTool инструмент ассистента чата
+type ChatTool struct {
+	Name        string
+	Description string
+	Permission  string                 // ChatTool*
+	Parameters  map[string]interface{} // JSON Schema аргументов
+	run         func(ctx context.Context, env ChatToolEnv, arguments string) (*ChatToolResult, error)
+}
+
+// NewChatTool создает инструмент с аргументами типа A. JSON Schema строится по
+// структуре A (см. toolSchema), аргументы модели разбираются в A до вызова handler.
+func NewChatTool[A any](name, description, permission string, handler func(ctx context.Context, env ChatToolEnv, args A) (*ChatToolResult, error)) ChatTool {
+	return ChatTool{
+		Name:        name,
+		Description: description,
+		Permission:  permission,
+		Parameters:  toolSchema(reflect.TypeOf((*A)(nil)).Elem()),
+		run: func(ctx context.Context, env ChatToolEnv, arguments string) (*ChatToolResult, error) {
+			var args A
+			if err := json.Unmarshal([]byte(jsonObjectOrEmpty(arguments)), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			return handler(ctx, env, args)
+		},
+	}
+}
+
+// ChatToolRegistry инструменты, доступные модели в чате. Сервисы регистрируют
+// свои инструменты при создании контейнера.
+type ChatToolRegistry struct {
+	tools map[string]ChatTool
+	names []string // порядок регистрации
+}
+
+func NewChatToolRegistry() *ChatToolRegistry {
+	return &ChatToolRegistry{tools: make(map[string]ChatTool)}
+}
+
+// Register добавляет инструменты, инструмент с тем же именем заменяется
+func (r *ChatToolRegistry) Register(tools ...ChatTool) {
+	for _, tool := range tools {
+		if _, exists := r.tools[tool.Name]; !exists {
+			r.names = append(r.names, tool.Name)
+		}
+		r.tools[tool.Name] = tool
+	}
+}
+
+// Tools возвращает описания инструментов для запроса к модели
+func (r *ChatToolRegistry) Tools() []LLMTool {
+	tools := make([]LLMTool, 0, len(r.names))
+	for _, name := range r.names {
+		tool := r.tools[name]
+		tools = append(tools, LLMTool{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	return tools
+}
+
+// Execute проверяет права и вызывает инструмент
+func (r *ChatToolRegistry) Execute(ctx context.Context, env ChatToolEnv, call LLMToolCall) (*ChatToolResult, error) {
+	tool, ok := r.tools[call.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChatToolNotFound, call.Name)
+	}
+
+	switch tool.Permission {
+	case ChatToolConfirm:
+		if !hasUserConfirmation(env.UserMessage) {
+			return nil, ErrChatToolNotConfirmed
+		}
+	case ChatToolUser:
+		if env.UserID == uuid.Nil {
+			return nil, ErrChatToolNoUser
+		}
+	}
+
+	if env.Progress == nil {
+		env.Progress = func(ProgressInfo) {}
+	}
+	return tool.run(ctx, env, call.Arguments)
+}
+
+// hasUserConfirmation ищет в сообщении слово согласия. Только ЯВНЫЕ слова,
+// без слов из поисковых запросов: "найди", "ищи", "поиск" подтверждением не считаются.
+func hasUserConfirmation(message string) bool {
+	confirmationWords := map[string]bool{
+		"да": true, "согласен": true, "согласна": true, "подтверждаю": true, "запускай": true,
+		"давай": true, "окей": true, "ок": true, "старт": true, "иә": true, "yes": true, "ok": true,
+	}
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		if confirmationWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textUnmarshalerTy = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// toolSchema строит JSON Schema по типу аргументов. Теги полей те же, что
+// понимает swag: имя из json, обязательность из binding:"required", enums,
+// minimum, maximum и format; описание поля - из тега description.
+func toolSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.PointerTo(t).Implements(textUnmarshalerTy):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		var required []string
+		addStructFields(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": toolSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// addStructFields добавляет в схему поля структуры, поля встроенных структур
+// поднимаются на уровень выше, как при разборе JSON
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := toolSchema(field.Type)
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		if format := field.Tag.Get("format"); format != "" {
+			schema["format"] = format
+		}
+		if enums := field.Tag.Get("enums"); enums != "" {
+			target := schema
+			if items, ok := schema["items"].(map[string]interface{}); ok {
+				target = items // enums у массива относится к элементам, как в swag
+			}
+			target["enum"] = strings.Split(enums, ",")
+		}
+		for _, key := range []string{"minimum", "maximum"} {
+			if value, err := strconv.ParseFloat(field.Tag.Get(key), 64); err == nil {
+				schema[key] = value
+			}
+		}
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
@@ -8,15 +8,26 @@ import (
 )
 
 type Container struct {
-	Auth      *AuthService
-	User      *UserService
-	Property  *PropertyService
-	Chat      *ChatService
-	AI        *AIService
-	Search    *SearchService
-	Targeting *TargetingService
-	Analytics *AnalyticsService
-	Parser    *ParserService
+	Auth       *AuthService
+	User       *UserService
+	Property   *PropertyService
+	Chat       *ChatService
+	AI         *AIService
+	Search     *SearchService
+	Targeting  *TargetingService
+	Analytics  *AnalyticsService
+	Parser     *ParserService
+	ParseQueue *ParseQueue
+	Listing    *ListingService
+	Duplicate  *DuplicateService
+	Scheduler  *CrawlScheduler
+	Selectors  *SelectorStore
+	Health     *SourceHealthService
+	Geocoder   *GeocodeService
+	POI        *POIService
+	Searches   *SavedSearchService
+	Locations  *LocationDictionary
+	Viewings   *ViewingService
 }
 
 func NewContainer(db *gorm.DB, redis *redis.Client, cfg *config.Config) *Container {
@@ -29,23 +40,56 @@ func NewContainer(db *gorm.DB, redis *redis.Client, cfg *config.Config) *Contain
 	searchService := NewSearchService(db, redis)
 	targetingService := NewTargetingService(db, redis, cfg)
 	analyticsService := NewAnalyticsService(db, redis)
-	parserService := NewParserService(db)
-	krishaFilterService := NewKrishaFilterService()
+	fetcher := NewPoliteFetcher(cfg.Parser)
+	selectors := NewSelectorStore(cfg.Parser)
+	parserService := NewParserService(db, fetcher, selectors, cfg.Parser)
+	krishaFilterService := NewKrishaFilterService(fetcher, selectors)
+	parseQueue := NewParseQueue(db, redis, parserService, cfg.Parser)
+	listingService := NewListingService(db, cfg.Parser)
+	duplicateService := NewDuplicateService(db)
+	crawlScheduler := NewCrawlScheduler(db, parseQueue, parserService, cfg.Parser)
+	healthService := NewSourceHealthService(db, cfg.Parser)
+	geocodeService := NewGeocodeService(db, cfg.Geocoder)
+	poiService := NewPOIService(db)
+	savedSearchService := NewSavedSearchService(db, parserService)
+	viewingService := NewViewingService(db)
 
 	// Set up AI service integrations
 	aiService.SetParserService(parserService)
 	aiService.SetChatService(chatService)
 	aiService.SetKrishaFilterService(krishaFilterService)
+	aiService.Tools().Register(analyticsService.ChatTools()...)
+	aiService.Tools().Register(viewingService.ChatTools()...)
+	parserService.SetListingService(listingService)
+	parserService.SetDuplicateService(duplicateService)
+	parserService.SetHealthService(healthService)
+	parserService.SetGeocoder(geocodeService)
+	parserService.SetPOIService(poiService)
+	propertyService.SetGeocoder(geocodeService)
+	if cfg.Parser.DetailEnabled {
+		parserService.SetDetailEnricher(NewDetailEnricher(db, parserService.Sources(), cfg.Parser))
+	}
 
 	return &Container{
-		Auth:      authService,
-		User:      userService,
-		Property:  propertyService,
-		Chat:      chatService,
-		AI:        aiService,
-		Search:    searchService,
-		Targeting: targetingService,
-		Analytics: analyticsService,
-		Parser:    parserService,
+		Auth:       authService,
+		User:       userService,
+		Property:   propertyService,
+		Chat:       chatService,
+		AI:         aiService,
+		Search:     searchService,
+		Targeting:  targetingService,
+		Analytics:  analyticsService,
+		Parser:     parserService,
+		ParseQueue: parseQueue,
+		Listing:    listingService,
+		Duplicate:  duplicateService,
+		Scheduler:  crawlScheduler,
+		Selectors:  selectors,
+		Health:     healthService,
+		Geocoder:   geocodeService,
+		POI:        poiService,
+		Searches:   savedSearchService,
+		Locations:  DefaultLocations(),
+		Viewings:   viewingService,
 	}
 }
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+const (
+	crawlSchedulerDefaultInterval = 30 * time.Second
+	crawlSchedulerBatchSize       = 50
+	crawlScheduleMaxPages         = 10
+)
+
+var ErrCrawlScheduleNotFound = errors.New("crawl schedule not found")
+
+// CrawlScheduleInput параметры создания расписания
+type CrawlScheduleInput struct {
+	Name    string                 `json:"name" binding:"required"`
+	Sources []string               `json:"sources"`
+	Filters models.PropertyFilters `json:"filters"`
+	// SearchURL ссылка на поиск krisha.kz или olx.kz. Если задана, фильтры
+	// берутся из нее, а источник - из ссылки, если sources не указаны.
+	SearchURL string `json:"search_url"`
+	Cron      string `json:"cron" binding:"required"`
+	MaxPages  int    `json:"max_pages"`
+	Paused    bool   `json:"paused"`
+}
+
+// CrawlScheduler запускает парсинг по расписаниям из таблицы crawl_schedules.
+// Запуск ставит обычный запрос в ParseQueue, поэтому выполнение, повторы и
+// отмена работают так же, как для запросов пользователей. Время следующего
+// запуска занимается условным UPDATE, так что несколько экземпляров сервера
+// не запустят одно расписание дважды.
+type CrawlScheduler struct {
+	db       *gorm.DB
+	queue    *ParseQueue
+	parser   *ParserService
+	enabled  bool
+	interval time.Duration
+}
+
+// NewCrawlScheduler создает планировщик регулярного парсинга
+func NewCrawlScheduler(db *gorm.DB, queue *ParseQueue, parser *ParserService, cfg config.ParserConfig) *CrawlScheduler {
+	interval := time.Duration(cfg.SchedulerInterval) * time.Second
+	if interval <= 0 {
+		interval = crawlSchedulerDefaultInterval
+	}
+
+	return &CrawlScheduler{
+		db:       db,
+		queue:    queue,
+		parser:   parser,
+		enabled:  cfg.SchedulerEnabled,
+		interval: interval,
+	}
+}
+
+// parseCron разбирает cron выражение: 5 полей, дескрипторы @hourly/@daily/@every
+// и префикс CRON_TZ= для часового пояса
+func parseCron(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(strings.TrimSpace(expr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// Create проверяет и сохраняет новое расписание
+func (s *CrawlScheduler) Create(input CrawlScheduleInput, createdBy *uuid.UUID) (*models.CrawlSchedule, error) {
+	schedule, err := parseCron(input.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.SearchURL != "" {
+		imported, err := ParseSearchURL(input.SearchURL)
+		if err != nil {
+			return nil, err
+		}
+		input.Filters = imported.Filters
+		if len(input.Sources) == 0 {
+			input.Sources = []string{imported.Source}
+		}
+	}
+
+	// Источники и фильтры проверяем сразу, чтобы не узнать об ошибке при первом запуске
+	if _, err := s.parser.Sources().Resolve(input.Sources); err != nil {
+		return nil, err
+	}
+	if err := normalizeSearchFilters(&input.Filters); err != nil {
+		return nil, err
+	}
+
+	maxPages := input.MaxPages
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+	if maxPages > crawlScheduleMaxPages {
+		maxPages = crawlScheduleMaxPages
+	}
+
+	crawlSchedule := &models.CrawlSchedule{
+		Name:      strings.TrimSpace(input.Name),
+		Sources:   models.StringSlice(input.Sources),
+		Filters:   input.Filters,
+		Cron:      strings.TrimSpace(input.Cron),
+		MaxPages:  maxPages,
+		IsPaused:  input.Paused,
+		CreatedBy: createdBy,
+	}
+	if !crawlSchedule.IsPaused {
+		next := schedule.Next(time.Now())
+		crawlSchedule.NextRunAt = &next
+	}
+
+	if err := s.db.Create(crawlSchedule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create crawl schedule: %w", err)
+	}
+	return crawlSchedule, nil
+}
+
+// List возвращает все расписания
+func (s *CrawlScheduler) List() ([]models.CrawlSchedule, error) {
+	var schedules []models.CrawlSchedule
+	err := s.db.Order("created_at ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// Get возвращает расписание по ID
+func (s *CrawlScheduler) Get(id uuid.UUID) (*models.CrawlSchedule, error) {
+	var schedule models.CrawlSchedule
+	if err := s.db.Where("id = ?", id).First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCrawlScheduleNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListRuns возвращает последние запуски расписания
+func (s *CrawlScheduler) ListRuns(id uuid.UUID, limit int) ([]models.ParseRequest, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	var runs []models.ParseRequest
+	err := s.db.Omit("results").
+		Where("schedule_id = ?", id).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// SetPaused ставит расписание на паузу или возобновляет его. При возобновлении
+// следующий запуск считается от текущего момента, пропущенные не догоняются.
+func (s *CrawlScheduler) SetPaused(id uuid.UUID, paused bool) (*models.CrawlSchedule, error) {
+	crawlSchedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextRunAt *time.Time
+	if !paused {
+		schedule, err := parseCron(crawlSchedule.Cron)
+		if err != nil {
+			return nil, err
+		}
+		next := schedule.Next(time.Now())
+		nextRunAt = &next
+	}
+
+	if err := s.db.Model(crawlSchedule).Updates(map[string]interface{}{
+		"is_paused":   paused,
+		"next_run_at": nextRunAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	crawlSchedule.IsPaused = paused
+	crawlSchedule.NextRunAt = nextRunAt
+	return crawlSchedule, nil
+}
+
+// Delete удаляет расписание. Уже поставленные запуски не отменяются.
+func (s *CrawlScheduler) Delete(id uuid.UUID) error {
+	result := s.db.Where("id = ?", id).Delete(&models.CrawlSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCrawlScheduleNotFound
+	}
+	return nil
+}
+
+// Trigger запускает расписание вне очереди, в том числе стоящее на паузе.
+// Время следующего планового запуска не меняется.
+func (s *CrawlScheduler) Trigger(ctx context.Context, id uuid.UUID) (*models.ParseRequest, error) {
+	crawlSchedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, crawlSchedule)
+}
+
+// Start периодически запускает расписания, время которых подошло
+func (s *CrawlScheduler) Start(ctx context.Context) {
+	if !s.enabled {
+		log.Println("Crawl scheduler is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.runDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runDue находит и запускает расписания с наступившим next_run_at
+func (s *CrawlScheduler) runDue(ctx context.Context) {
+	now := time.Now()
+
+	var due []models.CrawlSchedule
+	if err := s.db.Where("is_paused = ? AND next_run_at <= ?", false, now).
+		Order("next_run_at ASC").
+		Limit(crawlSchedulerBatchSize).
+		Find(&due).Error; err != nil {
+		log.Printf("Failed to load due crawl schedules: %v", err)
+		return
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		crawlSchedule := &due[i]
+		claimed, err := s.claim(crawlSchedule, now)
+		if err != nil {
+			log.Printf("Failed to claim crawl schedule %s: %v", crawlSchedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		// Не накапливаем запуски, если предыдущий еще в очереди или выполняется
+		if s.previousRunActive(crawlSchedule) {
+			s.db.Model(crawlSchedule).Updates(map[string]interface{}{
+				"last_run_at": now,
+				"last_status": models.CrawlRunStatusSkipped,
+			})
+			log.Printf("Crawl schedule %q skipped: previous run is still active", crawlSchedule.Name)
+			continue
+		}
+
+		if _, err := s.run(ctx, crawlSchedule); err != nil {
+			log.Printf("Crawl schedule %q failed to start: %v", crawlSchedule.Name, err)
+		}
+	}
+}
+
+// claim переносит next_run_at на следующее время по cron. Возвращает false,
+// если расписание уже забрал другой экземпляр сервера.
+func (s *CrawlScheduler) claim(crawlSchedule *models.CrawlSchedule, now time.Time) (bool, error) {
+	var nextRunAt *time.Time
+	isPaused := false
+
+	schedule, err := parseCron(crawlSchedule.Cron)
+	if err != nil {
+		// Некорректное выражение могли записать в базу вручную, ставим на паузу
+		isPaused = true
+	} else {
+		next := schedule.Next(now)
+		nextRunAt = &next
+	}
+
+	result := s.db.Model(&models.CrawlSchedule{}).
+		Where("id = ? AND next_run_at = ?", crawlSchedule.ID, crawlSchedule.NextRunAt).
+		Updates(map[string]interface{}{
+			"next_run_at": nextRunAt,
+			"is_paused":   isPaused,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err != nil {
+		s.db.Model(crawlSchedule).Updates(map[string]interface{}{
+			"last_status": models.ParseStatusFailed,
+			"last_error":  err.Error(),
+		})
+		return false, err
+	}
+
+	crawlSchedule.NextRunAt = nextRunAt
+	return true, nil
+}
+
+// previousRunActive сообщает, что последний запуск расписания еще не завершен
+func (s *CrawlScheduler) previousRunActive(crawlSchedule *models.CrawlSchedule) bool {
+	if crawlSchedule.LastParseRequestID == nil {
+		return false
+	}
+
+	var count int64
+	s.db.Model(&models.ParseRequest{}).
+		Where("id = ? AND status IN ?", *crawlSchedule.LastParseRequestID,
+			[]string{models.ParseStatusPending, models.ParseStatusProcessing}).
+		Count(&count)
+	return count > 0
+}
+
+// run ставит запрос парсинга по расписанию в очередь и запоминает его
+func (s *CrawlScheduler) run(ctx context.Context, crawlSchedule *models.CrawlSchedule) (*models.ParseRequest, error) {
+	now := time.Now()
+
+	parseRequest, err := s.parser.BuildParseRequest(crawlSchedule.Filters, crawlSchedule.Sources, crawlSchedule.MaxPages, nil)
+	if err == nil {
+		parseRequest.ScheduleID = &crawlSchedule.ID
+		parseRequest, err = s.queue.EnqueueRequest(ctx, parseRequest)
+	}
+
+	updates := map[string]interface{}{
+		"last_run_at": now,
+		"last_count":  0,
+	}
+	if err != nil {
+		updates["last_status"] = models.ParseStatusFailed
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_status"] = models.CrawlRunStatusQueued
+		updates["last_error"] = ""
+		updates["last_parse_request_id"] = parseRequest.ID
+	}
+
+	if updateErr := s.db.Model(&models.CrawlSchedule{}).Where("id = ?", crawlSchedule.ID).Updates(updates).Error; updateErr != nil {
+		log.Printf("Failed to update crawl schedule %s: %v", crawlSchedule.ID, updateErr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Воркер мог завершить запрос раньше, чем он был записан в расписание
+	var current models.ParseRequest
+	if s.db.Select("id", "status", "error", "count").Where("id = ?", parseRequest.ID).First(&current).Error == nil && current.IsFinished() {
+		s.db.Model(&models.CrawlSchedule{}).
+			Where("id = ? AND last_parse_request_id = ?", crawlSchedule.ID, parseRequest.ID).
+			Updates(map[string]interface{}{
+				"last_status": current.Status,
+				"last_error":  current.Error,
+				"last_count":  current.Count,
+			})
+	}
+
+	return parseRequest, nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"gorm.io/gorm"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+const (
+	detailDefaultConcurrency = 3
+	detailDefaultMaxAttempts = 3
+	detailDefaultRetryDelay  = time.Second
+	detailDefaultMaxPerRun   = 50
+)
+
+// DetailEnricher загружает страницы новых объявлений после поиска и дополняет
+// карточки тем, чего нет в выдаче: полным описанием, всеми фото, параметрами
+// дома, продавцом, датой публикации и просмотрами. Объявления, страница которых
+// уже загружалась раньше, пропускаются.
+type DetailEnricher struct {
+	db          *gorm.DB
+	sources     *SourceRegistry
+	concurrency int
+	maxAttempts int
+	retryDelay  time.Duration
+	maxPerRun   int
+}
+
+// EnrichStats итог обогащения за один запуск
+type EnrichStats struct {
+	Pending  int // новых объявлений без загруженной страницы
+	Enriched int
+	Failed   int
+	Skipped  int // не уложились в лимит на запуск или в таймаут запроса
+}
+
+// NewDetailEnricher создает этап обогащения с лимитами из конфигурации
+func NewDetailEnricher(db *gorm.DB, sources *SourceRegistry, cfg config.ParserConfig) *DetailEnricher {
+	e := &DetailEnricher{
+		db:          db,
+		sources:     sources,
+		concurrency: cfg.DetailConcurrency,
+		maxAttempts: cfg.DetailMaxAttempts,
+		retryDelay:  time.Duration(cfg.DetailRetryDelay) * time.Millisecond,
+		maxPerRun:   cfg.DetailMaxPerRun,
+	}
+	if e.concurrency <= 0 {
+		e.concurrency = detailDefaultConcurrency
+	}
+	if e.maxAttempts <= 0 {
+		e.maxAttempts = detailDefaultMaxAttempts
+	}
+	if e.retryDelay <= 0 {
+		e.retryDelay = detailDefaultRetryDelay
+	}
+	if e.maxPerRun <= 0 {
+		e.maxPerRun = detailDefaultMaxPerRun
+	}
+	return e
+}
+
+// Enrich дополняет новые объявления из properties на месте
+func (e *DetailEnricher) Enrich(ctx context.Context, properties []models.ParsedProperty) EnrichStats {
+	pending := e.pending(properties)
+	stats := EnrichStats{Pending: len(pending)}
+	if len(pending) == 0 {
+		return stats
+	}
+	if len(pending) > e.maxPerRun {
+		stats.Skipped = len(pending) - e.maxPerRun
+		pending = pending[:e.maxPerRun]
+	}
+
+	workers := e.concurrency
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := e.enrichOne(ctx, &properties[i])
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					stats.Enriched++
+				case ctx.Err() != nil:
+					stats.Skipped++
+				default:
+					stats.Failed++
+					log.Printf("Detail enrichment failed for %s/%s: %v", properties[i].Source, properties[i].ID, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+			sent++
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	stats.Skipped += len(pending) - sent
+	log.Printf("Detail enrichment: %d new, %d enriched, %d failed, %d skipped",
+		stats.Pending, stats.Enriched, stats.Failed, stats.Skipped)
+	return stats
+}
+
+// pending возвращает индексы объявлений, страницы которых еще не загружались.
+// Повторы одного объявления в выдаче обогащаются один раз.
+func (e *DetailEnricher) pending(properties []models.ParsedProperty) []int {
+	var keys [][]interface{}
+	for _, property := range properties {
+		if property.Source != "" && property.ID != "" {
+			keys = append(keys, []interface{}{property.Source, property.ID})
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	enriched := make(map[string]bool)
+	var known []models.Listing
+	if err := e.db.Select("source", "external_id").
+		Where("detail_fetched_at IS NOT NULL AND (source, external_id) IN ?", keys).
+		Find(&known).Error; err != nil {
+		log.Printf("Failed to load enriched listings, enriching all: %v", err)
+	}
+	for _, listing := range known {
+		enriched[listing.Source+"/"+listing.ExternalID] = true
+	}
+
+	var indexes []int
+	for i, property := range properties {
+		key := property.Source + "/" + property.ID
+		if property.ID == "" || property.URL == "" || property.DetailFetchedAt != nil || enriched[key] {
+			continue
+		}
+		enriched[key] = true
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// enrichOne загружает страницу объявления и переносит ее данные в карточку
+func (e *DetailEnricher) enrichOne(ctx context.Context, property *models.ParsedProperty) error {
+	source, ok := e.sources.Get(property.Source)
+	if !ok {
+		return fmt.Errorf("unknown listing source %q", property.Source)
+	}
+
+	doc, err := e.fetch(ctx, source, property.URL)
+	if err != nil {
+		return err
+	}
+
+	// Карточка остается основой: страница дополняет и уточняет ее поля
+	detail := *property
+	source.ExtractDetail(doc, &detail)
+	detail.ID = property.ID
+	detail.URL = property.URL
+
+	now := time.Now()
+	detail.DetailFetchedAt = &now
+	*property = detail
+	return nil
+}
+
+// fetch загружает страницу, повторяя временные ошибки с растущей паузой
+func (e *DetailEnricher) fetch(ctx context.Context, source ListingSource, pageURL string) (*goquery.Document, error) {
+	var lastErr error
+	for attempt := 1; attempt <= e.maxAttempts; attempt++ {
+		doc, err := source.FetchPage(ctx, pageURL)
+		if err == nil {
+			return doc, nil
+		}
+		lastErr = err
+
+		if attempt == e.maxAttempts || !isRetryableFetchError(ctx, err) {
+			break
+		}
+		select {
+		case <-time.After(e.retryDelay * time.Duration(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// isRetryableFetchError сообщает, что загрузку стоит повторить. Ошибки HTTP
+// запроса уже повторены загрузчиком с паузой, а запрет robots.txt и отключение
+// площадки повтор не исправит, поэтому повторяются только прочие ошибки,
+// например загрузки через браузер.
+func isRetryableFetchError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var attemptsErr *FetchAttemptsError
+	var statusErr *HTTPStatusError
+	var circuitErr *CircuitOpenError
+	if errors.As(err, &attemptsErr) || errors.As(err, &statusErr) || errors.As(err, &circuitErr) || errors.Is(err, ErrRobotsDisallowed) {
+		return false
+	}
+	return true
+}
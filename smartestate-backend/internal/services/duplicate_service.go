@@ -0,0 +1,631 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/models"
+)
+
+const (
+	// duplicateThreshold минимальная оценка сходства для объединения пары
+	duplicateThreshold = 0.8
+	// duplicateMinEvidence минимальный суммарный вес признаков, по которым
+	// можно сравнить пару. Пары с малым количеством данных не объединяем.
+	duplicateMinEvidence = 0.45
+	// hashedImagesPerListing сколько фото объявления участвуют в сравнении
+	hashedImagesPerListing = 3
+)
+
+// Веса признаков при оценке сходства
+const (
+	weightAddress = 0.25
+	weightArea    = 0.2
+	weightFloor   = 0.15
+	weightRooms   = 0.1
+	weightPrice   = 0.15
+	weightImages  = 0.15
+)
+
+// addressStopWords служебные слова адреса, не помогающие сравнению
+var addressStopWords = map[string]bool{
+	"г": true, "город": true, "алматы": true, "астана": true, "нур": true, "султан": true, "шымкент": true,
+	"ул": true, "улица": true, "пр": true, "пр-т": true, "проспект": true, "мкр": true, "микрорайон": true,
+	"р": true, "н": true, "р-н": true, "район": true, "д": true, "дом": true, "кв": true, "квартира": true,
+	"жк": true, "угол": true, "уг": true, "и": true, "в": true, "на": true,
+}
+
+// duplicateCandidate признаки объявления, по которым ищутся дубликаты
+type duplicateCandidate struct {
+	source      string
+	dealType    string
+	category    string
+	address     map[string]bool
+	area        *float64
+	floor       *int
+	totalFloors *int
+	landArea    *float64
+	rooms       *int
+	price       int64
+	currency    string
+	images      []string
+	hashes      []uint64
+	hashed      bool
+}
+
+func candidateFromParsed(p models.ParsedProperty) *duplicateCandidate {
+	return &duplicateCandidate{
+		source:      p.Source,
+		dealType:    p.DealType,
+		category:    p.PropertyType,
+		address:     normalizeAddress(p.Address),
+		area:        p.Area,
+		floor:       p.Floor,
+		totalFloors: p.TotalFloors,
+		landArea:    p.LandArea,
+		rooms:       p.Rooms,
+		price:       p.Price,
+		currency:    p.Currency,
+		images:      p.Images,
+	}
+}
+
+func candidateFromListing(l models.Listing) *duplicateCandidate {
+	hashes := parseImageHashes(l.ImageHash)
+	return &duplicateCandidate{
+		source:      l.Source,
+		dealType:    l.DealType,
+		category:    l.PropertyType,
+		address:     normalizeAddress(l.Address),
+		area:        l.Area,
+		floor:       l.Floor,
+		totalFloors: l.TotalFloors,
+		landArea:    l.LandArea,
+		rooms:       l.Rooms,
+		price:       l.Price,
+		currency:    l.Currency,
+		images:      l.Images,
+		hashes:      hashes,
+		hashed:      len(hashes) > 0,
+	}
+}
+
+// DuplicateService находит одно и то же объявление на разных площадках.
+// Пары оцениваются по адресу, площади, этажу, комнатам, цене и
+// перцептивным хэшам фото, связанные пары объединяются в группы.
+type DuplicateService struct {
+	db     *gorm.DB
+	hasher *ImageHasher
+}
+
+func NewDuplicateService(db *gorm.DB) *DuplicateService {
+	return &DuplicateService{
+		db:     db,
+		hasher: NewImageHasher(),
+	}
+}
+
+// GroupParsed группирует дубликаты среди результатов одного парсинга.
+// Возвращаются только группы из двух и более объявлений.
+func (s *DuplicateService) GroupParsed(ctx context.Context, properties []models.ParsedProperty) []models.PropertyCluster {
+	candidates := make([]*duplicateCandidate, len(properties))
+	for i, property := range properties {
+		candidates[i] = candidateFromParsed(property)
+	}
+
+	groups := newUnionFind(len(properties))
+	edgeScores := make(map[int]float64) // минимальная оценка пары по первому элементу пары
+
+	for i := 0; i < len(properties); i++ {
+		for j := i + 1; j < len(properties); j++ {
+			score, ok := s.compare(ctx, candidates[i], candidates[j])
+			if !ok {
+				continue
+			}
+			groups.union(i, j)
+			if current, exists := edgeScores[i]; !exists || score < current {
+				edgeScores[i] = score
+			}
+		}
+	}
+
+	var clusters []models.PropertyCluster
+	for _, members := range groups.groups() {
+		if len(members) < 2 {
+			continue
+		}
+
+		canonical := members[0]
+		for _, i := range members[1:] {
+			if parsedCompleteness(properties[i]) > parsedCompleteness(properties[canonical]) {
+				canonical = i
+			}
+		}
+
+		cluster := models.PropertyCluster{
+			CanonicalID: properties[canonical].ID,
+			Score:       1,
+		}
+		for _, i := range members {
+			cluster.MemberIDs = append(cluster.MemberIDs, properties[i].ID)
+			cluster.Sources = append(cluster.Sources, properties[i].Source)
+			if score, ok := edgeScores[i]; ok && score < cluster.Score {
+				cluster.Score = score
+			}
+		}
+		cluster.Score = math.Round(cluster.Score*100) / 100
+		cluster.Sources = uniqueStrings(cluster.Sources)
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters
+}
+
+// ClusterListings ищет в каталоге дубликаты указанных объявлений и
+// объединяет их в группы. Вызывается после сохранения результатов парсинга.
+func (s *DuplicateService) ClusterListings(ctx context.Context, listingIDs []uuid.UUID) {
+	for _, listingID := range listingIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var listing models.Listing
+		if err := s.db.Where("id = ?", listingID).First(&listing).Error; err != nil {
+			continue
+		}
+
+		candidates, err := s.findCandidates(listing)
+		if err != nil {
+			log.Printf("Duplicate search failed for listing %s: %v", listingID, err)
+			continue
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		base := candidateFromListing(listing)
+		for _, other := range candidates {
+			otherCandidate := candidateFromListing(other)
+			score, ok := s.compare(ctx, base, otherCandidate)
+			s.saveImageHashes(listing.ID, base)
+			s.saveImageHashes(other.ID, otherCandidate)
+			if !ok {
+				continue
+			}
+
+			if err := s.link(listing.ID, other.ID, score); err != nil {
+				log.Printf("Failed to link duplicate listings %s and %s: %v", listing.ID, other.ID, err)
+			}
+		}
+	}
+}
+
+// findCandidates выбирает из каталога объявления других площадок,
+// которые могут быть дубликатами: тот же город, комнаты и близкая площадь.
+func (s *DuplicateService) findCandidates(listing models.Listing) ([]models.Listing, error) {
+	query := s.db.Where("id <> ? AND source <> ? AND is_active = ? AND city = ?", listing.ID, listing.Source, true, listing.City).
+		Where("deal_type = ? AND property_type = ?", dealTypeOrSale(listing.DealType), propertyTypeOrApartment(listing.PropertyType))
+
+	if listing.Rooms != nil {
+		query = query.Where("(rooms IS NULL OR rooms = ?)", *listing.Rooms)
+	}
+	if listing.Area != nil {
+		query = query.Where("(area IS NULL OR area BETWEEN ? AND ?)", *listing.Area*0.9, *listing.Area*1.1)
+	}
+	if listing.Price > 0 {
+		query = query.Where("price BETWEEN ? AND ?", float64(listing.Price)*0.75, float64(listing.Price)*1.25)
+	}
+
+	var candidates []models.Listing
+	err := query.Limit(50).Find(&candidates).Error
+	return candidates, err
+}
+
+// link объединяет два объявления в одну группу, сливая существующие группы
+func (s *DuplicateService) link(aID, bID uuid.UUID, score float64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var pair []models.Listing
+		if err := tx.Where("id IN ?", []uuid.UUID{aID, bID}).Find(&pair).Error; err != nil {
+			return err
+		}
+		if len(pair) != 2 {
+			return nil
+		}
+
+		var clusterIDs []uuid.UUID
+		for _, listing := range pair {
+			if listing.ClusterID != nil {
+				clusterIDs = append(clusterIDs, *listing.ClusterID)
+			}
+		}
+
+		var cluster models.ListingCluster
+		if len(clusterIDs) > 0 {
+			if err := tx.Where("id = ?", clusterIDs[0]).First(&cluster).Error; err != nil {
+				return err
+			}
+		} else {
+			cluster = models.ListingCluster{CanonicalListingID: aID, Score: score}
+			if err := tx.Create(&cluster).Error; err != nil {
+				return err
+			}
+		}
+
+		// Переносим объявления второй группы и сами объявления пары в итоговую группу
+		for _, clusterID := range clusterIDs[min(1, len(clusterIDs)):] {
+			if clusterID == cluster.ID {
+				continue
+			}
+			if err := tx.Model(&models.Listing{}).Where("cluster_id = ?", clusterID).Update("cluster_id", cluster.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.ListingCluster{}, "id = ?", clusterID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Listing{}).Where("id IN ?", []uuid.UUID{aID, bID}).Update("cluster_id", cluster.ID).Error; err != nil {
+			return err
+		}
+
+		return s.refreshCluster(tx, &cluster, score)
+	})
+}
+
+// refreshCluster пересчитывает размер и каноническое объявление группы
+func (s *DuplicateService) refreshCluster(tx *gorm.DB, cluster *models.ListingCluster, score float64) error {
+	var members []models.Listing
+	if err := tx.Where("cluster_id = ?", cluster.ID).Find(&members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	canonical := members[0]
+	for _, member := range members[1:] {
+		if parsedCompleteness(member.ToParseProperty()) > parsedCompleteness(canonical.ToParseProperty()) {
+			canonical = member
+		}
+	}
+
+	if cluster.Score == 0 || score < cluster.Score {
+		cluster.Score = math.Round(score*100) / 100
+	}
+
+	return tx.Model(cluster).Updates(map[string]interface{}{
+		"canonical_listing_id": canonical.ID,
+		"size":                 len(members),
+		"score":                cluster.Score,
+	}).Error
+}
+
+// saveImageHashes сохраняет посчитанные хэши фото, чтобы не скачивать их повторно
+func (s *DuplicateService) saveImageHashes(listingID uuid.UUID, candidate *duplicateCandidate) {
+	if !candidate.hashed || len(candidate.hashes) == 0 {
+		return
+	}
+	s.db.Model(&models.Listing{}).Where("id = ? AND (image_hash IS NULL OR image_hash = '')", listingID).
+		Update("image_hash", formatImageHashes(candidate.hashes))
+}
+
+// ListClusters возвращает группы дубликатов с объявлениями, начиная с новых
+func (s *DuplicateService) ListClusters(page, limit int) ([]models.ListingCluster, int64, error) {
+	var clusters []models.ListingCluster
+	var total int64
+
+	query := s.db.Model(&models.ListingCluster{}).Where("size > 1")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	err := query.Preload("Listings").Order("updated_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&clusters).Error
+	return clusters, total, err
+}
+
+// GetListingCluster возвращает группу дубликатов, в которую входит объявление.
+// Если дубликатов нет, возвращается nil без ошибки.
+func (s *DuplicateService) GetListingCluster(listingID uuid.UUID) (*models.ListingCluster, error) {
+	var listing models.Listing
+	if err := s.db.Select("id", "cluster_id").Where("id = ?", listingID).First(&listing).Error; err != nil {
+		return nil, err
+	}
+	if listing.ClusterID == nil {
+		return nil, nil
+	}
+
+	var cluster models.ListingCluster
+	if err := s.db.Preload("Listings").Where("id = ?", *listing.ClusterID).First(&cluster).Error; err != nil {
+		return nil, err
+	}
+	return &cluster, nil
+}
+
+// compare оценивает сходство пары объявлений. Второе значение сообщает,
+// что пара считается дубликатом.
+func (s *DuplicateService) compare(ctx context.Context, a, b *duplicateCandidate) (float64, bool) {
+	// Дубликаты ищем только между площадками
+	if a.source == b.source {
+		return 0, false
+	}
+
+	score, evidence, ok := scoreDuplicate(a, b)
+	if !ok {
+		return 0, false
+	}
+
+	// Фото сравниваем только для пар, прошедших жесткие проверки
+	sameObject := sameObjectEvidence(a, b)
+	if len(a.images) > 0 && len(b.images) > 0 {
+		s.ensureHashes(ctx, a)
+		s.ensureHashes(ctx, b)
+		if imageScore, ok := compareImageHashes(a.hashes, b.hashes); ok {
+			score = (score*evidence + imageScore*weightImages) / (evidence + weightImages)
+			evidence += weightImages
+			sameObject = sameObject || imageScore >= duplicateThreshold
+		}
+	}
+
+	if evidence < duplicateMinEvidence || !sameObject {
+		return score, false
+	}
+	return score, score >= duplicateThreshold
+}
+
+// sameObjectEvidence сообщает, что у пары сравнены площадь или этаж.
+// Комнаты, район и цена совпадают у многих квартир одного района, поэтому
+// без признака самого объекта (площади, этажа или фото) пару не объединяем.
+// Вызывается после scoreDuplicate: сравненные признаки уже прошли допуск.
+func sameObjectEvidence(a, b *duplicateCandidate) bool {
+	if a.floor != nil && b.floor != nil {
+		return true
+	}
+	if a.area != nil && b.area != nil && *a.area > 0 && *b.area > 0 {
+		return true
+	}
+	return a.landArea != nil && b.landArea != nil && *a.landArea > 0 && *b.landArea > 0
+}
+
+func (s *DuplicateService) ensureHashes(ctx context.Context, candidate *duplicateCandidate) {
+	if candidate.hashed {
+		return
+	}
+	candidate.hashes = s.hasher.HashAll(ctx, candidate.images, hashedImagesPerListing)
+	candidate.hashed = true
+}
+
+// scoreDuplicate сравнивает пару по структурированным признакам.
+// Возвращает взвешенную оценку, суммарный вес сравненных признаков и false,
+// если пара отсекается жесткими проверками (разные комнаты, этаж и т.п.).
+func scoreDuplicate(a, b *duplicateCandidate) (score, evidence float64, ok bool) {
+	var total float64
+
+	add := func(weight, value float64) {
+		total += weight * value
+		evidence += weight
+	}
+
+	// Продажа и аренда одной квартиры - разные объявления
+	if dealTypeOrSale(a.dealType) != dealTypeOrSale(b.dealType) {
+		return 0, 0, false
+	}
+	if propertyTypeOrApartment(a.category) != propertyTypeOrApartment(b.category) {
+		return 0, 0, false
+	}
+
+	if a.rooms != nil && b.rooms != nil {
+		if *a.rooms != *b.rooms {
+			return 0, 0, false
+		}
+		add(weightRooms, 1)
+	}
+
+	if a.floor != nil && b.floor != nil {
+		if *a.floor != *b.floor {
+			return 0, 0, false
+		}
+		if a.totalFloors != nil && b.totalFloors != nil && *a.totalFloors != *b.totalFloors {
+			return 0, 0, false
+		}
+		add(weightFloor, 1)
+	}
+
+	if a.area != nil && b.area != nil && *a.area > 0 && *b.area > 0 {
+		diff := relativeDiff(*a.area, *b.area)
+		if diff > 0.1 {
+			return 0, 0, false
+		}
+		add(weightArea, linearSimilarity(diff, 0.02, 0.1))
+	}
+
+	// Площадь участка для домов и земли
+	if a.landArea != nil && b.landArea != nil && *a.landArea > 0 && *b.landArea > 0 {
+		diff := relativeDiff(*a.landArea, *b.landArea)
+		if diff > 0.1 {
+			return 0, 0, false
+		}
+		add(weightArea, linearSimilarity(diff, 0.02, 0.1))
+	}
+
+	if a.price > 0 && b.price > 0 && a.currency == b.currency {
+		diff := relativeDiff(float64(a.price), float64(b.price))
+		if diff > 0.25 {
+			return 0, 0, false
+		}
+		add(weightPrice, linearSimilarity(diff, 0.02, 0.15))
+	}
+
+	if len(a.address) > 0 && len(b.address) > 0 {
+		add(weightAddress, addressSimilarity(a.address, b.address))
+	}
+
+	if evidence == 0 {
+		return 0, 0, true
+	}
+	return total / evidence, evidence, true
+}
+
+// compareImageHashes возвращает сходство самой похожей пары фото
+func compareImageHashes(a, b []uint64) (float64, bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, false
+	}
+
+	best := 64
+	for _, hashA := range a {
+		for _, hashB := range b {
+			if distance := hammingDistance(hashA, hashB); distance < best {
+				best = distance
+			}
+		}
+	}
+	return linearSimilarity(float64(best), 5, 16), true
+}
+
+// normalizeAddress приводит адрес к набору значимых слов и номеров домов
+func normalizeAddress(address string) map[string]bool {
+	address = strings.ReplaceAll(strings.ToLower(address), "ё", "е")
+	tokens := strings.FieldsFunc(address, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	result := make(map[string]bool)
+	for _, token := range tokens {
+		if addressStopWords[token] {
+			continue
+		}
+		result[token] = true
+	}
+	return result
+}
+
+// addressSimilarity доля совпавших слов от более короткого адреса.
+// OLX часто указывает только район, поэтому адрес из одного слова
+// не может дать полного совпадения.
+func addressSimilarity(a, b map[string]bool) float64 {
+	shorter, longer := a, b
+	if len(b) < len(a) {
+		shorter, longer = b, a
+	}
+
+	common := 0
+	for token := range shorter {
+		if longer[token] {
+			common++
+		}
+	}
+
+	similarity := float64(common) / float64(len(shorter))
+	if len(shorter) < 2 {
+		similarity = math.Min(similarity, 0.6)
+	}
+	return similarity
+}
+
+// dealTypeOrSale возвращает тип сделки, считая пустой продажей
+func dealTypeOrSale(dealType string) string {
+	if dealType == "" {
+		return models.DealTypeSale
+	}
+	return dealType
+}
+
+// propertyTypeOrApartment возвращает категорию, считая пустую квартирой
+func propertyTypeOrApartment(propertyType string) string {
+	if propertyType == "" {
+		return models.PropertyTypeApartment
+	}
+	return propertyType
+}
+
+func relativeDiff(a, b float64) float64 {
+	return math.Abs(a-b) / math.Max(a, b)
+}
+
+// linearSimilarity 1 при diff <= full, 0 при diff >= zero, линейно между ними
+func linearSimilarity(diff, full, zero float64) float64 {
+	switch {
+	case diff <= full:
+		return 1
+	case diff >= zero:
+		return 0
+	default:
+		return (zero - diff) / (zero - full)
+	}
+}
+
+// parsedCompleteness оценивает полноту объявления для выбора канонического
+func parsedCompleteness(p models.ParsedProperty) int {
+	score := len(p.Images) + len(p.Description)/100
+	for _, filled := range []bool{p.Area != nil, p.Floor != nil, p.TotalFloors != nil, p.Rooms != nil, p.BuildYear != nil, p.Phone != "", p.Address != ""} {
+		if filled {
+			score += 2
+		}
+	}
+	// При прочих равных предпочитаем krisha: там больше структурированных данных
+	if p.Source == "krisha" {
+		score++
+	}
+	return score
+}
+
+// unionFind система непересекающихся множеств для объединения пар в группы
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(size int) *unionFind {
+	parent := make([]int, size)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &unionFind{parent: parent}
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+// union объединяет множества элементов a и b
+func (u *unionFind) union(a, b int) {
+	rootA, rootB := u.find(a), u.find(b)
+	if rootA != rootB {
+		u.parent[rootB] = rootA
+	}
+}
+
+// groups возвращает элементы по множествам в порядке первого появления
+func (u *unionFind) groups() [][]int {
+	byRoot := make(map[int][]int)
+	var roots []int
+	for i := range u.parent {
+		root := u.find(i)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], i)
+	}
+
+	result := make([][]int, 0, len(roots))
+	for _, root := range roots {
+		result = append(result, byRoot[root])
+	}
+	return result
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompareRequiresSameObjectEvidence(t *testing.T) {
+	rooms, area, floor := 2, 54.0, 5
+	// OLX часто указывает только район, цена почти совпадает
+	olx := &duplicateCandidate{source: "olx", rooms: &rooms, price: 30_000_000, currency: "KZT",
+		address: normalizeAddress("Бостандыкский район")}
+	krisha := &duplicateCandidate{source: "krisha", rooms: &rooms, price: 30_200_000, currency: "KZT",
+		address: normalizeAddress("Бостандыкский р-н, Тимирязева 42")}
+
+	service := NewDuplicateService(nil)
+	if score, ok := service.compare(context.Background(), olx, krisha); ok {
+		t.Fatalf("score = %.2f, want no duplicate without area, floor or photos", score)
+	}
+
+	olx.area, krisha.area = &area, &area
+	olx.floor, krisha.floor = &floor, &floor
+	if score, ok := service.compare(context.Background(), olx, krisha); !ok {
+		t.Errorf("score = %.2f, want duplicate with matching area and floor", score)
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/models"
+)
+
+const (
+	geoMaxRadiusM       = 100000 // 100 км
+	geoMaxPolygonPoints = 200
+	geoMaxMarkers       = 5000
+	geoMinZoom          = 1
+	geoMaxZoom          = 20
+	geoClusterPixels    = 64 // размер ячейки кластера на экране, при тайлах 256 px
+
+	// Выражения координат в таблицах
+	listingLatExpr  = "listings.latitude"
+	listingLonExpr  = "listings.longitude"
+	propertyLatExpr = "(properties.coordinates->>'latitude')::float8"
+	propertyLonExpr = "(properties.coordinates->>'longitude')::float8"
+
+	listingPrecisionExpr = "COALESCE(listings.geo_precision, '')"
+)
+
+// ErrInvalidGeoFilter некорректные параметры поиска по карте
+var ErrInvalidGeoFilter = errors.New("invalid geo filter")
+
+// GeoPoint точка на карте
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeoBounds видимая область карты
+type GeoBounds struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// GeoFilter поиск по карте: точка с радиусом, видимая область или
+// нарисованный многоугольник. Center без радиуса только сортирует по
+// расстоянию от точки, с радиусом - еще и ограничивает выдачу.
+type GeoFilter struct {
+	Center  *GeoPoint
+	RadiusM float64
+	Bounds  *GeoBounds
+	Polygon []GeoPoint
+}
+
+// MapMarker маркер на карте. При Count > 1 это кластер из нескольких
+// объявлений, координаты - их среднее, ID не заполнен.
+type MapMarker struct {
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	Count     int        `json:"count"`
+	MinPrice  int64      `json:"min_price"`
+	MaxPrice  int64      `json:"max_price"`
+	ID        *uuid.UUID `json:"id,omitempty"`
+}
+
+// Validate проверяет координаты и размеры области
+func (f *GeoFilter) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidGeoFilter, fmt.Sprintf(format, args...))
+	}
+
+	if f.Center != nil && !validGeoPoint(*f.Center) {
+		return invalid("center is out of range")
+	}
+	if f.RadiusM != 0 {
+		if f.Center == nil {
+			return invalid("radius requires lat and lon")
+		}
+		if f.RadiusM < 0 || f.RadiusM > geoMaxRadiusM {
+			return invalid("radius must be between 0 and %d meters", geoMaxRadiusM)
+		}
+	}
+	if b := f.Bounds; b != nil {
+		if !validGeoPoint(GeoPoint{b.South, b.West}) || !validGeoPoint(GeoPoint{b.North, b.East}) {
+			return invalid("bbox is out of range")
+		}
+		if b.South >= b.North || b.West >= b.East {
+			return invalid("bbox must be west,south,east,north")
+		}
+	}
+	if len(f.Polygon) > 0 {
+		if len(f.Polygon) < 3 || len(f.Polygon) > geoMaxPolygonPoints {
+			return invalid("polygon must have from 3 to %d points", geoMaxPolygonPoints)
+		}
+		for _, point := range f.Polygon {
+			if !validGeoPoint(point) {
+				return invalid("polygon point is out of range")
+			}
+		}
+	}
+	return nil
+}
+
+// HasArea сообщает, что фильтр ограничивает область, а не только сортирует
+func (f *GeoFilter) HasArea() bool {
+	return f.RadiusM > 0 || f.Bounds != nil || len(f.Polygon) > 0
+}
+
+func validGeoPoint(point GeoPoint) bool {
+	return point.Latitude >= -90 && point.Latitude <= 90 && point.Longitude >= -180 && point.Longitude <= 180
+}
+
+// NeedsPrecision сообщает, что фильтр считает расстояния или границы и
+// координаты центра района или города в нем дадут неверный результат
+func (f *GeoFilter) NeedsPrecision() bool {
+	return f.HasArea() || f.Center != nil
+}
+
+// preciseGeo оставляет строки с координатами не грубее микрорайона, как и
+// poiPrecise. Пустая точность - координаты с площадки или от клиента.
+func preciseGeo(query *gorm.DB, precisionExpr string) *gorm.DB {
+	return query.Where(precisionExpr+" IN ?", []string{
+		"", models.GeoPrecisionExact, models.GeoPrecisionStreet, models.GeoPrecisionMicrodistrict,
+	})
+}
+
+// apply добавляет условия области к запросу. Сначала всегда проверяется
+// прямоугольник по индексу координат, затем точное условие.
+func (f *GeoFilter) apply(query *gorm.DB, latExpr, lonExpr string) *gorm.DB {
+	if f.Bounds != nil {
+		query = query.Where(fmt.Sprintf("%s BETWEEN ? AND ? AND %s BETWEEN ? AND ?", latExpr, lonExpr),
+			f.Bounds.South, f.Bounds.North, f.Bounds.West, f.Bounds.East)
+	}
+
+	if f.Center != nil && f.RadiusM > 0 {
+		bounds := radiusBounds(*f.Center, f.RadiusM)
+		distance, args := f.distanceExpr(latExpr, lonExpr)
+		query = query.
+			Where(fmt.Sprintf("%s BETWEEN ? AND ? AND %s BETWEEN ? AND ?", latExpr, lonExpr),
+				bounds.South, bounds.North, bounds.West, bounds.East).
+			Where(distance+" <= ?", append(args, f.RadiusM)...)
+	}
+
+	if len(f.Polygon) > 0 {
+		bounds := polygonBounds(f.Polygon)
+		query = query.
+			Where(fmt.Sprintf("%s BETWEEN ? AND ? AND %s BETWEEN ? AND ?", latExpr, lonExpr),
+				bounds.South, bounds.North, bounds.West, bounds.East).
+			Where(fmt.Sprintf("?::polygon @> point(%s, %s)", lonExpr, latExpr), polygonLiteral(f.Polygon))
+	}
+
+	return query
+}
+
+// distanceExpr возвращает SQL выражение расстояния от центра в метрах
+func (f *GeoFilter) distanceExpr(latExpr, lonExpr string) (string, []interface{}) {
+	expr := fmt.Sprintf("(12742000 * ASIN(SQRT(POWER(SIN(RADIANS(%[1]s - ?) / 2), 2) + COS(RADIANS(?)) * COS(RADIANS(%[1]s)) * POWER(SIN(RADIANS(%[2]s - ?) / 2), 2))))", latExpr, lonExpr)
+	return expr, []interface{}{f.Center.Latitude, f.Center.Latitude, f.Center.Longitude}
+}
+
+// applyDistanceOrder выбирает расстояние до центра в поле distance и сортирует по нему
+func (f *GeoFilter) applyDistanceOrder(query *gorm.DB, table, latExpr, lonExpr string) *gorm.DB {
+	distance, args := f.distanceExpr(latExpr, lonExpr)
+	return query.
+		Select(table+".*, "+distance+" AS distance", args...).
+		Order("distance ASC")
+}
+
+// radiusBounds прямоугольник, описанный вокруг круга
+func radiusBounds(center GeoPoint, radiusM float64) GeoBounds {
+	const metersPerDegree = 111320.0
+	dLat := radiusM / metersPerDegree
+	dLon := radiusM / (metersPerDegree * math.Max(math.Cos(center.Latitude*math.Pi/180), 0.01))
+	return GeoBounds{
+		South: center.Latitude - dLat,
+		North: center.Latitude + dLat,
+		West:  center.Longitude - dLon,
+		East:  center.Longitude + dLon,
+	}
+}
+
+func polygonBounds(polygon []GeoPoint) GeoBounds {
+	bounds := GeoBounds{South: 90, West: 180, North: -90, East: -180}
+	for _, point := range polygon {
+		bounds.South = math.Min(bounds.South, point.Latitude)
+		bounds.North = math.Max(bounds.North, point.Latitude)
+		bounds.West = math.Min(bounds.West, point.Longitude)
+		bounds.East = math.Max(bounds.East, point.Longitude)
+	}
+	return bounds
+}
+
+// polygonLiteral записывает многоугольник в формате типа polygon PostgreSQL: ((x,y),...), x - долгота
+func polygonLiteral(polygon []GeoPoint) string {
+	points := make([]string, len(polygon))
+	for i, point := range polygon {
+		points[i] = fmt.Sprintf("(%g,%g)", point.Longitude, point.Latitude)
+	}
+	return "(" + strings.Join(points, ",") + ")"
+}
+
+// clusterCellDegrees размер ячейки кластера в градусах для масштаба карты
+func clusterCellDegrees(zoom int) float64 {
+	if zoom < geoMinZoom {
+		zoom = geoMinZoom
+	}
+	if zoom > geoMaxZoom {
+		zoom = geoMaxZoom
+	}
+	return 360 / math.Pow(2, float64(zoom)) * geoClusterPixels / 256
+}
+
+// clusterMarkers группирует строки запроса по ячейкам сетки. Объявления в
+// одной ячейке становятся одним кластером, одиночные - маркером с ID.
+func clusterMarkers(query *gorm.DB, latExpr, lonExpr, table string, zoom int) ([]MapMarker, error) {
+	cell := clusterCellDegrees(zoom)
+
+	var rows []struct {
+		Count     int
+		Latitude  float64
+		Longitude float64
+		MinPrice  int64
+		MaxPrice  int64
+		ID        string
+	}
+	err := query.
+		Select(fmt.Sprintf("COUNT(*) AS count, AVG(%[1]s) AS latitude, AVG(%[2]s) AS longitude, MIN(%[3]s.price) AS min_price, MAX(%[3]s.price) AS max_price, MIN(%[3]s.id::text) AS id", latExpr, lonExpr, table)).
+		Where(fmt.Sprintf("%s IS NOT NULL AND %s IS NOT NULL", latExpr, lonExpr)).
+		Group(fmt.Sprintf("FLOOR(%s / %g), FLOOR(%s / %g)", latExpr, cell, lonExpr, cell)).
+		Limit(geoMaxMarkers).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	markers := make([]MapMarker, 0, len(rows))
+	for _, row := range rows {
+		marker := MapMarker{
+			Latitude:  row.Latitude,
+			Longitude: row.Longitude,
+			Count:     row.Count,
+			MinPrice:  row.MinPrice,
+			MaxPrice:  row.MaxPrice,
+		}
+		if row.Count == 1 {
+			if id, err := uuid.Parse(row.ID); err == nil {
+				marker.ID = &id
+			}
+		}
+		markers = append(markers, marker)
+	}
+	return markers, nil
+}
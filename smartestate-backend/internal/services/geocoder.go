@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+const geocodeMemoryCacheSize = 10000
+
+// ErrAddressNotFound провайдер не нашел адрес. Такой ответ кэшируется.
+var ErrAddressNotFound = errors.New("address not found")
+
+// GeocodeQuery адрес для геокодирования. City - город из фильтров поиска,
+// используется, если в адресе город не указан.
+type GeocodeQuery struct {
+	Address string `json:"address"`
+	City    string `json:"city"`
+}
+
+// GeocodeResult координаты и административное деление адреса
+type GeocodeResult struct {
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	City          string  `json:"city"`
+	District      string  `json:"district"`
+	Microdistrict string  `json:"microdistrict"`
+	Street        string  `json:"street"`
+	Precision     string  `json:"precision"` // models.GeoPrecision*
+	Provider      string  `json:"provider"`
+}
+
+// Geocoder провайдер геокодирования
+type Geocoder interface {
+	Name() string
+	// Geocode возвращает ErrAddressNotFound, если адрес не найден
+	Geocode(ctx context.Context, query GeocodeQuery) (*GeocodeResult, error)
+}
+
+// ReverseGeocoder определяет район и микрорайон по координатам
+type ReverseGeocoder interface {
+	Reverse(ctx context.Context, latitude, longitude float64) (*GeocodeResult, error)
+}
+
+// GeocodeService геокодирует адреса объявлений: опрашивает провайдеров по
+// порядку до первого найденного адреса и кэширует ответы в памяти и в базе
+type GeocodeService struct {
+	db        *gorm.DB
+	providers []Geocoder
+	reverse   ReverseGeocoder
+	ttl       time.Duration
+	missTTL   time.Duration
+
+	mu     sync.Mutex
+	memory map[string]*models.GeocodeCacheEntry
+}
+
+// NewGeocodeService создает сервис с провайдерами из GEOCODER_PROVIDERS.
+// Справочник gazetteer используется для определения района по координатам
+// всегда, даже если в списке провайдеров его нет.
+func NewGeocodeService(db *gorm.DB, cfg config.GeocoderConfig) *GeocodeService {
+	gazetteer := DefaultGazetteer()
+
+	var providers []Geocoder
+	for _, name := range strings.Split(cfg.Providers, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "gazetteer":
+			providers = append(providers, gazetteer)
+		case "nominatim":
+			providers = append(providers, NewNominatimGeocoder(cfg))
+		default:
+			log.Printf("Unknown geocoder provider %q, skipping", name)
+		}
+	}
+	if len(providers) == 0 {
+		providers = []Geocoder{gazetteer}
+	}
+
+	ttl := time.Duration(cfg.CacheTTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = 30 * 24 * time.Hour
+	}
+
+	return &GeocodeService{
+		db:        db,
+		providers: providers,
+		reverse:   gazetteer,
+		ttl:       ttl,
+		missTTL:   24 * time.Hour,
+		memory:    make(map[string]*models.GeocodeCacheEntry),
+	}
+}
+
+// Providers возвращает имена провайдеров в порядке опроса
+func (s *GeocodeService) Providers() []string {
+	names := make([]string, len(s.providers))
+	for i, provider := range s.providers {
+		names[i] = provider.Name()
+	}
+	return names
+}
+
+// Geocode возвращает координаты адреса из кэша или от первого провайдера,
+// который нашел его с точностью до улицы. Если все нашли адрес грубее,
+// берется самый точный ответ. Сетевые ошибки провайдеров не кэшируются.
+func (s *GeocodeService) Geocode(ctx context.Context, query GeocodeQuery) (*GeocodeResult, error) {
+	key := geocodeCacheKey(query)
+	if key == "|" {
+		return nil, ErrAddressNotFound
+	}
+
+	if entry := s.cached(key); entry != nil {
+		if !entry.Found {
+			return nil, ErrAddressNotFound
+		}
+		return entryToResult(entry), nil
+	}
+
+	var best *GeocodeResult
+	var lastErr error
+	for _, provider := range s.providers {
+		result, err := provider.Geocode(ctx, query)
+		if err != nil {
+			if !errors.Is(err, ErrAddressNotFound) {
+				log.Printf("Geocoder %s failed for %q: %v", provider.Name(), query.Address, err)
+				lastErr = err
+			}
+			continue
+		}
+		if best == nil || models.GeoPrecisionRank(result.Precision) > models.GeoPrecisionRank(best.Precision) {
+			best = result
+		}
+		if models.GeoPrecisionRank(best.Precision) >= models.GeoPrecisionRank(models.GeoPrecisionStreet) {
+			break
+		}
+	}
+
+	switch {
+	case best != nil && (lastErr == nil || models.GeoPrecisionRank(best.Precision) >= models.GeoPrecisionRank(models.GeoPrecisionStreet)):
+		s.store(key, best)
+		return best, nil
+	case best != nil:
+		// Провайдер, который мог найти адрес точнее, недоступен: отдаем ответ без кэширования
+		return best, nil
+	case lastErr != nil:
+		return nil, lastErr
+	}
+	s.store(key, nil)
+	return nil, ErrAddressNotFound
+}
+
+// GeocodeProperties заполняет координаты, район и микрорайон объявлений.
+// Координаты с площадки не заменяются, для них определяется только район.
+func (s *GeocodeService) GeocodeProperties(ctx context.Context, city string, properties []models.ParsedProperty) {
+	for i := range properties {
+		if ctx.Err() != nil {
+			return
+		}
+		property := &properties[i]
+
+		if property.Latitude != nil && property.Longitude != nil {
+			if property.GeoPrecision == "" {
+				property.GeoPrecision = models.GeoPrecisionExact
+			}
+			if property.District == "" {
+				if result, err := s.reverse.Reverse(ctx, *property.Latitude, *property.Longitude); err == nil {
+					property.District = result.District
+					property.Microdistrict = result.Microdistrict
+				}
+			}
+			continue
+		}
+
+		result, err := s.Geocode(ctx, GeocodeQuery{Address: property.Address, City: city})
+		if err != nil {
+			continue
+		}
+		applyGeocodeResult(property, result)
+	}
+}
+
+// applyGeocodeResult переносит результат геокодирования в объявление
+func applyGeocodeResult(property *models.ParsedProperty, result *GeocodeResult) {
+	latitude, longitude := result.Latitude, result.Longitude
+	property.Latitude = &latitude
+	property.Longitude = &longitude
+	property.GeoPrecision = result.Precision
+	if result.District != "" {
+		property.District = result.District
+	}
+	if result.Microdistrict != "" {
+		property.Microdistrict = result.Microdistrict
+	}
+}
+
+// BackfillListings геокодирует объявления каталога, которые еще не обрабатывались.
+// Объявления с ненайденным адресом тоже помечаются обработанными.
+// Возвращает число обработанных и найденных объявлений.
+func (s *GeocodeService) BackfillListings(ctx context.Context, limit int) (int, int, error) {
+	if limit <= 0 || limit > 5000 {
+		limit = 500
+	}
+
+	var listings []models.Listing
+	if err := s.db.Select("id", "address", "city", "latitude", "longitude", "district", "microdistrict", "geo_precision").
+		Where("geocoded_at IS NULL").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&listings).Error; err != nil {
+		return 0, 0, err
+	}
+
+	processed, found := 0, 0
+	for _, listing := range listings {
+		if ctx.Err() != nil {
+			break
+		}
+
+		properties := []models.ParsedProperty{listing.ToParseProperty()}
+		s.GeocodeProperties(ctx, listing.City, properties)
+		property := properties[0]
+
+		updates := map[string]interface{}{"geocoded_at": time.Now()}
+		if property.GeoPrecision != "" {
+			updates["latitude"] = property.Latitude
+			updates["longitude"] = property.Longitude
+			updates["district"] = property.District
+			updates["microdistrict"] = property.Microdistrict
+			updates["geo_precision"] = property.GeoPrecision
+			found++
+		}
+		if err := s.db.Model(&models.Listing{}).Where("id = ?", listing.ID).Updates(updates).Error; err != nil {
+			return processed, found, err
+		}
+		processed++
+	}
+
+	return processed, found, nil
+}
+
+// cached возвращает неистекшую запись кэша из памяти или из базы
+func (s *GeocodeService) cached(key string) *models.GeocodeCacheEntry {
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.memory[key]
+	s.mu.Unlock()
+	if ok && entry.ExpiresAt.After(now) {
+		return entry
+	}
+
+	if s.db == nil {
+		return nil
+	}
+	var stored models.GeocodeCacheEntry
+	if err := s.db.Where("key = ? AND expires_at > ?", key, now).Limit(1).Find(&stored).Error; err != nil || stored.Key == "" {
+		return nil
+	}
+	s.remember(&stored)
+	return &stored
+}
+
+// store кэширует результат, nil - адрес не найден
+func (s *GeocodeService) store(key string, result *GeocodeResult) {
+	entry := &models.GeocodeCacheEntry{Key: key, ExpiresAt: time.Now().Add(s.missTTL)}
+	if result != nil {
+		entry = &models.GeocodeCacheEntry{
+			Key:           key,
+			Found:         true,
+			Latitude:      result.Latitude,
+			Longitude:     result.Longitude,
+			City:          result.City,
+			District:      result.District,
+			Microdistrict: result.Microdistrict,
+			Street:        result.Street,
+			Precision:     result.Precision,
+			Provider:      result.Provider,
+			ExpiresAt:     time.Now().Add(s.ttl),
+		}
+	}
+	s.remember(entry)
+
+	if s.db == nil {
+		return
+	}
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+		log.Printf("Failed to cache geocode result for %q: %v", key, err)
+	}
+}
+
+func (s *GeocodeService) remember(entry *models.GeocodeCacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.memory) >= geocodeMemoryCacheSize {
+		s.memory = make(map[string]*models.GeocodeCacheEntry)
+	}
+	s.memory[entry.Key] = entry
+}
+
+func entryToResult(entry *models.GeocodeCacheEntry) *GeocodeResult {
+	return &GeocodeResult{
+		Latitude:      entry.Latitude,
+		Longitude:     entry.Longitude,
+		City:          entry.City,
+		District:      entry.District,
+		Microdistrict: entry.Microdistrict,
+		Street:        entry.Street,
+		Precision:     entry.Precision,
+		Provider:      entry.Provider,
+	}
+}
+
+// geocodeCacheKey строит ключ кэша из нормализованных города и адреса
+func geocodeCacheKey(query GeocodeQuery) string {
+	key := []rune(normalizeGeoText(query.City) + "|" + normalizeGeoText(query.Address))
+	if len(key) > 255 {
+		key = key[:255]
+	}
+	return string(key)
+}
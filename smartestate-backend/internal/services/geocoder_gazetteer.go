@@ -0,0 +1,345 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"smartestate/internal/models"
+)
+
+//go:embed gazetteer.yaml
+var defaultGazetteerData []byte
+
+const (
+	gazetteerCityRadiusKm          = 40.0 // дальше от центра города адрес не относится к нему
+	gazetteerMicrodistrictRadiusKm = 1.5  // дальше от центра микрорайона точка не относится к нему
+)
+
+// streetTypeWords слова, которые отбрасываются из названия улицы при сравнении
+var streetTypeWords = map[string]bool{
+	"улица": true, "ул": true, "проспект": true, "пр": true,
+	"бульвар": true, "переулок": true, "пер": true, "шоссе": true,
+}
+
+// Gazetteer офлайн геокодер по встроенному справочнику улиц, районов и
+// микрорайонов Алматы, Астаны и Шымкента. Находит адрес по названиям, номер
+// дома не учитывается.
+type Gazetteer struct {
+	Cities []*gazetteerCity `yaml:"cities"`
+}
+
+type gazetteerCity struct {
+	gazetteerPlace `yaml:",inline"`
+	Districts      []*gazetteerPlace `yaml:"districts"`
+	Microdistricts []*gazetteerPlace `yaml:"microdistricts"`
+	Streets        []*gazetteerPlace `yaml:"streets"`
+}
+
+type gazetteerPlace struct {
+	Name     string     `yaml:"name"`
+	Aliases  []string   `yaml:"aliases"`
+	District string     `yaml:"district"`
+	Center   [2]float64 `yaml:"center"` // широта, долгота
+
+	keys []string // нормализованные название и псевдонимы
+}
+
+// ParseGazetteer разбирает и проверяет YAML справочника
+func ParseGazetteer(data []byte) (*Gazetteer, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var gazetteer Gazetteer
+	if err := decoder.Decode(&gazetteer); err != nil {
+		return nil, fmt.Errorf("invalid gazetteer: %w", err)
+	}
+	if err := gazetteer.prepare(); err != nil {
+		return nil, err
+	}
+	return &gazetteer, nil
+}
+
+// DefaultGazetteer возвращает встроенный справочник
+func DefaultGazetteer() *Gazetteer {
+	gazetteer, err := ParseGazetteer(defaultGazetteerData)
+	if err != nil {
+		panic(fmt.Sprintf("embedded gazetteer: %v", err))
+	}
+	return gazetteer
+}
+
+// prepare проверяет записи и строит ключи для поиска
+func (g *Gazetteer) prepare() error {
+	var problems []string
+	check := func(path string, place *gazetteerPlace, street bool) {
+		if strings.TrimSpace(place.Name) == "" {
+			problems = append(problems, path+": name is required")
+			return
+		}
+		lat, lon := place.Center[0], place.Center[1]
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 || (lat == 0 && lon == 0) {
+			problems = append(problems, fmt.Sprintf("%s %q: invalid center", path, place.Name))
+		}
+		place.keys = gazetteerKeys(place, street)
+	}
+
+	if len(g.Cities) == 0 {
+		return fmt.Errorf("invalid gazetteer: no cities")
+	}
+	for _, city := range g.Cities {
+		check("city", &city.gazetteerPlace, false)
+		// Написания города берутся из справочника мест: поиск и геокодер
+		// должны понимать один и тот же набор городов
+		if location := DefaultLocations().City(city.Name); location == nil || location.Name != city.Name {
+			problems = append(problems, fmt.Sprintf("city %q: missing from locations", city.Name))
+		} else {
+			for _, key := range location.keys {
+				if !containsString(city.keys, key) {
+					city.keys = append(city.keys, key)
+				}
+			}
+		}
+
+		districts := make(map[string]bool)
+		for _, district := range city.Districts {
+			check(city.Name+" district", district, false)
+			districts[district.Name] = true
+		}
+		for _, microdistrict := range city.Microdistricts {
+			check(city.Name+" microdistrict", microdistrict, false)
+		}
+		for _, street := range city.Streets {
+			check(city.Name+" street", street, true)
+		}
+
+		for _, places := range [][]*gazetteerPlace{city.Microdistricts, city.Streets} {
+			for _, place := range places {
+				if place.District != "" && !districts[place.District] {
+					problems = append(problems, fmt.Sprintf("%s %q: unknown district %q", city.Name, place.Name, place.District))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid gazetteer: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// gazetteerKeys возвращает нормализованные название и псевдонимы, для улиц
+// также название без слова "улица", "проспект"
+func gazetteerKeys(place *gazetteerPlace, street bool) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	add := func(value string) {
+		key := normalizeGeoText(value)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add(place.Name)
+	for _, alias := range place.Aliases {
+		add(alias)
+	}
+	if street {
+		add(stripStreetType(normalizeGeoText(place.Name)))
+	}
+	return keys
+}
+
+// stripStreetType убирает слово "улица", "проспект" в начале нормализованного названия
+func stripStreetType(name string) string {
+	words := strings.Fields(name)
+	for len(words) > 1 && streetTypeWords[words[0]] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// normalizeGeoText приводит текст к словам в нижнем регистре через пробел,
+// ё заменяется на е, знаки препинания и дефисы - на пробелы
+func normalizeGeoText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func (g *Gazetteer) Name() string {
+	return "gazetteer"
+}
+
+// Geocode ищет в адресе город, микрорайон, улицу и район. Координаты берутся
+// у самого точного найденного места: микрорайон, улица, район, центр города.
+func (g *Gazetteer) Geocode(ctx context.Context, query GeocodeQuery) (*GeocodeResult, error) {
+	text := normalizeGeoText(query.Address)
+
+	city := g.findCity(text)
+	if city == nil {
+		city = g.findCity(normalizeGeoText(query.City))
+	}
+	if city == nil {
+		city = g.findCityByDistrict(text)
+	}
+	if city == nil {
+		return nil, ErrAddressNotFound
+	}
+
+	result := &GeocodeResult{City: city.Name, Provider: g.Name()}
+	district := findGazetteerPlace(text, city.Districts)
+	microdistrict := findGazetteerPlace(text, city.Microdistricts)
+	street := findGazetteerPlace(text, city.Streets)
+
+	if street != nil {
+		result.Street = street.Name
+	}
+
+	var place *gazetteerPlace
+	switch {
+	case microdistrict != nil:
+		place, result.Precision = microdistrict, models.GeoPrecisionMicrodistrict
+		result.Microdistrict = microdistrict.Name
+	case street != nil:
+		place, result.Precision = street, models.GeoPrecisionStreet
+	case district != nil:
+		place, result.Precision = district, models.GeoPrecisionDistrict
+	default:
+		place, result.Precision = &city.gazetteerPlace, models.GeoPrecisionCity
+	}
+	result.Latitude, result.Longitude = place.Center[0], place.Center[1]
+
+	// Район, указанный в адресе явно, важнее района улицы: улица может
+	// проходить через несколько районов
+	switch {
+	case district != nil:
+		result.District = district.Name
+	case place.District != "":
+		result.District = place.District
+	}
+
+	return result, nil
+}
+
+// Reverse находит ближайший к точке город и район, а микрорайон - если точка
+// рядом с его центром. Границ районов в справочнике нет, поэтому район
+// определяется приблизительно.
+func (g *Gazetteer) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodeResult, error) {
+	var city *gazetteerCity
+	best := gazetteerCityRadiusKm
+	for _, candidate := range g.Cities {
+		if distance := haversineKm(latitude, longitude, candidate.Center[0], candidate.Center[1]); distance < best {
+			city, best = candidate, distance
+		}
+	}
+	if city == nil {
+		return nil, ErrAddressNotFound
+	}
+
+	result := &GeocodeResult{
+		Latitude:  latitude,
+		Longitude: longitude,
+		City:      city.Name,
+		Precision: models.GeoPrecisionExact,
+		Provider:  g.Name(),
+	}
+	if district, _ := nearestGazetteerPlace(latitude, longitude, city.Districts); district != nil {
+		result.District = district.Name
+	}
+	if microdistrict, distance := nearestGazetteerPlace(latitude, longitude, city.Microdistricts); microdistrict != nil && distance <= gazetteerMicrodistrictRadiusKm {
+		result.Microdistrict = microdistrict.Name
+		if microdistrict.District != "" {
+			result.District = microdistrict.District
+		}
+	}
+
+	return result, nil
+}
+
+func (g *Gazetteer) findCity(text string) *gazetteerCity {
+	places := make([]*gazetteerPlace, len(g.Cities))
+	for i, city := range g.Cities {
+		places[i] = &city.gazetteerPlace
+	}
+	place := findGazetteerPlace(text, places)
+	for _, city := range g.Cities {
+		if place == &city.gazetteerPlace {
+			return city
+		}
+	}
+	return nil
+}
+
+// findCityByDistrict определяет город по району или микрорайону, когда город
+// в адресе не указан: krisha.kz пишет в карточке только "Бостандыкский р-н".
+// Если название есть в нескольких городах, город не определяется.
+func (g *Gazetteer) findCityByDistrict(text string) *gazetteerCity {
+	var found *gazetteerCity
+	for _, city := range g.Cities {
+		if findGazetteerPlace(text, city.Districts) == nil && findGazetteerPlace(text, city.Microdistricts) == nil {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = city
+	}
+	return found
+}
+
+// findGazetteerPlace возвращает место, название которого встречается в тексте
+// раньше остальных целыми словами. При совпадении позиции выигрывает более
+// длинное название: "аксай 3" точнее, чем "аксай".
+func findGazetteerPlace(text string, places []*gazetteerPlace) *gazetteerPlace {
+	if text == "" {
+		return nil
+	}
+	padded := " " + text + " "
+
+	var found *gazetteerPlace
+	foundPos, foundLen := -1, 0
+	for _, place := range places {
+		for _, key := range place.keys {
+			pos := strings.Index(padded, " "+key+" ")
+			if pos < 0 {
+				continue
+			}
+			if found == nil || pos < foundPos || (pos == foundPos && len(key) > foundLen) {
+				found, foundPos, foundLen = place, pos, len(key)
+			}
+		}
+	}
+	return found
+}
+
+func nearestGazetteerPlace(latitude, longitude float64, places []*gazetteerPlace) (*gazetteerPlace, float64) {
+	var nearest *gazetteerPlace
+	best := math.MaxFloat64
+	for _, place := range places {
+		if distance := haversineKm(latitude, longitude, place.Center[0], place.Center[1]); distance < best {
+			nearest, best = place, distance
+		}
+	}
+	return nearest, best
+}
+
+// haversineKm расстояние между точками по поверхности Земли, км
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
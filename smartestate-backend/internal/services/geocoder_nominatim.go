@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+// NominatimGeocoder внешний геокодер с API Nominatim (OpenStreetMap или свой
+// сервер). Находит адрес с точностью до дома, но требует сети и ограничивает
+// частоту запросов, поэтому используется вместе с кэшем GeocodeService.
+type NominatimGeocoder struct {
+	baseURL     string
+	userAgent   string
+	minInterval time.Duration
+	client      *http.Client
+
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+type nominatimPlace struct {
+	Lat     string `json:"lat"`
+	Lon     string `json:"lon"`
+	Address struct {
+		HouseNumber   string `json:"house_number"`
+		Road          string `json:"road"`
+		Neighbourhood string `json:"neighbourhood"`
+		Suburb        string `json:"suburb"`
+		CityDistrict  string `json:"city_district"`
+		City          string `json:"city"`
+		Town          string `json:"town"`
+	} `json:"address"`
+}
+
+func NewNominatimGeocoder(cfg config.GeocoderConfig) *NominatimGeocoder {
+	minInterval := time.Duration(cfg.MinIntervalMs) * time.Millisecond
+	if minInterval <= 0 {
+		minInterval = time.Second // политика публичного сервера - не чаще раза в секунду
+	}
+
+	return &NominatimGeocoder{
+		baseURL:     strings.TrimRight(cfg.NominatimURL, "/"),
+		userAgent:   cfg.UserAgent,
+		minInterval: minInterval,
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (g *NominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+// Geocode ищет адрес в Казахстане. Город из запроса добавляется к адресу,
+// если его там нет.
+func (g *NominatimGeocoder) Geocode(ctx context.Context, query GeocodeQuery) (*GeocodeResult, error) {
+	text := strings.TrimSpace(query.Address)
+	if query.City != "" && !strings.Contains(strings.ToLower(text), strings.ToLower(query.City)) {
+		text = strings.Trim(text+", "+query.City, ", ")
+	}
+	if text == "" {
+		return nil, ErrAddressNotFound
+	}
+
+	params := url.Values{}
+	params.Set("q", text)
+	params.Set("format", "jsonv2")
+	params.Set("addressdetails", "1")
+	params.Set("limit", "1")
+	params.Set("countrycodes", "kz")
+	params.Set("accept-language", "ru")
+
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", g.userAgent)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("failed to decode nominatim response: %w", err)
+	}
+	if len(places) == 0 {
+		return nil, ErrAddressNotFound
+	}
+
+	return places[0].toResult(g.Name())
+}
+
+func (p nominatimPlace) toResult(provider string) (*GeocodeResult, error) {
+	latitude, err := strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude %q", p.Lat)
+	}
+	longitude, err := strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude %q", p.Lon)
+	}
+
+	address := p.Address
+	if address.City == "" {
+		address.City = address.Town
+	}
+	if address.Suburb == "" {
+		address.Suburb = address.Neighbourhood
+	}
+	result := &GeocodeResult{
+		Latitude:      latitude,
+		Longitude:     longitude,
+		City:          address.City,
+		District:      address.CityDistrict,
+		Microdistrict: address.Suburb,
+		Street:        address.Road,
+		Provider:      provider,
+	}
+
+	switch {
+	case address.HouseNumber != "":
+		result.Precision = models.GeoPrecisionExact
+	case address.Road != "":
+		result.Precision = models.GeoPrecisionStreet
+	case result.Microdistrict != "":
+		result.Precision = models.GeoPrecisionMicrodistrict
+	case result.District != "":
+		result.Precision = models.GeoPrecisionDistrict
+	default:
+		result.Precision = models.GeoPrecisionCity
+	}
+
+	return result, nil
+}
+
+// wait выдерживает паузу между запросами к серверу
+func (g *NominatimGeocoder) wait(ctx context.Context) error {
+	g.mu.Lock()
+	next := g.lastRequest.Add(g.minInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	g.lastRequest = next
+	g.mu.Unlock()
+
+	delay := time.Until(next)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
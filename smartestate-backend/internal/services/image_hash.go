@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // регистрация декодера
+	_ "image/jpeg" // регистрация декодера
+	_ "image/png"  // регистрация декодера
+	"io"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "golang.org/x/image/webp" // krisha отдает фото в webp
+)
+
+const (
+	maxHashedImageSize   = 5 << 20    // 5MB
+	maxHashedImagePixels = 25_000_000 // 25 мегапикселей, около 100MB после декодирования
+	imageHashCacheSize   = 10000
+)
+
+// ImageHasher считает перцептивные хэши (dHash) фотографий объявлений.
+// Хэши кэшируются по URL, поэтому одно фото скачивается один раз.
+type ImageHasher struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]uint64
+}
+
+func NewImageHasher() *ImageHasher {
+	return &ImageHasher{
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  make(map[string]uint64),
+	}
+}
+
+// Hash возвращает 64-битный dHash изображения по URL
+func (h *ImageHasher) Hash(ctx context.Context, imageURL string) (uint64, error) {
+	h.mu.Lock()
+	if hash, ok := h.cache[imageURL]; ok {
+		h.mu.Unlock()
+		return hash, nil
+	}
+	h.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", browserHeaders["User-Agent"])
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("image HTTP error: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHashedImageSize))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	// Лимит выше ограничивает только сжатые данные: небольшой файл с огромными
+	// размерами при декодировании занял бы гигабайты
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxHashedImagePixels {
+		return 0, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	hash := differenceHash(img)
+
+	h.mu.Lock()
+	if len(h.cache) >= imageHashCacheSize {
+		h.cache = make(map[string]uint64)
+	}
+	h.cache[imageURL] = hash
+	h.mu.Unlock()
+
+	return hash, nil
+}
+
+// HashAll считает хэши первых limit фотографий, пропуская недоступные
+func (h *ImageHasher) HashAll(ctx context.Context, imageURLs []string, limit int) []uint64 {
+	var hashes []uint64
+	for i, imageURL := range imageURLs {
+		if i >= limit {
+			break
+		}
+		if hash, err := h.Hash(ctx, imageURL); err == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// differenceHash уменьшает изображение до 9x8 в оттенках серого и сравнивает
+// соседние пиксели по горизонтали. Устойчив к сжатию, ресайзу и водяным знакам.
+func differenceHash(img image.Image) uint64 {
+	const width, height = 9, 8
+
+	bounds := img.Bounds()
+	var gray [height][width]float64
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+
+			// Среднее по блоку исходного изображения
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			gray[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// hammingDistance количество различающихся бит двух хэшей
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// formatImageHashes сериализует хэши для хранения в Listing.ImageHash
+func formatImageHashes(hashes []uint64) string {
+	parts := make([]string, len(hashes))
+	for i, hash := range hashes {
+		parts[i] = strconv.FormatUint(hash, 16)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseImageHashes разбирает Listing.ImageHash
+func parseImageHashes(value string) []uint64 {
+	if value == "" {
+		return nil
+	}
+	var hashes []uint64
+	for _, part := range strings.Split(value, ",") {
+		if hash, err := strconv.ParseUint(part, 16, 64); err == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
@@ -2,12 +2,12 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -30,41 +30,27 @@ type N8nWebhookPayloadKrisha struct {
 
 // KrishaFilterService - сервис для работы с фильтрами Krisha.kz
 type KrishaFilterService struct {
-	client *http.Client
+	client    *http.Client // webhook n8n
+	fetcher   *PoliteFetcher
+	selectors *SelectorStore
 }
 
-// NewKrishaFilterService создает новый сервис
-func NewKrishaFilterService() *KrishaFilterService {
+// NewKrishaFilterService создает новый сервис. Страницы загружаются через общий
+// fetcher и разбираются по профилю селекторов krisha.
+func NewKrishaFilterService(fetcher *PoliteFetcher, selectors *SelectorStore) *KrishaFilterService {
 	return &KrishaFilterService{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		fetcher:   fetcher,
+		selectors: selectors,
 	}
 }
 
-// KrishaFilters - расширенная структура фильтров для Krisha
+// KrishaFilters - фильтры поиска Krisha: общие фильтры поиска и параметры обхода страниц.
+// В URL krisha.kz переводятся тем же krishaSearchURL, что и у KrishaSource.
 type KrishaFilters struct {
-	City             string `json:"city"`              // almaty, nur-sultan, shymkent
-	District         string `json:"district"`          // район города
-	PriceFrom        string `json:"priceFrom"`         // минимальная цена
-	PriceTo          string `json:"priceTo"`           // максимальная цена
-	Rooms            string `json:"rooms"`             // количество комнат
-	AreaFrom         string `json:"areaFrom"`          // минимальная площадь
-	AreaTo           string `json:"areaTo"`            // максимальная площадь
-	KitchenAreaFrom  string `json:"kitchenAreaFrom"`   // минимальная площадь кухни
-	KitchenAreaTo    string `json:"kitchenAreaTo"`     // максимальная площадь кухни
-	FloorFrom        string `json:"floorFrom"`         // минимальный этаж
-	FloorTo          string `json:"floorTo"`           // максимальный этаж
-	FloorNotFirst    bool   `json:"floorNotFirst"`     // исключить первый этаж
-	FloorNotLast     bool   `json:"floorNotLast"`      // исключить последний этаж
-	HouseFloorFrom   string `json:"houseFloorFrom"`    // минимальная этажность дома
-	HouseFloorTo     string `json:"houseFloorTo"`      // максимальная этажность дома
-	YearFrom         string `json:"yearFrom"`          // год постройки от
-	YearTo           string `json:"yearTo"`            // год постройки до
-	HouseType        string `json:"houseType"`         // тип дома
-	WhoType          string `json:"whoType"`           // тип продавца
-	HasPhoto         bool   `json:"hasPhoto"`          // только с фото
-	Complex          string `json:"complex"`           // жилой комплекс
+	models.PropertyFilters
 	Page             int    `json:"page"`              // номер страницы
 	CollectAllPages  bool   `json:"collectAllPages"`   // собрать все страницы
 	MaxResults       int    `json:"maxResults"`        // максимум результатов
@@ -79,6 +65,7 @@ type KrishaResult struct {
 	HasNextPage  bool                    `json:"hasNextPage"`
 	URL          string                  `json:"url"`
 	Filters      KrishaFilters           `json:"filters"`
+	Unsupported  []string                `json:"unsupported,omitempty"` // фильтры, которых нет в поиске krisha.kz
 }
 
 // ParseWithFilters парсит объявления с фильтрами
@@ -87,6 +74,9 @@ func (s *KrishaFilterService) ParseWithFilters(filters KrishaFilters) (*KrishaRe
 	if filters.City == "" {
 		return nil, fmt.Errorf("город обязателен")
 	}
+	if err := normalizeSearchFilters(&filters.PropertyFilters); err != nil {
+		return nil, err
+	}
 
 	// Если нужно собрать все страницы
 	if filters.CollectAllPages {
@@ -115,7 +105,7 @@ func (s *KrishaFilterService) parseSinglePage(filters KrishaFilters) (*KrishaRes
 	}
 
 	// Парсим объявления
-	properties := s.parseProperties(doc)
+	properties := s.parseProperties(doc, filters)
 	log.Printf("✅ Krisha Filter: Найдено %d объявлений", len(properties))
 
 	// Парсим пагинацию
@@ -132,6 +122,7 @@ func (s *KrishaFilterService) parseSinglePage(filters KrishaFilters) (*KrishaRes
 		HasNextPage: hasNextPage,
 		URL:         targetURL,
 		Filters:     filters,
+		Unsupported: s.unsupportedFilters(filters),
 	}
 
 	return result, nil
@@ -161,7 +152,7 @@ func (s *KrishaFilterService) collectAllPages(filters KrishaFilters, maxResults
 		}
 
 		// Парсим объявления на текущей странице
-		pageProperties := s.parseProperties(doc)
+		pageProperties := s.parseProperties(doc, filters)
 		log.Printf("✅ Krisha Filter: Найдено объявлений на странице %d: %d", currentPage, len(pageProperties))
 
 		// Добавляем найденные объявления к общему списку
@@ -237,298 +228,34 @@ finished:
 		HasNextPage: false,              // Никогда нет следующей страницы в режиме всех страниц
 		URL:         s.buildFilterURL(filters),
 		Filters:     filters,
+		Unsupported: s.unsupportedFilters(filters),
 	}
 
 	return result, nil
 }
 
+// unsupportedFilters возвращает фильтры, которые krisha.kz не учитывает
+func (s *KrishaFilterService) unsupportedFilters(filters KrishaFilters) []string {
+	_, unsupported := krishaSearchURL(filters.PropertyFilters, 1)
+	return unsupported
+}
+
 // fetchPage получает и парсит HTML страницу
 func (s *KrishaFilterService) fetchPage(targetURL string) (*goquery.Document, error) {
-	// Делаем HTTP запрос
-	req, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
-	}
-
-	// Устанавливаем заголовки для имитации браузера (как в рабочем krisha проекте)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "ru-RU,ru;q=0.8,en-US;q=0.5,en;q=0.3")
-	// req.Header.Set("Accept-Encoding", "gzip, deflate, br") // Убираем gzip - проблемы с декодированием
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Pragma", "no-cache")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка HTTP запроса: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
-	}
-
-	// Читаем HTML
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
-	}
-
-	// Парсим HTML
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга HTML: %w", err)
-	}
-
-	return doc, nil
+	return s.fetcher.Fetch(context.Background(), targetURL)
 }
 
 // buildFilterURL строит URL с расширенными фильтрами
 func (s *KrishaFilterService) buildFilterURL(filters KrishaFilters) string {
-	baseURL := "https://krisha.kz/prodazha/kvartiry"
-
-	// Добавляем район к URL если указан
-	cityURL := fmt.Sprintf("%s/%s", baseURL, filters.City)
-	if filters.District != "" {
-		cityURL += "-" + filters.District
-	}
-	cityURL += "/"
-
-	params := url.Values{}
-
-	// Базовые параметры
-	if filters.Rooms != "" {
-		params.Set("das[live.rooms]", filters.Rooms)
-	}
-
-	// Цена
-	if filters.PriceFrom != "" {
-		params.Set("das[price][from]", filters.PriceFrom)
-	}
-	if filters.PriceTo != "" {
-		params.Set("das[price][to]", filters.PriceTo)
-	}
-
-	// Площадь общая
-	if filters.AreaFrom != "" {
-		params.Set("das[live.square][from]", filters.AreaFrom)
-	}
-	if filters.AreaTo != "" {
-		params.Set("das[live.square][to]", filters.AreaTo)
-	}
-
-	// Площадь кухни
-	if filters.KitchenAreaFrom != "" {
-		params.Set("das[kitchen.square][from]", filters.KitchenAreaFrom)
-	}
-	if filters.KitchenAreaTo != "" {
-		params.Set("das[kitchen.square][to]", filters.KitchenAreaTo)
-	}
-
-	// Этаж квартиры
-	if filters.FloorFrom != "" {
-		params.Set("das[flat.floor][from]", filters.FloorFrom)
-	}
-	if filters.FloorTo != "" {
-		params.Set("das[flat.floor][to]", filters.FloorTo)
-	}
-
-	// Этажность дома
-	if filters.HouseFloorFrom != "" {
-		params.Set("das[house.floor_num][from]", filters.HouseFloorFrom)
-	}
-	if filters.HouseFloorTo != "" {
-		params.Set("das[house.floor_num][to]", filters.HouseFloorTo)
-	}
-
-	// Год постройки
-	if filters.YearFrom != "" {
-		params.Set("das[house.year][from]", filters.YearFrom)
-	}
-	if filters.YearTo != "" {
-		params.Set("das[house.year][to]", filters.YearTo)
-	}
-
-	// Тип дома
-	if filters.HouseType != "" {
-		params.Set("das[house.type]", filters.HouseType)
-	}
-
-	// Тип объявления (от кого)
-	if filters.WhoType != "" {
-		params.Set("das[who]", filters.WhoType)
-	}
-
-	// Дополнительные параметры
-	if filters.HasPhoto {
-		params.Set("das[_sys.hasphoto]", "1")
-	}
-
-	if filters.FloorNotFirst {
-		params.Set("das[floor_not_first]", "1")
-	}
-
-	if filters.FloorNotLast {
-		params.Set("das[floor_not_last]", "1")
-	}
-
-	if filters.Complex != "" {
-		params.Set("das[map.complex]", filters.Complex)
-	}
-
-	// Пагинация
-	if filters.Page > 1 {
-		params.Set("page", strconv.Itoa(filters.Page))
-	}
-
-	finalURL := cityURL
-	if len(params) > 0 {
-		finalURL = fmt.Sprintf("%s?%s", cityURL, params.Encode())
-	}
-
+	finalURL, _ := krishaSearchURL(filters.PropertyFilters, filters.Page)
 	log.Printf("🔗 Krisha Filter: Сформированный URL: %s", finalURL)
 	return finalURL
 }
 
-// parseProperties парсит объявления со страницы
-func (s *KrishaFilterService) parseProperties(doc *goquery.Document) []models.ParsedProperty {
-	var properties []models.ParsedProperty
-
-	doc.Find(".a-card").Each(func(i int, sel *goquery.Selection) {
-		// Пропускаем рекламные блоки
-		if sel.HasClass("ddl_campaign") || sel.Find(".adfox").Length() > 0 {
-			return
-		}
-
-		property := s.parsePropertyCard(sel)
-		if property.Title != "" && property.Price > 0 {
-			properties = append(properties, property)
-		}
-	})
-
-	return properties
-}
-
-// parsePropertyCard парсит одну карточку объявления
-func (s *KrishaFilterService) parsePropertyCard(card *goquery.Selection) models.ParsedProperty {
-	property := models.ParsedProperty{}
-
-	// ID и UUID
-	if id, exists := card.Attr("data-id"); exists {
-		property.ID = id
-	}
-
-	// Заголовок
-	titleText := card.Find(".a-card__title").Text()
-	property.Title = strings.TrimSpace(titleText)
-
-	// URL
-	if href, exists := card.Find(".a-card__title").Attr("href"); exists {
-		if strings.HasPrefix(href, "/") {
-			property.URL = "https://krisha.kz" + href
-		} else {
-			property.URL = href
-		}
-	}
-
-	// Цена
-	priceText := card.Find(".a-card__price").Text()
-	property.Price, property.Currency = s.parsePrice(strings.TrimSpace(priceText))
-
-	// Адрес
-	addressText := card.Find(".a-card__subtitle").Text()
-	property.Address = strings.TrimSpace(addressText)
-
-	// Описание
-	descText := card.Find(".a-card__text-preview").Text()
-	property.Description = strings.TrimSpace(descText)
-
-	// Изображение
-	property.Images = s.parseImages(card)
-
-	// Извлекаем площадь и этаж из заголовка
-	s.parseAreaAndFloor(property.Title, &property)
-
-	// Телефон (если есть)
-	phoneText := card.Find(".seller-phone").Text()
-	if phoneText != "" {
-		property.Phone = strings.TrimSpace(phoneText)
-	}
-
-	return property
-}
-
-// parsePrice извлекает цену из текста
-func (s *KrishaFilterService) parsePrice(priceText string) (int64, string) {
-	if priceText == "" {
-		return 0, "KZT"
-	}
-
-	// Убираем все кроме цифр
-	re := regexp.MustCompile(`[^\d]`)
-	priceStr := re.ReplaceAllString(priceText, "")
-	
-	if price, err := strconv.ParseInt(priceStr, 10, 64); err == nil {
-		return price, "KZT"
-	}
-
-	return 0, "KZT"
-}
-
-// parseImages извлекает изображения используя продвинутый алгоритм
-func (s *KrishaFilterService) parseImages(card *goquery.Selection) []string {
-	var images []string
-
-	// Убираем поиск существующих изображений - генерируем только из UUID
-
-	// Ищем data-photo-id в picture элементе для определения номера фото
-	pictureElement := card.Find("picture")
-	photoId := "1"
-	if pictureElement.Length() > 0 {
-		if dataPhotoId, exists := pictureElement.Attr("data-photo-id"); exists && dataPhotoId != "" {
-			photoId = dataPhotoId
-		}
-	}
-
-	// Генерируем одно главное изображение из data-uuid
-	if uuid, exists := card.Attr("data-uuid"); exists && uuid != "" {
-		firstTwoChars := uuid[:2]
-		mainDomain := "https://krisha-photos.kcdn.online" // Основной рабочий домен
-
-		// Добавляем только ОДНО главное изображение
-		imageURL := fmt.Sprintf("%s/webp/%s/%s/%s-%s.webp", mainDomain, firstTwoChars, uuid, photoId, "400x300")
-		images = append(images, imageURL)
-	}
-
-	// Возвращаем только сгенерированные из UUID изображения (без дубликатов)
-	return images
-}
-
-// parseAreaAndFloor извлекает площадь и этаж из заголовка
-func (s *KrishaFilterService) parseAreaAndFloor(title string, property *models.ParsedProperty) {
-	// Ищем площадь и этаж в заголовке: "25.5 м² 3/9 этаж"
-	areaFloorRe := regexp.MustCompile(`(\d+(?:\.\d+)?)\s*м².*?(\d+)\/(\d+)\s*этаж`)
-	if matches := areaFloorRe.FindStringSubmatch(title); len(matches) >= 4 {
-		if area, err := strconv.ParseFloat(matches[1], 64); err == nil {
-			property.Area = &area
-		}
-		if floor, err := strconv.Atoi(matches[2]); err == nil {
-			property.Floor = &floor
-		}
-		if totalFloors, err := strconv.Atoi(matches[3]); err == nil {
-			property.TotalFloors = &totalFloors
-		}
-	}
-
-	// Ищем количество комнат
-	roomsRe := regexp.MustCompile(`(\d+)[\-\s]*комн`)
-	if matches := roomsRe.FindStringSubmatch(title); len(matches) >= 2 {
-		if rooms, err := strconv.Atoi(matches[1]); err == nil {
-			property.Rooms = &rooms
-		}
-	}
+// parseProperties парсит объявления со страницы. Фильтры передаются целиком:
+// площадь участка и животных krisha.kz не фильтрует, их проверяют по карточкам.
+func (s *KrishaFilterService) parseProperties(doc *goquery.Document, filters KrishaFilters) []models.ParsedProperty {
+	return applySearchFilters(extractKrishaCards(doc, s.selectors.Source("krisha")), filters.PropertyFilters)
 }
 
 // parsePagination парсит информацию о пагинации
@@ -536,15 +263,17 @@ func (s *KrishaFilterService) parsePagination(doc *goquery.Document) (totalPages
 	totalPages = 1
 	hasNextPage = false
 
+	pagination := s.selectors.Source("krisha").Search.Pagination
+
 	// Ищем пагинатор
-	paginator := doc.Find(".paginator, .pagination, nav.paginator")
+	paginator := pagination.Container.Find(doc.Selection)
 	if paginator.Length() == 0 {
 		return totalPages, hasNextPage
 	}
 
 	// Ищем номера страниц
 	var pageNumbers []int
-	paginator.Find(".paginator__btn, .pagination__btn, .page-btn, a[data-page]").Each(func(i int, btn *goquery.Selection) {
+	pagination.Page.FindAll(paginator).Each(func(i int, btn *goquery.Selection) {
 		// Из data-page атрибута
 		if dataPage, exists := btn.Attr("data-page"); exists {
 			if pageNum, err := strconv.Atoi(dataPage); err == nil && pageNum > 0 {
@@ -581,8 +310,7 @@ func (s *KrishaFilterService) parsePagination(doc *goquery.Document) (totalPages
 	}
 
 	// Проверяем есть ли кнопка "Дальше"
-	nextBtn := paginator.Find(".paginator__btn--next, .pagination__btn--next, .next, .page-next")
-	hasNextPage = nextBtn.Length() > 0
+	hasNextPage = pagination.Next.Find(paginator).Length() > 0
 
 	return totalPages, hasNextPage
 }
@@ -647,24 +375,20 @@ func (s *KrishaFilterService) parseTotalCount(doc *goquery.Document, foundCount,
 
 // GenerateFiltersFromMessage - ИИ будет генерировать фильтры из сообщения пользователя
 func (s *KrishaFilterService) GenerateFiltersFromMessage(message string) KrishaFilters {
-	filters := KrishaFilters{
-		City:      "almaty", // по умолчанию
-		PriceFrom: "",
-		PriceTo:   "",
-		Rooms:     "",
-		Page:      1,
+	filters := KrishaFilters{Page: 1}
+
+	// Определяем город, район и ЖК
+	applyDetectedLocation(&filters.PropertyFilters, message)
+	if filters.City == "" {
+		filters.City = "Алматы" // по умолчанию
 	}
 
 	message = strings.ToLower(message)
 
-	// Определяем город
-	if strings.Contains(message, "астана") || strings.Contains(message, "нур-султан") {
-		filters.City = "astana"
-	} else if strings.Contains(message, "шымкент") {
-		filters.City = "shymkent"
-	} else if strings.Contains(message, "алматы") {
-		filters.City = "almaty"
-	}
+	// Определяем тип сделки и категорию
+	filters.DealType = detectDealType(message)
+	filters.PropertyType, filters.CommercialType = detectSearchCategory(message)
+	filters.NearPOI = detectNearPOI(message)
 
 	// Извлекаем цену
 	priceRe := regexp.MustCompile(`(\d+(?:\s+\d+)*)\s*(?:млн|миллион|тысяч|тенге|₸)`)
@@ -687,21 +411,47 @@ func (s *KrishaFilterService) GenerateFiltersFromMessage(message string) KrishaF
 	}
 
 	if len(prices) >= 2 {
-		filters.PriceFrom = strconv.FormatInt(prices[0], 10)
-		filters.PriceTo = strconv.FormatInt(prices[1], 10)
+		filters.PriceMin = &prices[0]
+		filters.PriceMax = &prices[1]
 	} else if len(prices) == 1 {
-		filters.PriceFrom = strconv.FormatInt(prices[0], 10)
+		filters.PriceMin = &prices[0]
 	}
 
 	// Извлекаем количество комнат
 	roomsRe := regexp.MustCompile(`(\d+)[\-\s]*(?:комн|комнат)`)
 	if matches := roomsRe.FindStringSubmatch(message); len(matches) >= 2 {
-		filters.Rooms = matches[1]
+		if rooms, err := strconv.Atoi(matches[1]); err == nil {
+			filters.Rooms = &rooms
+		}
 	}
 
 	return filters
 }
 
+// detectDealType определяет тип сделки по тексту запроса
+func detectDealType(message string) string {
+	switch {
+	case containsAny(message, []string{"посуточ", "на сутки", "на ночь", "на выходные"}):
+		return models.DealTypeRentDaily
+	case containsAny(message, []string{"аренд", "снять", "сниму", "в наем", "в найм", "долгосроч"}):
+		return models.DealTypeRentLong
+	}
+	return models.DealTypeSale
+}
+
+// detectSearchCategory определяет по тексту запроса категорию недвижимости
+// и вид коммерческого помещения, по умолчанию квартиры
+func detectSearchCategory(message string) (string, string) {
+	propertyType := detectPropertyType(message)
+	if propertyType == "" {
+		return models.PropertyTypeApartment, ""
+	}
+	if propertyType == models.PropertyTypeCommercial {
+		return propertyType, detectCommercialType(message)
+	}
+	return propertyType, ""
+}
+
 // FormatResultForChat форматирует результат для отправки в чат
 func (s *KrishaFilterService) FormatResultForChat(result *KrishaResult) string {
 	if len(result.Properties) == 0 {
@@ -723,7 +473,7 @@ func (s *KrishaFilterService) FormatResultForChat(result *KrishaResult) string {
 		prop := result.Properties[i]
 		
 		response.WriteString(fmt.Sprintf("🏡 **%s**\n", prop.Title))
-		response.WriteString(fmt.Sprintf("💰 %s %s\n", s.formatPrice(prop.Price), prop.Currency))
+		response.WriteString(fmt.Sprintf("💰 %s %s%s\n", s.formatPrice(prop.Price), prop.Currency, pricePeriodSuffix(prop.PricePeriod)))
 		
 		if prop.Address != "" {
 			response.WriteString(fmt.Sprintf("📍 %s\n", prop.Address))
@@ -780,7 +530,10 @@ func (s *KrishaFilterService) sendToN8nWebhook(filters KrishaFilters, properties
 	log.Printf("📡 Krisha Filter: Отправка данных в n8n webhook: %d объявлений", len(properties))
 
 	// Конвертируем фильтры в формат, ожидаемый n8n
-	filtersMap := s.convertKrishaFiltersToMap(filters)
+	filtersMap := n8nFilters(filters.PropertyFilters)
+	filtersMap["page"] = filters.Page
+	filtersMap["collectAllPages"] = filters.CollectAllPages
+	filtersMap["maxResults"] = filters.MaxResults
 
 	// Создаем полезную нагрузку для webhook
 	payload := N8nWebhookPayloadKrisha{
@@ -827,68 +580,3 @@ func (s *KrishaFilterService) sendToN8nWebhook(filters KrishaFilters, properties
 		log.Printf("❌ Krisha Filter: n8n webhook вернул ошибку %d: %s", resp.StatusCode, string(responseBody))
 	}
 }
-
-// convertKrishaFiltersToMap конвертирует KrishaFilters в map для n8n
-func (s *KrishaFilterService) convertKrishaFiltersToMap(filters KrishaFilters) map[string]interface{} {
-	filtersMap := make(map[string]interface{})
-
-	if filters.City != "" {
-		filtersMap["city"] = filters.City
-	}
-	if filters.District != "" {
-		filtersMap["district"] = filters.District
-	}
-	if filters.PriceFrom != "" {
-		filtersMap["price_min"] = filters.PriceFrom
-	}
-	if filters.PriceTo != "" {
-		filtersMap["price_max"] = filters.PriceTo
-	}
-	if filters.Rooms != "" {
-		filtersMap["rooms"] = filters.Rooms
-	}
-	if filters.AreaFrom != "" {
-		filtersMap["total_area_from"] = filters.AreaFrom
-	}
-	if filters.AreaTo != "" {
-		filtersMap["total_area_to"] = filters.AreaTo
-	}
-	if filters.KitchenAreaFrom != "" {
-		filtersMap["kitchen_area_from"] = filters.KitchenAreaFrom
-	}
-	if filters.KitchenAreaTo != "" {
-		filtersMap["kitchen_area_to"] = filters.KitchenAreaTo
-	}
-	if filters.FloorFrom != "" {
-		filtersMap["floor_from"] = filters.FloorFrom
-	}
-	if filters.FloorTo != "" {
-		filtersMap["floor_to"] = filters.FloorTo
-	}
-	if filters.FloorNotFirst {
-		filtersMap["not_first_floor"] = true
-	}
-	if filters.FloorNotLast {
-		filtersMap["not_last_floor"] = true
-	}
-	if filters.HouseFloorFrom != "" {
-		filtersMap["total_floors_from"] = filters.HouseFloorFrom
-	}
-	if filters.HouseFloorTo != "" {
-		filtersMap["total_floors_to"] = filters.HouseFloorTo
-	}
-	if filters.YearFrom != "" {
-		filtersMap["build_year_from"] = filters.YearFrom
-	}
-	if filters.YearTo != "" {
-		filtersMap["build_year_to"] = filters.YearTo
-	}
-	if filters.CollectAllPages {
-		filtersMap["collect_all_pages"] = true
-	}
-	if filters.MaxResults > 0 {
-		filtersMap["max_results"] = filters.MaxResults
-	}
-
-	return filtersMap
-}
\ No newline at end of file
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"smartestate/internal/models"
+)
+
+func TestKrishaFilterParsePropertiesAppliesPostFilters(t *testing.T) {
+	const page = `<div class="a-card" data-id="1"><a class="a-card__title" href="/a/show/1">Дом · 120 м² · 6 сот.</a><div class="a-card__price">40 000 000 〒</div></div>
+<div class="a-card" data-id="2"><a class="a-card__title" href="/a/show/2">Дом · 150 м² · 12 сот.</a><div class="a-card__price">55 000 000 〒</div></div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewKrishaFilterService(nil, NewStaticSelectorStore(DefaultSelectorProfiles()))
+	from := 10
+	filters := KrishaFilters{PropertyFilters: models.PropertyFilters{
+		City: "Алматы", PropertyType: models.PropertyTypeHouse, LandAreaFrom: &from,
+	}}
+
+	properties := service.parseProperties(doc, filters)
+	if len(properties) != 1 || properties[0].ID != "2" {
+		t.Fatalf("properties = %+v, want only the house on 12 sotka", properties)
+	}
+	if unsupported := service.unsupportedFilters(filters); containsString(unsupported, "land_area_from") {
+		t.Errorf("unsupported = %v, land area is filtered on the cards", unsupported)
+	}
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"smartestate/internal/models"
+)
+
+const krishaBaseURL = "https://krisha.kz"
+
+var (
+	krishaAreaFloorRe = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*м².*?(\d+)\/(\d+)\s*этаж`)
+	krishaRoomsRe     = regexp.MustCompile(`(\d+)[\-\s]*комн`)
+	krishaShowIDRe    = regexp.MustCompile(`/a/show/(\d+)`)
+	nonDigitRe        = regexp.MustCompile(`[^\d]`)
+)
+
+// KrishaSource источник объявлений krisha.kz. Страницы загружаются обычным
+// HTTP запросом через общий загрузчик, без Selenium.
+type KrishaSource struct {
+	fetcher   *PoliteFetcher
+	selectors *SelectorStore
+}
+
+// NewKrishaSource создает источник krisha.kz
+func NewKrishaSource(fetcher *PoliteFetcher, selectors *SelectorStore) *KrishaSource {
+	return &KrishaSource{
+		fetcher:   fetcher,
+		selectors: selectors,
+	}
+}
+
+// Name возвращает имя источника
+func (k *KrishaSource) Name() string {
+	return "krisha"
+}
+
+// BuildSearchURL строит URL для поиска на krisha.kz
+func (k *KrishaSource) BuildSearchURL(filters models.PropertyFilters, page int) string {
+	searchURL, _ := krishaSearchURL(filters, page)
+	return searchURL
+}
+
+// UnsupportedFilters возвращает фильтры, которых нет в поиске krisha.kz
+func (k *KrishaSource) UnsupportedFilters(filters models.PropertyFilters) []string {
+	_, unsupported := krishaSearchURL(filters, 1)
+	return unsupported
+}
+
+// krishaCitySlug возвращает слаг города krisha.kz, по умолчанию Алматы
+func krishaCitySlug(city string) string {
+	return DefaultLocations().SearchCity(city).Krisha
+}
+
+// krishaDistrictSlug возвращает слаг района krisha.kz по коду из справочника
+// мест. Пустая строка - района нет или krisha.kz по нему не ищет.
+func krishaDistrictSlug(city, district string) string {
+	if district == "" {
+		return ""
+	}
+	if found := DefaultLocations().SearchCity(city).District(district); found != nil {
+		return found.Krisha
+	}
+	return ""
+}
+
+// krishaRangeParams диапазонные фильтры и параметры das[...][from|to] krisha.kz
+var krishaRangeParams = []struct {
+	param string
+	field func(*models.PropertyFilters) (from, to **int)
+}{
+	{"live.square", func(f *models.PropertyFilters) (**int, **int) { return &f.TotalAreaFrom, &f.TotalAreaTo }},
+	{"kitchen.square", func(f *models.PropertyFilters) (**int, **int) { return &f.KitchenAreaFrom, &f.KitchenAreaTo }},
+	{"land.square", func(f *models.PropertyFilters) (**int, **int) { return &f.LandAreaFrom, &f.LandAreaTo }},
+	{"flat.floor", func(f *models.PropertyFilters) (**int, **int) { return &f.FloorFrom, &f.FloorTo }},
+	{"house.floor_num", func(f *models.PropertyFilters) (**int, **int) { return &f.TotalFloorsFrom, &f.TotalFloorsTo }},
+	{"house.year", func(f *models.PropertyFilters) (**int, **int) { return &f.BuildYearFrom, &f.BuildYearTo }},
+}
+
+// krishaHouseTypes значения das[house.type] по материалу стен
+var krishaHouseTypes = map[string]string{
+	models.HouseTypeBrick:    "1",
+	models.HouseTypePanel:    "2",
+	models.HouseTypeMonolith: "3",
+	models.HouseTypeOther:    "0",
+}
+
+// krishaSearchURL переводит фильтры в URL поиска krisha.kz: раздел, город с
+// районом и параметры das[...]. Возвращает и фильтры, которых у krisha.kz нет.
+func krishaSearchURL(filters models.PropertyFilters, page int) (string, []string) {
+	city := DefaultLocations().SearchCity(filters.City)
+	searchURL := krishaBaseURL + krishaSectionPath(filters.DealType, filters.PropertyType, filters.CommercialType) +
+		"/" + city.Krisha
+
+	params := url.Values{}
+	var unsupported []string
+
+	// Район задается в пути: almaty-bostandykskij
+	if slug := krishaDistrictSlug(filters.City, filters.District); slug != "" {
+		searchURL += "-" + slug
+	} else if filters.District != "" {
+		unsupported = append(unsupported, "district")
+	}
+	searchURL += "/"
+
+	// Помесячная или посуточная аренда
+	if period := krishaRentPeriod(filters.DealType); period != "" {
+		params.Set("das[rent.period]", period)
+	}
+
+	if filters.Rooms != nil {
+		params.Set("das[live.rooms]", strconv.Itoa(*filters.Rooms))
+	}
+	if filters.PriceMin != nil {
+		params.Set("das[price][from]", strconv.FormatInt(*filters.PriceMin, 10))
+	}
+	if filters.PriceMax != nil {
+		params.Set("das[price][to]", strconv.FormatInt(*filters.PriceMax, 10))
+	}
+	for _, r := range krishaRangeParams {
+		from, to := r.field(&filters)
+		if *from != nil {
+			params.Set("das["+r.param+"][from]", strconv.Itoa(**from))
+		}
+		if *to != nil {
+			params.Set("das["+r.param+"][to]", strconv.Itoa(**to))
+		}
+	}
+
+	if houseType, ok := krishaHouseTypes[filters.HouseType]; ok {
+		params.Set("das[house.type]", houseType)
+	}
+
+	// Только от хозяина, отдельного фильтра для агентств и застройщиков нет
+	switch filters.SellerType {
+	case "":
+	case models.SellerTypeOwner:
+		params.Set("das[who]", "1")
+	default:
+		unsupported = append(unsupported, "seller_type")
+	}
+
+	if filters.HasPhotos {
+		params.Set("das[_sys.hasphoto]", "1")
+	}
+	if filters.IsNewBuilding {
+		params.Set("das[novostroiki]", "1")
+	}
+	if filters.NotFirstFloor {
+		params.Set("das[floor_not_first]", "1")
+	}
+	if filters.NotLastFloor {
+		params.Set("das[floor_not_last]", "1")
+	}
+
+	// ЖК в поиске krisha.kz задается ID, он есть только у ЖК из справочника мест
+	if filters.ResidentialComplex != "" {
+		if complex := city.Complex(filters.ResidentialComplex); complex != nil && complex.Krisha != "" {
+			params.Set("das[map.complex]", complex.Krisha)
+		} else {
+			unsupported = append(unsupported, "residential_complex")
+		}
+	}
+
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	if len(params) > 0 {
+		searchURL += "?" + params.Encode()
+	}
+	return searchURL, unsupported
+}
+
+// FetchPage загружает страницу krisha.kz
+func (k *KrishaSource) FetchPage(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	return k.fetcher.Fetch(ctx, pageURL)
+}
+
+// ExtractCards извлекает карточки объявлений со страницы поиска krisha.kz
+func (k *KrishaSource) ExtractCards(doc *goquery.Document) []models.ParsedProperty {
+	return extractKrishaCards(doc, k.selectors.Source(k.Name()))
+}
+
+// CountResults читает счетчик "Найдено 1 234 объявления" страницы поиска krisha.kz
+func (k *KrishaSource) CountResults(doc *goquery.Document) (int, bool) {
+	text := k.selectors.Source(k.Name()).Search.Results.Value(doc.Selection)
+	if text == "" {
+		return 0, false
+	}
+	return parseCount(text)
+}
+
+// ExtractDetail дополняет объявление данными со страницы объявления krisha.kz
+func (k *KrishaSource) ExtractDetail(doc *goquery.Document, property *models.ParsedProperty) {
+	property.Source = k.Name()
+	fields := k.selectors.Source(k.Name()).Detail.Fields
+
+	if title := fields["title"].Value(doc.Selection); title != "" {
+		property.Title = title
+	}
+
+	if priceText := fields["price"].Value(doc.Selection); priceText != "" {
+		property.Price, property.Currency = parseKrishaPrice(priceText)
+		if period := parsePricePeriod(priceText); period != "" {
+			property.PricePeriod = period
+		}
+	}
+
+	if address := fields["address"].Value(doc.Selection); address != "" {
+		property.Address = address
+	}
+
+	if description := fields["description"].Value(doc.Selection); description != "" {
+		property.Description = description
+	}
+
+	var images []string
+	for _, src := range fields["images"].Values(doc.Selection) {
+		images = append(images, absoluteURL(krishaBaseURL, src))
+	}
+	if len(images) > 0 {
+		property.Images = uniqueStrings(images)
+	}
+
+	// Продавец
+	if name := fields["seller_name"].Value(doc.Selection); name != "" {
+		property.SellerName = name
+	}
+	if sellerType := parseSellerLabel(fields["seller_label"].Value(doc.Selection)); sellerType != "" {
+		property.SellerType = sellerType
+	}
+
+	// Дата публикации и просмотры
+	if published, ok := parsePublishedDate(fields["published"].Value(doc.Selection), time.Now()); ok {
+		property.PublishedAt = &published
+	}
+	if views, ok := parseViews(fields["views"].Value(doc.Selection)); ok {
+		property.Views = &views
+	}
+
+	// Параметры объявления: дом, условия аренды, площадь и назначение участка, вид помещения
+	for _, params := range k.selectors.Source(k.Name()).Detail.Parameters {
+		doc.Find(params.Item).Each(func(i int, item *goquery.Selection) {
+			parseListingParameter(item.Find(params.Name).Text(), item.Find(params.Value).Text(), property)
+		})
+	}
+
+	parseKrishaAreaAndFloor(property.Title, property)
+	parseCategoryAttributes(property.Title, property)
+}
+
+// krishaCategoryPaths разделы krisha.kz по категории недвижимости
+var krishaCategoryPaths = map[string]string{
+	models.PropertyTypeApartment:  "kvartiry",
+	models.PropertyTypeHouse:      "doma-dachi",
+	models.PropertyTypeLand:       "uchastkov",
+	models.PropertyTypeCommercial: "pomeshhenija", // помещения свободного назначения
+}
+
+// krishaCommercialPaths разделы krisha.kz по виду коммерческой недвижимости
+var krishaCommercialPaths = map[string]string{
+	models.CommercialTypeOffice:    "ofisa",
+	models.CommercialTypeRetail:    "magaziny",
+	models.CommercialTypeWarehouse: "prombazy",
+}
+
+// krishaSectionPath возвращает раздел krisha.kz для типа сделки и категории
+func krishaSectionPath(dealType, propertyType, commercialType string) string {
+	deal := "/prodazha/"
+	if models.IsRent(dealType) {
+		deal = "/arenda/"
+	}
+
+	category, ok := krishaCategoryPaths[propertyType]
+	if !ok {
+		category = krishaCategoryPaths[models.PropertyTypeApartment]
+	}
+	if propertyType == models.PropertyTypeCommercial {
+		if path, ok := krishaCommercialPaths[commercialType]; ok {
+			category = path
+		}
+	}
+	return deal + category
+}
+
+// krishaRentPeriod возвращает значение das[rent.period]: 1 - посуточно, 2 - помесячно
+func krishaRentPeriod(dealType string) string {
+	switch dealType {
+	case models.DealTypeRentDaily:
+		return "1"
+	case models.DealTypeRentLong:
+		return "2"
+	}
+	return ""
+}
+
+// extractKrishaCards парсит объявления со страницы поиска krisha.kz
+func extractKrishaCards(doc *goquery.Document, selectors *SourceSelectors) []models.ParsedProperty {
+	var properties []models.ParsedProperty
+
+	selectors.Search.Card.Find(doc.Selection).Each(func(i int, sel *goquery.Selection) {
+		// Пропускаем рекламные блоки
+		if selectors.Search.Skip.Matches(sel) {
+			return
+		}
+
+		property := parseKrishaCard(sel, selectors.Search.Fields)
+		if property.Title != "" && property.Price > 0 {
+			properties = append(properties, property)
+		}
+	})
+
+	return properties
+}
+
+// parseKrishaCard парсит одну карточку объявления krisha.kz
+func parseKrishaCard(card *goquery.Selection, fields map[string]SelectorList) models.ParsedProperty {
+	property := models.ParsedProperty{Source: "krisha"}
+
+	// ID и UUID
+	property.ID = fields["id"].Value(card)
+
+	// Заголовок
+	property.Title = fields["title"].Value(card)
+
+	// URL
+	if href := fields["url"].Value(card); href != "" {
+		property.URL = absoluteURL(krishaBaseURL, href)
+	}
+
+	// ID из URL, если нет data-id
+	if property.ID == "" {
+		if matches := krishaShowIDRe.FindStringSubmatch(property.URL); len(matches) == 2 {
+			property.ID = matches[1]
+		}
+	}
+
+	// Цена
+	priceText := fields["price"].Value(card)
+	property.Price, property.Currency = parseKrishaPrice(priceText)
+	property.PricePeriod = parsePricePeriod(priceText)
+
+	// Адрес
+	property.Address = fields["address"].Value(card)
+
+	// Описание
+	property.Description = fields["description"].Value(card)
+
+	// Изображение
+	property.Images = parseKrishaImages(card, fields)
+
+	// Извлекаем площадь и этаж из заголовка
+	parseKrishaAreaAndFloor(property.Title, &property)
+	parseCategoryAttributes(property.Title, &property)
+
+	// Телефон (если есть)
+	property.Phone = fields["phone"].Value(card)
+
+	return property
+}
+
+// parseKrishaPrice извлекает цену из текста
+func parseKrishaPrice(priceText string) (int64, string) {
+	if priceText == "" {
+		return 0, "KZT"
+	}
+
+	// Убираем все кроме цифр
+	priceStr := nonDigitRe.ReplaceAllString(priceText, "")
+
+	if price, err := strconv.ParseInt(priceStr, 10, 64); err == nil {
+		return price, "KZT"
+	}
+
+	return 0, "KZT"
+}
+
+// parseKrishaImages генерирует ссылку на главное изображение из UUID объявления
+// (поле image_id профиля) и номера фото (photo_id, по умолчанию первое)
+func parseKrishaImages(card *goquery.Selection, fields map[string]SelectorList) []string {
+	var images []string
+
+	photoId := fields["photo_id"].Value(card)
+	if photoId == "" {
+		photoId = "1"
+	}
+
+	// Генерируем одно главное изображение из UUID
+	if uuid := fields["image_id"].Value(card); len(uuid) >= 2 {
+		firstTwoChars := uuid[:2]
+		mainDomain := "https://krisha-photos.kcdn.online" // Основной рабочий домен
+
+		imageURL := fmt.Sprintf("%s/webp/%s/%s/%s-%s.webp", mainDomain, firstTwoChars, uuid, photoId, "400x300")
+		images = append(images, imageURL)
+	}
+
+	return images
+}
+
+// parseKrishaAreaAndFloor извлекает площадь, этаж и комнаты из заголовка
+func parseKrishaAreaAndFloor(title string, property *models.ParsedProperty) {
+	// Ищем площадь и этаж в заголовке: "25.5 м² 3/9 этаж"
+	if matches := krishaAreaFloorRe.FindStringSubmatch(title); len(matches) >= 4 {
+		if area, err := strconv.ParseFloat(matches[1], 64); err == nil {
+			property.Area = &area
+		}
+		if floor, err := strconv.Atoi(matches[2]); err == nil {
+			property.Floor = &floor
+		}
+		if totalFloors, err := strconv.Atoi(matches[3]); err == nil {
+			property.TotalFloors = &totalFloors
+		}
+	}
+
+	// Ищем количество комнат
+	if matches := krishaRoomsRe.FindStringSubmatch(title); len(matches) >= 2 {
+		if rooms, err := strconv.Atoi(matches[1]); err == nil {
+			property.Rooms = &rooms
+		}
+	}
+}
+
+// uniqueStrings убирает дубликаты с сохранением порядка
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+const listingJanitorInterval = time.Hour
+
+// ListingService ведет каталог объявлений из внешних источников.
+// Парсер передает сюда результаты каждого запуска, одно объявление
+// хранится одной записью независимо от количества запусков.
+type ListingService struct {
+	db         *gorm.DB
+	staleAfter time.Duration
+}
+
+// ListingFilters фильтры списка объявлений каталога
+type ListingFilters struct {
+	Source       string
+	City         string
+	DealType     string
+	PropertyType string
+	District     string
+	Rooms        *int
+	PriceMin     *int64
+	PriceMax     *int64
+	ActiveOnly   bool
+
+	// PriceDropped оставляет только объявления, цена которых снизилась с первого появления
+	PriceDropped bool
+
+	// Geo поиск по карте; при заданном центре выдача сортируется по расстоянию
+	Geo *GeoFilter
+
+	// NearPOI и MinWalkScore фильтры окружения: "не дальше 500 м от школы"
+	NearPOI      []models.POIDistanceFilter
+	MinWalkScore *int
+}
+
+// ListingPriceHistoryResult история цены объявления с производными показателями
+type ListingPriceHistoryResult struct {
+	ListingID            uuid.UUID                    `json:"listing_id"`
+	Currency             string                       `json:"currency"`
+	InitialPrice         int64                        `json:"initial_price"`
+	CurrentPrice         int64                        `json:"current_price"`
+	PriceChange          int64                        `json:"price_change"`
+	PriceChangePercent   float64                      `json:"price_change_percent"`
+	DaysSincePriceChange int                          `json:"days_since_price_change"`
+	PriceChangedAt       *time.Time                   `json:"price_changed_at"`
+	ChangesCount         int                          `json:"changes_count"`
+	History              []models.ListingPriceHistory `json:"history"`
+}
+
+func NewListingService(db *gorm.DB, cfg config.ParserConfig) *ListingService {
+	staleAfter := time.Duration(cfg.ListingStaleHours) * time.Hour
+	if staleAfter <= 0 {
+		staleAfter = 72 * time.Hour
+	}
+
+	return &ListingService{
+		db:         db,
+		staleAfter: staleAfter,
+	}
+}
+
+// UpsertParsed сохраняет результаты парсинга в каталог. Новые объявления
+// создаются, уже известные обновляются и снова помечаются активными.
+// Возвращает ID сохраненных объявлений.
+func (s *ListingService) UpsertParsed(parseRequestID *uuid.UUID, city string, properties []models.ParsedProperty) ([]uuid.UUID, error) {
+	seenAt := time.Now()
+	if city == "" {
+		city = "Алматы"
+	}
+
+	// В одном INSERT ... ON CONFLICT ключ не может повторяться
+	byKey := make(map[string]int)
+	var listings []models.Listing
+	for _, property := range properties {
+		if property.Source == "" || property.ID == "" {
+			continue
+		}
+
+		listing := models.NewListingFromParsed(property, city, seenAt)
+		listing.LastParseRequestID = parseRequestID
+
+		key := property.Source + "/" + property.ID
+		if i, ok := byKey[key]; ok {
+			listings[i] = listing
+			continue
+		}
+		byKey[key] = len(listings)
+		listings = append(listings, listing)
+	}
+
+	if len(listings) == 0 {
+		return nil, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Загружаем уже известные объявления, чтобы сравнить цены
+		keys := make([][]interface{}, 0, len(listings))
+		lockKeys := make([]string, 0, len(listings))
+		for _, listing := range listings {
+			keys = append(keys, []interface{}{listing.Source, listing.ExternalID})
+			lockKeys = append(lockKeys, listing.Source+"/"+listing.ExternalID)
+		}
+
+		// Новое объявление FOR UPDATE не блокирует: без блокировки по ключу два
+		// воркера не видят вставки друг друга и оба пишут начальную цену.
+		// Блокировки берутся в одном порядке, чтобы воркеры не ждали друг друга
+		// по кругу, и снимаются с концом транзакции.
+		sort.Strings(lockKeys)
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(k)) FROM jsonb_array_elements_text(?::jsonb) AS k ORDER BY k",
+			models.StringSlice(lockKeys)).Error; err != nil {
+			return err
+		}
+
+		var existing []models.Listing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "source", "external_id", "price").
+			Where("(source, external_id) IN ?", keys).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+
+		known := make(map[string]models.Listing, len(existing))
+		for _, listing := range existing {
+			known[listing.Source+"/"+listing.ExternalID] = listing
+		}
+
+		for i := range listings {
+			listing := &listings[i]
+			if previous, ok := known[listing.Source+"/"+listing.ExternalID]; ok {
+				listing.ID = previous.ID
+			} else {
+				listing.ID = uuid.New()
+			}
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source"}, {Name: "external_id"}},
+			DoUpdates: listingUpsertAssignments(),
+		}).Create(&listings).Error; err != nil {
+			return err
+		}
+
+		// Параллельный воркер мог вставить то же объявление раньше нас: тогда
+		// в строке остался его ID, а сгенерированный здесь нигде не сохранен
+		var stored []models.Listing
+		if err := tx.Select("id", "source", "external_id").
+			Where("(source, external_id) IN ?", keys).
+			Find(&stored).Error; err != nil {
+			return err
+		}
+		storedIDs := make(map[string]uuid.UUID, len(stored))
+		for _, listing := range stored {
+			storedIDs[listing.Source+"/"+listing.ExternalID] = listing.ID
+		}
+
+		var history []models.ListingPriceHistory
+		for i := range listings {
+			listing := &listings[i]
+			key := listing.Source + "/" + listing.ExternalID
+			if id, ok := storedIDs[key]; ok {
+				listing.ID = id
+			}
+
+			previous, ok := known[key]
+			if !ok {
+				if listing.Price > 0 {
+					history = append(history, newPriceHistory(listing, nil))
+				}
+				continue
+			}
+			if listing.Price > 0 && listing.Price != previous.Price {
+				previousPrice := previous.Price
+				history = append(history, newPriceHistory(listing, &previousPrice))
+			}
+		}
+
+		if len(history) > 0 {
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert listings: %w", err)
+	}
+
+	ids := make([]uuid.UUID, len(listings))
+	for i, listing := range listings {
+		ids[i] = listing.ID
+	}
+	return ids, nil
+}
+
+// newPriceHistory создает запись истории цены для объявления
+func newPriceHistory(listing *models.Listing, previousPrice *int64) models.ListingPriceHistory {
+	return models.ListingPriceHistory{
+		ListingID:      listing.ID,
+		Price:          listing.Price,
+		PreviousPrice:  previousPrice,
+		Currency:       listing.Currency,
+		ObservedAt:     listing.LastSeenAt,
+		ParseRequestID: listing.LastParseRequestID,
+	}
+}
+
+// listingUpsertAssignments обновляет известное объявление. Пустые значения из
+// карточки поиска не затирают данные, полученные ранее, а описание и фото со
+// страницы объявления не заменяются превью из карточки.
+func listingUpsertAssignments() clause.Set {
+	keepText := []string{
+		"title", "currency", "address", "city", "url", "phone",
+		"building_type", "seller_type", "residential_complex", "deal_type", "price_period",
+		"property_type", "land_purpose", "commercial_type",
+		"bathroom_type", "condition", "parking", "seller_name",
+	}
+	keepNullable := []string{
+		"rooms", "area", "floor", "total_floors", "build_year", "kitchen_area", "last_parse_request_id",
+		"deposit", "utilities_included", "pets_allowed", "land_area",
+		"ceiling_height", "published_at", "views", "detail_fetched_at",
+	}
+
+	// Новое значение из карточки, когда страница объявления уже загружалась
+	cardOverDetail := "excluded.detail_fetched_at IS NULL AND listings.detail_fetched_at IS NOT NULL"
+
+	// Местоположение обновляется целиком и только не менее точным:
+	// координаты центра района не затирают координаты дома
+	geoRank := func(table string) string {
+		return fmt.Sprintf("CASE %s.geo_precision WHEN '%s' THEN %d WHEN '%s' THEN %d WHEN '%s' THEN %d WHEN '%s' THEN %d WHEN '%s' THEN %d ELSE 0 END", table,
+			models.GeoPrecisionExact, models.GeoPrecisionRank(models.GeoPrecisionExact),
+			models.GeoPrecisionStreet, models.GeoPrecisionRank(models.GeoPrecisionStreet),
+			models.GeoPrecisionMicrodistrict, models.GeoPrecisionRank(models.GeoPrecisionMicrodistrict),
+			models.GeoPrecisionDistrict, models.GeoPrecisionRank(models.GeoPrecisionDistrict),
+			models.GeoPrecisionCity, models.GeoPrecisionRank(models.GeoPrecisionCity))
+	}
+	geoBetter := fmt.Sprintf("excluded.latitude IS NOT NULL AND %s >= %s", geoRank("excluded"), geoRank("listings"))
+	geoColumns := []string{"latitude", "longitude", "district", "microdistrict", "geo_precision", "geocoded_at"}
+
+	set := clause.Set{
+		// Выражения SET видят старые значения строки, поэтому порядок не важен
+		{Column: clause.Column{Name: "price_changed_at"}, Value: gorm.Expr("CASE WHEN excluded.price > 0 AND excluded.price <> listings.price THEN excluded.last_seen_at ELSE listings.price_changed_at END")},
+		{Column: clause.Column{Name: "initial_price"}, Value: gorm.Expr("CASE WHEN listings.initial_price > 0 THEN listings.initial_price ELSE excluded.initial_price END")},
+		{Column: clause.Column{Name: "price"}, Value: gorm.Expr("CASE WHEN excluded.price > 0 THEN excluded.price ELSE listings.price END")},
+		{Column: clause.Column{Name: "images"}, Value: gorm.Expr("CASE WHEN " + cardOverDetail + " THEN listings.images WHEN jsonb_array_length(COALESCE(excluded.images, '[]'::jsonb)) > 0 THEN excluded.images ELSE listings.images END")},
+		{Column: clause.Column{Name: "description"}, Value: gorm.Expr("CASE WHEN " + cardOverDetail + " THEN listings.description ELSE COALESCE(NULLIF(excluded.description, ''), listings.description) END")},
+		{Column: clause.Column{Name: "is_new_building"}, Value: gorm.Expr("excluded.is_new_building OR listings.is_new_building")},
+		{Column: clause.Column{Name: "last_seen_at"}, Value: gorm.Expr("excluded.last_seen_at")},
+		{Column: clause.Column{Name: "is_active"}, Value: true},
+		{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("excluded.updated_at")},
+	}
+	for _, column := range keepText {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr(fmt.Sprintf("COALESCE(NULLIF(excluded.%s, ''), listings.%s)", column, column)),
+		})
+	}
+	for _, column := range geoColumns {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr(fmt.Sprintf("CASE WHEN %s THEN excluded.%s ELSE listings.%s END", geoBetter, column, column)),
+		})
+	}
+	for _, column := range keepNullable {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr(fmt.Sprintf("COALESCE(excluded.%s, listings.%s)", column, column)),
+		})
+	}
+
+	return set
+}
+
+// DeactivateStale помечает неактивными объявления, которые не встречались с момента before
+func (s *ListingService) DeactivateStale(before time.Time) (int64, error) {
+	result := s.db.Model(&models.Listing{}).
+		Where("is_active = ? AND last_seen_at < ?", true, before).
+		Updates(map[string]interface{}{
+			"is_active":  false,
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Start периодически снимает с публикации объявления, пропавшие из выдачи
+func (s *ListingService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(listingJanitorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := s.DeactivateStale(time.Now().Add(-s.staleAfter))
+				if err != nil {
+					log.Printf("Failed to deactivate stale listings: %v", err)
+				} else if count > 0 {
+					log.Printf("Deactivated %d stale listings", count)
+				}
+			}
+		}
+	}()
+}
+
+// List возвращает объявления каталога с фильтрами и пагинацией
+func (s *ListingService) List(filters ListingFilters, page, limit int) ([]models.Listing, int64, error) {
+	var listings []models.Listing
+	var total int64
+
+	query := s.filterQuery(filters)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	if filters.Geo != nil && filters.Geo.Center != nil {
+		query = filters.Geo.applyDistanceOrder(query, "listings", listingLatExpr, listingLonExpr)
+	} else {
+		query = query.Order("last_seen_at DESC")
+	}
+
+	err := query.Offset((page - 1) * limit).Limit(limit).Find(&listings).Error
+	return listings, total, err
+}
+
+// Markers возвращает маркеры объявлений для карты, сгруппированные по
+// масштабу zoom: на мелком масштабе близкие объявления объединяются в кластеры.
+// Объявления с координатами центра района или города на карту не попадают.
+func (s *ListingService) Markers(filters ListingFilters, zoom int) ([]MapMarker, error) {
+	query := preciseGeo(s.filterQuery(filters), listingPrecisionExpr)
+	return clusterMarkers(query, listingLatExpr, listingLonExpr, "listings", zoom)
+}
+
+func (s *ListingService) filterQuery(filters ListingFilters) *gorm.DB {
+	query := s.db.Model(&models.Listing{})
+
+	if filters.Source != "" {
+		query = query.Where("source = ?", filters.Source)
+	}
+	if filters.City != "" {
+		query = query.Where("city = ?", filters.City)
+	}
+	if filters.DealType != "" {
+		query = query.Where("deal_type = ?", filters.DealType)
+	}
+	if filters.PropertyType != "" {
+		query = query.Where("property_type = ?", filters.PropertyType)
+	}
+	if filters.District != "" {
+		query = query.Where("district = ?", filters.District)
+	}
+	if filters.Rooms != nil {
+		query = query.Where("rooms = ?", *filters.Rooms)
+	}
+	if filters.PriceMin != nil {
+		query = query.Where("price >= ?", *filters.PriceMin)
+	}
+	if filters.PriceMax != nil {
+		query = query.Where("price <= ?", *filters.PriceMax)
+	}
+	if filters.PriceDropped {
+		query = query.Where("initial_price > 0 AND price < initial_price")
+	}
+	if filters.ActiveOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	if filters.Geo != nil {
+		query = filters.Geo.apply(query, listingLatExpr, listingLonExpr)
+		if filters.Geo.NeedsPrecision() {
+			query = preciseGeo(query, listingPrecisionExpr)
+		}
+	}
+	for _, near := range filters.NearPOI {
+		query = query.Where("EXISTS (SELECT 1 FROM listing_poi_distances d WHERE d.listing_id = listings.id AND d.category = ? AND d.distance_m <= ?)",
+			near.Category, near.WithinM)
+	}
+	if filters.MinWalkScore != nil {
+		query = query.Where("walk_score >= ?", *filters.MinWalkScore)
+	}
+
+	return query
+}
+
+// GetPriceHistory возвращает историю цены объявления в хронологическом порядке
+func (s *ListingService) GetPriceHistory(listingID uuid.UUID) (*ListingPriceHistoryResult, error) {
+	listing, err := s.GetByID(listingID)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []models.ListingPriceHistory
+	if err := s.db.Where("listing_id = ?", listingID).Order("observed_at ASC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+
+	changes := 0
+	for _, entry := range history {
+		if entry.PreviousPrice != nil {
+			changes++
+		}
+	}
+
+	return &ListingPriceHistoryResult{
+		ListingID:            listing.ID,
+		Currency:             listing.Currency,
+		InitialPrice:         listing.InitialPrice,
+		CurrentPrice:         listing.Price,
+		PriceChange:          listing.Price - listing.InitialPrice,
+		PriceChangePercent:   listing.PriceChangePercent,
+		DaysSincePriceChange: listing.DaysSincePriceChange,
+		PriceChangedAt:       listing.PriceChangedAt,
+		ChangesCount:         changes,
+		History:              history,
+	}, nil
+}
+
+// GetByID возвращает объявление каталога по ID
+func (s *ListingService) GetByID(id uuid.UUID) (*models.Listing, error) {
+	var listing models.Listing
+	if err := s.db.Where("id = ?", id).First(&listing).Error; err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tebeka/selenium"
+
+	"smartestate/internal/models"
+)
+
+// ListingSource описывает площадку-источник объявлений (krisha.kz, olx.kz и т.д.).
+// Парсер работает с источниками только через этот интерфейс, поэтому новая
+// площадка подключается реализацией интерфейса и регистрацией в SourceRegistry.
+type ListingSource interface {
+	// Name возвращает короткое имя источника (krisha, olx)
+	Name() string
+	// BuildSearchURL строит URL страницы поиска с учетом фильтров. Пустой URL
+	// значит, что площадка не ищет такие объявления
+	BuildSearchURL(filters models.PropertyFilters, page int) string
+	// UnsupportedFilters возвращает имена JSON фильтров, которые площадка не
+	// может учесть ни в поиске, ни при разборе выдачи
+	UnsupportedFilters(filters models.PropertyFilters) []string
+	// FetchPage загружает страницу и возвращает разобранный HTML документ
+	FetchPage(ctx context.Context, pageURL string) (*goquery.Document, error)
+	// ExtractCards извлекает карточки объявлений со страницы поиска
+	ExtractCards(doc *goquery.Document) []models.ParsedProperty
+	// CountResults возвращает число найденных объявлений по счетчику страницы
+	// поиска, false - если счетчика на странице нет
+	CountResults(doc *goquery.Document) (int, bool)
+	// ExtractDetail дополняет объявление данными со страницы объявления
+	ExtractDetail(doc *goquery.Document, property *models.ParsedProperty)
+}
+
+// SourceRegistry хранит зарегистрированные источники объявлений
+type SourceRegistry struct {
+	mu      sync.RWMutex
+	sources map[string]ListingSource
+	order   []string
+}
+
+// NewSourceRegistry создает пустой реестр источников
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{
+		sources: make(map[string]ListingSource),
+	}
+}
+
+// NewDefaultSourceRegistry создает реестр со всеми поддерживаемыми площадками.
+// Страницы загружаются через общий fetcher и разбираются по профилям selectors.
+// newDriver нужен olx.kz только как запасной способ загрузки, nil отключает Selenium.
+func NewDefaultSourceRegistry(fetcher *PoliteFetcher, selectors *SelectorStore, newDriver func() (selenium.WebDriver, error)) *SourceRegistry {
+	registry := NewSourceRegistry()
+	registry.Register(NewKrishaSource(fetcher, selectors))
+	registry.Register(NewOlxSource(fetcher, selectors, newDriver))
+	return registry
+}
+
+// Register добавляет источник в реестр. Повторная регистрация заменяет источник.
+func (r *SourceRegistry) Register(source ListingSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := source.Name()
+	if _, exists := r.sources[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.sources[name] = source
+}
+
+// Get возвращает источник по имени
+func (r *SourceRegistry) Get(name string) (ListingSource, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	source, ok := r.sources[strings.ToLower(strings.TrimSpace(name))]
+	return source, ok
+}
+
+// Names возвращает имена источников в порядке регистрации
+func (r *SourceRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	return names
+}
+
+// Resolve возвращает источники по списку имен. Пустой список означает все источники.
+func (r *SourceRegistry) Resolve(names []string) ([]ListingSource, error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+
+	var sources []ListingSource
+	var unknown []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		source, ok := r.Get(name)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if seen[source.Name()] {
+			continue
+		}
+		seen[source.Name()] = true
+		sources = append(sources, source)
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown listing sources: %s", strings.Join(unknown, ", "))
+	}
+
+	return sources, nil
+}
+
+// browserHeaders заголовки для имитации браузера при HTTP загрузке страниц
+var browserHeaders = map[string]string{
+	"User-Agent":                "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
+	"Accept-Language":           "ru-RU,ru;q=0.8,en-US;q=0.5,en;q=0.3",
+	"Connection":                "keep-alive",
+	"Upgrade-Insecure-Requests": "1",
+	"Cache-Control":             "no-cache",
+	"Pragma":                    "no-cache",
+}
+
+// HTTPStatusError ответ площадки с кодом, отличным от 200
+type HTTPStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // из заголовка Retry-After, если площадка его прислала
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d", e.StatusCode)
+}
+
+// Temporary сообщает, что запрос имеет смысл повторить: 429 и ошибки сервера
+func (e *HTTPStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// absoluteURL приводит относительную ссылку к абсолютной
+func absoluteURL(base, href string) string {
+	if href == "" || strings.HasPrefix(href, "http") {
+		return href
+	}
+	if strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	if !strings.HasPrefix(href, "/") {
+		href = "/" + href
+	}
+	return base + href
+}
+
+// parsePricePeriod определяет по тексту цены, за какой период указана аренда
+// ("250 000 〒 в месяц", "15 000 ₸ за сутки"). Для продажи возвращает пусто.
+func parsePricePeriod(priceText string) string {
+	lower := strings.ToLower(priceText)
+	switch {
+	case strings.Contains(lower, "сут") || strings.Contains(lower, "ночь") || strings.Contains(lower, "/день"):
+		return models.PricePeriodDay
+	case strings.Contains(lower, "мес"):
+		return models.PricePeriodMonth
+	}
+	return ""
+}
+
+// parseListingParameter разбирает параметр со страницы объявления: условия
+// аренды или атрибуты дома, участка и коммерческой недвижимости
+func parseListingParameter(title, value string, property *models.ParsedProperty) bool {
+	return parseRentalParameter(title, value, property) ||
+		parseCategoryParameter(title, value, property) ||
+		parseBuildingParameter(title, value, property)
+}
+
+// parseBuildingParameter разбирает параметры дома и квартиры: тип дома, высоту
+// потолков, санузел, состояние и парковку. Возвращает false для остальных параметров.
+func parseBuildingParameter(title, value string, property *models.ParsedProperty) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+
+	switch {
+	case strings.Contains(title, "тип дома") || strings.Contains(title, "тип строения") || strings.Contains(title, "материал стен"):
+		property.BuildingType = value
+	case strings.Contains(title, "потолк"):
+		if height, ok := parseCeilingHeight(value); ok {
+			property.CeilingHeight = &height
+		}
+	case strings.Contains(title, "санузел"):
+		property.BathroomType = value
+	case strings.Contains(title, "состояние") || title == "ремонт":
+		property.Condition = value
+	case strings.Contains(title, "парковка") || strings.Contains(title, "паркинг"):
+		property.Parking = value
+	default:
+		return false
+	}
+	return true
+}
+
+// parseCeilingHeight разбирает высоту потолков в метрах ("2.7 м", "270 см")
+func parseCeilingHeight(value string) (float64, bool) {
+	matches := decimalRe.FindString(value)
+	if matches == "" {
+		return 0, false
+	}
+	height, ok := parseDecimal(matches)
+	if !ok || height <= 0 {
+		return 0, false
+	}
+	if height > 10 {
+		height /= 100
+	}
+	return height, true
+}
+
+// parseSellerLabel определяет тип продавца по подписи: "Хозяин недвижимости",
+// "Специалист", "Застройщик". Пусто, если подпись не распознана.
+func parseSellerLabel(label string) string {
+	words := lowerWords(label)
+	switch {
+	case hasWordPrefix(words, "хозя", "собственни", "частн"):
+		return "owner"
+	case hasWordPrefix(words, "застройщ"):
+		return "developer"
+	case hasWordPrefix(words, "специалист", "агент", "риелтор", "риэлтор", "бизнес"):
+		return "agent"
+	}
+	return ""
+}
+
+// parseViews извлекает счетчик просмотров ("Просмотров: 1 234", "356 просмотров")
+func parseViews(text string) (int, bool) {
+	return parseCount(text)
+}
+
+// parseCount извлекает первое число с разделителями тысяч ("Найдено 1 234 объявления")
+func parseCount(text string) (int, bool) {
+	match := countRe.FindString(text)
+	if match == "" {
+		return 0, false
+	}
+	views, err := strconv.Atoi(strings.NewReplacer(" ", "", "\u00a0", "").Replace(match))
+	return views, err == nil
+}
+
+var russianMonths = map[string]time.Month{
+	"января": time.January, "февраля": time.February, "марта": time.March,
+	"апреля": time.April, "мая": time.May, "июня": time.June,
+	"июля": time.July, "августа": time.August, "сентября": time.September,
+	"октября": time.October, "ноября": time.November, "декабря": time.December,
+}
+
+// parsePublishedDate разбирает дату публикации: "12 мая 2024 г.", "3 июня",
+// "05.06.2024", "Сегодня в 12:00", "вчера". Возвращается дата без времени в UTC.
+func parsePublishedDate(text string, now time.Time) (time.Time, bool) {
+	text = strings.ToLower(text)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch {
+	case strings.Contains(text, "сегодня"):
+		return today, true
+	case strings.Contains(text, "вчера"):
+		return today.AddDate(0, 0, -1), true
+	}
+
+	if matches := numericDateRe.FindStringSubmatch(text); len(matches) == 4 {
+		day, _ := strconv.Atoi(matches[1])
+		month, _ := strconv.Atoi(matches[2])
+		year, _ := strconv.Atoi(matches[3])
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+	}
+
+	matches := textDateRe.FindStringSubmatch(text)
+	if len(matches) != 4 {
+		return time.Time{}, false
+	}
+	month, ok := russianMonths[matches[2]]
+	if !ok {
+		return time.Time{}, false
+	}
+	day, _ := strconv.Atoi(matches[1])
+	if matches[3] != "" {
+		year, _ := strconv.Atoi(matches[3])
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+	}
+
+	// Без года - последняя такая дата, не позже сегодняшней
+	date := time.Date(now.Year(), month, day, 0, 0, 0, 0, time.UTC)
+	if date.After(today) {
+		date = date.AddDate(-1, 0, 0)
+	}
+	return date, true
+}
+
+// parseRentalParameter разбирает параметры аренды: залог, коммунальные услуги
+// и животных. Возвращает false, если параметр не относится к аренде.
+func parseRentalParameter(title, value string, property *models.ParsedProperty) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	switch {
+	case strings.Contains(title, "залог") || strings.Contains(title, "депозит"):
+		deposit := int64(0)
+		if digits := nonDigitRe.ReplaceAllString(value, ""); digits != "" {
+			if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+				deposit = n
+			}
+		} else if yes, ok := parseYesNo(value); !ok || yes {
+			return true
+		}
+		property.Deposit = &deposit
+	case strings.Contains(title, "коммунал"):
+		if included, ok := parseUtilitiesIncluded(value); ok {
+			property.UtilitiesIncluded = &included
+		}
+	case strings.Contains(title, "живот"):
+		if allowed, ok := parseYesNo(value); ok {
+			property.PetsAllowed = &allowed
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// parseUtilitiesIncluded разбирает "включены", "не включены", "оплачиваются отдельно"
+func parseUtilitiesIncluded(value string) (bool, bool) {
+	switch {
+	case strings.Contains(value, "не вкл") || strings.Contains(value, "отдельно") || strings.Contains(value, "по счетчикам"):
+		return false, true
+	case strings.Contains(value, "включ"):
+		return true, true
+	}
+	return parseYesNo(value)
+}
+
+// parseYesNo разбирает ответы "да"/"нет", "можно"/"нельзя"
+func parseYesNo(value string) (bool, bool) {
+	switch {
+	case strings.HasPrefix(value, "нет") || strings.HasPrefix(value, "нельзя") || strings.Contains(value, "запрещ") || strings.HasPrefix(value, "без"):
+		return false, true
+	case strings.HasPrefix(value, "да") || strings.HasPrefix(value, "можно") || strings.Contains(value, "разреш"):
+		return true, true
+	}
+	return false, false
+}
+
+var (
+	// Числа с разделителем разрядов: "1 200 м²"
+	areaSquareMetersRe = regexp.MustCompile(`(\d{1,3}(?:[ \x{00a0}]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?)\s*м²`)
+	landAreaRe         = regexp.MustCompile(`(\d{1,3}(?:[ \x{00a0}]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?)\s*(сот|га(?:[^а-яё]|$))`)
+
+	decimalRe     = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+	countRe       = regexp.MustCompile(`\d{1,3}(?:[ \x{00a0}]\d{3})+|\d+`)
+	numericDateRe = regexp.MustCompile(`(\d{1,2})\.(\d{1,2})\.(\d{4})`)
+	textDateRe    = regexp.MustCompile(`(\d{1,2})\s+([а-я]+)(?:\s+(\d{4}))?`)
+
+	// Формы слова "дом" и названия домов, по которым заголовок относится к house
+	houseWords = map[string]bool{
+		"дом": true, "дома": true, "доме": true, "домом": true,
+		"дача": true, "дачу": true, "дачи": true,
+		"коттедж": true, "коттеджа": true, "особняк": true, "таунхаус": true,
+	}
+)
+
+// parseCategoryAttributes определяет по заголовку категорию и ее атрибуты:
+// площадь в м², площадь участка ("8 сот.", "1.5 га") и вид коммерческой недвижимости
+func parseCategoryAttributes(title string, property *models.ParsedProperty) {
+	if property.PropertyType == "" {
+		property.PropertyType = detectPropertyType(title)
+	}
+
+	if property.Area == nil {
+		if matches := areaSquareMetersRe.FindStringSubmatch(title); len(matches) == 2 {
+			if area, ok := parseDecimal(matches[1]); ok {
+				property.Area = &area
+			}
+		}
+	}
+
+	if property.LandArea == nil {
+		if area, ok := parseLandArea(title); ok {
+			property.LandArea = &area
+		}
+	}
+
+	if property.PropertyType == models.PropertyTypeCommercial && property.CommercialType == "" {
+		property.CommercialType = detectCommercialType(title)
+	}
+}
+
+// parseCategoryParameter разбирает параметры домов, участков и коммерческой
+// недвижимости. Возвращает false, если параметр к ним не относится.
+func parseCategoryParameter(title, value string, property *models.ParsedProperty) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.Contains(title, "назначение"):
+		property.LandPurpose = value
+	case strings.Contains(title, "площадь участка") || title == "участок":
+		if area, ok := parseLandArea(value); ok {
+			property.LandArea = &area
+		}
+	case strings.Contains(title, "тип помещения") || strings.Contains(title, "вид объекта") || strings.Contains(title, "тип объекта"):
+		if commercialType := detectCommercialType(value); commercialType != "" {
+			property.CommercialType = commercialType
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// parseLandArea извлекает площадь участка в сотках, гектары переводятся в сотки
+func parseLandArea(text string) (float64, bool) {
+	matches := landAreaRe.FindStringSubmatch(strings.ToLower(text))
+	if len(matches) != 3 {
+		return 0, false
+	}
+
+	area, ok := parseDecimal(matches[1])
+	if !ok {
+		return 0, false
+	}
+	if strings.HasPrefix(matches[2], "га") {
+		area *= 100
+	}
+	return area, true
+}
+
+// parseDecimal разбирает число с разделителями разрядов и десятичной запятой
+func parseDecimal(text string) (float64, bool) {
+	text = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(text)
+	n, err := strconv.ParseFloat(text, 64)
+	return n, err == nil
+}
+
+// detectPropertyType определяет категорию по заголовку объявления, пусто - не удалось
+func detectPropertyType(text string) string {
+	words := lowerWords(text)
+
+	switch {
+	case hasWordPrefix(words, "квартир"):
+		return models.PropertyTypeApartment
+	case detectCommercialType(text) != "" || hasWordPrefix(words, "помещени", "здани", "коммерческ"):
+		return models.PropertyTypeCommercial
+	case hasWord(words, houseWords):
+		return models.PropertyTypeHouse
+	case hasWordPrefix(words, "участ", "земел"):
+		return models.PropertyTypeLand
+	}
+	return ""
+}
+
+// detectCommercialType определяет вид коммерческой недвижимости по тексту
+func detectCommercialType(text string) string {
+	words := lowerWords(text)
+
+	switch {
+	case hasWordPrefix(words, "офис"):
+		return models.CommercialTypeOffice
+	case hasWordPrefix(words, "магазин", "бутик", "торгов", "павильон"):
+		return models.CommercialTypeRetail
+	case hasWordPrefix(words, "склад", "производств", "промбаз", "цех", "ангар"):
+		return models.CommercialTypeWarehouse
+	}
+	return ""
+}
+
+// lowerWords разбивает текст на слова в нижнем регистре
+func lowerWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+func hasWordPrefix(words []string, prefixes ...string) bool {
+	for _, word := range words {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasWord(words []string, set map[string]bool) bool {
+	for _, word := range words {
+		if set[word] {
+			return true
+		}
+	}
+	return false
+}
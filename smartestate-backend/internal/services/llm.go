@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"smartestate/internal/config"
+)
+
+// Роли сообщений диалога с LLM. Системный промпт передается в LLMRequest.System.
+const (
+	LLMRoleUser      = "user"
+	LLMRoleAssistant = "assistant"
+	LLMRoleTool      = "tool" // результат вызова инструмента
+)
+
+// Причины завершения ответа LLM
+const (
+	LLMStopEnd       = "stop"
+	LLMStopToolCalls = "tool_calls"
+	LLMStopLength    = "length"
+)
+
+// Функции приложения, для которых провайдер выбирается отдельно (AI_FEATURE_PROVIDERS)
+const (
+	LLMFeatureChat        = "chat"
+	LLMFeatureDescription = "description"
+	LLMFeatureSummary     = "summary" // краткое содержание старых сообщений чата
+)
+
+// ErrLLMProviderNotConfigured провайдер неизвестен или для него нет API ключа
+var ErrLLMProviderNotConfigured = errors.New("llm provider is not configured")
+
+// LLMMessage сообщение диалога
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content,omitempty"`
+	// ToolCalls вызовы инструментов в ответе ассистента
+	ToolCalls []LLMToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID и ToolName для роли tool: на какой вызов это ответ.
+	// Gemini сопоставляет ответ с вызовом по имени инструмента.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+}
+
+// LLMTool инструмент, который модель может вызвать
+type LLMTool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema аргументов
+}
+
+// LLMToolCall вызов инструмента моделью
+type LLMToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON объект
+}
+
+// LLMUsage расход токенов на запрос
+type LLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add прибавляет расход еще одного запроса
+func (u *LLMUsage) Add(other LLMUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// LLMRequest запрос к модели
+type LLMRequest struct {
+	Model       string // пусто - модель провайдера из настроек
+	System      string
+	Messages    []LLMMessage
+	Tools       []LLMTool
+	NoToolCalls bool // описания инструментов отправляются, но вызывать их нельзя
+	MaxTokens   int  // 0 - AI_MAX_TOKENS
+	Temperature *float32
+}
+
+// LLMResponse ответ модели
+type LLMResponse struct {
+	Provider   string        `json:"provider"`
+	Model      string        `json:"model"`
+	Content    string        `json:"content"`
+	ToolCalls  []LLMToolCall `json:"tool_calls,omitempty"`
+	StopReason string        `json:"stop_reason"` // LLMStop*
+	Usage      LLMUsage      `json:"usage"`
+}
+
+// LLMProvider провайдер языковой модели
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+	// Stream работает как Complete и передает текст ответа в onDelta по мере
+	// генерации. Вызовы инструментов и расход токенов есть только в итоговом ответе.
+	Stream(ctx context.Context, req LLMRequest, onDelta func(string)) (*LLMResponse, error)
+}
+
+// LLMRegistry провайдеры LLM с настроенными ключами и выбор провайдера:
+// указанный в запросе, затем для функции из AI_FEATURE_PROVIDERS, затем AI_PROVIDER
+type LLMRegistry struct {
+	providers       map[string]LLMProvider
+	defaultProvider string
+	features        map[string]string
+}
+
+// NewLLMRegistry создает провайдеров, для которых заданы ключи. Fake
+// подключается, только если выбран в AI_PROVIDER или AI_FEATURE_PROVIDERS.
+func NewLLMRegistry(cfg config.AIConfig) *LLMRegistry {
+	registry := &LLMRegistry{
+		providers:       make(map[string]LLMProvider),
+		defaultProvider: normalizeLLMProviderName(cfg.Provider),
+		features:        make(map[string]string),
+	}
+
+	for _, pair := range strings.Split(cfg.FeatureProviders, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		feature, provider, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Printf("Invalid AI_FEATURE_PROVIDERS entry %q, expected feature=provider", pair)
+			continue
+		}
+		registry.features[strings.TrimSpace(feature)] = normalizeLLMProviderName(provider)
+	}
+
+	if cfg.OpenAIKey != "" {
+		registry.Register(NewOpenAIProvider(cfg))
+	}
+	if cfg.GeminiKey != "" {
+		registry.Register(NewGeminiProvider(cfg))
+	}
+	if cfg.AnthropicKey != "" {
+		registry.Register(NewAnthropicProvider(cfg))
+	}
+
+	wantsFake := registry.defaultProvider == "fake"
+	for _, provider := range registry.features {
+		wantsFake = wantsFake || provider == "fake"
+	}
+	if wantsFake {
+		registry.Register(NewFakeLLM())
+	}
+	return registry
+}
+
+// normalizeLLMProviderName приводит имя провайдера к нижнему регистру, claude - к anthropic
+func normalizeLLMProviderName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "claude" {
+		return "anthropic"
+	}
+	return name
+}
+
+// Register добавляет или заменяет провайдера, например FakeLLM в тестах
+func (r *LLMRegistry) Register(provider LLMProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+// Providers возвращает имена доступных провайдеров
+func (r *LLMRegistry) Providers() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProviderName возвращает имя провайдера, который будет выбран для функции
+func (r *LLMRegistry) ProviderName(feature, requested string) string {
+	if name := normalizeLLMProviderName(requested); name != "" {
+		return name
+	}
+	if name, ok := r.features[feature]; ok && name != "" {
+		return name
+	}
+	return r.defaultProvider
+}
+
+// Provider выбирает провайдера для функции feature. requested - провайдер из
+// запроса пользователя, пустая строка - по настройкам.
+func (r *LLMRegistry) Provider(feature, requested string) (LLMProvider, error) {
+	name := r.ProviderName(feature, requested)
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrLLMProviderNotConfigured, name)
+	}
+	return provider, nil
+}
+
+// llmMaxTokens ограничение длины ответа из запроса или настроек
+func llmMaxTokens(req LLMRequest, fallback int) int {
+	if req.MaxTokens > 0 {
+		return req.MaxTokens
+	}
+	if fallback > 0 {
+		return fallback
+	}
+	return 2048
+}
+
+// llmAPIError ошибка HTTP API провайдера с началом тела ответа
+func llmAPIError(provider string, statusCode int, body io.Reader) error {
+	message, _ := io.ReadAll(io.LimitReader(body, 1024))
+	return fmt.Errorf("%s: %w: %s", provider, &HTTPStatusError{StatusCode: statusCode}, strings.TrimSpace(string(message)))
+}
+
+// readSSE читает поток Server-Sent Events и передает события с данными в
+// handle. Поле event пустое, если сервер его не прислал.
+func readSSE(body io.Reader, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var event string
+	var data []string
+	flush := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := handle(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// комментарий, например keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"smartestate/internal/config"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+)
+
+// AnthropicProvider модели Anthropic Claude через Messages API
+type AnthropicProvider struct {
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Temperature *float32             `json:"temperature,omitempty"`
+	Stream      bool                 `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"` // user или assistant
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"` // text, tool_use, tool_result
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"` // auto, any, tool, none
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicStreamEvent событие потока Messages API
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message"`
+	ContentBlock *anthropicBlock    `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"` // text_delta, input_json_delta
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewAnthropicProvider(cfg config.AIConfig) *AnthropicProvider {
+	return &AnthropicProvider{
+		apiKey:    cfg.AnthropicKey,
+		model:     cfg.AnthropicModel,
+		maxTokens: cfg.MaxTokens,
+		client:    &http.Client{},
+	}
+}
+
+func (p *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (p *AnthropicProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, p.request(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var message anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("anthropic: failed to decode response: %w", err)
+	}
+
+	result := &LLMResponse{
+		Provider:   p.Name(),
+		Model:      message.Model,
+		StopReason: anthropicStopReason(message.StopReason),
+		Usage:      anthropicLLMUsage(message.Usage),
+	}
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			result.Content += block.Text
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, LLMToolCall{ID: block.ID, Name: block.Name, Arguments: jsonObjectOrEmpty(string(block.Input))})
+		}
+	}
+	return result, nil
+}
+
+func (p *AnthropicProvider) Stream(ctx context.Context, req LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	resp, err := p.post(ctx, p.request(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &LLMResponse{Provider: p.Name()}
+	var usage anthropicUsage
+	// Блоки tool_use собираются по индексу из кусков partial_json
+	calls := make(map[int]*LLMToolCall)
+	var order []int
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("anthropic: failed to decode stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				usage = event.Message.Usage
+			}
+		case "content_block_start":
+			if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+				calls[event.Index] = &LLMToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+				order = append(order, event.Index)
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				result.Content += event.Delta.Text
+				onDelta(event.Delta.Text)
+			case "input_json_delta":
+				if call, ok := calls[event.Index]; ok {
+					call.Arguments += event.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				result.StopReason = anthropicStopReason(event.Delta.StopReason)
+			}
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("anthropic: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return fmt.Errorf("anthropic: stream error")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range order {
+		call := calls[index]
+		call.Arguments = jsonObjectOrEmpty(call.Arguments)
+		result.ToolCalls = append(result.ToolCalls, *call)
+	}
+	result.Usage = anthropicLLMUsage(usage)
+	return result, nil
+}
+
+// post отправляет запрос к Messages API и проверяет статус ответа
+func (p *AnthropicProvider) post(ctx context.Context, request anthropicRequest) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, anthropicBaseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llmAPIError("anthropic", resp.StatusCode, resp.Body)
+	}
+	return resp, nil
+}
+
+// request переводит запрос в формат Messages API: результаты инструментов -
+// блоки tool_result в сообщении пользователя, подряд идущие сообщения одной
+// роли объединяются
+func (p *AnthropicProvider) request(req LLMRequest, stream bool) anthropicRequest {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+	request := anthropicRequest{
+		Model:       model,
+		MaxTokens:   llmMaxTokens(req, p.maxTokens),
+		System:      req.System,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+
+	for _, message := range req.Messages {
+		var converted anthropicMessage
+		switch message.Role {
+		case LLMRoleAssistant:
+			converted.Role = "assistant"
+			if message.Content != "" {
+				converted.Content = append(converted.Content, anthropicBlock{Type: "text", Text: message.Content})
+			}
+			for _, call := range message.ToolCalls {
+				converted.Content = append(converted.Content, anthropicBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: json.RawMessage(jsonObjectOrEmpty(call.Arguments)),
+				})
+			}
+		case LLMRoleTool:
+			converted.Role = "user"
+			converted.Content = []anthropicBlock{{Type: "tool_result", ToolUseID: message.ToolCallID, Content: message.Content}}
+		default:
+			converted.Role = "user"
+			converted.Content = []anthropicBlock{{Type: "text", Text: message.Content}}
+		}
+		if len(converted.Content) == 0 {
+			continue
+		}
+
+		if last := len(request.Messages) - 1; last >= 0 && request.Messages[last].Role == converted.Role {
+			request.Messages[last].Content = append(request.Messages[last].Content, converted.Content...)
+			continue
+		}
+		request.Messages = append(request.Messages, converted)
+	}
+
+	for _, tool := range req.Tools {
+		schema := tool.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		request.Tools = append(request.Tools, anthropicTool{Name: tool.Name, Description: tool.Description, InputSchema: schema})
+	}
+	if req.NoToolCalls && len(request.Tools) > 0 {
+		request.ToolChoice = &anthropicToolChoice{Type: "none"}
+	}
+	return request
+}
+
+func anthropicStopReason(reason string) string {
+	switch reason {
+	case "tool_use":
+		return LLMStopToolCalls
+	case "max_tokens":
+		return LLMStopLength
+	}
+	return LLMStopEnd
+}
+
+func anthropicLLMUsage(usage anthropicUsage) LLMUsage {
+	return LLMUsage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeLLM детерминированный провайдер для тестов и локального запуска без
+// ключей. Возвращает ответы сценария по порядку, а когда сценарий кончился,
+// повторяет последнее сообщение пользователя. Запросы сохраняются для проверок.
+type FakeLLM struct {
+	mu       sync.Mutex
+	script   []LLMResponse
+	requests []LLMRequest
+}
+
+func NewFakeLLM(script ...LLMResponse) *FakeLLM {
+	return &FakeLLM{script: script}
+}
+
+func (f *FakeLLM) Name() string {
+	return "fake"
+}
+
+// Script добавляет ответы в конец сценария
+func (f *FakeLLM) Script(responses ...LLMResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, responses...)
+}
+
+// Requests возвращает полученные запросы по порядку
+func (f *FakeLLM) Requests() []LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]LLMRequest(nil), f.requests...)
+}
+
+func (f *FakeLLM) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	var response LLMResponse
+	if len(f.script) > 0 {
+		response, f.script = f.script[0], f.script[1:]
+	} else {
+		response.Content = "fake: " + lastUserMessage(req.Messages)
+	}
+
+	response.Provider = f.Name()
+	if response.Model == "" {
+		response.Model = "fake"
+	}
+	if response.StopReason == "" {
+		response.StopReason = LLMStopEnd
+		if len(response.ToolCalls) > 0 {
+			response.StopReason = LLMStopToolCalls
+		}
+	}
+	if response.Usage.TotalTokens == 0 {
+		// Словами вместо токенов: считать точно не нужно, нужна повторяемость
+		prompt := len(strings.Fields(req.System))
+		for _, message := range req.Messages {
+			prompt += len(strings.Fields(message.Content))
+		}
+		completion := len(strings.Fields(response.Content))
+		response.Usage = LLMUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	}
+	return &response, nil
+}
+
+// Stream передает ответ сценария по словам
+func (f *FakeLLM) Stream(ctx context.Context, req LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	response, err := f.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, word := range strings.SplitAfter(response.Content, " ") {
+		// Как у настоящих провайдеров: отмена обрывает поток посреди ответа
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if word != "" {
+			onDelta(word)
+		}
+	}
+	return response, nil
+}
+
+func lastUserMessage(messages []LLMMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == LLMRoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
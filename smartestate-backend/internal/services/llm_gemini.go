@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"smartestate/internal/config"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider модели Google Gemini через REST API generateContent
+type GeminiProvider struct {
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig      `json:"toolConfig,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // user или model
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode string `json:"mode"` // AUTO, ANY, NONE
+	} `json:"functionCallingConfig"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float32 `json:"temperature,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+func NewGeminiProvider(cfg config.AIConfig) *GeminiProvider {
+	return &GeminiProvider{
+		apiKey:    cfg.GeminiKey,
+		model:     cfg.GeminiModel,
+		maxTokens: cfg.MaxTokens,
+		client:    &http.Client{},
+	}
+}
+
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *GeminiProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, model, err := p.post(ctx, req, "generateContent")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chunk geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+		return nil, fmt.Errorf("gemini: failed to decode response: %w", err)
+	}
+
+	result := &LLMResponse{Provider: p.Name(), Model: model}
+	mergeGeminiChunk(result, chunk, nil)
+	if len(chunk.Candidates) == 0 {
+		return nil, fmt.Errorf("gemini: empty response")
+	}
+	return result, nil
+}
+
+func (p *GeminiProvider) Stream(ctx context.Context, req LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	resp, model, err := p.post(ctx, req, "streamGenerateContent?alt=sse")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &LLMResponse{Provider: p.Name(), Model: model}
+	err = readSSE(resp.Body, func(_, data string) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("gemini: failed to decode stream chunk: %w", err)
+		}
+		mergeGeminiChunk(result, chunk, onDelta)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// post отправляет запрос к методу модели и проверяет статус ответа
+func (p *GeminiProvider) post(ctx context.Context, req LLMRequest, method string) (*http.Response, string, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	body, err := json.Marshal(p.request(req))
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiBaseURL+"/models/"+model+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("gemini: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, "", llmAPIError("gemini", resp.StatusCode, resp.Body)
+	}
+	return resp, model, nil
+}
+
+// request переводит запрос в формат generateContent: роль assistant - model,
+// результаты инструментов - functionResponse от пользователя
+func (p *GeminiProvider) request(req LLMRequest) geminiRequest {
+	request := geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			MaxOutputTokens: llmMaxTokens(req, p.maxTokens),
+			Temperature:     req.Temperature,
+		},
+	}
+	if req.System != "" {
+		request.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+
+	for _, message := range req.Messages {
+		var content geminiContent
+		switch message.Role {
+		case LLMRoleAssistant:
+			content.Role = "model"
+			if message.Content != "" {
+				content.Parts = append(content.Parts, geminiPart{Text: message.Content})
+			}
+			for _, call := range message.ToolCalls {
+				content.Parts = append(content.Parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Name, Args: json.RawMessage(jsonObjectOrEmpty(call.Arguments))}})
+			}
+		case LLMRoleTool:
+			content.Role = "user"
+			content.Parts = []geminiPart{{FunctionResponse: &geminiFunctionResponse{
+				Name:     message.ToolName,
+				Response: geminiToolResult(message.Content),
+			}}}
+		default:
+			content.Role = "user"
+			content.Parts = []geminiPart{{Text: message.Content}}
+		}
+
+		// Подряд идущие сообщения одной роли, например ответы на несколько вызовов, объединяются
+		if last := len(request.Contents) - 1; last >= 0 && request.Contents[last].Role == content.Role {
+			request.Contents[last].Parts = append(request.Contents[last].Parts, content.Parts...)
+			continue
+		}
+		request.Contents = append(request.Contents, content)
+	}
+
+	if len(req.Tools) > 0 {
+		declarations := make([]geminiFunctionDeclaration, len(req.Tools))
+		for i, tool := range req.Tools {
+			declarations[i] = geminiFunctionDeclaration{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters}
+		}
+		request.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+		if req.NoToolCalls {
+			request.ToolConfig = &geminiToolConfig{}
+			request.ToolConfig.FunctionCallingConfig.Mode = "NONE"
+		}
+	}
+	return request
+}
+
+// mergeGeminiChunk добавляет к ответу часть потока или полный ответ Gemini
+func mergeGeminiChunk(r *LLMResponse, chunk geminiResponse, onDelta func(string)) {
+	if chunk.ModelVersion != "" {
+		r.Model = chunk.ModelVersion
+	}
+	if usage := chunk.UsageMetadata; usage.TotalTokenCount > 0 {
+		r.Usage = LLMUsage{
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		}
+	}
+	if len(chunk.Candidates) == 0 {
+		return
+	}
+
+	candidate := chunk.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		if part.Text != "" {
+			r.Content += part.Text
+			if onDelta != nil {
+				onDelta(part.Text)
+			}
+		}
+		if part.FunctionCall != nil {
+			r.ToolCalls = append(r.ToolCalls, LLMToolCall{
+				// У Gemini нет ID вызовов, ответ сопоставляется по имени
+				ID:        fmt.Sprintf("call_%d", len(r.ToolCalls)+1),
+				Name:      part.FunctionCall.Name,
+				Arguments: jsonObjectOrEmpty(string(part.FunctionCall.Args)),
+			})
+		}
+	}
+
+	switch candidate.FinishReason {
+	case "":
+	case "MAX_TOKENS":
+		r.StopReason = LLMStopLength
+	default:
+		r.StopReason = LLMStopEnd
+	}
+	if len(r.ToolCalls) > 0 {
+		r.StopReason = LLMStopToolCalls
+	}
+}
+
+// geminiToolResult оборачивает результат инструмента в объект: Gemini
+// принимает в functionResponse только JSON объект
+func geminiToolResult(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"content": content})
+	return wrapped
+}
+
+// jsonObjectOrEmpty возвращает аргументы вызова или {}, если их нет
+func jsonObjectOrEmpty(arguments string) string {
+	if strings.TrimSpace(arguments) == "" || strings.TrimSpace(arguments) == "null" {
+		return "{}"
+	}
+	return arguments
+}
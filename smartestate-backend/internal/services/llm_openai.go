@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/sashabaranov/go-openai"
+
+	"smartestate/internal/config"
+)
+
+// OpenAIProvider модели OpenAI через Chat Completions API
+type OpenAIProvider struct {
+	client    *openai.Client
+	model     string
+	maxTokens int
+}
+
+func NewOpenAIProvider(cfg config.AIConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		client:    openai.NewClient(cfg.OpenAIKey),
+		model:     cfg.OpenAIModel,
+		maxTokens: cfg.MaxTokens,
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, p.request(req))
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("openai: empty response")
+	}
+
+	choice := resp.Choices[0]
+	result := &LLMResponse{
+		Provider:   p.Name(),
+		Model:      resp.Model,
+		Content:    choice.Message.Content,
+		StopReason: openAIStopReason(choice.FinishReason),
+		Usage: LLMUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+	for _, call := range choice.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, LLMToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return result, nil
+}
+
+func (p *OpenAIProvider) Stream(ctx context.Context, req LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	request := p.request(req)
+	request.Stream = true
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	defer stream.Close()
+
+	result := &LLMResponse{Provider: p.Name(), Model: request.Model}
+	var content []byte
+	// Аргументы вызовов приходят кусками, вызов определяется по индексу
+	calls := make(map[int]*LLMToolCall)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("openai: %w", err)
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = LLMUsage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			content = append(content, choice.Delta.Content...)
+			onDelta(choice.Delta.Content)
+		}
+		for _, delta := range choice.Delta.ToolCalls {
+			index := 0
+			if delta.Index != nil {
+				index = *delta.Index
+			}
+			call, ok := calls[index]
+			if !ok {
+				call = &LLMToolCall{}
+				calls[index] = call
+			}
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			if delta.Function.Name != "" {
+				call.Name = delta.Function.Name
+			}
+			call.Arguments += delta.Function.Arguments
+		}
+		if choice.FinishReason != "" {
+			result.StopReason = openAIStopReason(choice.FinishReason)
+		}
+	}
+
+	result.Content = string(content)
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		result.ToolCalls = append(result.ToolCalls, *calls[index])
+	}
+	return result, nil
+}
+
+// request переводит запрос в формат Chat Completions
+func (p *OpenAIProvider) request(req LLMRequest) openai.ChatCompletionRequest {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	var messages []openai.ChatCompletionMessage
+	if req.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: req.System})
+	}
+	for _, message := range req.Messages {
+		converted := openai.ChatCompletionMessage{Role: message.Role, Content: message.Content}
+		switch message.Role {
+		case LLMRoleAssistant:
+			for _, call := range message.ToolCalls {
+				converted.ToolCalls = append(converted.ToolCalls, openai.ToolCall{
+					ID:       call.ID,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+				})
+			}
+		case LLMRoleTool:
+			converted.ToolCallID = message.ToolCallID
+		}
+		messages = append(messages, converted)
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:     model,
+		Messages:  messages,
+		MaxTokens: llmMaxTokens(req, p.maxTokens),
+	}
+	if req.Temperature != nil {
+		request.Temperature = *req.Temperature
+	}
+	for _, tool := range req.Tools {
+		request.Tools = append(request.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if req.NoToolCalls && len(request.Tools) > 0 {
+		request.ToolChoice = "none"
+	}
+	return request
+}
+
+func openAIStopReason(reason openai.FinishReason) string {
+	switch reason {
+	case openai.FinishReasonToolCalls, openai.FinishReasonFunctionCall:
+		return LLMStopToolCalls
+	case openai.FinishReasonLength:
+		return LLMStopLength
+	}
+	return LLMStopEnd
+}
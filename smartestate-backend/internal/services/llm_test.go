@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	"smartestate/internal/config"
+)
+
+// roundTripFunc подменяет HTTP транспорт провайдера, базовые URL которых - константы
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// sseClient отвечает на любой запрос потоком body и сохраняет тело запроса
+func sseClient(body string, sent *[]byte) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if sent != nil {
+			*sent, _ = io.ReadAll(req.Body)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})}
+}
+
+func TestReadSSE(t *testing.T) {
+	type sseEvent struct{ event, data string }
+
+	tests := []struct {
+		name  string
+		input string
+		want  []sseEvent
+	}{
+		{
+			name:  "data only",
+			input: "data: {\"a\":1}\n\ndata: {\"a\":2}\n\n",
+			want:  []sseEvent{{"", `{"a":1}`}, {"", `{"a":2}`}},
+		},
+		{
+			name:  "named events",
+			input: "event: message_start\ndata: one\n\nevent: ping\ndata: two\n\n",
+			want:  []sseEvent{{"message_start", "one"}, {"ping", "two"}},
+		},
+		{
+			name:  "multiline data is joined",
+			input: "data: first\ndata: second\n\n",
+			want:  []sseEvent{{"", "first\nsecond"}},
+		},
+		{
+			name:  "comments and events without data are skipped",
+			input: ": keep-alive\n\nevent: ping\n\ndata: payload\n\n",
+			want:  []sseEvent{{"", "payload"}},
+		},
+		{
+			name:  "no space after colon",
+			input: "event:delta\ndata:text\n\n",
+			want:  []sseEvent{{"delta", "text"}},
+		},
+		{
+			name:  "last event without blank line",
+			input: "data: tail",
+			want:  []sseEvent{{"", "tail"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []sseEvent
+			err := readSSE(strings.NewReader(tt.input), func(event, data string) error {
+				got = append(got, sseEvent{event, data})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("readSSE: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEStopsOnHandlerError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := readSSE(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(_, _ string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v, calls = %d, want handler error after 1 call", err, calls)
+	}
+}
+
+// toolConversation история с вызовом двух инструментов и их результатами
+func toolConversation() LLMRequest {
+	return LLMRequest{
+		System: "system prompt",
+		Messages: []LLMMessage{
+			{Role: LLMRoleUser, Content: "найди квартиру"},
+			{Role: LLMRoleAssistant, Content: "Ищу", ToolCalls: []LLMToolCall{
+				{ID: "call_1", Name: "search", Arguments: `{"rooms":2}`},
+				{ID: "call_2", Name: "mortgage"},
+			}},
+			{Role: LLMRoleTool, ToolCallID: "call_1", ToolName: "search", Content: `{"found":3}`},
+			{Role: LLMRoleTool, ToolCallID: "call_2", ToolName: "mortgage", Content: "plain text"},
+		},
+		Tools: []LLMTool{{Name: "search", Description: "search listings"}, {Name: "mortgage"}},
+	}
+}
+
+func TestAnthropicRequest(t *testing.T) {
+	provider := NewAnthropicProvider(config.AIConfig{AnthropicModel: "claude-test", MaxTokens: 100})
+
+	request := provider.request(toolConversation(), true)
+	if request.Model != "claude-test" || request.MaxTokens != 100 || !request.Stream || request.System != "system prompt" {
+		t.Errorf("request settings = %+v", request)
+	}
+
+	// Результаты обоих инструментов - один ход пользователя с блоками tool_result
+	if len(request.Messages) != 3 {
+		t.Fatalf("messages = %d, want 3: %+v", len(request.Messages), request.Messages)
+	}
+	assistant := request.Messages[1]
+	if assistant.Role != "assistant" || len(assistant.Content) != 3 {
+		t.Fatalf("assistant turn = %+v", assistant)
+	}
+	if call := assistant.Content[2]; call.Type != "tool_use" || call.ID != "call_2" || string(call.Input) != "{}" {
+		t.Errorf("tool_use without arguments = %+v, want empty object input", call)
+	}
+	results := request.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 ||
+		results.Content[0].Type != "tool_result" || results.Content[0].ToolUseID != "call_1" ||
+		results.Content[1].ToolUseID != "call_2" {
+		t.Errorf("tool results turn = %+v", results)
+	}
+
+	if len(request.Tools) != 2 || request.Tools[1].InputSchema["type"] != "object" {
+		t.Errorf("tools = %+v, want default object schema", request.Tools)
+	}
+	if request.ToolChoice != nil {
+		t.Errorf("tool_choice = %+v, want unset", request.ToolChoice)
+	}
+
+	noCalls := toolConversation()
+	noCalls.NoToolCalls = true
+	request = provider.request(noCalls, false)
+	if len(request.Tools) != 2 || request.ToolChoice == nil || request.ToolChoice.Type != "none" {
+		t.Errorf("NoToolCalls: tools = %d, tool_choice = %+v, want tools kept and choice none", len(request.Tools), request.ToolChoice)
+	}
+}
+
+func TestOpenAIRequest(t *testing.T) {
+	provider := NewOpenAIProvider(config.AIConfig{OpenAIModel: "gpt-test", MaxTokens: 100})
+
+	request := provider.request(toolConversation())
+	if request.Model != "gpt-test" || request.MaxTokens != 100 {
+		t.Errorf("request settings: model %q, max tokens %d", request.Model, request.MaxTokens)
+	}
+
+	roles := make([]string, len(request.Messages))
+	for i, message := range request.Messages {
+		roles[i] = message.Role
+	}
+	wantRoles := []string{"system", "user", "assistant", "tool", "tool"}
+	if !reflect.DeepEqual(roles, wantRoles) {
+		t.Fatalf("roles = %v, want %v", roles, wantRoles)
+	}
+	if calls := request.Messages[2].ToolCalls; len(calls) != 2 || calls[0].Function.Arguments != `{"rooms":2}` {
+		t.Errorf("assistant tool calls = %+v", calls)
+	}
+	if request.Messages[4].ToolCallID != "call_2" {
+		t.Errorf("tool result call ID = %q, want call_2", request.Messages[4].ToolCallID)
+	}
+	if len(request.Tools) != 2 || request.ToolChoice != nil {
+		t.Errorf("tools = %d, tool_choice = %v", len(request.Tools), request.ToolChoice)
+	}
+
+	noCalls := toolConversation()
+	noCalls.NoToolCalls = true
+	request = provider.request(noCalls)
+	if len(request.Tools) != 2 || request.ToolChoice != "none" {
+		t.Errorf("NoToolCalls: tools = %d, tool_choice = %v, want tools kept and choice none", len(request.Tools), request.ToolChoice)
+	}
+}
+
+func TestGeminiRequest(t *testing.T) {
+	provider := NewGeminiProvider(config.AIConfig{GeminiModel: "gemini-test", MaxTokens: 100})
+
+	request := provider.request(toolConversation())
+	if request.SystemInstruction == nil || request.SystemInstruction.Parts[0].Text != "system prompt" {
+		t.Errorf("system instruction = %+v", request.SystemInstruction)
+	}
+	if len(request.Contents) != 3 {
+		t.Fatalf("contents = %d, want 3: %+v", len(request.Contents), request.Contents)
+	}
+	if model := request.Contents[1]; model.Role != "model" || len(model.Parts) != 3 || model.Parts[1].FunctionCall.Name != "search" {
+		t.Errorf("model turn = %+v", model)
+	}
+
+	results := request.Contents[2]
+	if results.Role != "user" || len(results.Parts) != 2 {
+		t.Fatalf("function responses = %+v", results)
+	}
+	if got := string(results.Parts[0].FunctionResponse.Response); got != `{"found":3}` {
+		t.Errorf("object result = %s, want it unchanged", got)
+	}
+	if got := string(results.Parts[1].FunctionResponse.Response); got != `{"content":"plain text"}` {
+		t.Errorf("text result = %s, want it wrapped into an object", got)
+	}
+	if request.ToolConfig != nil {
+		t.Errorf("tool config = %+v, want unset", request.ToolConfig)
+	}
+
+	noCalls := toolConversation()
+	noCalls.NoToolCalls = true
+	request = provider.request(noCalls)
+	if len(request.Tools) != 1 || request.ToolConfig == nil || request.ToolConfig.FunctionCallingConfig.Mode != "NONE" {
+		t.Errorf("NoToolCalls: tools = %+v, tool config = %+v", request.Tools, request.ToolConfig)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Ищу "}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"варианты"}}`,
+		``,
+		`event: ping`,
+		`data: {"type":"ping"}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"rooms\""}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":": 2}"}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"mortgage","input":{}}}`,
+		``,
+		`event: message_delta`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+		``,
+		`event: message_stop`,
+		`data: {"type":"message_stop"}`,
+		``,
+	}, "\n")
+
+	var sent []byte
+	provider := NewAnthropicProvider(config.AIConfig{AnthropicModel: "claude-test", MaxTokens: 100})
+	provider.client = sseClient(stream, &sent)
+
+	var deltas []string
+	response, err := provider.Stream(context.Background(), toolConversation(), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	var request map[string]interface{}
+	if err := json.Unmarshal(sent, &request); err != nil || request["stream"] != true {
+		t.Errorf("sent request = %s, want stream enabled", sent)
+	}
+	if !reflect.DeepEqual(deltas, []string{"Ищу ", "варианты"}) || response.Content != "Ищу варианты" {
+		t.Errorf("deltas = %q, content = %q", deltas, response.Content)
+	}
+	wantCalls := []LLMToolCall{
+		{ID: "toolu_1", Name: "search", Arguments: `{"rooms": 2}`},
+		{ID: "toolu_2", Name: "mortgage", Arguments: "{}"},
+	}
+	if !reflect.DeepEqual(response.ToolCalls, wantCalls) {
+		t.Errorf("tool calls = %+v, want %+v", response.ToolCalls, wantCalls)
+	}
+	if response.StopReason != LLMStopToolCalls || response.Model != "claude-test" {
+		t.Errorf("stop reason = %q, model = %q", response.StopReason, response.Model)
+	}
+	if want := (LLMUsage{PromptTokens: 12, CompletionTokens: 30, TotalTokens: 42}); response.Usage != want {
+		t.Errorf("usage = %+v, want %+v", response.Usage, want)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	provider := NewAnthropicProvider(config.AIConfig{MaxTokens: 100})
+	provider.client = sseClient("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n", nil)
+
+	_, err := provider.Stream(context.Background(), LLMRequest{Messages: []LLMMessage{{Role: LLMRoleUser, Content: "hi"}}}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("err = %v, want stream error from the event", err)
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Счи"}]}}],"modelVersion":"gemini-test-001"}`,
+		``,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"таю"},{"functionCall":{"name":"mortgage","args":{"price":30000000}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`,
+		``,
+	}, "\n")
+
+	provider := NewGeminiProvider(config.AIConfig{GeminiModel: "gemini-test", MaxTokens: 100})
+	provider.client = sseClient(stream, nil)
+
+	var deltas []string
+	response, err := provider.Stream(context.Background(), toolConversation(), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if !reflect.DeepEqual(deltas, []string{"Счи", "таю"}) || response.Content != "Считаю" {
+		t.Errorf("deltas = %q, content = %q", deltas, response.Content)
+	}
+	wantCalls := []LLMToolCall{{ID: "call_1", Name: "mortgage", Arguments: `{"price":30000000}`}}
+	if !reflect.DeepEqual(response.ToolCalls, wantCalls) {
+		t.Errorf("tool calls = %+v, want %+v", response.ToolCalls, wantCalls)
+	}
+	// Вызов функции важнее finishReason STOP
+	if response.StopReason != LLMStopToolCalls || response.Model != "gemini-test-001" {
+		t.Errorf("stop reason = %q, model = %q", response.StopReason, response.Model)
+	}
+	if response.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", response.Usage)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	chunks := []string{
+		`{"model":"gpt-test","choices":[{"index":0,"delta":{"role":"assistant","content":"Один "}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"момент"}}]}`,
+		// Вызовы приходят вперемешку, аргументы собираются по индексу
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"mortgage","arguments":""}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"search","arguments":"{\"ro"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"oms\":2}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":8,"total_tokens":28}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	provider := NewOpenAIProvider(config.AIConfig{OpenAIModel: "gpt-test", MaxTokens: 100})
+	provider.client = openai.NewClientWithConfig(clientConfig)
+
+	var deltas []string
+	response, err := provider.Stream(context.Background(), toolConversation(), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	if !reflect.DeepEqual(deltas, []string{"Один ", "момент"}) || response.Content != "Один момент" {
+		t.Errorf("deltas = %q, content = %q", deltas, response.Content)
+	}
+	wantCalls := []LLMToolCall{
+		{ID: "call_a", Name: "search", Arguments: `{"rooms":2}`},
+		{ID: "call_b", Name: "mortgage", Arguments: "{}"},
+	}
+	if !reflect.DeepEqual(response.ToolCalls, wantCalls) {
+		t.Errorf("tool calls = %+v, want %+v", response.ToolCalls, wantCalls)
+	}
+	if response.StopReason != LLMStopToolCalls || response.Usage.TotalTokens != 28 {
+		t.Errorf("stop reason = %q, usage = %+v", response.StopReason, response.Usage)
+	}
+}
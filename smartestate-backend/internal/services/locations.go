@@ -0,0 +1,504 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"smartestate/internal/models"
+)
+
+//go:embed locations.yaml
+var defaultLocationsData []byte
+
+var locationCodeRe = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Типы мест справочника
+const (
+	LocationTypeCity     = "city"
+	LocationTypeDistrict = "district"
+	LocationTypeComplex  = "complex"
+)
+
+const (
+	locationAutocompleteLimit    = 10
+	locationAutocompleteMaxLimit = 50
+	locationStemMinLength        = 7 // короче слово в чате сравнивается целиком
+)
+
+// locationTypeWords слова "город", "район", "ЖК", которые не учитываются при
+// сравнении значения фильтра с названием: "г. Алматы", "Бостандыкский район",
+// "ЖК Хайвил"
+var locationTypeWords = map[string]bool{
+	"город": true, "г": true, "қаласы": true, "city": true,
+	"район": true, "р": true, "н": true, "рн": true, "ауданы": true, "аудан": true,
+	"district": true, "жк": true, "жилой": true, "комплекс": true,
+}
+
+// LocationDictionary справочник городов, районов и жилых комплексов для
+// фильтров поиска: названия, псевдонимы и идентификаторы площадок.
+type LocationDictionary struct {
+	Cities []*LocationCity `yaml:"cities" json:"cities"`
+}
+
+// LocationPlace общие поля мест справочника
+type LocationPlace struct {
+	Code      string   `yaml:"code" json:"code"`
+	Name      string   `yaml:"name" json:"name"`
+	NameKk    string   `yaml:"name_kk" json:"name_kk,omitempty"`
+	NameLatin string   `yaml:"name_latin" json:"name_latin,omitempty"`
+	Aliases   []string `yaml:"aliases" json:"aliases,omitempty"`
+	// Krisha слаг города или района, ID жилого комплекса на krisha.kz
+	Krisha string `yaml:"krisha" json:"krisha,omitempty"`
+
+	keys []string // нормализованные код, названия и псевдонимы
+}
+
+type LocationCity struct {
+	LocationPlace `yaml:",inline"`
+	Olx           string              `yaml:"olx" json:"olx,omitempty"` // слаг города на olx.kz
+	Districts     []*LocationDistrict `yaml:"districts" json:"districts"`
+	Complexes     []*LocationComplex  `yaml:"complexes" json:"complexes"`
+}
+
+type LocationDistrict struct {
+	LocationPlace `yaml:",inline"`
+	Olx           string `yaml:"olx" json:"olx,omitempty"` // search[district_id] на olx.kz
+}
+
+type LocationComplex struct {
+	LocationPlace `yaml:",inline"`
+	District      string `yaml:"district" json:"district,omitempty"` // код района
+}
+
+// LocationSuggestion подсказка автодополнения
+type LocationSuggestion struct {
+	Type      string `json:"type" example:"district"`
+	Code      string `json:"code" example:"bostandyk"`
+	Name      string `json:"name" example:"Бостандыкский"`
+	NameKk    string `json:"name_kk,omitempty" example:"Бостандық ауданы"`
+	NameLatin string `json:"name_latin,omitempty" example:"Bostandyk"`
+	City      string `json:"city" example:"Алматы"`
+	District  string `json:"district,omitempty"`
+}
+
+// LocationMatch места, найденные в тексте сообщения
+type LocationMatch struct {
+	City     *LocationCity
+	District *LocationDistrict
+	Complex  *LocationComplex
+}
+
+// ParseLocations разбирает и проверяет YAML справочника мест
+func ParseLocations(data []byte) (*LocationDictionary, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var locations LocationDictionary
+	if err := decoder.Decode(&locations); err != nil {
+		return nil, fmt.Errorf("invalid locations: %w", err)
+	}
+	if err := locations.prepare(); err != nil {
+		return nil, err
+	}
+	return &locations, nil
+}
+
+var defaultLocations = sync.OnceValue(func() *LocationDictionary {
+	locations, err := ParseLocations(defaultLocationsData)
+	if err != nil {
+		panic(fmt.Sprintf("embedded locations: %v", err))
+	}
+	return locations
+})
+
+// DefaultLocations возвращает встроенный справочник мест. Справочник
+// разбирается один раз и не изменяется.
+func DefaultLocations() *LocationDictionary {
+	return defaultLocations()
+}
+
+// prepare проверяет записи и строит ключи для поиска
+func (d *LocationDictionary) prepare() error {
+	var problems []string
+	// Слаги krisha.kz городов и районов тоже ищутся: они приходят из старых
+	// сохраненных фильтров и ссылок. ID жилых комплексов - нет.
+	check := func(path string, place *LocationPlace, codes map[string]bool, slug bool) {
+		if strings.TrimSpace(place.Code) == "" || strings.TrimSpace(place.Name) == "" {
+			problems = append(problems, fmt.Sprintf("%s %q: code and name are required", path, place.Name))
+			return
+		}
+		if !locationCodeRe.MatchString(place.Code) {
+			problems = append(problems, fmt.Sprintf("%s %q: code must be lowercase latin", path, place.Code))
+		}
+		if codes[place.Code] {
+			problems = append(problems, fmt.Sprintf("%s %q: duplicate code", path, place.Code))
+		}
+		codes[place.Code] = true
+		place.keys = locationKeys(place, slug)
+	}
+
+	cityCodes := make(map[string]bool)
+	for _, city := range d.Cities {
+		check("city", &city.LocationPlace, cityCodes, true)
+		if city.Krisha == "" || city.Olx == "" {
+			problems = append(problems, fmt.Sprintf("city %q: krisha and olx slugs are required", city.Code))
+		}
+
+		districtCodes := make(map[string]bool)
+		for _, district := range city.Districts {
+			check(city.Code+" district", &district.LocationPlace, districtCodes, true)
+		}
+		complexCodes := make(map[string]bool)
+		for _, complex := range city.Complexes {
+			check(city.Code+" complex", &complex.LocationPlace, complexCodes, false)
+			if complex.District != "" && !districtCodes[complex.District] {
+				problems = append(problems, fmt.Sprintf("%s complex %q: unknown district %q", city.Code, complex.Code, complex.District))
+			}
+		}
+	}
+	if len(d.Cities) == 0 {
+		problems = append(problems, "no cities")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid locations: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// locationKeys возвращает нормализованные код, названия, псевдонимы и, если
+// slug, слаг krisha.kz
+func locationKeys(place *LocationPlace, slug bool) []string {
+	values := append([]string{place.Name, place.NameKk, place.NameLatin, place.Code}, place.Aliases...)
+	if slug {
+		values = append(values, place.Krisha)
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, value := range values {
+		key := normalizeGeoText(value)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// matches сравнивает значение с кодом, названиями и псевдонимами места
+func (p *LocationPlace) matches(value string) bool {
+	key := normalizeGeoText(value)
+	stripped := stripLocationType(key)
+	for _, k := range p.keys {
+		if k == key || (stripped != "" && stripLocationType(k) == stripped) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLocationType убирает из нормализованного значения слова locationTypeWords
+func stripLocationType(value string) string {
+	words := strings.Fields(value)
+	kept := words[:0]
+	for _, word := range words {
+		if !locationTypeWords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// City находит город по коду, названию или псевдониму
+func (d *LocationDictionary) City(value string) *LocationCity {
+	for _, city := range d.Cities {
+		if city.matches(value) {
+			return city
+		}
+	}
+	return nil
+}
+
+// NormalizeCity приводит город фильтров к названию из справочника по коду,
+// названию или псевдониму. Пустое значение допустимо, для неизвестного
+// города возвращается false.
+func (d *LocationDictionary) NormalizeCity(value string) (string, bool) {
+	if strings.TrimSpace(value) == "" {
+		return "", true
+	}
+	if city := d.City(value); city != nil {
+		return city.Name, true
+	}
+	return "", false
+}
+
+// SearchCity город поиска по названию из фильтров, по умолчанию Алматы
+func (d *LocationDictionary) SearchCity(name string) *LocationCity {
+	if city := d.City(name); city != nil {
+		return city
+	}
+	return d.City("almaty")
+}
+
+// District находит район города по коду, названию, псевдониму или слагу krisha.kz
+func (c *LocationCity) District(value string) *LocationDistrict {
+	for _, district := range c.Districts {
+		if district.matches(value) {
+			return district
+		}
+	}
+	return nil
+}
+
+// Complex находит жилой комплекс города по коду, названию или псевдониму
+func (c *LocationCity) Complex(value string) *LocationComplex {
+	for _, complex := range c.Complexes {
+		if complex.matches(value) {
+			return complex
+		}
+	}
+	return nil
+}
+
+// DistrictByOlx находит район по search[district_id] olx.kz
+func (c *LocationCity) DistrictByOlx(id string) *LocationDistrict {
+	for _, district := range c.Districts {
+		if district.Olx != "" && district.Olx == id {
+			return district
+		}
+	}
+	return nil
+}
+
+// ComplexByKrisha находит жилой комплекс по das[map.complex] krisha.kz
+func (c *LocationCity) ComplexByKrisha(id string) *LocationComplex {
+	for _, complex := range c.Complexes {
+		if complex.Krisha != "" && complex.Krisha == id {
+			return complex
+		}
+	}
+	return nil
+}
+
+// FindDistrict ищет район во всех городах. Если район с таким названием
+// есть в нескольких городах, возвращается nil.
+func (d *LocationDictionary) FindDistrict(value string) (*LocationCity, *LocationDistrict) {
+	var foundCity *LocationCity
+	var found *LocationDistrict
+	for _, city := range d.Cities {
+		if district := city.District(value); district != nil {
+			if found != nil {
+				return nil, nil
+			}
+			foundCity, found = city, district
+		}
+	}
+	return foundCity, found
+}
+
+// FindComplex ищет жилой комплекс во всех городах, как FindDistrict
+func (d *LocationDictionary) FindComplex(value string) (*LocationCity, *LocationComplex) {
+	var foundCity *LocationCity
+	var found *LocationComplex
+	for _, city := range d.Cities {
+		if complex := city.Complex(value); complex != nil {
+			if found != nil {
+				return nil, nil
+			}
+			foundCity, found = city, complex
+		}
+	}
+	return foundCity, found
+}
+
+// Autocomplete подсказывает города, районы и ЖК по началу названия на любом
+// языке. city ограничивает районы и ЖК городом, types - типами мест. Сначала
+// идут точные совпадения, затем совпадения с начала названия, затем с начала
+// любого слова.
+func (d *LocationDictionary) Autocomplete(query, city string, types []string, limit int) []LocationSuggestion {
+	if limit <= 0 {
+		limit = locationAutocompleteLimit
+	}
+	if limit > locationAutocompleteMaxLimit {
+		limit = locationAutocompleteMaxLimit
+	}
+	allowed := func(locationType string) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, t := range types {
+			if t == locationType {
+				return true
+			}
+		}
+		return false
+	}
+
+	query = normalizeGeoText(query)
+	type scored struct {
+		suggestion LocationSuggestion
+		rank       int
+	}
+	var found []scored
+	add := func(locationType string, place *LocationPlace, cityName, district string) {
+		if !allowed(locationType) {
+			return
+		}
+		rank := locationMatchRank(place.keys, query)
+		if rank < 0 {
+			return
+		}
+		found = append(found, scored{LocationSuggestion{
+			Type:      locationType,
+			Code:      place.Code,
+			Name:      place.Name,
+			NameKk:    place.NameKk,
+			NameLatin: place.NameLatin,
+			City:      cityName,
+			District:  district,
+		}, rank})
+	}
+
+	for _, c := range d.Cities {
+		if city != "" && !c.matches(city) {
+			continue
+		}
+		add(LocationTypeCity, &c.LocationPlace, c.Name, "")
+		for _, district := range c.Districts {
+			add(LocationTypeDistrict, &district.LocationPlace, c.Name, "")
+		}
+		for _, complex := range c.Complexes {
+			add(LocationTypeComplex, &complex.LocationPlace, c.Name, complex.District)
+		}
+	}
+
+	// Порядок справочника сохраняется внутри одного ранга: города, районы, ЖК
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].rank < found[j].rank
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	suggestions := make([]LocationSuggestion, len(found))
+	for i, f := range found {
+		suggestions[i] = f.suggestion
+	}
+	return suggestions
+}
+
+// locationMatchRank 0 - точное совпадение, 1 - с начала названия, 2 - с
+// начала слова, -1 - не подходит. Пустой запрос подходит ко всем местам.
+func locationMatchRank(keys []string, query string) int {
+	if query == "" {
+		return 2
+	}
+	rank := -1
+	for _, key := range keys {
+		switch {
+		case key == query:
+			return 0
+		case strings.HasPrefix(key, query):
+			rank = 1
+		case rank < 0 && strings.Contains(" "+key, " "+query):
+			rank = 2
+		}
+	}
+	return rank
+}
+
+// Detect находит в тексте город, район и жилой комплекс. Район и ЖК ищутся в
+// найденном городе, а без него - во всех городах, и тогда определяют город.
+// Длинные слова сравниваются без окончаний: "в бостандыкском районе".
+func (d *LocationDictionary) Detect(text string) LocationMatch {
+	words := strings.Fields(normalizeGeoText(text))
+	var match LocationMatch
+
+	for _, city := range d.Cities {
+		if containsLocation(words, city.keys) {
+			match.City = city
+			break
+		}
+	}
+
+	cities := d.Cities
+	if match.City != nil {
+		cities = []*LocationCity{match.City}
+	}
+	for _, city := range cities {
+		for _, complex := range city.Complexes {
+			if match.Complex == nil && containsLocation(words, complex.keys) {
+				match.City, match.Complex = city, complex
+			}
+		}
+		for _, district := range city.Districts {
+			if match.District == nil && containsLocation(words, district.keys) {
+				match.City, match.District = city, district
+			}
+		}
+		if match.Complex != nil || match.District != nil {
+			break
+		}
+	}
+
+	// Район ЖК известен из справочника, даже если в тексте его нет
+	if match.Complex != nil && match.District == nil && match.Complex.District != "" {
+		match.District = match.City.District(match.Complex.District)
+	}
+	return match
+}
+
+// containsLocation проверяет, встречается ли ключ в словах текста подряд
+func containsLocation(words []string, keys []string) bool {
+	for _, key := range keys {
+		keyWords := strings.Fields(key)
+		for start := 0; start+len(keyWords) <= len(words); start++ {
+			matched := true
+			for i, keyWord := range keyWords {
+				if !locationWordMatches(words[start+i], keyWord) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// locationWordMatches сравнивает слово текста со словом названия. У длинных
+// слов отбрасываются два последних символа: "бостандыкский" - "бостандыкском".
+func locationWordMatches(word, keyWord string) bool {
+	if word == keyWord {
+		return true
+	}
+	if utf8.RuneCountInString(keyWord) < locationStemMinLength {
+		return false
+	}
+	runes := []rune(keyWord)
+	return strings.HasPrefix(word, string(runes[:len(runes)-2]))
+}
+
+// applyDetectedLocation заполняет город, район и ЖК фильтров по тексту сообщения
+func applyDetectedLocation(filters *models.PropertyFilters, message string) {
+	match := DefaultLocations().Detect(message)
+	if match.City != nil {
+		filters.City = match.City.Name
+	}
+	if match.District != nil {
+		filters.District = match.District.Code
+	}
+	if match.Complex != nil {
+		filters.ResidentialComplex = match.Complex.Name
+	}
+}
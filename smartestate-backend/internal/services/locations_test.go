@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeCityUsesLocations(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"", "", true},
+		{"almaty", "Алматы", true},
+		{"алмата", "Алматы", true},
+		{"г. Алматы", "Алматы", true},
+		{"Нур-Султан", "Астана", true},
+		{"Чимкент", "Шымкент", true},
+		{"Москва", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DefaultLocations().NormalizeCity(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeCity(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGazetteerCitiesFromLocations(t *testing.T) {
+	// Псевдоним города есть только в справочнике мест
+	result, err := DefaultGazetteer().Geocode(context.Background(), GeocodeQuery{Address: "Целиноград, Есиль"})
+	if err != nil || result.City != "Астана" {
+		t.Fatalf("Geocode = %+v, %v, want Астана", result, err)
+	}
+}
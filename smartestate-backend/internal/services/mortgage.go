@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Виды платежей по ипотеке
+const (
+	MortgagePaymentAnnuity        = "annuity"        // равные платежи
+	MortgagePaymentDifferentiated = "differentiated" // платежи уменьшаются, тело долга гасится равными частями
+)
+
+// mortgageMaxPaymentShare банки Казахстана одобряют ипотеку, если платеж не
+// больше половины дохода
+const mortgageMaxPaymentShare = 0.5
+
+// MortgageInput параметры ипотеки
+type MortgageInput struct {
+	Price              int64    `json:"price" binding:"required" minimum:"1" description:"Стоимость недвижимости в тенге"`
+	DownPayment        *int64   `json:"down_payment" minimum:"0" description:"Первоначальный взнос в тенге"`
+	DownPaymentPercent *float64 `json:"down_payment_percent" minimum:"0" maximum:"100" description:"Первоначальный взнос в процентах от стоимости, если сумма не указана"`
+	AnnualRate         float64  `json:"annual_rate" binding:"required" minimum:"0" maximum:"60" description:"Годовая ставка в процентах, например 7 для программы 7-20-25"`
+	TermYears          int      `json:"term_years" binding:"required" minimum:"1" maximum:"35" description:"Срок кредита в годах"`
+	PaymentType        string   `json:"payment_type" enums:"annuity,differentiated" description:"Вид платежей: annuity - равные, differentiated - уменьшающиеся. По умолчанию annuity"`
+}
+
+// MortgageCalculation расчет ипотеки. Суммы в тенге, округлены до целых.
+type MortgageCalculation struct {
+	Price          int64   `json:"price"`
+	DownPayment    int64   `json:"down_payment"`
+	LoanAmount     int64   `json:"loan_amount"`
+	AnnualRate     float64 `json:"annual_rate"`
+	TermMonths     int     `json:"term_months"`
+	PaymentType    string  `json:"payment_type"`
+	MonthlyPayment int64   `json:"monthly_payment"`        // для дифференцированных - первый платеж
+	LastPayment    int64   `json:"last_payment,omitempty"` // только для дифференцированных
+	TotalPayment   int64   `json:"total_payment"`          // все платежи по кредиту без взноса
+	Overpayment    int64   `json:"overpayment"`            // проценты за весь срок
+	RequiredIncome int64   `json:"required_income"`        // доход, при котором платеж не больше половины
+}
+
+// CalculateMortgage считает платежи по ипотеке
+func CalculateMortgage(input MortgageInput) (*MortgageCalculation, error) {
+	if input.Price <= 0 {
+		return nil, errors.New("price must be positive")
+	}
+	if input.TermYears < 1 || input.TermYears > 35 {
+		return nil, errors.New("term_years must be between 1 and 35")
+	}
+	if input.AnnualRate < 0 || input.AnnualRate > 60 {
+		return nil, errors.New("annual_rate must be between 0 and 60 percent")
+	}
+
+	var downPayment int64
+	switch {
+	case input.DownPayment != nil:
+		downPayment = *input.DownPayment
+	case input.DownPaymentPercent != nil:
+		downPayment = int64(math.Round(float64(input.Price) * *input.DownPaymentPercent / 100))
+	}
+	if downPayment < 0 || downPayment >= input.Price {
+		return nil, errors.New("down payment must be less than the price")
+	}
+
+	paymentType := input.PaymentType
+	if paymentType == "" {
+		paymentType = MortgagePaymentAnnuity
+	}
+	if paymentType != MortgagePaymentAnnuity && paymentType != MortgagePaymentDifferentiated {
+		return nil, errors.New("payment_type must be annuity or differentiated")
+	}
+
+	loan := float64(input.Price - downPayment)
+	months := input.TermYears * 12
+	rate := input.AnnualRate / 100 / 12
+
+	result := &MortgageCalculation{
+		Price:       input.Price,
+		DownPayment: downPayment,
+		LoanAmount:  input.Price - downPayment,
+		AnnualRate:  input.AnnualRate,
+		TermMonths:  months,
+		PaymentType: paymentType,
+	}
+
+	var first, total float64
+	if paymentType == MortgagePaymentAnnuity {
+		first = loan / float64(months)
+		if rate > 0 {
+			first = loan * rate / (1 - math.Pow(1+rate, -float64(months)))
+		}
+		total = first * float64(months)
+	} else {
+		principal := loan / float64(months)
+		first = principal + loan*rate
+		result.LastPayment = int64(math.Round(principal + principal*rate))
+		// Проценты начисляются на остаток, который уменьшается на principal каждый месяц
+		total = loan + loan*rate*float64(months+1)/2
+	}
+
+	result.MonthlyPayment = int64(math.Round(first))
+	result.TotalPayment = int64(math.Round(total))
+	result.Overpayment = result.TotalPayment - result.LoanAmount
+	result.RequiredIncome = int64(math.Round(first / mortgageMaxPaymentShare))
+	return result, nil
+}
+
+// mortgageChatTool инструмент расчета ипотеки для чата
+func mortgageChatTool() ChatTool {
+	return NewChatTool("calculate_mortgage",
+		"Считает ипотеку: ежемесячный платеж, переплату и доход, нужный для одобрения. Используй для любых вопросов о платежах по ипотеке, не считай сам.",
+		ChatToolPublic,
+		func(ctx context.Context, env ChatToolEnv, input MortgageInput) (*ChatToolResult, error) {
+			calculation, err := CalculateMortgage(input)
+			if err != nil {
+				return nil, err
+			}
+			return &ChatToolResult{Data: calculation, Actions: []string{"mortgage_calculation"}}, nil
+		})
+}
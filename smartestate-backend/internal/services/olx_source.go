@@ -0,0 +1,493 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tebeka/selenium"
+
+	"smartestate/internal/models"
+)
+
+const olxBaseURL = "https://www.olx.kz"
+
+var olxIDRe = regexp.MustCompile(`-ID([A-Za-z0-9]+)\.html`)
+
+// olxSectionPaths разделы olx.kz по категории недвижимости и типу сделки.
+// Вида коммерческого помещения в URL нет, он отбирается после разбора.
+var olxSectionPaths = map[string]map[string]string{
+	models.PropertyTypeApartment: {
+		models.DealTypeSale:      "/nedvizhimost/prodazha-kvartiry/",
+		models.DealTypeRentLong:  "/nedvizhimost/arenda-kvartiry/dolgosrochnaya-arenda-kvartiry/",
+		models.DealTypeRentDaily: "/nedvizhimost/posutochno-pochasovo/posutochno-pochasovo-kvartiry/",
+	},
+	models.PropertyTypeHouse: {
+		models.DealTypeSale:      "/nedvizhimost/prodazha-domov/",
+		models.DealTypeRentLong:  "/nedvizhimost/arenda-domov/",
+		models.DealTypeRentDaily: "/nedvizhimost/posutochno-pochasovo/posutochno-pochasovo-doma/",
+	},
+	models.PropertyTypeLand: {
+		models.DealTypeSale:     "/nedvizhimost/prodazha-zemli/",
+		models.DealTypeRentLong: "/nedvizhimost/arenda-zemli/",
+	},
+	models.PropertyTypeCommercial: {
+		models.DealTypeSale:     "/nedvizhimost/prodazha-kommercheskoy-nedvizhimosti/",
+		models.DealTypeRentLong: "/nedvizhimost/arenda-kommercheskoy-nedvizhimosti/",
+	},
+}
+
+// OlxSource источник объявлений olx.kz. Страницы загружаются обычным HTTP
+// запросом: olx.kz рендерит их на сервере, данные объявлений берутся из
+// встроенного JSON состояния, JSON-LD и HTML. Selenium используется только как
+// запасной вариант, если задана фабрика WebDriver.
+type OlxSource struct {
+	fetcher    *PoliteFetcher
+	selectors  *SelectorStore
+	newDriver  func() (selenium.WebDriver, error)
+	renderWait time.Duration
+}
+
+// NewOlxSource создает источник olx.kz. newDriver может быть nil, тогда
+// страницы загружаются только по HTTP.
+func NewOlxSource(fetcher *PoliteFetcher, selectors *SelectorStore, newDriver func() (selenium.WebDriver, error)) *OlxSource {
+	return &OlxSource{
+		fetcher:    fetcher,
+		selectors:  selectors,
+		newDriver:  newDriver,
+		renderWait: 2 * time.Second,
+	}
+}
+
+// Name возвращает имя источника
+func (o *OlxSource) Name() string {
+	return "olx"
+}
+
+// BuildSearchURL строит URL для поиска на olx.kz. Пустой URL значит, что у
+// категории нет раздела с нужным типом сделки.
+func (o *OlxSource) BuildSearchURL(filters models.PropertyFilters, page int) string {
+	searchURL, _ := olxSearchURL(filters, page)
+	return searchURL
+}
+
+// UnsupportedFilters возвращает фильтры, которых нет в поиске olx.kz
+func (o *OlxSource) UnsupportedFilters(filters models.PropertyFilters) []string {
+	_, unsupported := olxSearchURL(filters, 1)
+	return unsupported
+}
+
+// olxSearchURL переводит фильтры в URL поиска olx.kz. Возвращает и фильтры,
+// которых у olx.kz нет. Вид помещения, площадь участка и животные в URL не
+// передаются, но проверяются после разбора, поэтому не считаются неучтенными.
+// Если раздела с таким типом сделки нет, URL пустой: выдача другого раздела
+// сохранилась бы с чужим типом сделки.
+func olxSearchURL(filters models.PropertyFilters, page int) (string, []string) {
+	section, ok := olxSectionPath(filters.DealType, filters.PropertyType)
+	if !ok {
+		return "", []string{"deal_type"}
+	}
+
+	city := DefaultLocations().SearchCity(filters.City)
+	searchURL := olxBaseURL + section + city.Olx + "/"
+	params := url.Values{}
+	var unsupported []string
+
+	// Район olx.kz задается ID из справочника мест
+	if filters.District != "" {
+		if district := city.District(filters.District); district != nil && district.Olx != "" {
+			params.Set("search[district_id]", district.Olx)
+		} else {
+			unsupported = append(unsupported, "district")
+		}
+	}
+
+	if filters.PriceMin != nil && *filters.PriceMin > 0 {
+		params.Set("search[filter_float_price:from]", strconv.FormatInt(*filters.PriceMin, 10))
+	}
+	if filters.PriceMax != nil && *filters.PriceMax > 0 {
+		params.Set("search[filter_float_price:to]", strconv.FormatInt(*filters.PriceMax, 10))
+	}
+	if filters.Rooms != nil && *filters.Rooms > 0 {
+		params.Set("search[filter_enum_kolichestvokomnat][0]", strconv.Itoa(*filters.Rooms))
+	}
+	if filters.TotalAreaFrom != nil {
+		params.Set("search[filter_float_total_area:from]", strconv.Itoa(*filters.TotalAreaFrom))
+	}
+	if filters.TotalAreaTo != nil {
+		params.Set("search[filter_float_total_area:to]", strconv.Itoa(*filters.TotalAreaTo))
+	}
+	// olx.kz делит продавцов на частных лиц и бизнес, агентства и застройщики - бизнес
+	switch filters.SellerType {
+	case models.SellerTypeOwner:
+		params.Set("search[private_business]", "private")
+	case models.SellerTypeAgent, models.SellerTypeDeveloper:
+		params.Set("search[private_business]", "business")
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+
+	unsupported = append(unsupported, setFilterNames(map[string]bool{
+		"kitchen_area_from":   filters.KitchenAreaFrom != nil,
+		"kitchen_area_to":     filters.KitchenAreaTo != nil,
+		"floor_from":          filters.FloorFrom != nil,
+		"floor_to":            filters.FloorTo != nil,
+		"total_floors_from":   filters.TotalFloorsFrom != nil,
+		"total_floors_to":     filters.TotalFloorsTo != nil,
+		"build_year_from":     filters.BuildYearFrom != nil,
+		"build_year_to":       filters.BuildYearTo != nil,
+		"has_photos":          filters.HasPhotos,
+		"is_new_building":     filters.IsNewBuilding,
+		"not_first_floor":     filters.NotFirstFloor,
+		"not_last_floor":      filters.NotLastFloor,
+		"house_type":          filters.HouseType != "",
+		"residential_complex": filters.ResidentialComplex != "",
+	})...)
+
+	if len(params) > 0 {
+		return searchURL + "?" + params.Encode(), unsupported
+	}
+	return searchURL, unsupported
+}
+
+// olxSectionPath возвращает раздел olx.kz, по умолчанию продажа квартир.
+// Возвращает false, если у категории нет раздела с таким типом сделки.
+func olxSectionPath(dealType, propertyType string) (string, bool) {
+	sections, ok := olxSectionPaths[propertyType]
+	if !ok {
+		sections = olxSectionPaths[models.PropertyTypeApartment]
+	}
+	dealType, ok = models.NormalizeDealType(dealType)
+	if !ok {
+		return "", false
+	}
+	section, ok := sections[dealType]
+	return section, ok
+}
+
+// FetchPage загружает страницу olx.kz по HTTP. Если страница не загрузилась
+// или в ней нет данных объявлений, а Selenium подключен, она рендерится в браузере.
+func (o *OlxSource) FetchPage(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	doc, err := o.fetcher.Fetch(ctx, pageURL)
+	if err == nil && o.hasListingData(doc) {
+		return doc, nil
+	}
+	if o.newDriver == nil || ctx.Err() != nil {
+		if err != nil {
+			return nil, fmt.Errorf("OLX: %w", err)
+		}
+		return doc, nil
+	}
+
+	// 404, запрет в robots.txt и отключенную площадку браузер не исправит
+	var statusErr *HTTPStatusError
+	var circuitErr *CircuitOpenError
+	if (errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound) ||
+		errors.Is(err, ErrRobotsDisallowed) || errors.As(err, &circuitErr) {
+		return nil, fmt.Errorf("OLX: %w", err)
+	}
+
+	if err == nil {
+		log.Printf("OLX: на странице %s нет данных объявлений, пробуем Selenium", pageURL)
+		return o.fetchWithBrowser(ctx, pageURL)
+	}
+
+	log.Printf("OLX: HTTP загрузка %s не удалась (%v), пробуем Selenium", pageURL, err)
+	doc, browserErr := o.fetchWithBrowser(ctx, pageURL)
+	if browserErr != nil {
+		// Ошибка HTTP остается в цепочке: по ней видно, что запрос уже повторялся
+		return nil, fmt.Errorf("OLX: %w (Selenium: %v)", err, browserErr)
+	}
+	return doc, nil
+}
+
+// hasListingData проверяет, что в HTML есть данные объявлений
+func (o *OlxSource) hasListingData(doc *goquery.Document) bool {
+	if _, ok := parseOlxState(doc); ok {
+		return true
+	}
+	if doc.Find("script[type='application/ld+json']").Length() > 0 {
+		return true
+	}
+	selectors := o.selectors.Source(o.Name())
+	return selectors.Search.Card.Find(doc.Selection).Length() > 0
+}
+
+// fetchWithBrowser загружает страницу olx.kz через Selenium с учетом лимитов загрузчика
+func (o *OlxSource) fetchWithBrowser(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	if err := o.fetcher.Acquire(ctx, pageURL); err != nil {
+		return nil, fmt.Errorf("OLX: %w", err)
+	}
+	doc, err := o.renderPage(ctx, pageURL)
+	o.fetcher.Report(pageURL, err)
+	return doc, err
+}
+
+// renderPage загружает страницу olx.kz через Selenium и возвращает итоговый HTML
+func (o *OlxSource) renderPage(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	wd, err := o.newDriver()
+	if err != nil {
+		return nil, fmt.Errorf("OLX: failed to create webdriver: %w", err)
+	}
+	defer wd.Quit()
+
+	if err := wd.Get(pageURL); err != nil {
+		return nil, fmt.Errorf("OLX: failed to load page %s: %w", pageURL, err)
+	}
+
+	// Ждем отрисовки объявлений
+	select {
+	case <-time.After(o.renderWait):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	pageSource, err := wd.PageSource()
+	if err != nil {
+		return nil, fmt.Errorf("OLX: failed to read page source: %w", err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageSource))
+	if err != nil {
+		return nil, fmt.Errorf("OLX: ошибка парсинга HTML: %w", err)
+	}
+
+	return doc, nil
+}
+
+// CountResults берет число найденных объявлений из состояния страницы olx.kz,
+// без него - из счетчика "Мы нашли 1 234 объявления"
+func (o *OlxSource) CountResults(doc *goquery.Document) (int, bool) {
+	if state, ok := parseOlxState(doc); ok && state.Listing.Listing.TotalElements != nil {
+		return *state.Listing.Listing.TotalElements, true
+	}
+	text := o.selectors.Source(o.Name()).Search.Results.Value(doc.Selection)
+	if text == "" {
+		return 0, false
+	}
+	return parseCount(text)
+}
+
+// ExtractCards извлекает карточки объявлений со страницы поиска olx.kz.
+// Сначала из JSON состояния страницы, затем из JSON-LD и только потом из HTML.
+func (o *OlxSource) ExtractCards(doc *goquery.Document) []models.ParsedProperty {
+	if state, ok := parseOlxState(doc); ok {
+		if properties := extractOlxStateCards(state); len(properties) > 0 {
+			return properties
+		}
+	}
+	if properties := extractOlxJSONLDCards(parseOlxJSONLD(doc)); len(properties) > 0 {
+		return properties
+	}
+
+	// Селекторы карточек перебираются по порядку до первого найденного
+	selectors := o.selectors.Source(o.Name()).Search
+	cards := selectors.Card.Find(doc.Selection)
+	if cards.Length() == 0 {
+		log.Printf("🔍 OLX: карточки объявлений не найдены")
+		return nil
+	}
+
+	var properties []models.ParsedProperty
+	cards.Each(func(i int, card *goquery.Selection) {
+		property := parseOlxCard(card, selectors.Fields)
+
+		// Пропускаем объявления без основной информации
+		if property.ID != "" && property.Title != "" && property.Price > 0 {
+			properties = append(properties, property)
+		}
+	})
+
+	return properties
+}
+
+// ExtractDetail дополняет объявление данными со страницы объявления olx.kz
+func (o *OlxSource) ExtractDetail(doc *goquery.Document, property *models.ParsedProperty) {
+	property.Source = o.Name()
+
+	// Структурированные данные полнее и стабильнее разметки
+	if state, ok := parseOlxState(doc); ok && state.Ad.Ad != nil {
+		state.Ad.Ad.toProperty(property)
+		parseKrishaAreaAndFloor(property.Title, property)
+		parseCategoryAttributes(property.Title, property)
+		return
+	}
+	if items := parseOlxJSONLD(doc); len(items) > 0 {
+		items[0].toProperty(property)
+	}
+
+	selectors := o.selectors.Source(o.Name()).Detail
+	fields := selectors.Fields
+
+	if title := fields["title"].Value(doc.Selection); title != "" {
+		property.Title = title
+	}
+
+	if priceText := fields["price"].Value(doc.Selection); priceText != "" {
+		property.Price, property.Currency = parseOlxPrice(priceText)
+		if period := parsePricePeriod(priceText); period != "" {
+			property.PricePeriod = period
+		}
+	}
+
+	if description := fields["description"].Value(doc.Selection); description != "" {
+		property.Description = description
+	}
+
+	if images := fields["images"].Values(doc.Selection); len(images) > 0 {
+		property.Images = uniqueStrings(images)
+	}
+
+	// Продавец
+	if name := fields["seller_name"].Value(doc.Selection); name != "" {
+		property.SellerName = name
+	}
+
+	// Дата публикации и просмотры
+	if published, ok := parsePublishedDate(fields["published"].Value(doc.Selection), time.Now()); ok {
+		property.PublishedAt = &published
+	}
+	if views, ok := parseViews(fields["views"].Value(doc.Selection)); ok {
+		property.Views = &views
+	}
+
+	for _, params := range selectors.Parameters {
+		doc.Find(params.Item).Each(func(i int, param *goquery.Selection) {
+			if params.Name == "" {
+				parseOlxParameter(strings.TrimSpace(param.Text()), property)
+				return
+			}
+			parseOlxParameter(strings.TrimSpace(param.Find(params.Name).Text())+": "+strings.TrimSpace(param.Find(params.Value).Text()), property)
+		})
+	}
+
+	parseCategoryAttributes(property.Title, property)
+}
+
+// parseOlxCard парсит одну карточку объявления olx.kz
+func parseOlxCard(card *goquery.Selection, fields map[string]SelectorList) models.ParsedProperty {
+	property := models.ParsedProperty{Source: "olx"}
+
+	// URL объявления и ID из URL
+	if href := fields["url"].Value(card); href != "" {
+		property.URL = absoluteURL(olxBaseURL, href)
+		if matches := olxIDRe.FindStringSubmatch(property.URL); len(matches) == 2 {
+			property.ID = "olx_" + matches[1]
+		}
+	}
+	if property.ID == "" {
+		if id := fields["id"].Value(card); id != "" {
+			property.ID = "olx_" + id
+		}
+	}
+
+	// Заголовок
+	property.Title = fields["title"].Value(card)
+
+	// Цена
+	if priceText := fields["price"].Value(card); priceText != "" {
+		property.Price, property.Currency = parseOlxPrice(priceText)
+		property.PricePeriod = parsePricePeriod(priceText)
+	}
+
+	// Адрес/локация: "Алматы, Бостандыкский район - Сегодня в 12:00"
+	if location := fields["location"].Value(card); location != "" {
+		property.Address = strings.TrimSpace(strings.Split(location, " - ")[0])
+	}
+
+	// Площадь из блока параметров карточки: "45 м²"
+	if params := fields["params"].Value(card); params != "" {
+		parseOlxParameter(params, &property)
+	}
+
+	// Изображение
+	if src := fields["image"].Value(card); src != "" && !strings.HasPrefix(src, "data:") {
+		property.Images = []string{src}
+	}
+
+	parseKrishaAreaAndFloor(property.Title, &property)
+	parseCategoryAttributes(property.Title, &property)
+
+	return property
+}
+
+// parseOlxPrice извлекает цену и валюту из текста OLX ("25 000 000 ₸", "Договорная")
+func parseOlxPrice(priceText string) (int64, string) {
+	currency := "KZT"
+	if strings.Contains(priceText, "$") {
+		currency = "USD"
+	}
+
+	priceStr := nonDigitRe.ReplaceAllString(priceText, "")
+	if priceStr == "" {
+		return 0, currency
+	}
+
+	price, err := strconv.ParseInt(priceStr, 10, 64)
+	if err != nil {
+		return 0, currency
+	}
+	return price, currency
+}
+
+// parseOlxParameter разбирает строку параметра OLX ("Общая площадь: 65 м²", "Этаж: 5")
+func parseOlxParameter(text string, property *models.ParsedProperty) {
+	lower := strings.ToLower(text)
+	value := text
+	if idx := strings.Index(text, ":"); idx >= 0 {
+		value = strings.TrimSpace(text[idx+1:])
+
+		// Условия аренды и атрибуты домов, участков и коммерческой недвижимости
+		if parseListingParameter(text[:idx], value, property) {
+			return
+		}
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(value, ",", "."))
+	if len(fields) == 0 {
+		return
+	}
+	number := func() (float64, bool) {
+		n, err := strconv.ParseFloat(fields[0], 64)
+		return n, err == nil
+	}
+
+	switch {
+	case strings.Contains(lower, "площадь кухни"):
+		if n, ok := number(); ok {
+			property.KitchenArea = &n
+		}
+	case strings.Contains(lower, "общая площадь") || (strings.Contains(lower, "м²") && property.Area == nil):
+		if n, ok := number(); ok {
+			property.Area = &n
+		}
+	case strings.Contains(lower, "этажность"):
+		if n, ok := number(); ok {
+			totalFloors := int(n)
+			property.TotalFloors = &totalFloors
+		}
+	case strings.HasPrefix(lower, "этаж"):
+		if n, ok := number(); ok {
+			floor := int(n)
+			property.Floor = &floor
+		}
+	case strings.Contains(lower, "количество комнат"):
+		if n, ok := number(); ok {
+			rooms := int(n)
+			property.Rooms = &rooms
+		}
+	case strings.HasPrefix(lower, "частное лицо"):
+		property.SellerType = "owner"
+	case strings.HasPrefix(lower, "бизнес"):
+		property.SellerType = "agent"
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"smartestate/internal/models"
+)
+
+// olx.kz отдает страницы с серверным рендерингом: данные объявлений лежат в
+// window.__PRERENDERED_STATE__ (JSON строкой) и в разметке schema.org JSON-LD.
+// Их разбор не зависит от CSS классов и не требует браузера.
+
+var olxStateRe = regexp.MustCompile(`(?s)window\.__PRERENDERED_STATE__\s*=\s*("(?:[^"\\]|\\.)*")`)
+
+// olxState часть __PRERENDERED_STATE__: объявления страницы поиска и страницы объявления
+type olxState struct {
+	Listing struct {
+		Listing struct {
+			Ads           []olxStateAd `json:"ads"`
+			TotalElements *int         `json:"totalElements"`
+		} `json:"listing"`
+	} `json:"listing"`
+	Ad struct {
+		Ad *olxStateAd `json:"ad"`
+	} `json:"ad"`
+}
+
+type olxStateAd struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"` // HTML
+	URL         string `json:"url"`
+	IsBusiness  bool   `json:"isBusiness"`
+	CreatedTime string `json:"createdTime"`
+	Price       struct {
+		DisplayValue string `json:"displayValue"`
+		RegularPrice *struct {
+			Value        float64 `json:"value"`
+			CurrencyCode string  `json:"currencyCode"`
+		} `json:"regularPrice"`
+	} `json:"price"`
+	Location struct {
+		CityName     string `json:"cityName"`
+		DistrictName string `json:"districtName"`
+	} `json:"location"`
+	Map struct {
+		Lat          float64 `json:"lat"`
+		Lon          float64 `json:"lon"`
+		ShowDetailed bool    `json:"show_detailed"` // false - точка смещена в пределах радиуса
+	} `json:"map"`
+	Photos []string `json:"photos"` // шаблон ссылки с {width}x{height}
+	Params []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"params"`
+	User struct {
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+// parseOlxState извлекает __PRERENDERED_STATE__, false - на странице его нет
+func parseOlxState(doc *goquery.Document) (*olxState, bool) {
+	var state *olxState
+	doc.Find("script").EachWithBreak(func(i int, script *goquery.Selection) bool {
+		text := script.Text()
+		if !strings.Contains(text, "__PRERENDERED_STATE__") {
+			return true
+		}
+		matches := olxStateRe.FindStringSubmatch(text)
+		if len(matches) != 2 {
+			return true
+		}
+
+		// Состояние сериализовано дважды: JSON внутри строкового литерала
+		var raw string
+		if err := json.Unmarshal([]byte(matches[1]), &raw); err != nil {
+			return true
+		}
+		var parsed olxState
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+			return true
+		}
+		state = &parsed
+		return false
+	})
+	return state, state != nil
+}
+
+// toProperty переносит объявление из состояния страницы в property.
+// Заполняются только найденные поля, остальные остаются как были.
+func (ad *olxStateAd) toProperty(property *models.ParsedProperty) {
+	property.Source = "olx"
+
+	if ad.URL != "" {
+		property.URL = absoluteURL(olxBaseURL, ad.URL)
+	}
+	if matches := olxIDRe.FindStringSubmatch(property.URL); len(matches) == 2 {
+		property.ID = "olx_" + matches[1]
+	} else if property.ID == "" && ad.ID != 0 {
+		property.ID = "olx_" + strconv.FormatInt(ad.ID, 10)
+	}
+
+	if ad.Title != "" {
+		property.Title = strings.TrimSpace(ad.Title)
+	}
+	if description := htmlToText(ad.Description); description != "" {
+		property.Description = description
+	}
+
+	if regular := ad.Price.RegularPrice; regular != nil && regular.Value > 0 {
+		property.Price = int64(regular.Value)
+		property.Currency = regular.CurrencyCode
+		if property.Currency == "" {
+			property.Currency = "KZT"
+		}
+	} else if ad.Price.DisplayValue != "" {
+		property.Price, property.Currency = parseOlxPrice(ad.Price.DisplayValue)
+	}
+	if period := parsePricePeriod(ad.Price.DisplayValue); period != "" {
+		property.PricePeriod = period
+	}
+
+	var location []string
+	for _, part := range []string{ad.Location.CityName, ad.Location.DistrictName} {
+		if part != "" {
+			location = append(location, part)
+		}
+	}
+	if len(location) > 0 {
+		property.Address = strings.Join(location, ", ")
+	}
+	if ad.Map.Lat != 0 && ad.Map.Lon != 0 {
+		lat, lon := ad.Map.Lat, ad.Map.Lon
+		property.Latitude, property.Longitude = &lat, &lon
+		property.GeoPrecision = models.GeoPrecisionStreet
+		if ad.Map.ShowDetailed {
+			property.GeoPrecision = models.GeoPrecisionExact
+		}
+	}
+
+	if len(ad.Photos) > 0 {
+		images := make([]string, 0, len(ad.Photos))
+		for _, photo := range ad.Photos {
+			images = append(images, strings.ReplaceAll(photo, "{width}x{height}", "1000x700"))
+		}
+		property.Images = uniqueStrings(images)
+	}
+
+	if ad.IsBusiness {
+		property.SellerType = "agent"
+	} else {
+		property.SellerType = "owner"
+	}
+	if ad.User.Name != "" {
+		property.SellerName = ad.User.Name
+	}
+
+	if created, err := time.Parse(time.RFC3339, ad.CreatedTime); err == nil {
+		published := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+		property.PublishedAt = &published
+	}
+
+	for _, param := range ad.Params {
+		parseOlxParameter(param.Name+": "+param.Value, property)
+	}
+}
+
+// extractOlxStateCards возвращает объявления страницы поиска из __PRERENDERED_STATE__
+func extractOlxStateCards(state *olxState) []models.ParsedProperty {
+	var properties []models.ParsedProperty
+	for i := range state.Listing.Listing.Ads {
+		var property models.ParsedProperty
+		state.Listing.Listing.Ads[i].toProperty(&property)
+		parseKrishaAreaAndFloor(property.Title, &property)
+		parseCategoryAttributes(property.Title, &property)
+
+		if property.ID != "" && property.Title != "" && property.Price > 0 {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// olxJSONLDItem объявление из разметки schema.org: Product или Offer
+type olxJSONLDItem struct {
+	Name        string
+	Description string
+	URL         string
+	Images      []string
+	Price       float64
+	Currency    string
+}
+
+// parseOlxJSONLD собирает объявления из всех блоков application/ld+json страницы
+func parseOlxJSONLD(doc *goquery.Document) []olxJSONLDItem {
+	var items []olxJSONLDItem
+	seen := make(map[string]bool)
+
+	doc.Find("script[type='application/ld+json']").Each(func(i int, script *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(script.Text()), &data); err != nil {
+			return
+		}
+		walkJSONLD(data, func(node map[string]interface{}) {
+			item, ok := jsonLDItem(node)
+			if !ok || seen[item.URL+"|"+item.Name] {
+				return
+			}
+			seen[item.URL+"|"+item.Name] = true
+			items = append(items, item)
+		})
+	})
+	return items
+}
+
+// walkJSONLD обходит JSON-LD и вызывает visit для узлов Product и Offer.
+// Вложенные предложения найденного узла повторно не посещаются.
+func walkJSONLD(data interface{}, visit func(map[string]interface{})) {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			walkJSONLD(item, visit)
+		}
+	case map[string]interface{}:
+		if jsonLDHasType(value, "Product", "Offer") && jsonLDString(value, "name") != "" {
+			visit(value)
+			return
+		}
+		for _, child := range value {
+			walkJSONLD(child, visit)
+		}
+	}
+}
+
+// jsonLDItem собирает объявление из узла Product или Offer
+func jsonLDItem(node map[string]interface{}) (olxJSONLDItem, bool) {
+	item := olxJSONLDItem{
+		Name:        jsonLDString(node, "name"),
+		Description: jsonLDString(node, "description"),
+		URL:         jsonLDString(node, "url"),
+		Images:      jsonLDStrings(node["image"]),
+		Price:       jsonLDNumber(node["price"]),
+		Currency:    jsonLDString(node, "priceCurrency"),
+	}
+
+	// У Product цена лежит в offers: Offer или AggregateOffer
+	if offers, ok := node["offers"].(map[string]interface{}); ok {
+		if item.Price == 0 {
+			item.Price = jsonLDNumber(offers["price"])
+		}
+		if item.Price == 0 {
+			item.Price = jsonLDNumber(offers["lowPrice"])
+		}
+		if item.Currency == "" {
+			item.Currency = jsonLDString(offers, "priceCurrency")
+		}
+		if item.URL == "" {
+			item.URL = jsonLDString(offers, "url")
+		}
+	}
+	return item, item.Name != ""
+}
+
+// toProperty переносит объявление из JSON-LD в property, заполняя только найденные поля
+func (item olxJSONLDItem) toProperty(property *models.ParsedProperty) {
+	property.Source = "olx"
+	property.Title = strings.TrimSpace(item.Name)
+
+	if item.URL != "" {
+		property.URL = absoluteURL(olxBaseURL, item.URL)
+		if matches := olxIDRe.FindStringSubmatch(property.URL); len(matches) == 2 {
+			property.ID = "olx_" + matches[1]
+		}
+	}
+	if description := strings.TrimSpace(item.Description); description != "" {
+		property.Description = description
+	}
+	if len(item.Images) > 0 {
+		property.Images = uniqueStrings(item.Images)
+	}
+	if item.Price > 0 {
+		property.Price = int64(item.Price)
+		property.Currency = item.Currency
+		if property.Currency == "" {
+			property.Currency = "KZT"
+		}
+	}
+}
+
+// extractOlxJSONLDCards возвращает объявления страницы поиска из JSON-LD
+func extractOlxJSONLDCards(items []olxJSONLDItem) []models.ParsedProperty {
+	var properties []models.ParsedProperty
+	for _, item := range items {
+		var property models.ParsedProperty
+		item.toProperty(&property)
+		parseKrishaAreaAndFloor(property.Title, &property)
+		parseCategoryAttributes(property.Title, &property)
+
+		if property.ID != "" && property.Title != "" && property.Price > 0 {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+func jsonLDHasType(node map[string]interface{}, types ...string) bool {
+	for _, nodeType := range jsonLDStrings(node["@type"]) {
+		for _, t := range types {
+			if nodeType == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonLDString(node map[string]interface{}, key string) string {
+	if value, ok := node[key].(string); ok {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// jsonLDStrings приводит строку, массив строк или ImageObject к списку строк
+func jsonLDStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, jsonLDStrings(item)...)
+		}
+		return result
+	case map[string]interface{}:
+		if url := jsonLDString(v, "url"); url != "" {
+			return []string{url}
+		}
+	}
+	return nil
+}
+
+// jsonLDNumber разбирает число, которое в JSON-LD бывает и строкой
+func jsonLDNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		if n, ok := parseDecimal(v); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// htmlToText убирает теги из HTML описания, переносы строк сохраняются
+func htmlToText(html string) string {
+	if html == "" {
+		return ""
+	}
+	html = strings.NewReplacer("<br />", "\n", "<br/>", "\n", "<br>", "\n").Replace(html)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return strings.TrimSpace(html)
+	}
+	return strings.TrimSpace(doc.Text())
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+const (
+	parseQueueKey           = "parser:queue"
+	parseCancelChannel      = "parser:cancel"
+	parseQueuePollTimeout   = 5 * time.Second
+	parseHeartbeatInterval  = 15 * time.Second
+	parseStaleAfter         = 2 * time.Minute
+	parseRecoveryInterval   = time.Minute
+	parseQueueDefaultWorker = 2
+)
+
+var (
+	ErrParseRequestNotFound = errors.New("parse request not found")
+	ErrParseRequestFinished = errors.New("parse request already finished")
+
+	// errParseQueueStopped причина отмены задач при остановке сервера
+	errParseQueueStopped = errors.New("parse queue stopped")
+)
+
+// ParseQueue очередь запросов парсинга в Redis с пулом воркеров.
+// Состояние задач хранится в таблице parse_requests, в Redis лежат только ID.
+// Задачи, оставшиеся в processing после падения сервера, определяются по
+// heartbeat_at и возвращаются в очередь.
+type ParseQueue struct {
+	db          *gorm.DB
+	redis       *redis.Client
+	parser      *ParserService
+	workers     int
+	maxAttempts int
+
+	mu      sync.Mutex
+	running map[uuid.UUID]context.CancelCauseFunc
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewParseQueue создает очередь парсинга
+func NewParseQueue(db *gorm.DB, redisClient *redis.Client, parser *ParserService, cfg config.ParserConfig) *ParseQueue {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = parseQueueDefaultWorker
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	return &ParseQueue{
+		db:          db,
+		redis:       redisClient,
+		parser:      parser,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		running:     make(map[uuid.UUID]context.CancelCauseFunc),
+	}
+}
+
+// Enqueue создает запрос парсинга и ставит его в очередь
+func (q *ParseQueue) Enqueue(ctx context.Context, filters models.PropertyFilters, sourceNames []string, maxPages int, userID *uuid.UUID) (*models.ParseRequest, error) {
+	parseRequest, err := q.parser.BuildParseRequest(filters, sourceNames, maxPages, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.EnqueueRequest(ctx, parseRequest)
+}
+
+// EnqueueRequest сохраняет подготовленный BuildParseRequest запрос и ставит его в очередь
+func (q *ParseQueue) EnqueueRequest(ctx context.Context, parseRequest *models.ParseRequest) (*models.ParseRequest, error) {
+	if err := q.db.Create(parseRequest).Error; err != nil {
+		return nil, fmt.Errorf("failed to create parse request: %w", err)
+	}
+
+	if err := q.push(ctx, parseRequest.ID); err != nil {
+		q.db.Model(&models.ParseRequest{}).
+			Where("id = ? AND status = ?", parseRequest.ID, models.ParseStatusPending).
+			Updates(map[string]interface{}{
+				"status": models.ParseStatusFailed,
+				"error":  "failed to enqueue parse request",
+			})
+		return nil, fmt.Errorf("failed to enqueue parse request: %w", err)
+	}
+
+	return parseRequest, nil
+}
+
+// Cancel отменяет запрос в статусе pending или processing.
+// Воркер, выполняющий запрос, получает сигнал через Redis pub/sub.
+func (q *ParseQueue) Cancel(ctx context.Context, requestID uuid.UUID) (*models.ParseRequest, error) {
+	now := time.Now()
+	result := q.db.Model(&models.ParseRequest{}).
+		Where("id = ? AND status IN ?", requestID, []string{models.ParseStatusPending, models.ParseStatusProcessing}).
+		Updates(map[string]interface{}{
+			"status":      models.ParseStatusCancelled,
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to cancel parse request: %w", result.Error)
+	}
+
+	parseRequest, err := q.parser.GetParseRequest(requestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrParseRequestNotFound
+		}
+		return nil, err
+	}
+
+	if result.RowsAffected == 0 {
+		return parseRequest, ErrParseRequestFinished
+	}
+
+	// Останавливаем локальный воркер сразу, остальные экземпляры узнают через pub/sub
+	q.cancelRunning(requestID)
+	if err := q.redis.Publish(ctx, parseCancelChannel, requestID.String()).Err(); err != nil {
+		log.Printf("Parse queue: failed to publish cancel for %s: %v", requestID, err)
+	}
+
+	return parseRequest, nil
+}
+
+// Start восстанавливает незавершенные задачи и запускает воркеры
+func (q *ParseQueue) Start(ctx context.Context) {
+	ctx, q.stop = context.WithCancel(ctx)
+
+	q.recover(ctx, true)
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		q.listenCancellations(ctx)
+	}()
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		ticker := time.NewTicker(parseRecoveryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				q.recover(ctx, false)
+			}
+		}
+	}()
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go func(worker int) {
+			defer q.wg.Done()
+			q.work(ctx, worker)
+		}(i)
+	}
+
+	log.Printf("Parse queue started with %d workers", q.workers)
+}
+
+// Stop останавливает воркеры. Задачи в работе возвращаются в очередь
+// и будут выполнены после перезапуска.
+func (q *ParseQueue) Stop(ctx context.Context) error {
+	if q.stop == nil {
+		return nil
+	}
+	q.stop()
+
+	q.mu.Lock()
+	for _, cancel := range q.running {
+		cancel(errParseQueueStopped)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work забирает задачи из очереди, пока не остановлен контекст
+func (q *ParseQueue) work(ctx context.Context, worker int) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		result, err := q.redis.BRPop(ctx, parseQueuePollTimeout, parseQueueKey).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("Parse worker %d: queue error: %v", worker, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(parseQueuePollTimeout):
+			}
+			continue
+		}
+
+		requestID, err := uuid.Parse(result[1])
+		if err != nil {
+			log.Printf("Parse worker %d: invalid request id %q", worker, result[1])
+			continue
+		}
+
+		q.process(worker, requestID)
+	}
+}
+
+// process выполняет одну задачу парсинга
+func (q *ParseQueue) process(worker int, requestID uuid.UUID) {
+	parseRequest, err := q.parser.ClaimParseRequest(requestID)
+	if err != nil {
+		log.Printf("Parse worker %d: %v", worker, err)
+		return
+	}
+	if parseRequest == nil {
+		// Задача отменена или уже выполняется другим воркером
+		return
+	}
+
+	log.Printf("Parse worker %d: processing request %s", worker, requestID)
+
+	// Контекст задачи не наследуется от контекста очереди: при остановке
+	// сервера задачи отменяются явно с причиной errParseQueueStopped
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	q.mu.Lock()
+	q.running[requestID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, requestID)
+		q.mu.Unlock()
+	}()
+
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go q.heartbeat(requestID, heartbeatDone)
+
+	properties, parseErr := q.parser.ExecuteParseRequest(jobCtx, parseRequest, func(pagesDone, propertiesFound int) {
+		q.db.Model(&models.ParseRequest{}).
+			Where("id = ? AND status = ?", requestID, models.ParseStatusProcessing).
+			Updates(map[string]interface{}{
+				"pages_done":   pagesDone,
+				"count":        propertiesFound,
+				"heartbeat_at": time.Now(),
+			})
+	})
+
+	if errors.Is(context.Cause(jobCtx), errParseQueueStopped) {
+		q.requeue(requestID)
+		return
+	}
+
+	if err := q.parser.FinishParseRequest(parseRequest, properties, parseErr); err != nil {
+		log.Printf("Parse worker %d: failed to save results for %s: %v", worker, requestID, err)
+		return
+	}
+
+	log.Printf("Parse worker %d: request %s finished with status %s (%d properties)", worker, requestID, parseRequest.Status, parseRequest.Count)
+}
+
+// heartbeat периодически отмечает, что задача еще выполняется
+func (q *ParseQueue) heartbeat(requestID uuid.UUID, done <-chan struct{}) {
+	ticker := time.NewTicker(parseHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			q.db.Model(&models.ParseRequest{}).
+				Where("id = ? AND status = ?", requestID, models.ParseStatusProcessing).
+				Update("heartbeat_at", time.Now())
+		}
+	}
+}
+
+// requeue возвращает прерванную задачу в очередь без учета попытки
+func (q *ParseQueue) requeue(requestID uuid.UUID) {
+	err := q.db.Model(&models.ParseRequest{}).
+		Where("id = ? AND status = ?", requestID, models.ParseStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":       models.ParseStatusPending,
+			"attempts":     gorm.Expr("attempts - 1"),
+			"pages_done":   0,
+			"count":        0,
+			"heartbeat_at": nil,
+		}).Error
+	if err != nil {
+		log.Printf("Parse queue: failed to requeue %s: %v", requestID, err)
+		return
+	}
+
+	// Сервер останавливается, поэтому не используем его контекст
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.push(ctx, requestID); err != nil {
+		log.Printf("Parse queue: request %s will be recovered on next start: %v", requestID, err)
+	}
+}
+
+// recover возвращает в очередь задачи, зависшие в processing без heartbeat.
+// При старте сервера дополнительно переотправляет все pending задачи,
+// так как список в Redis мог быть потерян.
+func (q *ParseQueue) recover(ctx context.Context, startup bool) {
+	cutoff := time.Now().Add(-parseStaleAfter)
+
+	var stale []models.ParseRequest
+	if err := q.db.Select("id", "attempts").
+		Where("status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", models.ParseStatusProcessing, cutoff).
+		Find(&stale).Error; err != nil {
+		log.Printf("Parse queue: failed to load stale requests: %v", err)
+		return
+	}
+
+	var requeue []uuid.UUID
+	for _, parseRequest := range stale {
+		updates := map[string]interface{}{
+			"status":       models.ParseStatusPending,
+			"pages_done":   0,
+			"count":        0,
+			"heartbeat_at": nil,
+		}
+		if parseRequest.Attempts >= q.maxAttempts {
+			updates = map[string]interface{}{
+				"status":      models.ParseStatusFailed,
+				"error":       fmt.Sprintf("parse request interrupted %d times", parseRequest.Attempts),
+				"finished_at": time.Now(),
+			}
+		}
+
+		result := q.db.Model(&models.ParseRequest{}).
+			Where("id = ? AND status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", parseRequest.ID, models.ParseStatusProcessing, cutoff).
+			Updates(updates)
+		if result.Error != nil {
+			log.Printf("Parse queue: failed to recover %s: %v", parseRequest.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected > 0 && updates["status"] == models.ParseStatusPending {
+			requeue = append(requeue, parseRequest.ID)
+		}
+	}
+
+	if startup {
+		var pending []uuid.UUID
+		if err := q.db.Model(&models.ParseRequest{}).
+			Where("status = ?", models.ParseStatusPending).
+			Pluck("id", &pending).Error; err != nil {
+			log.Printf("Parse queue: failed to load pending requests: %v", err)
+		}
+		requeue = append(requeue, pending...)
+	}
+
+	if len(requeue) == 0 {
+		return
+	}
+
+	// Дубликаты в очереди безопасны: задачу забирает только один воркер
+	for _, requestID := range uniqueUUIDs(requeue) {
+		if err := q.push(ctx, requestID); err != nil {
+			log.Printf("Parse queue: failed to requeue %s: %v", requestID, err)
+		}
+	}
+	log.Printf("Parse queue: recovered %d requests", len(requeue))
+}
+
+// listenCancellations получает отмены задач от других экземпляров сервера
+func (q *ParseQueue) listenCancellations(ctx context.Context) {
+	pubsub := q.redis.Subscribe(ctx, parseCancelChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if requestID, err := uuid.Parse(msg.Payload); err == nil {
+				q.cancelRunning(requestID)
+			}
+		}
+	}
+}
+
+// cancelRunning отменяет задачу, если она выполняется в этом процессе
+func (q *ParseQueue) cancelRunning(requestID uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cancel, ok := q.running[requestID]; ok {
+		cancel(ErrParseCancelled)
+	}
+}
+
+func (q *ParseQueue) push(ctx context.Context, requestID uuid.UUID) error {
+	return q.redis.LPush(ctx, parseQueueKey, requestID.String()).Err()
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	result := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"smartestate/internal/models"
+)
+
+// Снапшоты страниц для проверки извлечения без сети лежат в
+// <dir>/<source>/<name>.html, рядом ожидаемый результат <name>.golden.json.
+// Страницы с префиксом detail_ разбираются как страница объявления
+// (ExtractDetail), остальные - как страница поиска (ExtractCards).
+const (
+	ParserFixtureKindSearch = "search"
+	ParserFixtureKindDetail = "detail"
+
+	parserFixtureGoldenSuffix = ".golden.json"
+
+	maxDiffLines = 20
+)
+
+// ParserFixture сохраненная страница площадки с ожидаемым результатом разбора
+type ParserFixture struct {
+	Source     string
+	Name       string
+	Kind       string
+	HTMLPath   string
+	GoldenPath string
+}
+
+// ParserFixtureResult результат сверки одного снапшота с эталоном
+type ParserFixtureResult struct {
+	Fixture ParserFixture
+	Actual  []byte
+	Diff    string // пусто, если результат совпал с эталоном
+	Err     error
+}
+
+// Passed сообщает, что снапшот разобран без ошибок и совпал с эталоном
+func (r ParserFixtureResult) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// NewParserFixture описывает снапшот source/name в каталоге dir
+func NewParserFixture(dir, source, name string) ParserFixture {
+	kind := ParserFixtureKindSearch
+	if strings.HasPrefix(name, ParserFixtureKindDetail+"_") {
+		kind = ParserFixtureKindDetail
+	}
+
+	base := filepath.Join(dir, source, name)
+	return ParserFixture{
+		Source:     source,
+		Name:       name,
+		Kind:       kind,
+		HTMLPath:   base + ".html",
+		GoldenPath: base + parserFixtureGoldenSuffix,
+	}
+}
+
+// LoadParserFixtures находит все снапшоты в каталоге dir
+func LoadParserFixtures(dir string) ([]ParserFixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]ParserFixture, 0, len(paths))
+	for _, path := range paths {
+		source := filepath.Base(filepath.Dir(path))
+		name := strings.TrimSuffix(filepath.Base(path), ".html")
+		fixtures = append(fixtures, NewParserFixture(dir, source, name))
+	}
+	return fixtures, nil
+}
+
+// ExtractParserFixture разбирает сохраненную страницу извлекающей логикой источника
+func ExtractParserFixture(registry *SourceRegistry, fixture ParserFixture) ([]models.ParsedProperty, error) {
+	source, ok := registry.Get(fixture.Source)
+	if !ok {
+		return nil, fmt.Errorf("unknown listing source %q", fixture.Source)
+	}
+
+	file, err := os.Open(fixture.HTMLPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", fixture.HTMLPath, err)
+	}
+
+	if fixture.Kind == ParserFixtureKindDetail {
+		var property models.ParsedProperty
+		source.ExtractDetail(doc, &property)
+		return []models.ParsedProperty{property}, nil
+	}
+
+	properties := source.ExtractCards(doc)
+	if properties == nil {
+		properties = []models.ParsedProperty{}
+	}
+	return properties, nil
+}
+
+// VerifyParserFixture сравнивает результат разбора снапшота с эталонным JSON
+func VerifyParserFixture(registry *SourceRegistry, fixture ParserFixture) ParserFixtureResult {
+	result := ParserFixtureResult{Fixture: fixture}
+
+	properties, err := ExtractParserFixture(registry, fixture)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Actual, result.Err = marshalParserFixture(properties)
+	if result.Err != nil {
+		return result
+	}
+
+	expected, err := os.ReadFile(fixture.GoldenPath)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read golden file: %w", err)
+		return result
+	}
+
+	result.Diff = diffLines(expected, result.Actual)
+	return result
+}
+
+// UpdateParserFixture перезаписывает эталон текущим результатом разбора
+func UpdateParserFixture(registry *SourceRegistry, fixture ParserFixture) error {
+	properties, err := ExtractParserFixture(registry, fixture)
+	if err != nil {
+		return err
+	}
+
+	data, err := marshalParserFixture(properties)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fixture.GoldenPath, data, 0o644)
+}
+
+// CaptureParserFixture загружает страницу через источник, сохраняет HTML в
+// каталог снапшотов и записывает для нее эталон
+func CaptureParserFixture(ctx context.Context, registry *SourceRegistry, fixture ParserFixture, pageURL string) error {
+	source, ok := registry.Get(fixture.Source)
+	if !ok {
+		return fmt.Errorf("unknown listing source %q", fixture.Source)
+	}
+
+	doc, err := source.FetchPage(ctx, pageURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+
+	html, err := goquery.OuterHtml(doc.Selection)
+	if err != nil {
+		return fmt.Errorf("failed to render HTML: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fixture.HTMLPath), 0o755); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("<!-- captured from %s -->\n%s\n", pageURL, html)
+	if err := os.WriteFile(fixture.HTMLPath, []byte(content), 0o644); err != nil {
+		return err
+	}
+
+	return UpdateParserFixture(registry, fixture)
+}
+
+// marshalParserFixture сериализует результат в стабильный читаемый JSON
+func marshalParserFixture(properties []models.ParsedProperty) ([]byte, error) {
+	data, err := json.MarshalIndent(properties, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// diffLines возвращает построчное описание расхождений ожидаемого и фактического текста
+func diffLines(expected, actual []byte) string {
+	if bytes.Equal(expected, actual) {
+		return ""
+	}
+
+	want := strings.Split(string(expected), "\n")
+	got := strings.Split(string(actual), "\n")
+
+	var diff strings.Builder
+	shown := 0
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w == g {
+			continue
+		}
+		if shown == maxDiffLines {
+			diff.WriteString("  ...\n")
+			break
+		}
+		shown++
+		fmt.Fprintf(&diff, "line %d:\n  - %s\n  + %s\n", i+1, w, g)
+	}
+	return diff.String()
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"smartestate/internal/config"
+)
+
+// parserFixturesDir снапшоты страниц площадок, те же, что проверяет cmd/fixtures
+const parserFixturesDir = "../../testdata/parser"
+
+func TestParserFixtures(t *testing.T) {
+	fixtures, err := LoadParserFixtures(parserFixturesDir)
+	if err != nil {
+		t.Fatalf("failed to load fixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found in %s", parserFixturesDir)
+	}
+
+	registry := NewDefaultSourceRegistry(NewPoliteFetcher(config.ParserConfig{}),
+		NewStaticSelectorStore(DefaultSelectorProfiles()), nil)
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Source+"/"+fixture.Name, func(t *testing.T) {
+			result := VerifyParserFixture(registry, fixture)
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+			if result.Diff != "" {
+				t.Errorf("output differs from %s (run 'make fixtures-update' if the change is intended):\n%s",
+					fixture.GoldenPath, result.Diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"smartestate/internal/models"
+)
+
+const poiImportBatch = 500
+
+// POIImportOptions параметры импорта точек интереса
+type POIImportOptions struct {
+	// Replace удаляет точки, которых нет в выгрузке. Подходит, когда выгрузка
+	// покрывает все города, иначе точки других городов будут удалены.
+	Replace bool
+}
+
+// POIImportResult итог импорта точек интереса
+type POIImportResult struct {
+	Imported   int            `json:"imported"`
+	Categories map[string]int `json:"categories"`
+	Skipped    int            `json:"skipped"` // линии без координат узлов
+	Removed    int64          `json:"removed"`
+	Duration   time.Duration  `json:"-"`
+}
+
+type osmTag struct {
+	Key   string `xml:"k,attr"`
+	Value string `xml:"v,attr"`
+}
+
+type osmNode struct {
+	ID   int64    `xml:"id,attr"`
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Tags []osmTag `xml:"tag"`
+}
+
+type osmWay struct {
+	ID   int64 `xml:"id,attr"`
+	Refs []struct {
+		Ref int64 `xml:"ref,attr"`
+	} `xml:"nd"`
+	Center *struct {
+		Lat float64 `xml:"lat,attr"`
+		Lon float64 `xml:"lon,attr"`
+	} `xml:"center"`
+	Tags []osmTag `xml:"tag"`
+}
+
+// pendingWay линия, координаты которой считаются по ее узлам во втором проходе
+type pendingWay struct {
+	poi  models.POI
+	refs []int64
+}
+
+// ImportOSMFile импортирует точки интереса из выгрузки OpenStreetMap в формате
+// XML (.osm, .osm.bz2, .osm.gz), например извлечения Geofabrik или ответа
+// Overpass с "out center". Точки берутся из узлов и линий, у линий - центр
+// по узлам. Отношения (мультиполигоны) не поддерживаются.
+func (s *POIService) ImportOSMFile(ctx context.Context, path string, options POIImportOptions) (*POIImportResult, error) {
+	open := func() (io.ReadCloser, error) {
+		return openOSMFile(path)
+	}
+	return s.ImportOSM(ctx, open, options)
+}
+
+// ImportOSM импортирует точки интереса из XML OpenStreetMap. open вызывается
+// дважды, если координаты линий нужно собрать по узлам.
+func (s *POIService) ImportOSM(ctx context.Context, open func() (io.ReadCloser, error), options POIImportOptions) (*POIImportResult, error) {
+	started := time.Now()
+	result := &POIImportResult{Categories: make(map[string]int)}
+
+	var pois []models.POI
+	pending := make(map[int64]*pendingWay)
+	needed := make(map[int64][2]float64)
+
+	// Первый проход: точки-узлы и линии с готовым центром
+	err := readOSM(ctx, open, func(node *osmNode) {
+		if category, name := osmPOICategory(node.Tags); category != "" {
+			pois = append(pois, models.POI{
+				ID:        fmt.Sprintf("node/%d", node.ID),
+				Category:  category,
+				Name:      name,
+				Latitude:  node.Lat,
+				Longitude: node.Lon,
+			})
+		}
+	}, func(way *osmWay) {
+		category, name := osmPOICategory(way.Tags)
+		if category == "" {
+			return
+		}
+		poi := models.POI{ID: fmt.Sprintf("way/%d", way.ID), Category: category, Name: name}
+		if way.Center != nil {
+			poi.Latitude, poi.Longitude = way.Center.Lat, way.Center.Lon
+			pois = append(pois, poi)
+			return
+		}
+		refs := make([]int64, 0, len(way.Refs))
+		// У замкнутой линии последний узел повторяет первый и сместил бы центр
+		if n := len(way.Refs); n > 1 && way.Refs[0].Ref == way.Refs[n-1].Ref {
+			way.Refs = way.Refs[:n-1]
+		}
+		for _, ref := range way.Refs {
+			refs = append(refs, ref.Ref)
+			needed[ref.Ref] = [2]float64{}
+		}
+		pending[way.ID] = &pendingWay{poi: poi, refs: refs}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Второй проход: координаты узлов линий
+	if len(pending) > 0 {
+		found := make(map[int64]bool, len(needed))
+		err := readOSM(ctx, open, func(node *osmNode) {
+			if _, ok := needed[node.ID]; ok {
+				needed[node.ID] = [2]float64{node.Lat, node.Lon}
+				found[node.ID] = true
+			}
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, way := range pending {
+			var lat, lon float64
+			count := 0
+			for _, ref := range way.refs {
+				if found[ref] {
+					lat += needed[ref][0]
+					lon += needed[ref][1]
+					count++
+				}
+			}
+			if count == 0 {
+				result.Skipped++
+				continue
+			}
+			way.poi.Latitude, way.poi.Longitude = lat/float64(count), lon/float64(count)
+			pois = append(pois, way.poi)
+		}
+	}
+
+	for i := range pois {
+		pois[i].ImportedAt = started
+		result.Categories[pois[i].Category]++
+	}
+
+	for start := 0; start < len(pois); start += poiImportBatch {
+		end := start + poiImportBatch
+		if end > len(pois) {
+			end = len(pois)
+		}
+		if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(pois[start:end]).Error; err != nil {
+			return nil, fmt.Errorf("failed to save points of interest: %w", err)
+		}
+	}
+	result.Imported = len(pois)
+
+	if options.Replace {
+		removed := s.db.Where("imported_at < ?", started).Delete(&models.POI{})
+		if removed.Error != nil {
+			return nil, removed.Error
+		}
+		result.Removed = removed.RowsAffected
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	// Окружение объявлений пересчитывается с новыми точками
+	if err := s.RescoreAll(); err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(started)
+	log.Printf("Imported %d points of interest (%d ways skipped, %d removed) in %s",
+		result.Imported, result.Skipped, result.Removed, result.Duration)
+	return result, nil
+}
+
+// readOSM потоково читает XML OpenStreetMap и передает узлы и линии обработчикам
+func readOSM(ctx context.Context, open func() (io.ReadCloser, error), onNode func(*osmNode), onWay func(*osmWay)) error {
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(reader)
+	for elements := 0; ; elements++ {
+		if elements%100000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid osm xml: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "node":
+			var node osmNode
+			if err := decoder.DecodeElement(&node, &start); err != nil {
+				return fmt.Errorf("invalid osm node: %w", err)
+			}
+			onNode(&node)
+		case "way":
+			if onWay == nil {
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var way osmWay
+			if err := decoder.DecodeElement(&way, &start); err != nil {
+				return fmt.Errorf("invalid osm way: %w", err)
+			}
+			onWay(&way)
+		case "relation":
+			if err := decoder.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openOSMFile открывает выгрузку, распаковывая .bz2 и .gz
+func openOSMFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".bz2"):
+		return struct {
+			io.Reader
+			io.Closer
+		}{bzip2.NewReader(file), file}, nil
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{gz, file}, nil
+	case strings.HasSuffix(path, ".pbf"):
+		file.Close()
+		return nil, fmt.Errorf("pbf is not supported, convert the extract to xml: osmium cat %s -o extract.osm.bz2", path)
+	}
+	return file, nil
+}
+
+// osmPOICategory определяет категорию точки интереса по тегам OSM.
+// Название берется русское, если оно есть.
+func osmPOICategory(tags []osmTag) (string, string) {
+	values := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		values[tag.Key] = tag.Value
+	}
+
+	name := values["name:ru"]
+	if name == "" {
+		name = values["name"]
+	}
+
+	switch {
+	case values["station"] == "subway" || (values["railway"] == "station" && values["subway"] == "yes"):
+		return models.POICategoryMetro, name
+	case values["amenity"] == "school":
+		return models.POICategorySchool, name
+	case values["amenity"] == "kindergarten":
+		return models.POICategoryKindergarten, name
+	case values["highway"] == "bus_stop" || (values["public_transport"] == "platform" && (values["bus"] == "yes" || values["trolleybus"] == "yes")):
+		return models.POICategoryBusStop, name
+	case values["leisure"] == "park":
+		return models.POICategoryPark, name
+	case values["shop"] == "mall" || values["shop"] == "department_store":
+		return models.POICategoryMall, name
+	case values["shop"] == "supermarket":
+		return models.POICategorySupermarket, name
+	case values["amenity"] == "hospital" || values["amenity"] == "clinic":
+		return models.POICategoryHospital, name
+	case values["amenity"] == "pharmacy":
+		return models.POICategoryPharmacy, name
+	}
+	return "", ""
+}
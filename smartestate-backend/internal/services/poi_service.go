@@ -0,0 +1,501 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"smartestate/internal/models"
+)
+
+const (
+	poiSearchRadiusM = 2000 // дальше точки интереса не считаются соседними
+	poiCellDegrees   = 0.01 // ячейка индекса, около 1 км
+	poiFullScoreM    = 400  // до этого расстояния категория дает полный вклад в индекс
+	poiMaxWithinM    = poiSearchRadiusM
+	poiScoreBatch    = 200
+)
+
+// poiWalkWeights вклад категорий в индекс пешей доступности, в сумме 100
+var poiWalkWeights = map[string]float64{
+	models.POICategorySchool:       15,
+	models.POICategoryKindergarten: 10,
+	models.POICategoryMetro:        15,
+	models.POICategoryBusStop:      15,
+	models.POICategoryPark:         10,
+	models.POICategoryMall:         5,
+	models.POICategorySupermarket:  15,
+	models.POICategoryHospital:     5,
+	models.POICategoryPharmacy:     10,
+}
+
+// poiCategoryNames названия категорий для ответов в чате
+var poiCategoryNames = map[string]string{
+	models.POICategorySchool:       "школа",
+	models.POICategoryKindergarten: "детский сад",
+	models.POICategoryMetro:        "метро",
+	models.POICategoryBusStop:      "остановка",
+	models.POICategoryPark:         "парк",
+	models.POICategoryMall:         "ТРЦ",
+	models.POICategorySupermarket:  "супермаркет",
+	models.POICategoryHospital:     "больница",
+	models.POICategoryPharmacy:     "аптека",
+}
+
+// POIService считает расстояния от объявлений до ближайших точек интереса и
+// индекс пешей доступности. Точки загружаются из базы в память один раз и
+// перечитываются после импорта.
+type POIService struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	index *poiIndex
+}
+
+// POIStats количество точек интереса по категориям
+type POIStats struct {
+	Total      int64            `json:"total"`
+	Categories map[string]int64 `json:"categories"`
+}
+
+// ListingNearbyResult окружение объявления каталога
+type ListingNearbyResult struct {
+	ListingID   uuid.UUID          `json:"listing_id"`
+	WalkScore   *int               `json:"walk_score"`
+	Nearby      []models.NearbyPOI `json:"nearby"`
+	POIScoredAt *time.Time         `json:"poi_scored_at"`
+}
+
+// poiIndex сетка точек интереса для поиска ближайших
+type poiIndex struct {
+	cells map[[2]int][]models.POI
+	count int
+}
+
+func NewPOIService(db *gorm.DB) *POIService {
+	return &POIService{db: db}
+}
+
+// Reload перечитывает точки интереса из базы
+func (s *POIService) Reload() error {
+	var pois []models.POI
+	if err := s.db.Find(&pois).Error; err != nil {
+		return err
+	}
+
+	index := &poiIndex{cells: make(map[[2]int][]models.POI), count: len(pois)}
+	for _, poi := range pois {
+		cell := poiCell(poi.Latitude, poi.Longitude)
+		index.cells[cell] = append(index.cells[cell], poi)
+	}
+
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+	return nil
+}
+
+// loadedIndex возвращает индекс, при первом обращении загружает его из базы
+func (s *POIService) loadedIndex() (*poiIndex, error) {
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+	if index != nil {
+		return index, nil
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index, nil
+}
+
+// Available сообщает, что точки интереса импортированы
+func (s *POIService) Available() bool {
+	index, err := s.loadedIndex()
+	return err == nil && index.count > 0
+}
+
+// Nearby возвращает ближайшую точку каждой категории в радиусе 2 км
+func (s *POIService) Nearby(latitude, longitude float64) ([]models.NearbyPOI, error) {
+	index, err := s.loadedIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.nearby(latitude, longitude), nil
+}
+
+func poiCell(latitude, longitude float64) [2]int {
+	return [2]int{int(math.Floor(latitude / poiCellDegrees)), int(math.Floor(longitude / poiCellDegrees))}
+}
+
+// poiMatch ближайшая точка категории и расстояние до нее
+type poiMatch struct {
+	poi       models.POI
+	distanceM int
+}
+
+// nearest возвращает ближайшую точку каждой категории в радиусе поиска
+func (idx *poiIndex) nearest(latitude, longitude float64) []poiMatch {
+	bounds := radiusBounds(GeoPoint{Latitude: latitude, Longitude: longitude}, poiSearchRadiusM)
+	from, to := poiCell(bounds.South, bounds.West), poiCell(bounds.North, bounds.East)
+
+	best := make(map[string]poiMatch)
+	for row := from[0]; row <= to[0]; row++ {
+		for col := from[1]; col <= to[1]; col++ {
+			for _, poi := range idx.cells[[2]int{row, col}] {
+				distance := int(math.Round(haversineKm(latitude, longitude, poi.Latitude, poi.Longitude) * 1000))
+				if distance > poiSearchRadiusM {
+					continue
+				}
+				if current, ok := best[poi.Category]; ok && current.distanceM <= distance {
+					continue
+				}
+				best[poi.Category] = poiMatch{poi: poi, distanceM: distance}
+			}
+		}
+	}
+
+	matches := make([]poiMatch, 0, len(best))
+	for _, category := range models.POICategories {
+		if match, ok := best[category]; ok {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+func (idx *poiIndex) nearby(latitude, longitude float64) []models.NearbyPOI {
+	matches := idx.nearest(latitude, longitude)
+	nearby := make([]models.NearbyPOI, len(matches))
+	for i, match := range matches {
+		nearby[i] = models.NearbyPOI{Category: match.poi.Category, Name: match.poi.Name, DistanceM: match.distanceM}
+	}
+	return nearby
+}
+
+// WalkScore индекс пешей доступности 0-100. Каждая категория дает полный
+// вклад, если ближайшая точка не дальше 400 м, и линейно меньший до 2 км.
+func WalkScore(nearby []models.NearbyPOI) int {
+	var score float64
+	for _, poi := range nearby {
+		weight := poiWalkWeights[poi.Category]
+		switch {
+		case poi.DistanceM <= poiFullScoreM:
+			score += weight
+		case poi.DistanceM < poiSearchRadiusM:
+			score += weight * float64(poiSearchRadiusM-poi.DistanceM) / float64(poiSearchRadiusM-poiFullScoreM)
+		}
+	}
+	return int(math.Round(score))
+}
+
+// poiPrecise проверяет, что координаты достаточно точны для расстояний:
+// центр района или города может быть в нескольких километрах от объекта
+func poiPrecise(precision string) bool {
+	return precision == "" || models.GeoPrecisionRank(precision) >= models.GeoPrecisionRank(models.GeoPrecisionMicrodistrict)
+}
+
+// AnnotateProperties заполняет ближайшие точки интереса и индекс пешей
+// доступности у объявлений с достаточно точными координатами
+func (s *POIService) AnnotateProperties(properties []models.ParsedProperty) error {
+	index, err := s.loadedIndex()
+	if err != nil || index.count == 0 {
+		return err
+	}
+
+	for i := range properties {
+		property := &properties[i]
+		if property.Latitude == nil || property.Longitude == nil || !poiPrecise(property.GeoPrecision) {
+			continue
+		}
+		property.Nearby = index.nearby(*property.Latitude, *property.Longitude)
+		score := WalkScore(property.Nearby)
+		property.WalkScore = &score
+	}
+	return nil
+}
+
+// ValidatePOIFilters проверяет категории и расстояния фильтров окружения
+func ValidatePOIFilters(filters []models.POIDistanceFilter, minWalkScore *int) error {
+	for _, filter := range filters {
+		if !models.IsPOICategory(filter.Category) {
+			return fmt.Errorf("unknown poi category %q", filter.Category)
+		}
+		if filter.WithinM <= 0 || filter.WithinM > poiMaxWithinM {
+			return fmt.Errorf("distance to %s must be between 1 and %d meters", filter.Category, poiMaxWithinM)
+		}
+	}
+	if minWalkScore != nil && (*minWalkScore < 0 || *minWalkScore > 100) {
+		return fmt.Errorf("min walk score must be between 0 and 100")
+	}
+	return nil
+}
+
+// matchesPOIFilters проверяет объявление по фильтрам окружения. Объявление без
+// посчитанного окружения не подходит: нельзя утверждать, что школа рядом.
+func matchesPOIFilters(property models.ParsedProperty, filters []models.POIDistanceFilter, minWalkScore *int) bool {
+	if property.WalkScore == nil {
+		return false
+	}
+	if minWalkScore != nil && *property.WalkScore < *minWalkScore {
+		return false
+	}
+	for _, filter := range filters {
+		found := false
+		for _, poi := range property.Nearby {
+			if poi.Category == filter.Category && poi.DistanceM <= filter.WithinM {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ScoreListings считает окружение объявлений каталога по ID
+func (s *POIService) ScoreListings(listingIDs []uuid.UUID) error {
+	if len(listingIDs) == 0 {
+		return nil
+	}
+	var listings []models.Listing
+	if err := s.db.Where("id IN ?", listingIDs).Find(&listings).Error; err != nil {
+		return err
+	}
+	return s.scoreListings(listings)
+}
+
+// ScorePending считает окружение объявлений, которые еще не считались или
+// геокодированы заново после расчета. Возвращает количество обработанных.
+func (s *POIService) ScorePending(limit int) (int, error) {
+	if limit <= 0 || limit > 5000 {
+		limit = 500
+	}
+
+	var listings []models.Listing
+	err := s.db.
+		Where("latitude IS NOT NULL AND longitude IS NOT NULL").
+		Where("poi_scored_at IS NULL OR (geocoded_at IS NOT NULL AND poi_scored_at < geocoded_at)").
+		Order("last_seen_at DESC").
+		Limit(limit).
+		Find(&listings).Error
+	if err != nil {
+		return 0, err
+	}
+	return len(listings), s.scoreListings(listings)
+}
+
+// RescoreAll сбрасывает расчет у всех объявлений, например после импорта новых точек
+func (s *POIService) RescoreAll() error {
+	return s.db.Model(&models.Listing{}).Where("poi_scored_at IS NOT NULL").Update("poi_scored_at", nil).Error
+}
+
+func (s *POIService) scoreListings(listings []models.Listing) error {
+	index, err := s.loadedIndex()
+	if err != nil || index.count == 0 {
+		return err
+	}
+
+	for start := 0; start < len(listings); start += poiScoreBatch {
+		end := start + poiScoreBatch
+		if end > len(listings) {
+			end = len(listings)
+		}
+		if err := s.saveScores(index, listings[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *POIService) saveScores(index *poiIndex, listings []models.Listing) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, listing := range listings {
+			var walkScore *int
+			var distances []models.ListingPOIDistance
+
+			// Для неточных координат окружение не считается, но отмечается, чтобы не пересчитывать
+			if listing.Latitude != nil && listing.Longitude != nil && poiPrecise(listing.GeoPrecision) {
+				var nearby []models.NearbyPOI
+				for _, match := range index.nearest(*listing.Latitude, *listing.Longitude) {
+					distances = append(distances, models.ListingPOIDistance{
+						ListingID: listing.ID,
+						Category:  match.poi.Category,
+						DistanceM: match.distanceM,
+						POIID:     match.poi.ID,
+						POIName:   match.poi.Name,
+					})
+					nearby = append(nearby, models.NearbyPOI{Category: match.poi.Category, DistanceM: match.distanceM})
+				}
+				score := WalkScore(nearby)
+				walkScore = &score
+			}
+
+			if err := tx.Where("listing_id = ?", listing.ID).Delete(&models.ListingPOIDistance{}).Error; err != nil {
+				return err
+			}
+			if len(distances) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&distances).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&models.Listing{}).Where("id = ?", listing.ID).Updates(map[string]interface{}{
+				"walk_score":    walkScore,
+				"poi_scored_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListingNearby возвращает сохраненное окружение объявления каталога
+func (s *POIService) ListingNearby(listingID uuid.UUID) (*ListingNearbyResult, error) {
+	var listing models.Listing
+	if err := s.db.Where("id = ?", listingID).First(&listing).Error; err != nil {
+		return nil, err
+	}
+
+	var distances []models.ListingPOIDistance
+	if err := s.db.Where("listing_id = ?", listingID).Order("distance_m ASC").Find(&distances).Error; err != nil {
+		return nil, err
+	}
+
+	nearby := make([]models.NearbyPOI, 0, len(distances))
+	for _, distance := range distances {
+		nearby = append(nearby, models.NearbyPOI{
+			Category:  distance.Category,
+			Name:      distance.POIName,
+			DistanceM: distance.DistanceM,
+		})
+	}
+
+	return &ListingNearbyResult{
+		ListingID:   listing.ID,
+		WalkScore:   listing.WalkScore,
+		Nearby:      nearby,
+		POIScoredAt: listing.POIScoredAt,
+	}, nil
+}
+
+// Stats возвращает количество точек интереса по категориям
+func (s *POIService) Stats() (*POIStats, error) {
+	var rows []struct {
+		Category string
+		Count    int64
+	}
+	if err := s.db.Model(&models.POI{}).Select("category, COUNT(*) AS count").Group("category").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &POIStats{Categories: make(map[string]int64)}
+	for _, row := range rows {
+		stats.Categories[row.Category] = row.Count
+		stats.Total += row.Count
+	}
+	return stats, nil
+}
+
+// nearbySummary описывает окружение объявления для ответа в чате
+func nearbySummary(property models.ParsedProperty) string {
+	if len(property.Nearby) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(property.Nearby))
+	for _, poi := range property.Nearby {
+		parts = append(parts, fmt.Sprintf("%s %s", poiCategoryNames[poi.Category], formatDistance(poi.DistanceM)))
+	}
+	summary := strings.Join(parts, ", ")
+	if property.WalkScore != nil {
+		summary += fmt.Sprintf("; пешая доступность %d/100", *property.WalkScore)
+	}
+	return summary
+}
+
+func formatDistance(meters int) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%d м", meters/10*10)
+	}
+	return fmt.Sprintf("%.1f км", float64(meters)/1000)
+}
+
+// Окончания слов категорий. Слово запроса сравнивается с основой и окончанием
+// целиком, чтобы "парк" не находился в "паркет" и "парковка".
+var (
+	poiMasculineEndings = []string{"", "а", "у", "е", "ом", "ы", "и", "ов", "ам", "ами", "ах"}
+	poiFeminineEndings  = []string{"", "а", "ы", "и", "е", "у", "ой", "ою", "ам", "ами", "ах"}
+	poiAdjectiveEndings = []string{"ый", "ого", "ому", "ым", "ом", "ая", "ой", "ую", "ые", "ых", "ыми"}
+	poiIndeclinable     = []string{""}
+)
+
+// poiQueryStem основа слова категории и ее возможные окончания
+type poiQueryStem struct {
+	stem     string
+	endings  []string
+	category string
+}
+
+// matches сравнивает слово запроса с основой и окончаниями
+func (e poiQueryStem) matches(word string) bool {
+	ending, ok := strings.CutPrefix(word, e.stem)
+	return ok && containsString(e.endings, ending)
+}
+
+// poiQueryStems основы слов категорий в запросах пользователей
+var poiQueryStems = []poiQueryStem{
+	{"школ", poiFeminineEndings, models.POICategorySchool},
+	{"садик", poiMasculineEndings, models.POICategoryKindergarten},
+	{"детсад", poiMasculineEndings, models.POICategoryKindergarten},
+	{"метро", poiIndeclinable, models.POICategoryMetro},
+	{"остановк", poiFeminineEndings, models.POICategoryBusStop},
+	{"остановок", poiIndeclinable, models.POICategoryBusStop},
+	{"парк", poiMasculineEndings, models.POICategoryPark},
+	{"сквер", poiMasculineEndings, models.POICategoryPark},
+	{"трц", poiIndeclinable, models.POICategoryMall},
+	{"торгов", poiAdjectiveEndings, models.POICategoryMall},
+	{"супермаркет", poiMasculineEndings, models.POICategorySupermarket},
+	{"больниц", poiFeminineEndings, models.POICategoryHospital},
+	{"поликлиник", poiFeminineEndings, models.POICategoryHospital},
+	{"аптек", poiFeminineEndings, models.POICategoryPharmacy},
+}
+
+// poiNearWords слова, после которых категория означает "рядом с"
+var poiNearWords = []string{"рядом", "возле", "около", "недалеко", "поблизости", "близко", "шаговой"}
+
+// detectNearPOI находит в запросе пожелания вида "рядом со школой", "возле
+// метро". Расстояние по умолчанию - 500 м, для метро и парков - 1 км.
+func detectNearPOI(message string) []models.POIDistanceFilter {
+	if !containsAny(message, poiNearWords) {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var filters []models.POIDistanceFilter
+	for _, word := range strings.Fields(normalizeGeoText(message)) {
+		for _, entry := range poiQueryStems {
+			if !entry.matches(word) || seen[entry.category] {
+				continue
+			}
+			seen[entry.category] = true
+			within := 500
+			if entry.category == models.POICategoryMetro || entry.category == models.POICategoryPark {
+				within = 1000
+			}
+			filters = append(filters, models.POIDistanceFilter{Category: entry.category, WithinM: within})
+		}
+	}
+	return filters
+}
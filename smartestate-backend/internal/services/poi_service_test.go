@@ -0,0 +1,33 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"smartestate/internal/models"
+)
+
+func TestDetectNearPOI(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []string
+	}{
+		{"квартира рядом со школой и парком", []string{models.POICategorySchool, models.POICategoryPark}},
+		{"возле метро, недалеко от остановок", []string{models.POICategoryMetro, models.POICategoryBusStop}},
+		{"около торгового центра", []string{models.POICategoryMall}},
+		{"рядом аптека", []string{models.POICategoryPharmacy}},
+		// Совпадение внутри слова - не категория
+		{"паркет, рядом парковка и паркинг", nil},
+		{"рядом школьный стадион", nil},
+		{"квартира с паркетом у парка", nil}, // нет слова "рядом"
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, filter := range detectNearPOI(tt.message) {
+			got = append(got, filter.Category)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectNearPOI(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"smartestate/internal/config"
+)
+
+const (
+	fetchDefaultRatePerMinute    = 30
+	fetchDefaultBurst            = 3
+	fetchDefaultMaxAttempts      = 4
+	fetchDefaultBackoffBase      = time.Second
+	fetchDefaultBackoffMax       = 30 * time.Second
+	fetchDefaultBreakerThreshold = 5
+	fetchDefaultBreakerCooldown  = time.Minute
+	// fetchDefaultUserAgent представляется своим именем: по нему площадка
+	// находит нашу группу в robots.txt
+	fetchDefaultUserAgent = "SmartEstateBot/1.0"
+
+	robotsCacheTTL      = 24 * time.Hour
+	robotsErrorCacheTTL = 10 * time.Minute
+	maxRobotsSize       = 512 << 10 // 512KB
+)
+
+// ErrRobotsDisallowed страница закрыта для обхода в robots.txt площадки
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// CircuitOpenError площадка отключена после серии ошибок подряд и не
+// опрашивается до окончания паузы
+type CircuitOpenError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// FetchAttemptsError загрузчик исчерпал свои повторы запроса. Повторять
+// загрузку снаружи не нужно: это умножит число запросов к площадке.
+type FetchAttemptsError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *FetchAttemptsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FetchAttemptsError) Unwrap() error {
+	return e.Err
+}
+
+// Состояния автомата отключения площадки
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// PoliteFetcher общий загрузчик страниц площадок. Для каждого хоста держит
+// лимит запросов (token bucket), повторяет 429 и ошибки сервера с растущей
+// паузой со случайным разбросом, временно отключает площадку после серии
+// ошибок подряд и проверяет robots.txt. Все источники и KrishaFilterService
+// ходят через один загрузчик, поэтому лимиты общие для всего процесса.
+type PoliteFetcher struct {
+	client           *http.Client
+	userAgent        string
+	ratePerSecond    float64
+	burst            float64
+	maxAttempts      int
+	backoffBase      time.Duration
+	backoffMax       time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	respectRobots    bool
+
+	mu    sync.Mutex
+	hosts map[string]*fetchHost
+}
+
+// fetchHost состояние и счетчики одного хоста
+type fetchHost struct {
+	mu sync.Mutex
+
+	// token bucket
+	tokens     float64
+	refilledAt time.Time
+	crawlDelay time.Duration // из robots.txt, замедляет лимит хоста
+
+	// отключение площадки
+	failures  int
+	openUntil time.Time
+	probing   bool // в полуоткрытом состоянии пропускается один пробный запрос
+
+	// robots.txt
+	robots          *robotsRules
+	robotsExpiresAt time.Time
+	robotsLoading   chan struct{}
+
+	stats HostFetchStats
+}
+
+// HostFetchStats метрики загрузчика по одному хосту
+type HostFetchStats struct {
+	Host            string     `json:"host"`
+	Requests        int64      `json:"requests"`         // отправлено HTTP запросов, включая повторы
+	Succeeded       int64      `json:"succeeded"`        // загрузок, завершившихся успехом
+	Failed          int64      `json:"failed"`           // загрузок, завершившихся ошибкой
+	Retries         int64      `json:"retries"`          // повторных запросов
+	RateLimited     int64      `json:"rate_limited"`     // ответов 429
+	ServerErrors    int64      `json:"server_errors"`    // ответов 5xx
+	RobotsBlocked   int64      `json:"robots_blocked"`   // страниц, закрытых robots.txt
+	BreakerRejected int64      `json:"breaker_rejected"` // запросов, отклоненных при отключенной площадке
+	BreakerTrips    int64      `json:"breaker_trips"`    // сколько раз площадка отключалась
+	BreakerState    string     `json:"breaker_state"`    // closed, open, half_open
+	ThrottledMs     int64      `json:"throttled_ms"`     // суммарное ожидание лимита запросов
+	CrawlDelayMs    int64      `json:"crawl_delay_ms"`   // Crawl-delay из robots.txt
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+}
+
+// FetcherStats настройки загрузчика и метрики по хостам
+type FetcherStats struct {
+	UserAgent     string           `json:"user_agent"`
+	RatePerMinute int              `json:"rate_per_minute"`
+	Burst         int              `json:"burst"`
+	MaxAttempts   int              `json:"max_attempts"`
+	RespectRobots bool             `json:"respect_robots"`
+	Hosts         []HostFetchStats `json:"hosts"`
+}
+
+// NewPoliteFetcher создает загрузчик с лимитами из конфигурации.
+// Незаданные значения заменяются значениями по умолчанию.
+func NewPoliteFetcher(cfg config.ParserConfig) *PoliteFetcher {
+	f := &PoliteFetcher{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		userAgent:        cfg.FetchUserAgent,
+		ratePerSecond:    float64(cfg.FetchRatePerMinute) / 60,
+		burst:            float64(cfg.FetchBurst),
+		maxAttempts:      cfg.FetchMaxAttempts,
+		backoffBase:      time.Duration(cfg.FetchBackoffBase) * time.Millisecond,
+		backoffMax:       time.Duration(cfg.FetchBackoffMax) * time.Millisecond,
+		breakerThreshold: cfg.FetchBreakerThreshold,
+		breakerCooldown:  time.Duration(cfg.FetchBreakerCooldown) * time.Second,
+		respectRobots:    cfg.FetchRespectRobots,
+		hosts:            make(map[string]*fetchHost),
+	}
+	if f.userAgent == "" {
+		f.userAgent = fetchDefaultUserAgent
+	}
+	if f.ratePerSecond <= 0 {
+		f.ratePerSecond = float64(fetchDefaultRatePerMinute) / 60
+	}
+	if f.burst < 1 {
+		f.burst = fetchDefaultBurst
+	}
+	if f.maxAttempts <= 0 {
+		f.maxAttempts = fetchDefaultMaxAttempts
+	}
+	if f.backoffBase <= 0 {
+		f.backoffBase = fetchDefaultBackoffBase
+	}
+	if f.backoffMax < f.backoffBase {
+		f.backoffMax = fetchDefaultBackoffMax
+	}
+	if f.breakerThreshold <= 0 {
+		f.breakerThreshold = fetchDefaultBreakerThreshold
+	}
+	if f.breakerCooldown <= 0 {
+		f.breakerCooldown = fetchDefaultBreakerCooldown
+	}
+	return f
+}
+
+// Fetch загружает страницу и разбирает HTML. 429 и ошибки сервера повторяются
+// с паузой, 404 и другие ответы клиенту возвращаются сразу.
+func (f *PoliteFetcher) Fetch(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	host, err := f.acquire(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		doc, err := f.get(ctx, host, pageURL)
+		if err == nil {
+			f.Report(pageURL, nil)
+			return doc, nil
+		}
+		if attempt >= f.maxAttempts || !f.retryable(ctx, err) {
+			err = &FetchAttemptsError{Attempts: attempt, Err: err}
+			f.Report(pageURL, err)
+			return nil, err
+		}
+
+		delay := f.backoff(attempt)
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+			delay = statusErr.RetryAfter
+		}
+		host.record(func(s *HostFetchStats) { s.Retries++ })
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			f.Report(pageURL, ctx.Err())
+			return nil, ctx.Err()
+		}
+
+		// Повтор тоже расходует лимит хоста
+		if err := f.wait(ctx, host); err != nil {
+			f.Report(pageURL, err)
+			return nil, err
+		}
+	}
+}
+
+// Acquire проверяет robots.txt и состояние площадки и ждет свободного места в
+// лимите запросов. Используется и для загрузки через браузер: после нее
+// результат передается в Report.
+func (f *PoliteFetcher) Acquire(ctx context.Context, pageURL string) error {
+	_, err := f.acquire(ctx, pageURL)
+	return err
+}
+
+func (f *PoliteFetcher) acquire(ctx context.Context, pageURL string) (*fetchHost, error) {
+	parsed, err := url.Parse(pageURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("некорректный URL %q", pageURL)
+	}
+	host := f.host(parsed.Host)
+
+	if f.respectRobots {
+		rules := f.robots(ctx, parsed, host)
+		if !rules.allowed(parsed.RequestURI()) {
+			host.record(func(s *HostFetchStats) { s.RobotsBlocked++ })
+			return nil, fmt.Errorf("%s: %w", pageURL, ErrRobotsDisallowed)
+		}
+	}
+
+	if err := host.allow(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := f.wait(ctx, host); err != nil {
+		host.release()
+		return nil, err
+	}
+	return host, nil
+}
+
+// Report учитывает итог загрузки страницы: успех закрывает отключение площадки,
+// серия ошибок подряд его открывает. Ответы 4xx, кроме 429, и отмена запроса
+// площадку не отключают.
+func (f *PoliteFetcher) Report(pageURL string, err error) {
+	parsed, parseErr := url.Parse(pageURL)
+	if parseErr != nil || parsed.Host == "" {
+		return
+	}
+	host := f.host(parsed.Host)
+	now := time.Now()
+
+	host.mu.Lock()
+	defer host.mu.Unlock()
+
+	host.probing = false
+	if err == nil {
+		host.stats.Succeeded++
+		host.failures = 0
+		host.openUntil = time.Time{}
+		return
+	}
+
+	host.stats.Failed++
+	host.stats.LastError = err.Error()
+	host.stats.LastErrorAt = &now
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && !statusErr.Temporary() {
+		return
+	}
+
+	host.failures++
+	if host.failures >= f.breakerThreshold {
+		if host.openUntil.IsZero() || now.After(host.openUntil) {
+			host.stats.BreakerTrips++
+			log.Printf("Fetcher: %s отключен на %s после %d ошибок подряд: %v",
+				parsed.Host, f.breakerCooldown, host.failures, err)
+		}
+		host.openUntil = now.Add(f.breakerCooldown)
+	}
+}
+
+// Stats возвращает метрики загрузчика
+func (f *PoliteFetcher) Stats() FetcherStats {
+	f.mu.Lock()
+	hosts := make([]*fetchHost, 0, len(f.hosts))
+	for _, host := range f.hosts {
+		hosts = append(hosts, host)
+	}
+	f.mu.Unlock()
+
+	stats := FetcherStats{
+		UserAgent:     f.userAgent,
+		RatePerMinute: int(math.Round(f.ratePerSecond * 60)),
+		Burst:         int(f.burst),
+		MaxAttempts:   f.maxAttempts,
+		RespectRobots: f.respectRobots,
+		Hosts:         make([]HostFetchStats, 0, len(hosts)),
+	}
+	now := time.Now()
+	for _, host := range hosts {
+		host.mu.Lock()
+		hostStats := host.stats
+		hostStats.BreakerState = host.state(now)
+		hostStats.CrawlDelayMs = host.crawlDelay.Milliseconds()
+		host.mu.Unlock()
+		stats.Hosts = append(stats.Hosts, hostStats)
+	}
+	sort.Slice(stats.Hosts, func(i, j int) bool { return stats.Hosts[i].Host < stats.Hosts[j].Host })
+	return stats
+}
+
+// get отправляет один запрос
+func (f *PoliteFetcher) get(ctx context.Context, host *fetchHost, pageURL string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	for key, value := range browserHeaders {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	host.record(func(s *HostFetchStats) { s.Requests++ })
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+		host.record(func(s *HostFetchStats) {
+			switch {
+			case resp.StatusCode == http.StatusTooManyRequests:
+				s.RateLimited++
+			case resp.StatusCode >= 500:
+				s.ServerErrors++
+			}
+		})
+		return nil, statusErr
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка парсинга HTML: %w", err)
+	}
+	return doc, nil
+}
+
+// retryable сообщает, что запрос стоит повторить: сетевые ошибки, 429 и 5xx
+func (f *PoliteFetcher) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	return true
+}
+
+// backoff пауза перед повтором: экспонента от базовой паузы с разбросом
+// в верхней половине интервала, чтобы воркеры не повторяли запросы разом
+func (f *PoliteFetcher) backoff(attempt int) time.Duration {
+	delay := f.backoffBase << uint(attempt-1)
+	if delay <= 0 || delay > f.backoffMax {
+		delay = f.backoffMax
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// wait ждет токен в лимите хоста
+func (f *PoliteFetcher) wait(ctx context.Context, host *fetchHost) error {
+	host.mu.Lock()
+	rate := f.ratePerSecond
+	if host.crawlDelay > 0 {
+		rate = math.Min(rate, 1/host.crawlDelay.Seconds())
+	}
+	now := time.Now()
+	if host.refilledAt.IsZero() {
+		host.tokens = f.burst
+	} else {
+		host.tokens = math.Min(f.burst, host.tokens+now.Sub(host.refilledAt).Seconds()*rate)
+	}
+	host.refilledAt = now
+
+	// Токен резервируется сразу, поэтому очередь ожидающих не обгоняет друг друга
+	host.tokens--
+	var delay time.Duration
+	if host.tokens < 0 {
+		delay = time.Duration(-host.tokens / rate * float64(time.Second))
+		host.stats.ThrottledMs += delay.Milliseconds()
+	}
+	host.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		// Неиспользованный токен возвращается
+		host.mu.Lock()
+		host.tokens++
+		host.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// host возвращает состояние хоста, создавая его при первом обращении
+func (f *PoliteFetcher) host(name string) *fetchHost {
+	name = strings.ToLower(name)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	host, ok := f.hosts[name]
+	if !ok {
+		host = &fetchHost{stats: HostFetchStats{Host: name}}
+		f.hosts[name] = host
+	}
+	return host
+}
+
+// robots возвращает правила robots.txt хоста, загружая их при необходимости.
+// Пока правила загружаются, остальные запросы к хосту ждут их.
+func (f *PoliteFetcher) robots(ctx context.Context, pageURL *url.URL, host *fetchHost) *robotsRules {
+	for {
+		host.mu.Lock()
+		if host.robots != nil && time.Now().Before(host.robotsExpiresAt) {
+			rules := host.robots
+			host.mu.Unlock()
+			return rules
+		}
+		if loading := host.robotsLoading; loading != nil {
+			host.mu.Unlock()
+			select {
+			case <-loading:
+				continue
+			case <-ctx.Done():
+				return &robotsRules{}
+			}
+		}
+		loading := make(chan struct{})
+		host.robotsLoading = loading
+		host.mu.Unlock()
+
+		rules, ttl := f.loadRobots(ctx, pageURL)
+		if ctx.Err() != nil {
+			ttl = 0 // запрос отменили во время загрузки, правила перечитаются
+		}
+
+		host.mu.Lock()
+		host.robots = rules
+		host.robotsExpiresAt = time.Now().Add(ttl)
+		host.crawlDelay = rules.crawlDelay
+		host.robotsLoading = nil
+		host.mu.Unlock()
+		close(loading)
+		return rules
+	}
+}
+
+// loadRobots загружает robots.txt. Если файла нет, обход разрешен; если
+// площадка недоступна, обход тоже разрешается, но правила перечитываются раньше.
+func (f *PoliteFetcher) loadRobots(ctx context.Context, pageURL *url.URL) (*robotsRules, time.Duration) {
+	robotsURL := pageURL.Scheme + "://" + pageURL.Host + "/robots.txt"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return &robotsRules{}, robotsErrorCacheTTL
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		log.Printf("Fetcher: не удалось загрузить %s: %v", robotsURL, err)
+		return &robotsRules{}, robotsErrorCacheTTL
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseRobots(io.LimitReader(resp.Body, maxRobotsSize), robotsAgent(f.userAgent)), robotsCacheTTL
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsRules{}, robotsCacheTTL
+	default:
+		log.Printf("Fetcher: %s ответил %d", robotsURL, resp.StatusCode)
+		return &robotsRules{}, robotsErrorCacheTTL
+	}
+}
+
+// allow проверяет, можно ли сейчас отправить запрос на хост
+func (h *fetchHost) allow(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch h.state(now) {
+	case BreakerOpen:
+		h.stats.BreakerRejected++
+		return &CircuitOpenError{Host: h.stats.Host, Until: h.openUntil}
+	case BreakerHalfOpen:
+		// После паузы пропускается один пробный запрос, остальные ждут его итога
+		if h.probing {
+			h.stats.BreakerRejected++
+			return &CircuitOpenError{Host: h.stats.Host, Until: h.openUntil}
+		}
+		h.probing = true
+	}
+	return nil
+}
+
+// release снимает отметку пробного запроса, если он так и не был отправлен
+func (h *fetchHost) release() {
+	h.mu.Lock()
+	h.probing = false
+	h.mu.Unlock()
+}
+
+// state возвращает состояние отключения площадки, вызывается под h.mu
+func (h *fetchHost) state(now time.Time) string {
+	switch {
+	case h.openUntil.IsZero():
+		return BreakerClosed
+	case now.Before(h.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+func (h *fetchHost) record(update func(*HostFetchStats)) {
+	h.mu.Lock()
+	update(&h.stats)
+	h.mu.Unlock()
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: секунды или HTTP дата
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// robotsRules правила robots.txt для нашего User-Agent
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	path  string
+	allow bool
+}
+
+// allowed проверяет путь по правилам: побеждает самое длинное совпадение,
+// при равной длине Allow
+func (r *robotsRules) allowed(path string) bool {
+	best := -1
+	allowed := true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.path, path) {
+			continue
+		}
+		if len(rule.path) > best || (len(rule.path) == best && rule.allow) {
+			best = len(rule.path)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch сравнивает путь с шаблоном robots.txt: * - любая подстрока,
+// $ в конце - конец пути
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	if anchored {
+		return rest == "" || strings.HasSuffix(pattern, "*")
+	}
+	return true
+}
+
+// robotsAgent имя робота для групп robots.txt: первый продукт User-Agent без
+// версии, например smartestatebot для "SmartEstateBot/1.0"
+func robotsAgent(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	if len(fields) == 0 {
+		return "*"
+	}
+	return strings.ToLower(strings.SplitN(fields[0], "/", 2)[0])
+}
+
+// parseRobots разбирает robots.txt. Берется группа, User-agent которой без
+// учета регистра является началом agent, а если такой нет - группа *.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	var own, wildcard robotsRules
+	var hasOwn bool
+	var current []*robotsRules
+	groupStarted := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Несколько User-agent подряд относятся к одной группе
+			if groupStarted {
+				current = nil
+				groupStarted = false
+			}
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				current = append(current, &wildcard)
+			case agent != "*" && name != "" && strings.HasPrefix(agent, name):
+				current = append(current, &own)
+				hasOwn = true
+			}
+		case "allow", "disallow":
+			groupStarted = true
+			if value == "" {
+				continue // пустой Disallow разрешает все
+			}
+			for _, group := range current {
+				group.rules = append(group.rules, robotsRule{path: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			groupStarted = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				for _, group := range current {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if hasOwn {
+		return &own
+	}
+	return &wildcard
+}
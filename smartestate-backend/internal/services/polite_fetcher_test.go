@@ -0,0 +1,34 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"smartestate/internal/config"
+)
+
+func TestParseRobotsSelectsGroupByProductToken(t *testing.T) {
+	agent := robotsAgent(NewPoliteFetcher(config.ParserConfig{}).userAgent)
+	if agent != "smartestatebot" {
+		t.Fatalf("default robots agent = %q, want smartestatebot", agent)
+	}
+
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		allowed bool
+	}{
+		{"prefix of the product token", "User-agent: SmartEstate\nDisallow: /private/\n\nUser-agent: *\nDisallow: /search/\n", "/search/", true},
+		{"own group rules", "User-agent: smartestatebot\nDisallow: /private/\n", "/private/1", false},
+		{"browser group", "User-agent: Mozilla\nDisallow: /\n\nUser-agent: *\nDisallow: /search/\n", "/listing/1", true},
+		{"substring is not a prefix", "User-agent: Bot\nDisallow: /\n", "/listing/1", true},
+		{"longer name", "User-agent: SmartEstateBotPro\nDisallow: /\n", "/", true},
+	}
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader(tt.robots), agent)
+		if got := rules.allowed(tt.path); got != tt.allowed {
+			t.Errorf("%s: allowed(%s) = %v, want %v", tt.name, tt.path, got, tt.allowed)
+		}
+	}
+}
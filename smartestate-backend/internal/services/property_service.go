@@ -2,6 +2,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"mime/multipart"
 	"path/filepath"
@@ -14,18 +15,50 @@ import (
 )
 
 type PropertyService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db       *gorm.DB
+	redis    *redis.Client
+	geocoder *GeocodeService
 }
 
 func NewPropertyService(db *gorm.DB, redis *redis.Client) *PropertyService {
 	return &PropertyService{db: db, redis: redis}
 }
 
+// SetGeocoder подключает заполнение координат по адресу при создании объявления
+func (s *PropertyService) SetGeocoder(geocoder *GeocodeService) {
+	s.geocoder = geocoder
+}
+
 func (s *PropertyService) List(filters map[string]interface{}, page, limit int) ([]models.Property, int64, error) {
 	var properties []models.Property
 	var total int64
 
+	query := s.filterQuery(filters)
+
+	// Count total
+	query.Count(&total)
+
+	// Ближайшие к точке поиска по карте - первыми
+	if geo, ok := filters["geo"].(*GeoFilter); ok && geo.Center != nil {
+		query = geo.applyDistanceOrder(query, "properties", propertyLatExpr, propertyLonExpr)
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Find(&properties).Error
+
+	return properties, total, err
+}
+
+// Markers возвращает маркеры объектов для карты, сгруппированные по масштабу zoom.
+// Объекты без координат на карту не попадают.
+func (s *PropertyService) Markers(filters map[string]interface{}, zoom int) ([]MapMarker, error) {
+	query := s.filterQuery(filters).
+		Where(fmt.Sprintf("NOT (%s = 0 AND %s = 0)", propertyLatExpr, propertyLonExpr))
+	return clusterMarkers(query, propertyLatExpr, propertyLonExpr, "properties", zoom)
+}
+
+func (s *PropertyService) filterQuery(filters map[string]interface{}) *gorm.DB {
 	query := s.db.Model(&models.Property{})
 
 	// Apply filters
@@ -44,15 +77,11 @@ func (s *PropertyService) List(filters map[string]interface{}, page, limit int)
 	if rooms, ok := filters["rooms"].(int); ok {
 		query = query.Where("rooms = ?", rooms)
 	}
+	if geo, ok := filters["geo"].(*GeoFilter); ok {
+		query = geo.apply(query, propertyLatExpr, propertyLonExpr)
+	}
 
-	// Count total
-	query.Count(&total)
-
-	// Pagination
-	offset := (page - 1) * limit
-	err := query.Offset(offset).Limit(limit).Find(&properties).Error
-
-	return properties, total, err
+	return query
 }
 
 func (s *PropertyService) GetByID(id string) (*models.Property, error) {
@@ -62,6 +91,24 @@ func (s *PropertyService) GetByID(id string) (*models.Property, error) {
 }
 
 func (s *PropertyService) Create(property *models.Property) error {
+	// Координаты, которые не передал клиент, определяем по адресу. Центр
+	// района или города не сохраняем: у объектов нет поля точности, и поиск
+	// по карте считал бы такие координаты точными
+	if s.geocoder != nil && property.Coordinates.Latitude == 0 && property.Coordinates.Longitude == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result, err := s.geocoder.Geocode(ctx, GeocodeQuery{
+			Address: property.Address.Street,
+			City:    property.Address.City,
+		})
+		cancel()
+		if err == nil && poiPrecise(result.Precision) {
+			property.Coordinates = models.Coordinates{
+				Latitude:  result.Latitude,
+				Longitude: result.Longitude,
+			}
+		}
+	}
+
 	return s.db.Create(property).Error
 }
 
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/models"
+)
+
+// ErrSavedSearchNotFound сохраненный поиск не найден или принадлежит другому пользователю
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// SavedSearchInput параметры сохраненного поиска. Если задан SearchURL, фильтры
+// и источник берутся из ссылки на поиск krisha.kz или olx.kz.
+type SavedSearchInput struct {
+	Name                 string                 `json:"name"`
+	SearchURL            string                 `json:"search_url" example:"https://krisha.kz/prodazha/kvartiry/almaty/?das[live.rooms][]=2"`
+	Sources              []string               `json:"sources"`
+	Filters              models.PropertyFilters `json:"filters"`
+	NotificationsEnabled bool                   `json:"notifications_enabled"`
+}
+
+// SavedSearchService сохраненные поиски пользователей
+type SavedSearchService struct {
+	db     *gorm.DB
+	parser *ParserService
+}
+
+func NewSavedSearchService(db *gorm.DB, parser *ParserService) *SavedSearchService {
+	return &SavedSearchService{db: db, parser: parser}
+}
+
+// Create сохраняет поиск. Возвращает и параметры ссылки, которые не удалось перенести.
+func (s *SavedSearchService) Create(userID uuid.UUID, input SavedSearchInput) (*models.SavedSearch, []string, error) {
+	var unsupported []string
+	if input.SearchURL != "" {
+		imported, err := ParseSearchURL(input.SearchURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		input.Filters = imported.Filters
+		input.SearchURL = imported.URL
+		if len(input.Sources) == 0 {
+			input.Sources = []string{imported.Source}
+		}
+		unsupported = imported.Unsupported
+	}
+
+	if _, err := s.parser.Sources().Resolve(input.Sources); err != nil {
+		return nil, nil, err
+	}
+	if err := normalizeSearchFilters(&input.Filters); err != nil {
+		return nil, nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = searchFiltersTitle(input.Filters)
+	}
+
+	search := &models.SavedSearch{
+		UserID:               userID,
+		Name:                 name,
+		Sources:              models.StringSlice(input.Sources),
+		Filters:              input.Filters,
+		SourceURL:            input.SearchURL,
+		NotificationsEnabled: input.NotificationsEnabled,
+	}
+	if err := s.db.Create(search).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save search: %w", err)
+	}
+	return search, unsupported, nil
+}
+
+// List возвращает поиски пользователя, новые первыми
+func (s *SavedSearchService) List(userID uuid.UUID) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&searches).Error
+	return searches, err
+}
+
+// Delete удаляет поиск пользователя
+func (s *SavedSearchService) Delete(userID, id uuid.UUID) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedSearch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// searchFiltersTitle название поиска по умолчанию: "Квартиры, Алматы"
+func searchFiltersTitle(filters models.PropertyFilters) string {
+	titles := map[string]string{
+		models.PropertyTypeApartment:  "Квартиры",
+		models.PropertyTypeHouse:      "Дома",
+		models.PropertyTypeLand:       "Участки",
+		models.PropertyTypeCommercial: "Коммерческая недвижимость",
+	}
+	title := titles[filters.PropertyType]
+	if filters.Rooms != nil {
+		title = fmt.Sprintf("%d-комн. %s", *filters.Rooms, strings.ToLower(title))
+	}
+	if models.IsRent(filters.DealType) {
+		title += " в аренду"
+	}
+	if filters.City != "" {
+		title += ", " + filters.City
+	}
+	return title
+}
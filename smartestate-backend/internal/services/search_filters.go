@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smartestate/internal/models"
+)
+
+// normalizeSearchFilters проверяет фильтры поиска и приводит тип сделки,
+// категорию, город и перечисления к каноничным значениям. Фильтры хранятся и
+// передаются источникам только после этой проверки.
+func normalizeSearchFilters(filters *models.PropertyFilters) error {
+	dealType, ok := models.NormalizeDealType(filters.DealType)
+	if !ok {
+		return fmt.Errorf("unknown deal type %q", filters.DealType)
+	}
+	propertyType, ok := models.NormalizePropertyType(filters.PropertyType)
+	if !ok {
+		return fmt.Errorf("unknown property type %q", filters.PropertyType)
+	}
+	commercialType, ok := models.NormalizeCommercialType(filters.CommercialType)
+	if !ok {
+		return fmt.Errorf("unknown commercial type %q", filters.CommercialType)
+	}
+	if propertyType != models.PropertyTypeCommercial {
+		commercialType = ""
+	}
+	city, ok := DefaultLocations().NormalizeCity(filters.City)
+	if !ok {
+		return fmt.Errorf("unknown city %q", filters.City)
+	}
+	sellerType, ok := models.NormalizeSellerType(filters.SellerType)
+	if !ok {
+		return fmt.Errorf("unknown seller type %q", filters.SellerType)
+	}
+	houseType, ok := models.NormalizeHouseType(filters.HouseType)
+	if !ok {
+		return fmt.Errorf("unknown house type %q", filters.HouseType)
+	}
+
+	if filters.Rooms != nil && (*filters.Rooms < 1 || *filters.Rooms > 20) {
+		return fmt.Errorf("rooms must be between 1 and 20")
+	}
+	if err := checkPriceRange(filters.PriceMin, filters.PriceMax); err != nil {
+		return err
+	}
+	maxYear := time.Now().Year() + 10
+	ranges := []struct {
+		name     string
+		from, to *int
+		min, max int
+	}{
+		{"total_area", filters.TotalAreaFrom, filters.TotalAreaTo, 1, 100000},
+		{"kitchen_area", filters.KitchenAreaFrom, filters.KitchenAreaTo, 1, 1000},
+		{"land_area", filters.LandAreaFrom, filters.LandAreaTo, 1, 1000000},
+		{"floor", filters.FloorFrom, filters.FloorTo, 1, 200},
+		{"total_floors", filters.TotalFloorsFrom, filters.TotalFloorsTo, 1, 200},
+		{"build_year", filters.BuildYearFrom, filters.BuildYearTo, 1800, maxYear},
+	}
+	for _, r := range ranges {
+		if err := checkIntRange(r.name, r.from, r.to, r.min, r.max); err != nil {
+			return err
+		}
+	}
+
+	if err := ValidatePOIFilters(filters.NearPOI, filters.MinWalkScore); err != nil {
+		return err
+	}
+
+	filters.DealType = dealType
+	filters.PropertyType = propertyType
+	filters.CommercialType = commercialType
+	filters.City = city
+	filters.SellerType = sellerType
+	filters.HouseType = houseType
+	return normalizeSearchLocation(filters)
+}
+
+// normalizeSearchLocation приводит район к коду, а ЖК к названию из справочника
+// мест. Если город не задан, он определяется по району или ЖК. ЖК, которого
+// нет в справочнике, остается как есть: площадки его не учтут.
+func normalizeSearchLocation(filters *models.PropertyFilters) error {
+	locations := DefaultLocations()
+	var city *LocationCity
+	if filters.City != "" {
+		city = locations.City(filters.City)
+	}
+
+	if district := strings.TrimSpace(filters.District); district != "" {
+		var found *LocationDistrict
+		if city != nil {
+			found = city.District(district)
+		} else {
+			city, found = locations.FindDistrict(district)
+		}
+		if found == nil {
+			if city != nil {
+				return fmt.Errorf("unknown district %q in %s", district, city.Name)
+			}
+			return fmt.Errorf("unknown district %q, set the city", district)
+		}
+		filters.District = found.Code
+	} else {
+		filters.District = ""
+	}
+
+	if complexName := strings.TrimSpace(filters.ResidentialComplex); complexName != "" {
+		var found *LocationComplex
+		if city != nil {
+			found = city.Complex(complexName)
+		} else {
+			city, found = locations.FindComplex(complexName)
+		}
+		if found != nil {
+			complexName = found.Name
+		}
+		filters.ResidentialComplex = complexName
+	}
+
+	if filters.City == "" && city != nil {
+		filters.City = city.Name
+	}
+	return nil
+}
+
+// ValidateSearchFilters проверяет фильтры поиска, не изменяя их
+func ValidateSearchFilters(filters models.PropertyFilters) error {
+	return normalizeSearchFilters(&filters)
+}
+
+// checkIntRange проверяет границы диапазона name_from и name_to
+func checkIntRange(name string, from, to *int, min, max int) error {
+	for _, bound := range []struct {
+		suffix string
+		value  *int
+	}{{"_from", from}, {"_to", to}} {
+		if bound.value != nil && (*bound.value < min || *bound.value > max) {
+			return fmt.Errorf("%s%s must be between %d and %d", name, bound.suffix, min, max)
+		}
+	}
+	if from != nil && to != nil && *from > *to {
+		return fmt.Errorf("%s_from must not exceed %s_to", name, name)
+	}
+	return nil
+}
+
+func checkPriceRange(min, max *int64) error {
+	if (min != nil && *min < 0) || (max != nil && *max < 0) {
+		return fmt.Errorf("price must not be negative")
+	}
+	if min != nil && max != nil && *min > *max {
+		return fmt.Errorf("price_min must not exceed price_max")
+	}
+	return nil
+}
+
+// unsupportedFilters собирает фильтры, которые не учтут источники
+func unsupportedFilters(sources []ListingSource, filters models.PropertyFilters) models.UnsupportedFilters {
+	report := make(models.UnsupportedFilters)
+	for _, source := range sources {
+		if unsupported := source.UnsupportedFilters(filters); len(unsupported) > 0 {
+			report[source.Name()] = unsupported
+		}
+	}
+	if len(report) == 0 {
+		return nil
+	}
+	return report
+}
+
+// setFilterNames возвращает по алфавиту имена заданных фильтров
+func setFilterNames(filters map[string]bool) []string {
+	var names []string
+	for name, set := range filters {
+		if set {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// n8nFilters фильтры поиска в формате вебхуков n8n
+func n8nFilters(filters models.PropertyFilters) map[string]interface{} {
+	filtersMap := map[string]interface{}{
+		"city":          krishaCitySlug(filters.City),
+		"district":      krishaDistrictSlug(filters.City, filters.District),
+		"dealType":      filters.DealType,
+		"propertyType":  filters.PropertyType,
+		"floorNotFirst": filters.NotFirstFloor,
+		"floorNotLast":  filters.NotLastFloor,
+		"hasPhoto":      filters.HasPhotos,
+		"newBuilding":   filters.IsNewBuilding,
+		"houseType":     filters.HouseType,
+		"sellerType":    filters.SellerType,
+		"complex":       filters.ResidentialComplex,
+	}
+	if filters.CommercialType != "" {
+		filtersMap["commercialType"] = filters.CommercialType
+	}
+
+	ints := map[string]*int{
+		"rooms":           filters.Rooms,
+		"areaFrom":        filters.TotalAreaFrom,
+		"areaTo":          filters.TotalAreaTo,
+		"kitchenAreaFrom": filters.KitchenAreaFrom,
+		"kitchenAreaTo":   filters.KitchenAreaTo,
+		"landAreaFrom":    filters.LandAreaFrom,
+		"landAreaTo":      filters.LandAreaTo,
+		"floorFrom":       filters.FloorFrom,
+		"floorTo":         filters.FloorTo,
+		"houseFloorFrom":  filters.TotalFloorsFrom,
+		"houseFloorTo":    filters.TotalFloorsTo,
+		"yearFrom":        filters.BuildYearFrom,
+		"yearTo":          filters.BuildYearTo,
+	}
+	for key, value := range ints {
+		if value != nil {
+			filtersMap[key] = *value
+		} else {
+			filtersMap[key] = ""
+		}
+	}
+	for key, value := range map[string]*int64{"priceFrom": filters.PriceMin, "priceTo": filters.PriceMax} {
+		if value != nil {
+			filtersMap[key] = *value
+		} else {
+			filtersMap[key] = ""
+		}
+	}
+	return filtersMap
+}
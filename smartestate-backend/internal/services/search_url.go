@@ -0,0 +1,361 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"smartestate/internal/models"
+)
+
+// ErrUnsupportedSearchURL ссылка не является страницей поиска krisha.kz или olx.kz
+var ErrUnsupportedSearchURL = errors.New("unsupported search url")
+
+// SearchURLImport фильтры, восстановленные из ссылки на поиск krisha.kz или olx.kz
+type SearchURLImport struct {
+	Source  string                 `json:"source" example:"krisha"`
+	URL     string                 `json:"url"`
+	Filters models.PropertyFilters `json:"filters"`
+	// Unsupported параметры ссылки, которые не перенесены в Filters
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// searchURLIgnoredParams параметры, которые не влияют на выборку
+var searchURLIgnoredParams = map[string]bool{
+	"page":          true,
+	"sort_by":       true,
+	"search[order]": true,
+	"currency":      true,
+	"view":          true,
+}
+
+// searchURLRe ссылка на krisha.kz или olx.kz в тексте сообщения
+var searchURLRe = regexp.MustCompile(`https?://(?:www\.|m\.)?(?:krisha|olx)\.kz/[^\s<>"]+`)
+
+// detectSearchURL находит в сообщении ссылку на поиск krisha.kz или olx.kz.
+// Ссылки на отдельные объявления и другие страницы пропускаются.
+func detectSearchURL(message string) *SearchURLImport {
+	for _, match := range searchURLRe.FindAllString(message, -1) {
+		// Знаки препинания после ссылки в тексте к ней не относятся
+		match = strings.TrimRight(match, ".,;:!?)")
+		if imported, err := ParseSearchURL(match); err == nil {
+			return imported
+		}
+	}
+	return nil
+}
+
+// ParseSearchURL разбирает ссылку на поиск krisha.kz или olx.kz в фильтры.
+// Параметры, которых нет в PropertyFilters, перечисляются в Unsupported.
+func ParseSearchURL(rawURL string) (*SearchURLImport, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedSearchURL, err)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "www."), "m.")
+
+	var result *SearchURLImport
+	switch host {
+	case "krisha.kz":
+		result, err = parseKrishaSearchURL(parsed)
+	case "olx.kz":
+		result, err = parseOlxSearchURL(parsed)
+	default:
+		return nil, fmt.Errorf("%w: %s is not krisha.kz or olx.kz", ErrUnsupportedSearchURL, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.URL = parsed.String()
+	sort.Strings(result.Unsupported)
+	return result, nil
+}
+
+// parseKrishaSearchURL разбирает путь /prodazha/kvartiry/almaty-bostandykskij/
+// и параметры das[...], обратно к krishaSearchURL
+func parseKrishaSearchURL(u *url.URL) (*SearchURLImport, error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return nil, fmt.Errorf("%w: %s is not a krisha.kz search page", ErrUnsupportedSearchURL, u.Path)
+	}
+
+	result := &SearchURLImport{Source: "krisha"}
+	filters := &result.Filters
+
+	switch segments[0] {
+	case "prodazha":
+		filters.DealType = models.DealTypeSale
+	case "arenda":
+		filters.DealType = models.DealTypeRentLong
+	default:
+		return nil, fmt.Errorf("%w: %s is not a krisha.kz search page", ErrUnsupportedSearchURL, u.Path)
+	}
+
+	propertyType, commercialType, ok := krishaCategoryFromPath(segments[1])
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown krisha.kz section %q", ErrUnsupportedSearchURL, segments[1])
+	}
+	filters.PropertyType, filters.CommercialType = propertyType, commercialType
+
+	if len(segments) < 3 {
+		return nil, fmt.Errorf("%w: search without a city is not supported", ErrUnsupportedSearchURL)
+	}
+	city, districtSlug := krishaCityFromPath(segments[2])
+	if city == nil {
+		return nil, fmt.Errorf("%w: unsupported krisha.kz city %q", ErrUnsupportedSearchURL, segments[2])
+	}
+	filters.City = city.Name
+	if districtSlug != "" {
+		if district := city.District(districtSlug); district != nil {
+			filters.District = district.Code
+		} else {
+			result.Unsupported = append(result.Unsupported, "district="+districtSlug)
+		}
+	}
+
+	ranges := make(map[string]**int, len(krishaRangeParams)*2)
+	for _, r := range krishaRangeParams {
+		from, to := r.field(filters)
+		ranges["das["+r.param+"][from]"] = from
+		ranges["das["+r.param+"][to]"] = to
+	}
+
+	query := u.Query()
+	for _, key := range sortedQueryKeys(query) {
+		values := query[key]
+		if len(values) == 0 || searchURLIgnoredParams[key] {
+			continue
+		}
+		value := values[0]
+		// Старые закладки используют das[live_square] вместо das[live.square]
+		key = strings.Replace(key, "das[live_square]", "das[live.square]", 1)
+		unsupported := false
+
+		if target, ok := ranges[key]; ok {
+			unsupported = !setIntFilter(target, value)
+			if unsupported {
+				result.Unsupported = append(result.Unsupported, key+"="+value)
+			}
+			continue
+		}
+
+		switch key {
+		case "das[rent.period]":
+			switch {
+			case filters.DealType == models.DealTypeSale:
+				unsupported = true
+			case value == "1":
+				filters.DealType = models.DealTypeRentDaily
+			case value != "2":
+				unsupported = true
+			}
+		case "das[live.rooms]", "das[live.rooms][]":
+			// 5 и более комнат krisha.kz передает как 5.100
+			rooms, _, _ := strings.Cut(value, ".")
+			unsupported = !setIntFilter(&filters.Rooms, rooms)
+			if len(values) > 1 {
+				result.Unsupported = append(result.Unsupported, key+"="+strings.Join(values[1:], ","))
+			}
+		case "das[price][from]":
+			unsupported = !setInt64Filter(&filters.PriceMin, value)
+		case "das[price][to]":
+			unsupported = !setInt64Filter(&filters.PriceMax, value)
+		case "das[house.type]":
+			unsupported = true
+			for houseType, code := range krishaHouseTypes {
+				if code == value {
+					filters.HouseType = houseType
+					unsupported = false
+				}
+			}
+		case "das[_sys.hasphoto]":
+			filters.HasPhotos = value == "1"
+		case "das[novostroiki]":
+			filters.IsNewBuilding = value == "1"
+		case "das[who]":
+			// 1 - от хозяина, других значений в фильтрах нет
+			if value == "1" {
+				filters.SellerType = models.SellerTypeOwner
+			} else {
+				unsupported = true
+			}
+		case "das[floor_not_first]":
+			filters.NotFirstFloor = value == "1"
+		case "das[floor_not_last]":
+			filters.NotLastFloor = value == "1"
+		case "das[map.complex]":
+			// ID жилого комплекса krisha.kz, название берется из справочника мест
+			if complex := city.ComplexByKrisha(value); complex != nil {
+				filters.ResidentialComplex = complex.Name
+			} else {
+				unsupported = true
+			}
+		default:
+			unsupported = true
+		}
+
+		if unsupported {
+			result.Unsupported = append(result.Unsupported, key+"="+value)
+		}
+	}
+	return result, nil
+}
+
+// krishaCategoryFromPath возвращает категорию и вид коммерческой недвижимости по разделу krisha.kz
+func krishaCategoryFromPath(section string) (string, string, bool) {
+	for commercialType, path := range krishaCommercialPaths {
+		if path == section {
+			return models.PropertyTypeCommercial, commercialType, true
+		}
+	}
+	for propertyType, path := range krishaCategoryPaths {
+		if path == section {
+			return propertyType, "", true
+		}
+	}
+	return "", "", false
+}
+
+// krishaCityFromPath разбирает сегмент города вида almaty или almaty-bostandykskij
+// на город и слаг района. Кроме слага из справочника подходит код города:
+// krisha.kz открывает Астану и по /astana/.
+func krishaCityFromPath(segment string) (*LocationCity, string) {
+	for _, city := range DefaultLocations().Cities {
+		for _, slug := range []string{city.Krisha, city.Code} {
+			if segment == slug {
+				return city, ""
+			}
+			if district, ok := strings.CutPrefix(segment, slug+"-"); ok {
+				return city, district
+			}
+		}
+	}
+	return nil, ""
+}
+
+// parseOlxSearchURL разбирает путь раздела olx.kz и параметры search[...]
+func parseOlxSearchURL(u *url.URL) (*SearchURLImport, error) {
+	path := strings.TrimPrefix(u.Path, "/d")
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	result := &SearchURLImport{Source: "olx"}
+	filters := &result.Filters
+
+	var section string
+	for propertyType, sections := range olxSectionPaths {
+		for dealType, sectionPath := range sections {
+			// Самый длинный раздел: аренда квартир вложена в /arenda-kvartiry/
+			if strings.HasPrefix(path, sectionPath) && len(sectionPath) > len(section) {
+				section = sectionPath
+				filters.PropertyType, filters.DealType = propertyType, dealType
+			}
+		}
+	}
+	if section == "" {
+		return nil, fmt.Errorf("%w: %s is not an olx.kz real estate search page", ErrUnsupportedSearchURL, u.Path)
+	}
+
+	citySlug := strings.Trim(strings.TrimPrefix(path, section), "/")
+	if citySlug == "" {
+		return nil, fmt.Errorf("%w: search without a city is not supported", ErrUnsupportedSearchURL)
+	}
+	citySlug, _, _ = strings.Cut(citySlug, "/")
+	var city *LocationCity
+	for _, c := range DefaultLocations().Cities {
+		if c.Olx == citySlug {
+			city = c
+		}
+	}
+	if city == nil {
+		return nil, fmt.Errorf("%w: unsupported olx.kz city %q", ErrUnsupportedSearchURL, citySlug)
+	}
+	filters.City = city.Name
+
+	query := u.Query()
+	for _, key := range sortedQueryKeys(query) {
+		values := query[key]
+		if len(values) == 0 || searchURLIgnoredParams[key] {
+			continue
+		}
+		value := values[0]
+		unsupported := false
+
+		switch {
+		case key == "search[filter_float_price:from]":
+			unsupported = !setInt64Filter(&filters.PriceMin, value)
+		case key == "search[filter_float_price:to]":
+			unsupported = !setInt64Filter(&filters.PriceMax, value)
+		case key == "search[filter_float_total_area:from]":
+			unsupported = !setIntFilter(&filters.TotalAreaFrom, value)
+		case key == "search[filter_float_total_area:to]":
+			unsupported = !setIntFilter(&filters.TotalAreaTo, value)
+		case strings.HasPrefix(key, "search[filter_enum_kolichestvokomnat]"):
+			// Комнаты перечисляются по индексам [0], [1], ..., берется первый
+			if filters.Rooms == nil {
+				unsupported = !setIntFilter(&filters.Rooms, value)
+			} else {
+				unsupported = true
+			}
+		case key == "search[district_id]":
+			if district := city.DistrictByOlx(value); district != nil {
+				filters.District = district.Code
+			} else {
+				unsupported = true
+			}
+		case key == "search[private_business]":
+			if value == "private" {
+				filters.SellerType = models.SellerTypeOwner
+			} else {
+				unsupported = true
+			}
+		default:
+			unsupported = true
+		}
+
+		if unsupported {
+			result.Unsupported = append(result.Unsupported, key+"="+value)
+		}
+	}
+	return result, nil
+}
+
+// sortedQueryKeys возвращает параметры по порядку, чтобы разбор не зависел от порядка обхода map
+func sortedQueryKeys(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setIntFilter(target **int, value string) bool {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || number < 0 {
+		return false
+	}
+	*target = &number
+	return true
+}
+
+func setInt64Filter(target **int64, value string) bool {
+	number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || number < 0 {
+		return false
+	}
+	*target = &number
+	return true
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+
+	"smartestate/internal/config"
+)
+
+// Профили селекторов вынесены из кода в версионируемый файл, чтобы смену
+// верстки площадки можно было исправить без деплоя. Встроенный профиль
+// selector_profiles.yaml используется, пока не задан внешний файл.
+
+//go:embed selector_profiles.yaml
+var defaultSelectorProfilesData []byte
+
+const selectorDefaultReloadInterval = 30 * time.Second
+
+// Поля профилей. Поля вне этих списков считаются опечаткой.
+var (
+	searchSelectorFields = []string{"id", "title", "url", "price", "address", "description", "phone", "location", "params", "image", "image_id", "photo_id"}
+	detailSelectorFields = []string{"title", "price", "address", "description", "images", "seller_name", "seller_label", "published", "views"}
+
+	requiredSearchSelectorFields = []string{"title", "url", "price"}
+	requiredDetailSelectorFields = []string{"title", "price"}
+)
+
+// SelectorProfiles селекторы всех площадок
+type SelectorProfiles struct {
+	Version int                         `yaml:"version" json:"version"`
+	Sources map[string]*SourceSelectors `yaml:"sources" json:"sources"`
+}
+
+// SourceSelectors селекторы страницы поиска и страницы объявления площадки
+type SourceSelectors struct {
+	Search SearchSelectors `yaml:"search" json:"search"`
+	Detail DetailSelectors `yaml:"detail" json:"detail"`
+}
+
+// SearchSelectors селекторы страницы поиска
+type SearchSelectors struct {
+	Card       SelectorList            `yaml:"card" json:"card"`
+	Skip       SelectorList            `yaml:"skip" json:"skip,omitempty"`       // карточки, которые не являются объявлениями
+	Results    SelectorList            `yaml:"results" json:"results,omitempty"` // счетчик найденных объявлений на странице
+	Fields     map[string]SelectorList `yaml:"fields" json:"fields"`
+	Pagination PaginationSelectors     `yaml:"pagination" json:"pagination"`
+}
+
+// PaginationSelectors селекторы пагинации страницы поиска
+type PaginationSelectors struct {
+	Container SelectorList `yaml:"container" json:"container,omitempty"`
+	Page      SelectorList `yaml:"page" json:"page,omitempty"`
+	Next      SelectorList `yaml:"next" json:"next,omitempty"`
+}
+
+// DetailSelectors селекторы страницы объявления
+type DetailSelectors struct {
+	Fields     map[string]SelectorList `yaml:"fields" json:"fields"`
+	Parameters []ParameterSelectors    `yaml:"parameters" json:"parameters"`
+}
+
+// ParameterSelectors блок параметров объявления. Без name и value параметр
+// берется текстом элемента целиком ("Этаж: 5").
+type ParameterSelectors struct {
+	Item  string `yaml:"item" json:"item"`
+	Name  string `yaml:"name" json:"name,omitempty"`
+	Value string `yaml:"value" json:"value,omitempty"`
+}
+
+// SelectorList селекторы поля в порядке приоритета: следующие используются,
+// если предыдущие ничего не нашли. "css@attr" берет атрибут вместо текста,
+// "@attr" - атрибут самого элемента, "css@a|b" - первый непустой из атрибутов.
+type SelectorList []string
+
+// selectorSpec разобранный селектор из профиля
+type selectorSpec struct {
+	matcher goquery.Matcher // nil - сам элемент
+	attrs   []string
+}
+
+var (
+	selectorAttrRe = regexp.MustCompile(`^[A-Za-z_:][-A-Za-z0-9_:.|]*$`)
+
+	selectorSpecsMu sync.RWMutex
+	selectorSpecs   = make(map[string]selectorSpec)
+)
+
+// compileSelector разбирает селектор профиля, результат кэшируется
+func compileSelector(raw string) (selectorSpec, error) {
+	selectorSpecsMu.RLock()
+	spec, ok := selectorSpecs[raw]
+	selectorSpecsMu.RUnlock()
+	if ok {
+		return spec, nil
+	}
+
+	css := strings.TrimSpace(raw)
+	if idx := strings.LastIndex(css, "@"); idx >= 0 && selectorAttrRe.MatchString(css[idx+1:]) {
+		spec.attrs = strings.Split(css[idx+1:], "|")
+		css = strings.TrimSpace(css[:idx])
+	}
+	if css == "" && len(spec.attrs) == 0 {
+		return spec, errors.New("empty selector")
+	}
+	if css != "" {
+		matcher, err := cascadia.Compile(css)
+		if err != nil {
+			return spec, err
+		}
+		spec.matcher = matcher
+	}
+
+	selectorSpecsMu.Lock()
+	selectorSpecs[raw] = spec
+	selectorSpecsMu.Unlock()
+	return spec, nil
+}
+
+// nodes возвращает элементы, найденные селектором внутри sel
+func (spec selectorSpec) nodes(sel *goquery.Selection) *goquery.Selection {
+	if spec.matcher == nil {
+		return sel
+	}
+	return sel.FindMatcher(spec.matcher)
+}
+
+// value возвращает текст или атрибут элемента
+func (spec selectorSpec) value(node *goquery.Selection) string {
+	if len(spec.attrs) == 0 {
+		return strings.TrimSpace(node.Text())
+	}
+	for _, attr := range spec.attrs {
+		if value, exists := node.Attr(attr); exists && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// Value возвращает первое непустое значение по списку селекторов
+func (l SelectorList) Value(sel *goquery.Selection) string {
+	for _, raw := range l {
+		spec, err := compileSelector(raw)
+		if err != nil {
+			continue
+		}
+		if value := spec.value(spec.nodes(sel).First()); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Values возвращает значения всех элементов первого селектора, который что-то нашел
+func (l SelectorList) Values(sel *goquery.Selection) []string {
+	for _, raw := range l {
+		spec, err := compileSelector(raw)
+		if err != nil {
+			continue
+		}
+		var values []string
+		spec.nodes(sel).Each(func(i int, node *goquery.Selection) {
+			if value := spec.value(node); value != "" {
+				values = append(values, value)
+			}
+		})
+		if len(values) > 0 {
+			return values
+		}
+	}
+	return nil
+}
+
+// Find возвращает элементы первого селектора, который что-то нашел
+func (l SelectorList) Find(sel *goquery.Selection) *goquery.Selection {
+	for _, raw := range l {
+		spec, err := compileSelector(raw)
+		if err != nil || spec.matcher == nil {
+			continue
+		}
+		if nodes := sel.FindMatcher(spec.matcher); nodes.Length() > 0 {
+			return nodes
+		}
+	}
+	return sel.FindNodes()
+}
+
+// FindAll возвращает элементы всех селекторов списка
+func (l SelectorList) FindAll(sel *goquery.Selection) *goquery.Selection {
+	result := sel.FindNodes()
+	for _, raw := range l {
+		spec, err := compileSelector(raw)
+		if err != nil || spec.matcher == nil {
+			continue
+		}
+		result = result.AddSelection(sel.FindMatcher(spec.matcher))
+	}
+	return result
+}
+
+// Matches сообщает, что элемент сам подходит под один из селекторов или содержит такой элемент
+func (l SelectorList) Matches(sel *goquery.Selection) bool {
+	for _, raw := range l {
+		spec, err := compileSelector(raw)
+		if err != nil || spec.matcher == nil {
+			continue
+		}
+		if sel.IsMatcher(spec.matcher) || sel.FindMatcher(spec.matcher).Length() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSelectorProfiles разбирает профили из YAML или JSON и проверяет селекторы
+func ParseSelectorProfiles(data []byte) (*SelectorProfiles, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var profiles SelectorProfiles
+	if err := decoder.Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("invalid selector profiles: %w", err)
+	}
+	if err := profiles.Validate(); err != nil {
+		return nil, err
+	}
+	return &profiles, nil
+}
+
+// DefaultSelectorProfiles возвращает встроенные профили
+func DefaultSelectorProfiles() *SelectorProfiles {
+	profiles, err := ParseSelectorProfiles(defaultSelectorProfilesData)
+	if err != nil {
+		panic(fmt.Sprintf("embedded selector profiles: %v", err))
+	}
+	return profiles
+}
+
+// Validate проверяет версию, обязательные поля и синтаксис всех селекторов
+func (p *SelectorProfiles) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	check := func(path string, list SelectorList) {
+		for _, raw := range list {
+			if _, err := compileSelector(raw); err != nil {
+				add("%s: %q: %v", path, raw, err)
+			}
+		}
+	}
+	checkFields := func(path string, fields map[string]SelectorList, known, required []string) {
+		for name, list := range fields {
+			if !containsString(known, name) {
+				add("%s.%s: unknown field", path, name)
+			}
+			check(path+"."+name, list)
+		}
+		for _, name := range required {
+			if len(fields[name]) == 0 {
+				add("%s.%s: required", path, name)
+			}
+		}
+	}
+
+	if p.Version <= 0 {
+		add("version: must be positive")
+	}
+	if len(p.Sources) == 0 {
+		add("sources: empty")
+	}
+
+	names := make([]string, 0, len(p.Sources))
+	for name := range p.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := p.Sources[name]
+		if source == nil {
+			add("%s: empty", name)
+			continue
+		}
+		if len(source.Search.Card) == 0 {
+			add("%s.search.card: required", name)
+		}
+		check(name+".search.card", source.Search.Card)
+		check(name+".search.skip", source.Search.Skip)
+		check(name+".search.results", source.Search.Results)
+		check(name+".search.pagination.container", source.Search.Pagination.Container)
+		check(name+".search.pagination.page", source.Search.Pagination.Page)
+		check(name+".search.pagination.next", source.Search.Pagination.Next)
+		checkFields(name+".search.fields", source.Search.Fields, searchSelectorFields, requiredSearchSelectorFields)
+		checkFields(name+".detail.fields", source.Detail.Fields, detailSelectorFields, requiredDetailSelectorFields)
+
+		for i, param := range source.Detail.Parameters {
+			path := fmt.Sprintf("%s.detail.parameters[%d]", name, i)
+			if param.Item == "" {
+				add("%s.item: required", path)
+			}
+			for _, css := range []string{param.Item, param.Name, param.Value} {
+				if css == "" {
+					continue
+				}
+				if _, err := cascadia.Compile(css); err != nil {
+					add("%s: %q: %v", path, css, err)
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid selector profiles: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateSelectorProfilesAgainstFixtures разбирает все снапшоты из dir профилями
+// p и сверяет с эталонами. Если каталога нет, проверка пропускается.
+func ValidateSelectorProfilesAgainstFixtures(p *SelectorProfiles, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		log.Printf("Selector profiles: fixtures %s not found, skipping validation", dir)
+		return nil
+	}
+
+	fixtures, err := LoadParserFixtures(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	registry := NewDefaultSourceRegistry(nil, NewStaticSelectorStore(p), nil)
+	var failed []string
+	for _, fixture := range fixtures {
+		result := VerifyParserFixture(registry, fixture)
+		if !result.Passed() {
+			failed = append(failed, fixture.Source+"/"+fixture.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("selector profiles v%d break fixtures: %s", p.Version, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// SelectorStore хранит действующие профили селекторов. Если задан внешний файл,
+// он перечитывается при изменении; новая версия применяется только после
+// проверки синтаксиса и сверки со снапшотами, иначе остается предыдущая.
+type SelectorStore struct {
+	path        string
+	fixturesDir string
+	interval    time.Duration
+
+	mu          sync.RWMutex
+	profiles    *SelectorProfiles
+	origin      string
+	modTime     time.Time
+	loadedAt    time.Time
+	lastError   string
+	lastErrorAt *time.Time
+}
+
+// SelectorStatus состояние профилей селекторов
+type SelectorStatus struct {
+	Version     int        `json:"version"`
+	Origin      string     `json:"origin"` // embedded или путь к файлу
+	Path        string     `json:"path,omitempty"`
+	LoadedAt    time.Time  `json:"loaded_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// ErrSelectorProfilesNotConfigured внешний файл профилей не задан
+var ErrSelectorProfilesNotConfigured = errors.New("selector profiles file is not configured")
+
+// NewSelectorStore создает хранилище со встроенными профилями и, если задан
+// SELECTOR_PROFILES_PATH, загружает профили из файла
+func NewSelectorStore(cfg config.ParserConfig) *SelectorStore {
+	s := NewStaticSelectorStore(DefaultSelectorProfiles())
+	s.path = cfg.SelectorProfilesPath
+	s.fixturesDir = cfg.SelectorFixturesDir
+	s.interval = time.Duration(cfg.SelectorReloadInterval) * time.Second
+	if s.interval <= 0 {
+		s.interval = selectorDefaultReloadInterval
+	}
+
+	if s.path != "" {
+		if err := s.Reload(); err != nil {
+			log.Printf("Selector profiles: using embedded v%d: %v", s.profiles.Version, err)
+		}
+	}
+	return s
+}
+
+// NewStaticSelectorStore создает хранилище с заданными профилями без перезагрузки
+func NewStaticSelectorStore(profiles *SelectorProfiles) *SelectorStore {
+	return &SelectorStore{
+		profiles: profiles,
+		origin:   "embedded",
+		loadedAt: time.Now(),
+	}
+}
+
+// Source возвращает селекторы площадки. Для площадки без профиля - пустые.
+func (s *SelectorStore) Source(name string) *SourceSelectors {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if source, ok := s.profiles.Sources[name]; ok && source != nil {
+		return source
+	}
+	return &SourceSelectors{}
+}
+
+// Status возвращает версию и источник действующих профилей
+func (s *SelectorStore) Status() SelectorStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SelectorStatus{
+		Version:     s.profiles.Version,
+		Origin:      s.origin,
+		Path:        s.path,
+		LoadedAt:    s.loadedAt,
+		LastError:   s.lastError,
+		LastErrorAt: s.lastErrorAt,
+	}
+}
+
+// Reload перечитывает внешний файл профилей
+func (s *SelectorStore) Reload() error {
+	if s.path == "" {
+		return ErrSelectorProfilesNotConfigured
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return s.fail(err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return s.fail(err)
+	}
+
+	profiles, err := ParseSelectorProfiles(data)
+	if err != nil {
+		return s.fail(err)
+	}
+	if err := ValidateSelectorProfilesAgainstFixtures(profiles, s.fixturesDir); err != nil {
+		return s.fail(err)
+	}
+
+	s.mu.Lock()
+	previous := s.profiles.Version
+	s.profiles = profiles
+	s.origin = s.path
+	s.modTime = info.ModTime()
+	s.loadedAt = time.Now()
+	s.lastError = ""
+	s.lastErrorAt = nil
+	s.mu.Unlock()
+
+	log.Printf("Selector profiles: loaded v%d from %s (was v%d)", profiles.Version, s.path, previous)
+	return nil
+}
+
+// fail запоминает ошибку загрузки; файл с ошибкой повторно не читается, пока не изменится
+func (s *SelectorStore) fail(err error) error {
+	now := time.Now()
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.lastErrorAt = &now
+	if info, statErr := os.Stat(s.path); statErr == nil {
+		s.modTime = info.ModTime()
+	}
+	s.mu.Unlock()
+
+	log.Printf("Selector profiles: keeping v%d, failed to load %s: %v", s.Status().Version, s.path, err)
+	return err
+}
+
+// Start следит за внешним файлом профилей и перечитывает его при изменении
+func (s *SelectorStore) Start(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(s.path)
+				if err != nil {
+					continue
+				}
+				s.mu.RLock()
+				changed := !info.ModTime().Equal(s.modTime)
+				s.mu.RUnlock()
+				if changed {
+					_ = s.Reload()
+				}
+			}
+		}
+	}()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
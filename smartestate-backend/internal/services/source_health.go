@@ -0,0 +1,444 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/config"
+	"smartestate/internal/models"
+)
+
+// Статусы площадки в отчете о здоровье парсера
+const (
+	SourceHealthUnknown  = "unknown" // запусков еще не было
+	SourceHealthHealthy  = "healthy"
+	SourceHealthDegraded = "degraded"
+)
+
+// События оповещения о здоровье площадки
+const (
+	SourceHealthEventDegraded  = "source_degraded"
+	SourceHealthEventRecovered = "source_recovered"
+)
+
+// SourceRunStats счетчики одного прохода по страницам поиска площадки
+type SourceRunStats struct {
+	Source      string
+	Pages       int // загруженных страниц
+	PagesFailed int // страниц, которые не удалось загрузить
+	Items       int // карточек до фильтрации
+
+	priced      int
+	withArea    int
+	withImages  int
+	roomsTotal  int // объявлений, у которых бывают комнаты: квартиры и дома
+	withRooms   int
+	fieldsTotal int // карточек, по которым считается заполненность полей
+}
+
+// addPage учитывает загруженную страницу: cards - число карточек в верстке,
+// properties - объявления после фильтрации, по ним считается заполненность полей
+func (st *SourceRunStats) addPage(cards int, properties []models.ParsedProperty) {
+	st.Pages++
+	st.Items += cards
+
+	for _, property := range properties {
+		st.fieldsTotal++
+		if property.Price > 0 {
+			st.priced++
+		}
+		if property.Area != nil || property.LandArea != nil {
+			st.withArea++
+		}
+		if len(property.Images) > 0 {
+			st.withImages++
+		}
+		if property.PropertyType == models.PropertyTypeApartment || property.PropertyType == models.PropertyTypeHouse {
+			st.roomsTotal++
+			if property.Rooms != nil {
+				st.withRooms++
+			}
+		}
+	}
+}
+
+// addFailure учитывает страницу, которую не удалось загрузить
+func (st *SourceRunStats) addFailure() {
+	st.PagesFailed++
+}
+
+// SourceHealthThresholds пороги, при нарушении которых площадка считается сломанной.
+// Доли задаются от 0 до 1.
+type SourceHealthThresholds struct {
+	MinItemsPerPage float64 `json:"min_items_per_page"`
+	MinFillPrice    float64 `json:"min_fill_price"`
+	MinFillArea     float64 `json:"min_fill_area"`
+	MinFillRooms    float64 `json:"min_fill_rooms"`
+	MinFillImages   float64 `json:"min_fill_images"`
+	MaxErrorRate    float64 `json:"max_error_rate"`
+	MaxFillDrop     float64 `json:"max_fill_drop"` // падение заполненности относительно средней за прошлые запуски
+	MinSample       int     `json:"min_sample"`    // заполненность не проверяется на меньшей выборке
+	BaselineRuns    int     `json:"baseline_runs"` // по скольким прошлым запускам считается средняя
+}
+
+// SourceHealth состояние площадки: последний запуск и средние за последние запуски
+type SourceHealth struct {
+	Source   string                  `json:"source"`
+	Status   string                  `json:"status"`
+	Reasons  []string                `json:"reasons"`
+	LastRun  *models.SourceHealthRun `json:"last_run"`
+	Averages *SourceHealthAverages   `json:"averages"`
+}
+
+// SourceHealthAverages средние метрики площадки за последние запуски
+type SourceHealthAverages struct {
+	Runs         int      `json:"runs"`
+	ItemsPerPage float64  `json:"items_per_page"`
+	ErrorRate    float64  `json:"error_rate"`
+	FillPrice    *float64 `json:"fill_price"`
+	FillArea     *float64 `json:"fill_area"`
+	FillRooms    *float64 `json:"fill_rooms"`
+	FillImages   *float64 `json:"fill_images"`
+}
+
+// SourceHealthReport отчет о здоровье всех площадок
+type SourceHealthReport struct {
+	Thresholds SourceHealthThresholds `json:"thresholds"`
+	Sources    []SourceHealth         `json:"sources"`
+}
+
+// SourceHealthAlert оповещение, которое отправляется в HEALTH_ALERT_WEBHOOK_URL
+type SourceHealthAlert struct {
+	Event   string                  `json:"event"`
+	Source  string                  `json:"source"`
+	Reasons []string                `json:"reasons"`
+	Run     *models.SourceHealthRun `json:"run"`
+}
+
+// SourceHealthService записывает метрики каждого прохода по площадке и
+// оповещает, когда площадка перестает отдавать полноценные объявления
+type SourceHealthService struct {
+	db         *gorm.DB
+	thresholds SourceHealthThresholds
+	webhookURL string
+	httpClient *http.Client
+}
+
+func NewSourceHealthService(db *gorm.DB, cfg config.ParserConfig) *SourceHealthService {
+	thresholds := SourceHealthThresholds{
+		MinItemsPerPage: float64(cfg.HealthMinItemsPerPage),
+		MinFillPrice:    percent(cfg.HealthMinFillPrice),
+		MinFillArea:     percent(cfg.HealthMinFillArea),
+		MinFillRooms:    percent(cfg.HealthMinFillRooms),
+		MinFillImages:   percent(cfg.HealthMinFillImages),
+		MaxErrorRate:    percent(cfg.HealthMaxErrorRate),
+		MaxFillDrop:     percent(cfg.HealthMaxFillDrop),
+		MinSample:       cfg.HealthMinSample,
+		BaselineRuns:    cfg.HealthBaselineRuns,
+	}
+	if thresholds.BaselineRuns <= 0 {
+		thresholds.BaselineRuns = 10
+	}
+
+	return &SourceHealthService{
+		db:         db,
+		thresholds: thresholds,
+		webhookURL: cfg.HealthAlertWebhookURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// percent переводит проценты из настроек в долю
+func percent(value int) float64 {
+	return float64(value) / 100
+}
+
+// Thresholds возвращает действующие пороги
+func (s *SourceHealthService) Thresholds() SourceHealthThresholds {
+	return s.thresholds
+}
+
+// Record сохраняет метрики прохода по площадке и оповещает, если площадка
+// сломалась, починилась или у нее появились новые нарушения порогов.
+// Проходы без единой загруженной или упавшей страницы не записываются.
+func (s *SourceHealthService) Record(parseRequestID *uuid.UUID, stats SourceRunStats) (*models.SourceHealthRun, error) {
+	attempted := stats.Pages + stats.PagesFailed
+	if attempted == 0 {
+		return nil, nil
+	}
+
+	run := &models.SourceHealthRun{
+		Source:         stats.Source,
+		ParseRequestID: parseRequestID,
+		Pages:          stats.Pages,
+		PagesFailed:    stats.PagesFailed,
+		Items:          stats.Items,
+		ErrorRate:      float64(stats.PagesFailed) / float64(attempted),
+		FillPrice:      fillRate(stats.priced, stats.fieldsTotal),
+		FillArea:       fillRate(stats.withArea, stats.fieldsTotal),
+		FillRooms:      fillRate(stats.withRooms, stats.roomsTotal),
+		FillImages:     fillRate(stats.withImages, stats.fieldsTotal),
+	}
+	if stats.Pages > 0 {
+		run.ItemsPerPage = float64(stats.Items) / float64(stats.Pages)
+	}
+
+	recent, err := s.recentRuns(stats.Source, s.thresholds.BaselineRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Средняя считается по исправным запускам, иначе после поломки она
+	// сползает вниз и падение перестает замечаться
+	var baseline []models.SourceHealthRun
+	for _, previous := range recent {
+		if !previous.Degraded {
+			baseline = append(baseline, previous)
+		}
+	}
+
+	reasons := s.evaluate(run, stats, averageRuns(baseline))
+	run.Degraded = len(reasons) > 0
+	run.Reasons = models.StringSlice(reasons)
+
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+
+	var previous *models.SourceHealthRun
+	if len(recent) > 0 {
+		previous = &recent[0]
+	}
+	s.notify(previous, run)
+
+	return run, nil
+}
+
+// evaluate возвращает нарушенные пороги запуска
+func (s *SourceHealthService) evaluate(run *models.SourceHealthRun, stats SourceRunStats, baseline *SourceHealthAverages) []string {
+	t := s.thresholds
+	var reasons []string
+
+	if t.MaxErrorRate > 0 && run.ErrorRate > t.MaxErrorRate {
+		reasons = append(reasons, fmt.Sprintf("error_rate %.0f%% > %.0f%%", run.ErrorRate*100, t.MaxErrorRate*100))
+	}
+	if stats.Pages > 0 && run.ItemsPerPage < t.MinItemsPerPage {
+		reasons = append(reasons, fmt.Sprintf("items_per_page %.1f < %.1f", run.ItemsPerPage, t.MinItemsPerPage))
+	}
+
+	checkFill := func(field string, value *float64, sample int, minimum float64, average *float64) {
+		if value == nil || sample < t.MinSample {
+			return
+		}
+		if *value < minimum {
+			reasons = append(reasons, fmt.Sprintf("%s %.0f%% < %.0f%%", field, *value*100, minimum*100))
+			return
+		}
+		if t.MaxFillDrop > 0 && average != nil && *average-*value > t.MaxFillDrop {
+			reasons = append(reasons, fmt.Sprintf("%s dropped %.0f%% -> %.0f%%", field, *average*100, *value*100))
+		}
+	}
+
+	var averagePrice, averageArea, averageRooms, averageImages *float64
+	if baseline != nil {
+		averagePrice, averageArea, averageRooms, averageImages = baseline.FillPrice, baseline.FillArea, baseline.FillRooms, baseline.FillImages
+	}
+	checkFill("fill_price", run.FillPrice, stats.fieldsTotal, t.MinFillPrice, averagePrice)
+	checkFill("fill_area", run.FillArea, stats.fieldsTotal, t.MinFillArea, averageArea)
+	checkFill("fill_rooms", run.FillRooms, stats.roomsTotal, t.MinFillRooms, averageRooms)
+	checkFill("fill_images", run.FillImages, stats.fieldsTotal, t.MinFillImages, averageImages)
+
+	return reasons
+}
+
+// notify оповещает о поломке и восстановлении площадки, а также о новых
+// нарушениях у уже сломанной площадки, например когда к ошибкам добавилась пустая цена
+func (s *SourceHealthService) notify(previous, run *models.SourceHealthRun) {
+	var event string
+	var reasons []string
+
+	switch {
+	case run.Degraded && (previous == nil || !previous.Degraded):
+		event, reasons = SourceHealthEventDegraded, run.Reasons
+	case run.Degraded:
+		reasons = newReasons(previous.Reasons, run.Reasons)
+		if len(reasons) == 0 {
+			return
+		}
+		event = SourceHealthEventDegraded
+	case previous != nil && previous.Degraded:
+		event = SourceHealthEventRecovered
+	default:
+		return
+	}
+
+	if event == SourceHealthEventDegraded {
+		log.Printf("⚠️ Парсер %s: площадка работает с ошибками: %s", run.Source, strings.Join(reasons, "; "))
+	} else {
+		log.Printf("✅ Парсер %s: площадка снова работает нормально", run.Source)
+	}
+
+	if s.webhookURL == "" {
+		return
+	}
+	go s.sendAlert(SourceHealthAlert{
+		Event:   event,
+		Source:  run.Source,
+		Reasons: reasons,
+		Run:     run,
+	})
+}
+
+// newReasons возвращает нарушения, которых не было в прошлом запуске.
+// Сравниваются названия метрик без значений.
+func newReasons(previous, current []string) []string {
+	seen := make(map[string]bool, len(previous))
+	for _, reason := range previous {
+		seen[reasonMetric(reason)] = true
+	}
+
+	var result []string
+	for _, reason := range current {
+		if !seen[reasonMetric(reason)] {
+			result = append(result, reason)
+		}
+	}
+	return result
+}
+
+// reasonMetric возвращает название метрики из текста нарушения: "fill_price 40% < 80%" -> "fill_price"
+func reasonMetric(reason string) string {
+	metric, _, _ := strings.Cut(reason, " ")
+	return metric
+}
+
+// sendAlert отправляет оповещение в webhook
+func (s *SourceHealthService) sendAlert(alert SourceHealthAlert) {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode health alert for %s: %v", alert.Source, err)
+		return
+	}
+
+	resp, err := s.httpClient.Post(s.webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Failed to send health alert for %s: %v", alert.Source, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Health alert webhook for %s responded with %d", alert.Source, resp.StatusCode)
+	}
+}
+
+// Report возвращает состояние переданных площадок по последним запускам
+func (s *SourceHealthService) Report(sources []string) (*SourceHealthReport, error) {
+	report := &SourceHealthReport{
+		Thresholds: s.thresholds,
+		Sources:    make([]SourceHealth, 0, len(sources)),
+	}
+
+	for _, source := range sources {
+		runs, err := s.recentRuns(source, s.thresholds.BaselineRuns)
+		if err != nil {
+			return nil, err
+		}
+
+		health := SourceHealth{
+			Source:  source,
+			Status:  SourceHealthUnknown,
+			Reasons: []string{},
+		}
+		if len(runs) > 0 {
+			health.LastRun = &runs[0]
+			health.Averages = averageRuns(runs)
+			health.Status = SourceHealthHealthy
+			if runs[0].Degraded {
+				health.Status = SourceHealthDegraded
+				health.Reasons = runs[0].Reasons
+			}
+		}
+		report.Sources = append(report.Sources, health)
+	}
+
+	return report, nil
+}
+
+// History возвращает последние запуски площадки, новые первыми
+func (s *SourceHealthService) History(source string, limit int) ([]models.SourceHealthRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.recentRuns(source, limit)
+}
+
+func (s *SourceHealthService) recentRuns(source string, limit int) ([]models.SourceHealthRun, error) {
+	var runs []models.SourceHealthRun
+	err := s.db.Where("source = ?", source).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// averageRuns считает средние метрики запусков. Незаполненные доли не учитываются.
+func averageRuns(runs []models.SourceHealthRun) *SourceHealthAverages {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	averages := &SourceHealthAverages{Runs: len(runs)}
+	var price, area, rooms, images []float64
+	for _, run := range runs {
+		averages.ItemsPerPage += run.ItemsPerPage
+		averages.ErrorRate += run.ErrorRate
+		price = appendRate(price, run.FillPrice)
+		area = appendRate(area, run.FillArea)
+		rooms = appendRate(rooms, run.FillRooms)
+		images = appendRate(images, run.FillImages)
+	}
+	averages.ItemsPerPage /= float64(len(runs))
+	averages.ErrorRate /= float64(len(runs))
+	averages.FillPrice = meanRate(price)
+	averages.FillArea = meanRate(area)
+	averages.FillRooms = meanRate(rooms)
+	averages.FillImages = meanRate(images)
+
+	return averages
+}
+
+func fillRate(filled, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	rate := float64(filled) / float64(total)
+	return &rate
+}
+
+func appendRate(rates []float64, rate *float64) []float64 {
+	if rate == nil {
+		return rates
+	}
+	return append(rates, *rate)
+}
+
+func meanRate(rates []float64) *float64 {
+	if len(rates) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, rate := range rates {
+		sum += rate
+	}
+	mean := sum / float64(len(rates))
+	return &mean
+}
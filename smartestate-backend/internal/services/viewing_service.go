@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"smartestate/internal/models"
+)
+
+// ErrViewingNotFound запись на просмотр не найдена или принадлежит другому пользователю
+var ErrViewingNotFound = errors.New("viewing not found")
+
+// viewingMaxAhead насколько вперед можно записаться на просмотр
+const viewingMaxAhead = 90 * 24 * time.Hour
+
+// ViewingInput параметры записи на просмотр: объявление каталога или ссылка на объявление
+type ViewingInput struct {
+	ListingID   *uuid.UUID `json:"listing_id" format:"uuid" description:"ID объявления из каталога, если известен"`
+	ListingURL  string     `json:"listing_url" description:"Ссылка на объявление krisha.kz или olx.kz, если ID нет"`
+	ScheduledAt time.Time  `json:"scheduled_at" binding:"required" example:"2026-10-20T15:00:00+05:00" description:"Дата и время просмотра в формате RFC 3339 с часовым поясом"`
+	Phone       string     `json:"phone" description:"Телефон пользователя для подтверждения просмотра"`
+	Note        string     `json:"note" description:"Комментарий: удобное время, вопросы к продавцу"`
+}
+
+// ViewingService записи пользователей на просмотр объектов
+type ViewingService struct {
+	db *gorm.DB
+}
+
+func NewViewingService(db *gorm.DB) *ViewingService {
+	return &ViewingService{db: db}
+}
+
+// Schedule записывает пользователя на просмотр. Для объявления из каталога
+// ссылка и адрес берутся из объявления.
+func (s *ViewingService) Schedule(userID uuid.UUID, input ViewingInput) (*models.Viewing, error) {
+	now := time.Now()
+	if !input.ScheduledAt.After(now) {
+		return nil, errors.New("scheduled_at must be in the future")
+	}
+	if input.ScheduledAt.After(now.Add(viewingMaxAhead)) {
+		return nil, errors.New("scheduled_at must be within 90 days")
+	}
+
+	viewing := &models.Viewing{
+		UserID:      userID,
+		ListingID:   input.ListingID,
+		ListingURL:  strings.TrimSpace(input.ListingURL),
+		ScheduledAt: input.ScheduledAt,
+		Phone:       strings.TrimSpace(input.Phone),
+		Note:        strings.TrimSpace(input.Note),
+		Status:      models.ViewingStatusScheduled,
+	}
+
+	switch {
+	case input.ListingID != nil:
+		var listing models.Listing
+		if err := s.db.Where("id = ?", *input.ListingID).First(&listing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("listing %s not found", *input.ListingID)
+			}
+			return nil, err
+		}
+		viewing.ListingURL = listing.URL
+		viewing.Address = listing.Address
+	case viewing.ListingURL == "":
+		return nil, errors.New("listing_id or listing_url is required")
+	}
+
+	if err := s.db.Create(viewing).Error; err != nil {
+		return nil, fmt.Errorf("failed to save viewing: %w", err)
+	}
+	return viewing, nil
+}
+
+// List возвращает предстоящие просмотры пользователя, ближайшие первыми
+func (s *ViewingService) List(userID uuid.UUID) ([]models.Viewing, error) {
+	var viewings []models.Viewing
+	err := s.db.Where("user_id = ? AND status = ? AND scheduled_at >= ?", userID, models.ViewingStatusScheduled, time.Now()).
+		Order("scheduled_at ASC").
+		Find(&viewings).Error
+	return viewings, err
+}
+
+// Cancel отменяет просмотр пользователя
+func (s *ViewingService) Cancel(userID, id uuid.UUID) error {
+	result := s.db.Model(&models.Viewing{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, models.ViewingStatusScheduled).
+		Update("status", models.ViewingStatusCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrViewingNotFound
+	}
+	return nil
+}
+
+// ChatTools инструменты записи на просмотр для ассистента
+func (s *ViewingService) ChatTools() []ChatTool {
+	return []ChatTool{
+		NewChatTool("schedule_viewing",
+			"Записывает пользователя на просмотр объекта. Перед вызовом уточни объект (ID из каталога или ссылку) и точные дату и время.",
+			ChatToolUser,
+			func(ctx context.Context, env ChatToolEnv, input ViewingInput) (*ChatToolResult, error) {
+				viewing, err := s.Schedule(env.UserID, input)
+				if err != nil {
+					return nil, err
+				}
+				return &ChatToolResult{Data: viewing, Actions: []string{"schedule_viewing"}}, nil
+			}),
+		NewChatTool("list_viewings",
+			"Показывает предстоящие просмотры пользователя.",
+			ChatToolUser,
+			func(ctx context.Context, env ChatToolEnv, _ struct{}) (*ChatToolResult, error) {
+				viewings, err := s.List(env.UserID)
+				if err != nil {
+					return nil, err
+				}
+				return &ChatToolResult{Data: map[string]interface{}{"viewings": viewings, "total": len(viewings)}}, nil
+			}),
+	}
+}
@@ -3,19 +3,25 @@ package main
 import (
 	"fmt"
 	"log"
+	"smartestate/internal/config"
+	"smartestate/internal/models"
 	"smartestate/internal/services"
 )
 
 func main() {
 	// Создаем KrishaFilterService
-	krishaService := services.NewKrishaFilterService()
+	cfg := config.New()
+	krishaService := services.NewKrishaFilterService(services.NewPoliteFetcher(cfg.Parser), services.NewSelectorStore(cfg.Parser))
 
 	// Тестируем с 6 объявлениями чтобы проверить новый компактный формат
+	rooms := 2
+	priceTo := int64(40000000)
 	filters := services.KrishaFilters{
-		City:            "almaty",
-		Rooms:           "2",
-		PriceTo:         "40000000",
-		HasPhoto:        false,
+		PropertyFilters: models.PropertyFilters{
+			City:     "Алматы",
+			Rooms:    &rooms,
+			PriceMax: &priceTo,
+		},
 		CollectAllPages: true,
 		MaxResults:      6, // Тест с 6 объектами
 		Page:            1,
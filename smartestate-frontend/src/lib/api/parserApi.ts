@@ -4,13 +4,17 @@ const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/a
 
 // Типы для backend API
 export interface BackendPropertyFilters {
-  property_type?: string
+  property_type?: 'apartment' | 'house' | 'land' | 'commercial'
+  commercial_type?: 'office' | 'retail' | 'warehouse'
+  deal_type?: 'sale' | 'rent_long' | 'rent_daily'
   city?: string
   rooms?: number
   price_min?: number
   price_max?: number
   total_area_from?: number
   total_area_to?: number
+  land_area_from?: number
+  land_area_to?: number
   floor_from?: number
   floor_to?: number
   total_floors_from?: number
@@ -23,10 +27,12 @@ export interface BackendPropertyFilters {
   not_first_floor?: boolean
   not_last_floor?: boolean
   residential_complex?: string
+  pets_allowed?: boolean
 }
 
 export interface BackendParsedProperty {
   id: string
+  source?: string
   title: string
   price: number
   currency: string
@@ -45,6 +51,15 @@ export interface BackendParsedProperty {
   seller_type?: string
   kitchen_area?: number
   residential_complex?: string
+  deal_type?: 'sale' | 'rent_long' | 'rent_daily'
+  price_period?: 'month' | 'day'
+  deposit?: number
+  utilities_included?: boolean
+  pets_allowed?: boolean
+  property_type?: 'apartment' | 'house' | 'land' | 'commercial'
+  land_area?: number
+  land_purpose?: string
+  commercial_type?: 'office' | 'retail' | 'warehouse'
 }
 
 export interface ParseResponse {
@@ -60,9 +75,22 @@ export interface ParseResponse {
 
 export interface ParseRequest {
   filters: BackendPropertyFilters
+  sources?: string[]
   max_pages: number
 }
 
+export interface ParseRequestStatus {
+  id: string
+  status: 'pending' | 'processing' | 'completed' | 'failed' | 'cancelled'
+  results: BackendParsedProperty[] | null
+  count: number
+  error: string
+  pages_total: number
+  pages_done: number
+}
+
+const FINISHED_STATUSES = ['completed', 'failed', 'cancelled']
+
 class ParserApi {
   private baseUrl: string
 
@@ -75,12 +103,16 @@ class ParserApi {
     const backendFilters: BackendPropertyFilters = {}
 
     if (filters.propertyType) backendFilters.property_type = filters.propertyType
+    if (filters.commercialType) backendFilters.commercial_type = filters.commercialType
+    if (filters.dealType) backendFilters.deal_type = filters.dealType
     if (filters.city) backendFilters.city = filters.city
     if (filters.rooms) backendFilters.rooms = filters.rooms
     if (filters.priceMin) backendFilters.price_min = filters.priceMin
     if (filters.priceMax) backendFilters.price_max = filters.priceMax
     if (filters.totalAreaFrom) backendFilters.total_area_from = filters.totalAreaFrom
     if (filters.totalAreaTo) backendFilters.total_area_to = filters.totalAreaTo
+    if (filters.landAreaFrom) backendFilters.land_area_from = filters.landAreaFrom
+    if (filters.landAreaTo) backendFilters.land_area_to = filters.landAreaTo
     if (filters.floorFrom) backendFilters.floor_from = filters.floorFrom
     if (filters.floorTo) backendFilters.floor_to = filters.floorTo
     if (filters.totalFloorsFrom) backendFilters.total_floors_from = filters.totalFloorsFrom
@@ -93,6 +125,7 @@ class ParserApi {
     if (filters.notFirstFloor !== undefined) backendFilters.not_first_floor = filters.notFirstFloor
     if (filters.notLastFloor !== undefined) backendFilters.not_last_floor = filters.notLastFloor
     if (filters.residentialComplex) backendFilters.residential_complex = filters.residentialComplex
+    if (filters.petsAllowed !== undefined) backendFilters.pets_allowed = filters.petsAllowed
 
     return backendFilters
   }
@@ -119,6 +152,15 @@ class ParserApi {
       sellerType: backendProperty.seller_type,
       kitchenArea: backendProperty.kitchen_area,
       residentialComplex: backendProperty.residential_complex,
+      dealType: backendProperty.deal_type,
+      pricePeriod: backendProperty.price_period,
+      deposit: backendProperty.deposit,
+      utilitiesIncluded: backendProperty.utilities_included,
+      petsAllowed: backendProperty.pets_allowed,
+      propertyType: backendProperty.property_type,
+      landArea: backendProperty.land_area,
+      landPurpose: backendProperty.land_purpose,
+      commercialType: backendProperty.commercial_type,
     }
   }
 
@@ -134,7 +176,7 @@ class ParserApi {
       }
 
       const response = await axios.post(`${this.baseUrl}/parser/properties`, request, {
-        timeout: 120000, // 2 минуты таймаут для парсинга
+        timeout: 30000,
         headers: {
           'Content-Type': 'application/json'
         }
@@ -146,17 +188,25 @@ class ParserApi {
         throw new Error(data.error || 'Парсинг не удался')
       }
 
+      // Парсинг выполняется в очереди, ждем завершения запроса
+      const result = await this.waitForParseRequest(data.request_id)
+
+      if (result.status === 'failed') {
+        throw new Error(result.error || 'Парсинг не удался')
+      }
+
       // Конвертируем свойства в формат фронтенда
-      const convertedProperties = data.properties.map(prop => this.convertProperty(prop))
+      const convertedProperties = (result.results || []).map(prop => this.convertProperty(prop))
 
       return {
         success: true,
         properties: convertedProperties,
-        count: data.count,
+        count: result.count,
         searchParams: filters,
         parserType: data.parser_type,
         cached: data.cached,
-        requestId: data.request_id
+        requestId: data.request_id,
+        status: result.status
       }
     } catch (error: any) {
       console.error('Ошибка при парсинге:', error)
@@ -202,6 +252,27 @@ class ParserApi {
     }
   }
 
+  // Ожидание завершения запроса парсинга в очереди
+  async waitForParseRequest(requestId: string, timeoutMs: number = 300000, intervalMs: number = 2000): Promise<ParseRequestStatus> {
+    const deadline = Date.now() + timeoutMs
+
+    while (Date.now() < deadline) {
+      const request: ParseRequestStatus = await this.getParseRequest(requestId)
+      if (FINISHED_STATUSES.includes(request.status)) {
+        return request
+      }
+      await new Promise(resolve => setTimeout(resolve, intervalMs))
+    }
+
+    throw new Error('Тайм-аут при парсинге. Попробуйте уменьшить количество страниц.')
+  }
+
+  // Отменить запрос парсинга
+  async cancelParseRequest(requestId: string): Promise<ParseRequestStatus> {
+    const response = await axios.post(`${this.baseUrl}/parser/requests/${requestId}/cancel`)
+    return response.data
+  }
+
   // Получить информацию о запросе парсинга
   async getParseRequest(requestId: string): Promise<any> {
     try {
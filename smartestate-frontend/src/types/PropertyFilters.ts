@@ -1,6 +1,8 @@
 export interface PropertyFilters {
   // Базовые фильтры
-  propertyType?: 'apartment' | 'house' | 'commercial' | null
+  propertyType?: 'apartment' | 'house' | 'land' | 'commercial' | null
+  commercialType?: 'office' | 'retail' | 'warehouse' | null
+  dealType?: 'sale' | 'rent_long' | 'rent_daily' | null
   rooms?: number | null
   priceMin?: number | null
   priceMax?: number | null
@@ -32,6 +34,13 @@ export interface PropertyFilters {
   
   kitchenAreaFrom?: number | null
   kitchenAreaTo?: number | null
+
+  // Площадь участка в сотках, для домов и участков
+  landAreaFrom?: number | null
+  landAreaTo?: number | null
+
+  // Аренда
+  petsAllowed?: boolean | null
 }
 
 export interface PropertyFilterExtraction {